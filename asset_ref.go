@@ -0,0 +1,256 @@
+package roi
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// 애셋 참조는 유닛의 Assets를 애셋에서 유닛 방향으로 찾기 위한 역색인이다.
+//
+// 유닛이 참조하는 애셋 하나가 asset_refs 테이블의 한 행이며,
+// 유닛이 추가되거나 수정될 때 같은 트랜잭션에서 함께 갱신된다.
+
+// CreateTableIfNotExistsAssetRefsStmt는 DB에 asset_refs 테이블을 생성하는 sql 구문이다.
+// 테이블은 타입보다 많은 정보를 담고 있을수도 있다.
+var CreateTableIfNotExistsAssetRefsStmt = `CREATE TABLE IF NOT EXISTS asset_refs (
+	show STRING NOT NULL CHECK (length(show) > 0) CHECK (show NOT LIKE '% %'),
+	grp STRING NOT NULL CHECK (length(grp) > 0) CHECK (grp NOT LIKE '% %'),
+	unit STRING NOT NULL CHECK (length(unit) > 0) CHECK (unit NOT LIKE '% %'),
+	asset_show STRING NOT NULL CHECK (length(asset_show) > 0),
+	asset_grp STRING NOT NULL CHECK (length(asset_grp) > 0),
+	asset_unit STRING NOT NULL CHECK (length(asset_unit) > 0),
+	CONSTRAINT asset_refs_pk PRIMARY KEY (show, grp, unit, asset_show, asset_grp, asset_unit),
+	INDEX asset_refs_asset_idx (asset_show, asset_grp, asset_unit)
+)`
+
+// assetRefRow는 유닛이 애셋 하나를 참조하는 것을 나타낸다.
+type assetRefRow struct {
+	Show       string `db:"show"`
+	Group      string `db:"grp"`
+	Unit       string `db:"unit"`
+	AssetShow  string `db:"asset_show"`
+	AssetGroup string `db:"asset_grp"`
+	AssetUnit  string `db:"asset_unit"`
+}
+
+var assetRefDBKey string = strings.Join(dbKeys(&assetRefRow{}), ", ")
+var assetRefDBIdx string = strings.Join(dbIdxs(&assetRefRow{}), ", ")
+var _ []interface{} = dbVals(&assetRefRow{})
+
+// assetRefRows는 유닛이 참조하는 애셋들을 asset_refs 테이블의 행으로 반환한다.
+// 유닛의 애셋 참조는 verifyUnit에서 검사되었어야 한다.
+func assetRefRows(u *Unit) []*assetRefRow {
+	rows := make([]*assetRefRow, 0, len(u.Assets))
+	for _, a := range u.Assets {
+		show, grp, unit, ok := splitAssetRef(u.Show, a)
+		if !ok {
+			continue
+		}
+		rows = append(rows, &assetRefRow{
+			Show:       u.Show,
+			Group:      u.Group,
+			Unit:       u.Unit,
+			AssetShow:  show,
+			AssetGroup: grp,
+			AssetUnit:  unit,
+		})
+	}
+	return rows
+}
+
+// assetRefStmts는 유닛의 애셋 참조를 다시 색인하는 dbStatement를 반환한다.
+func assetRefStmts(u *Unit) []dbStatement {
+	stmts := []dbStatement{
+		dbStmt("DELETE FROM asset_refs WHERE show=$1 AND grp=$2 AND unit=$3", u.Show, u.Group, u.Unit),
+	}
+	for _, r := range assetRefRows(u) {
+		stmts = append(stmts, dbStmt(fmt.Sprintf("INSERT INTO asset_refs (%s) VALUES (%s)", assetRefDBKey, assetRefDBIdx), dbVals(r)...))
+	}
+	return stmts
+}
+
+// AssetUsers는 해당 애셋을 참조하는 유닛들을 쇼, 그룹, 유닛 이름 순으로 반환한다.
+// 라이브러리 애셋이라면 다른 쇼의 유닛도 포함한다.
+func AssetUsers(db *sql.DB, show, grp, unit string) ([]*Unit, error) {
+	_, err := GetUnit(db, show, grp, unit)
+	if err != nil {
+		return nil, err
+	}
+	keys := ""
+	for i, k := range dbKeys(&Unit{}) {
+		if i != 0 {
+			keys += ", "
+		}
+		keys += "units." + k
+	}
+	stmt := dbStmt(fmt.Sprintf(`SELECT %s FROM asset_refs
+		JOIN units ON (units.show=asset_refs.show AND units.grp=asset_refs.grp AND units.unit=asset_refs.unit)
+		WHERE asset_refs.asset_show=$1 AND asset_refs.asset_grp=$2 AND asset_refs.asset_unit=$3
+		ORDER BY units.show, units.grp, units.unit`, keys), show, grp, unit)
+	us := make([]*Unit, 0)
+	err = dbQuery(db, stmt, func(rows *sql.Rows) error {
+		u := &Unit{}
+		err := scan(rows, u)
+		if err != nil {
+			return err
+		}
+		us = append(us, u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return us, nil
+}
+
+// unitMustNotBeReferenced는 다른 유닛이 해당 유닛을 애셋으로 참조하고 있다면 에러를 반환한다.
+func unitMustNotBeReferenced(db *sql.DB, show, grp, unit string) error {
+	return unitMustNotBeReferencedExcept(db, show, grp, unit, nil)
+}
+
+// unitMustNotBeReferencedExcept는 unitMustNotBeReferenced와 같지만 except가 참을 반환하는 유닛의 참조는 무시한다.
+// 같은 트랜잭션에서 함께 지워지거나 참조가 없어질 유닛을 제외할 때 사용한다. except는 nil일 수 있다.
+func unitMustNotBeReferencedExcept(db *sql.DB, show, grp, unit string, except func(u *Unit) bool) error {
+	users, err := AssetUsers(db, show, grp, unit)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(users))
+	for _, u := range users {
+		if except != nil && except(u) {
+			continue
+		}
+		ids = append(ids, u.ID())
+	}
+	if len(ids) != 0 {
+		return BadRequest("asset is used by other units: %s: %s", JoinUnitID(show, grp, unit), strings.Join(ids, ", "))
+	}
+	return nil
+}
+
+// detachAssetStmts는 애셋을 참조하는 유닛들에서 그 참조를 지우는 dbStatement를 반환한다.
+func detachAssetStmts(db *sql.DB, show, grp, unit string) ([]dbStatement, error) {
+	users, err := AssetUsers(db, show, grp, unit)
+	if err != nil {
+		return nil, err
+	}
+	stmts := make([]dbStatement, 0)
+	for _, u := range users {
+		ref := assetRef(u.Show, show, grp, unit)
+		assets := make([]string, 0, len(u.Assets))
+		for _, a := range u.Assets {
+			if a != ref {
+				assets = append(assets, a)
+			}
+		}
+		u.Assets = assets
+		stmts = append(stmts, dbStmt(fmt.Sprintf("UPDATE units SET (%s) = (%s) WHERE show='%s' AND grp='%s' AND unit='%s'", unitDBKey, unitDBIdx, u.Show, u.Group, u.Unit), dbVals(u)...))
+		stmts = append(stmts, assetRefStmts(u)...)
+	}
+	return stmts, nil
+}
+
+// DetachAsset은 해당 애셋을 참조하는 모든 유닛에서 그 참조를 지운다.
+// 만일 처리 중간에 에러가 나면 아무 데이터도 바꾸지 않고 에러를 반환한다.
+func DetachAsset(db *sql.DB, show, grp, unit string) error {
+	stmts, err := detachAssetStmts(db, show, grp, unit)
+	if err != nil {
+		return err
+	}
+	return dbExec(db, stmts)
+}
+
+// DetachAndDeleteUnit은 해당 유닛을 참조하는 유닛들에서 그 참조를 지운 뒤
+// 유닛과 그 하위의 모든 데이터를 db에서 지운다.
+// 만일 처리 중간에 에러가 나면 아무 데이터도 바꾸지 않고 에러를 반환한다.
+func DetachAndDeleteUnit(db *sql.DB, show, grp, unit string) error {
+	stmts, err := detachAssetStmts(db, show, grp, unit)
+	if err != nil {
+		return err
+	}
+	stmts = append(stmts, deleteUnitStmts(show, grp, unit)...)
+	return dbExec(db, stmts)
+}
+
+// groupMustNotBeReferenced는 그룹 밖의 유닛이 그룹의 유닛을 애셋으로 참조하고 있다면 에러를 반환한다.
+func groupMustNotBeReferenced(db *sql.DB, show, grp string) error {
+	n := 0
+	stmt := dbStmt("SELECT count(*) FROM asset_refs WHERE asset_show=$1 AND asset_grp=$2 AND NOT (show=$1 AND grp=$2)", show, grp)
+	err := dbQueryRow(db, stmt, func(row *sql.Row) error {
+		return row.Scan(&n)
+	})
+	if err != nil {
+		return err
+	}
+	if n != 0 {
+		return BadRequest("assets of the group are used by %d other units: %s", n, JoinGroupID(show, grp))
+	}
+	return nil
+}
+
+// libraryMustNotBeReferenced는 다른 쇼의 유닛이 라이브러리 쇼의 애셋을 참조하고 있다면 에러를 반환한다.
+// 라이브러리 쇼를 바꾸거나 없애면 그 참조들은 더 이상 유효하지 않기 때문이다.
+func libraryMustNotBeReferenced(db *sql.DB, lib string) error {
+	n := 0
+	stmt := dbStmt("SELECT count(*) FROM asset_refs WHERE asset_show=$1 AND show<>$1", lib)
+	err := dbQueryRow(db, stmt, func(row *sql.Row) error {
+		return row.Scan(&n)
+	})
+	if err != nil {
+		return err
+	}
+	if n != 0 {
+		return BadRequest("assets of the library show are used by %d units of other shows: %s", n, lib)
+	}
+	return nil
+}
+
+// RebuildAssetRefs는 쇼 유닛들의 애셋 참조 색인을 지우고 처음부터 다시 만든다.
+// 애셋 참조 색인이 추가되기 전에 만들어진 유닛을 색인할 때 사용한다.
+func RebuildAssetRefs(db *sql.DB, show string) error {
+	_, err := GetShow(db, show)
+	if err != nil {
+		return err
+	}
+	units, err := searchUnits(db, show, &UnitQuery{})
+	if err != nil {
+		return err
+	}
+	stmts := []dbStatement{
+		dbStmt("DELETE FROM asset_refs WHERE show=$1", show),
+	}
+	for _, u := range units {
+		for _, r := range assetRefRows(u) {
+			stmts = append(stmts, dbStmt(fmt.Sprintf("INSERT INTO asset_refs (%s) VALUES (%s)", assetRefDBKey, assetRefDBIdx), dbVals(r)...))
+		}
+	}
+	return dbExec(db, stmts)
+}
+
+// backfillAssetRefs는 애셋 참조 색인이 비어 있는데 애셋을 참조하는 유닛이 있다면 모든 쇼의 색인을 만든다.
+// 색인이 추가되기 전에 만들어진 db를 초기화할 때 한번 색인되도록 initDB에서 호출한다.
+func backfillAssetRefs(db *sql.DB) error {
+	var indexed, referenced bool
+	stmt := dbStmt("SELECT EXISTS (SELECT 1 FROM asset_refs), EXISTS (SELECT 1 FROM units WHERE array_length(assets, 1) > 0)")
+	err := dbQueryRow(db, stmt, func(row *sql.Row) error {
+		return row.Scan(&indexed, &referenced)
+	})
+	if err != nil {
+		return err
+	}
+	if indexed || !referenced {
+		return nil
+	}
+	shows, err := AllShows(db)
+	if err != nil {
+		return err
+	}
+	for _, s := range shows {
+		err := RebuildAssetRefs(db, s.Show)
+		if err != nil {
+			return fmt.Errorf("backfill asset refs: %s: %w", s.Show, err)
+		}
+	}
+	return nil
+}
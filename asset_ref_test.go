@@ -0,0 +1,90 @@
+package roi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAssetRefRows(t *testing.T) {
+	u := &Unit{Show: "TEST", Group: "CG", Unit: "0010", Assets: []string{"char/hero", "LIB/prop/lamp"}}
+	want := []*assetRefRow{
+		{Show: "TEST", Group: "CG", Unit: "0010", AssetShow: "TEST", AssetGroup: "char", AssetUnit: "hero"},
+		{Show: "TEST", Group: "CG", Unit: "0010", AssetShow: "LIB", AssetGroup: "prop", AssetUnit: "lamp"},
+	}
+	got := assetRefRows(u)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAssetUsers(t *testing.T) {
+	db, err := testDB()
+	if err != nil {
+		t.Fatalf("could not connect to database: %v", err)
+	}
+	err = AddSite(db)
+	if err != nil {
+		t.Fatalf("could not add site: %s", err)
+	}
+	defer func() {
+		err := DeleteSite(db)
+		if err != nil {
+			t.Fatalf("could not delete site: %s", err)
+		}
+	}()
+	err = AddShow(db, testShow)
+	if err != nil {
+		t.Fatalf("could not add show: %s", err)
+	}
+	defer func() {
+		err = DeleteShow(db, testShow.Show)
+		if err != nil {
+			t.Fatalf("could not delete show: %s", err)
+		}
+	}()
+	err = AddGroup(db, testGroup)
+	if err != nil {
+		t.Fatalf("could not add group: %s", err)
+	}
+	defer func() {
+		err = DeleteGroup(db, testGroup.Show, testGroup.Group)
+		if err != nil {
+			t.Fatalf("could not delete group: %s", err)
+		}
+	}()
+	asset := &Unit{Show: testShow.Show, Group: testGroup.Group, Unit: "0100", Status: StatusInProgress, Tags: []string{}, Assets: []string{}, Tasks: []string{"fx"}, Attrs: DBStringMap{}}
+	shot := &Unit{Show: testShow.Show, Group: testGroup.Group, Unit: "0110", Status: StatusInProgress, Tags: []string{}, Assets: []string{testGroup.Group + "/0100"}, Tasks: []string{"fx"}, Attrs: DBStringMap{}}
+	for _, u := range []*Unit{asset, shot} {
+		err = AddUnit(db, u)
+		if err != nil {
+			t.Fatalf("could not add unit: %s", err)
+		}
+	}
+	users, err := AssetUsers(db, asset.Show, asset.Group, asset.Unit)
+	if err != nil {
+		t.Fatalf("could not get asset users: %s", err)
+	}
+	if len(users) != 1 || users[0].ID() != shot.ID() {
+		t.Fatalf("asset users: got %v, want [%s]", users, shot.ID())
+	}
+	err = DeleteUnit(db, asset.Show, asset.Group, asset.Unit)
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error when deleting a used asset, got %v", err)
+	}
+	err = DetachAndDeleteUnit(db, asset.Show, asset.Group, asset.Unit)
+	if err != nil {
+		t.Fatalf("could not detach and delete asset: %s", err)
+	}
+	got, err := GetUnit(db, shot.Show, shot.Group, shot.Unit)
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	if len(got.Assets) != 0 {
+		t.Fatalf("asset should be detached: got %v", got.Assets)
+	}
+	err = DeleteUnit(db, shot.Show, shot.Group, shot.Unit)
+	if err != nil {
+		t.Fatalf("could not delete unit: %s", err)
+	}
+}
@@ -0,0 +1,413 @@
+package roi
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 커스텀 속성의 타입이다.
+const (
+	AttrString = "string"
+	AttrInt    = "int"
+	AttrFloat  = "float"
+	AttrDate   = "date"
+	AttrEnum   = "enum"
+	AttrBool   = "bool"
+	AttrUser   = "user"
+)
+
+var AllAttrTypes = []string{
+	AttrString,
+	AttrInt,
+	AttrFloat,
+	AttrDate,
+	AttrEnum,
+	AttrBool,
+	AttrUser,
+}
+
+// 커스텀 속성 정의를 적용할 수 있는 항목의 종류이다.
+const (
+	AttrKindShow  = "show"
+	AttrKindGroup = "group"
+	AttrKindUnit  = "unit"
+)
+
+// AttrDef는 쇼, 그룹, 유닛의 커스텀 속성 하나의 정의이다.
+// 사이트와 쇼에 "이름: 타입[, required][, default=기본값]" 형식의 문자열로 저장된다.
+// enum 타입은 "enum(a|b|c)"처럼 고를 수 있는 값들을 함께 적는다.
+type AttrDef struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Values   []string `json:"values,omitempty"`
+	Required bool     `json:"required"`
+	Default  string   `json:"default,omitempty"`
+}
+
+// reAttrName은 정의할 수 있는 커스텀 속성의 이름이다.
+// 검색어에서 attr.<이름>으로 쓸 수 있어야 한다.
+var reAttrName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// reAttrEnum은 enum 타입과 그 값들을 나타내는 정규식이다.
+var reAttrEnum = regexp.MustCompile(`^enum\((.*)\)$`)
+
+// ParseAttrDef는 "이름: 타입[, required][, default=기본값]" 형식의 문자열을 속성 정의로 해석한다.
+// 형식이 맞지 않으면 BadRequest 에러를 반환한다.
+func ParseAttrDef(s string) (*AttrDef, error) {
+	kv := strings.SplitN(s, ":", 2)
+	if len(kv) != 2 {
+		return nil, BadRequest("invalid attribute definition: %q: want 'name: type[, required][, default=value]'", s)
+	}
+	d := &AttrDef{Name: strings.TrimSpace(kv[0])}
+	if !reAttrName.MatchString(d.Name) {
+		return nil, BadRequest("invalid attribute definition: %q: invalid name: %s", s, d.Name)
+	}
+	opts := strings.Split(kv[1], ",")
+	typ := strings.TrimSpace(opts[0])
+	if m := reAttrEnum.FindStringSubmatch(typ); m != nil {
+		typ = AttrEnum
+		for _, v := range strings.Split(m[1], "|") {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			d.Values = append(d.Values, v)
+		}
+		if len(d.Values) == 0 {
+			return nil, BadRequest("invalid attribute definition: %q: enum needs values", s)
+		}
+	}
+	if !hasString(AllAttrTypes, typ) {
+		return nil, BadRequest("invalid attribute definition: %q: invalid type: %s", s, typ)
+	}
+	if typ == AttrEnum && d.Values == nil {
+		return nil, BadRequest("invalid attribute definition: %q: want enum(a|b|c)", s)
+	}
+	d.Type = typ
+	for _, o := range opts[1:] {
+		o = strings.TrimSpace(o)
+		switch {
+		case o == "required":
+			d.Required = true
+		case strings.HasPrefix(o, "default="):
+			d.Default = strings.TrimSpace(strings.TrimPrefix(o, "default="))
+		default:
+			return nil, BadRequest("invalid attribute definition: %q: unknown option: %s", s, o)
+		}
+	}
+	if d.Default != "" {
+		v, err := d.normalize(d.Default)
+		if err != nil {
+			return nil, BadRequest("invalid attribute definition: %q: invalid default: %v", s, err)
+		}
+		d.Default = v
+	}
+	return d, nil
+}
+
+// String은 속성 정의를 사이트나 쇼에 저장되는 형식의 문자열로 반환한다.
+func (d *AttrDef) String() string {
+	s := d.Name + ": " + d.Type
+	if d.Type == AttrEnum {
+		s += "(" + strings.Join(d.Values, "|") + ")"
+	}
+	if d.Required {
+		s += ", required"
+	}
+	if d.Default != "" {
+		s += ", default=" + d.Default
+	}
+	return s
+}
+
+// normalize는 속성 값이 타입에 맞는지 검사하고 저장될 형식으로 바꾼다.
+// 예를 들어 bool 타입의 값은 true 또는 false로 저장된다.
+// 사용자 타입은 사용자가 있는지 db에서 검사해야 하므로 여기서는 검사하지 않는다.
+func (d *AttrDef) normalize(v string) (string, error) {
+	switch d.Type {
+	case AttrInt:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return "", BadRequest("attribute %s should be an integer: %s", d.Name, v)
+		}
+		return strconv.Itoa(n), nil
+	case AttrFloat:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return "", BadRequest("attribute %s should be a number: %s", d.Name, v)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case AttrDate:
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return "", BadRequest("attribute %s should be a date (yyyy-mm-dd): %s", d.Name, v)
+		}
+		return t.Format("2006-01-02"), nil
+	case AttrBool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return "", BadRequest("attribute %s should be true or false: %s", d.Name, v)
+		}
+		return strconv.FormatBool(b), nil
+	case AttrEnum:
+		if !hasString(d.Values, v) {
+			return "", BadRequest("attribute %s should be one of %s: %s", d.Name, strings.Join(d.Values, ", "), v)
+		}
+	}
+	return v, nil
+}
+
+// typedValue는 검사된 속성 값을 타입에 맞는 값으로 반환한다.
+// 숫자와 불리언은 db에서 비교할 수 있도록 그 타입으로, 나머지는 문자열로 반환한다.
+func (d *AttrDef) typedValue(v string) interface{} {
+	switch d.Type {
+	case AttrInt:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	case AttrFloat:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	case AttrBool:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return v
+}
+
+// mergeAttrDefs는 사이트의 속성 정의에 쇼의 정의를 덮어 쓴 속성 정의들을 반환한다.
+// 해석할 수 없는 정의는 무시한다. 사이트나 쇼를 수정할 때 검사하기 때문이다.
+func mergeAttrDefs(defs ...[]string) []*AttrDef {
+	merged := make([]*AttrDef, 0)
+	idx := make(map[string]int)
+	for _, ds := range defs {
+		for _, s := range ds {
+			d, err := ParseAttrDef(s)
+			if err != nil {
+				continue
+			}
+			if i, ok := idx[d.Name]; ok {
+				merged[i] = d
+				continue
+			}
+			idx[d.Name] = len(merged)
+			merged = append(merged, d)
+		}
+	}
+	return merged
+}
+
+// verifyAttrDefs는 사이트나 쇼에 저장될 속성 정의들이 유효하지 않다면 에러를 반환한다.
+func verifyAttrDefs(defs []string) error {
+	has := make(map[string]bool)
+	for _, s := range defs {
+		d, err := ParseAttrDef(s)
+		if err != nil {
+			return err
+		}
+		if has[d.Name] {
+			return BadRequest("attribute defined twice: %s", d.Name)
+		}
+		has[d.Name] = true
+	}
+	return nil
+}
+
+// attrDefs는 사이트와 쇼에 정의된 해당 종류의 속성 정의들을 반환한다.
+// 쇼의 속성은 사이트에서만, 그룹과 유닛의 속성은 사이트와 쇼에서 정의할 수 있다.
+// show가 nil이면 사이트의 정의만 반환한다.
+func attrDefs(site *Site, show *Show, kind string) []*AttrDef {
+	switch kind {
+	case AttrKindShow:
+		return mergeAttrDefs(site.ShowAttrDefs)
+	case AttrKindGroup:
+		if show == nil {
+			return mergeAttrDefs(site.GroupAttrDefs)
+		}
+		return mergeAttrDefs(site.GroupAttrDefs, show.GroupAttrDefs)
+	case AttrKindUnit:
+		if show == nil {
+			return mergeAttrDefs(site.UnitAttrDefs)
+		}
+		return mergeAttrDefs(site.UnitAttrDefs, show.UnitAttrDefs)
+	}
+	return nil
+}
+
+// AttrDefs는 쇼에서 해당 종류의 항목에 쓸 수 있는 속성 정의들을 반환한다.
+// 쇼 속성의 정의를 찾을 때는 show를 빈 문자열로 한다.
+func AttrDefs(db *sql.DB, kind, show string) ([]*AttrDef, error) {
+	site, err := GetSite(db)
+	if err != nil {
+		return nil, err
+	}
+	var sh *Show
+	if kind != AttrKindShow {
+		sh, err = GetShow(db, show)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return attrDefs(site, sh, kind), nil
+}
+
+// storedAttrs는 db에 저장되어 있는 항목의 커스텀 속성을 가져온다.
+// 캐시된 항목은 호출자가 수정했을 수 있으므로 캐시를 거치지 않는다.
+// 해당 항목이 아직 없다면 nil을 반환한다.
+func storedAttrs(db *sql.DB, table, where string, args ...interface{}) (*DBStringMap, error) {
+	m := make(DBStringMap)
+	stmt := dbStmt("SELECT attrs FROM "+table+" WHERE "+where, args...)
+	err := dbQueryRow(db, stmt, func(row *sql.Row) error {
+		return row.Scan(&m)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// verifyAttrs는 커스텀 속성이 정의에 맞지 않으면 에러를 반환한다.
+// 정의가 없다면 어떤 속성이든 쓸 수 있다. 정의가 있다면 정의되지 않은 속성은 쓸 수 없다.
+// 값이 없는 속성에는 기본값이 있다면 채우며 값들을 저장될 형식으로 바꾼다.
+// 반환되는 맵은 db에서 비교할 수 있도록 타입이 정해진 속성 값이다.
+//
+// old는 db에 저장되어 있던 속성들이며 새로 추가되는 항목이라면 nil이다.
+// 저장되어 있던 속성은 정의가 생기거나 바뀌기 전에 저장되었을 수 있으므로,
+// 바뀌지 않은 속성과 원래 없던 필수 속성은 다른 수정을 막지 않도록 검사하지 않는다.
+func verifyAttrs(db *sql.DB, defs []*AttrDef, m *DBStringMap, old *DBStringMap) (DBJSONMap, error) {
+	if *m == nil {
+		*m = make(DBStringMap)
+	}
+	attrs := *m
+	for k, v := range attrs {
+		if k == "" || strings.Contains(k, ": ") || strings.Contains(k, "\n") {
+			return nil, BadRequest("invalid attribute name: %q", k)
+		}
+		if strings.Contains(v, "\n") {
+			return nil, BadRequest("attribute %q should be a single line", k)
+		}
+		if v == "" {
+			delete(attrs, k)
+		}
+	}
+	// stored는 속성이 저장되어 있던 값과 같을 때 참을 반환한다.
+	stored := func(k, v string) bool {
+		if old == nil {
+			return false
+		}
+		return (*old)[k] == v
+	}
+	typed := make(DBJSONMap)
+	defined := make(map[string]bool)
+	for _, d := range defs {
+		defined[d.Name] = true
+		v := attrs[d.Name]
+		if v == "" {
+			v = d.Default
+		}
+		if v == "" {
+			if d.Required && !stored(d.Name, "") {
+				return nil, BadRequest("attribute required: %s", d.Name)
+			}
+			continue
+		}
+		if stored(d.Name, v) {
+			nv, err := d.normalize(v)
+			if err != nil {
+				// 정의에 맞지 않는 기존 값은 그대로 두되 타입이 다르므로
+				// 비교에 쓰이지 않도록 문자열로 저장한다.
+				typed[d.Name] = v
+				continue
+			}
+			attrs[d.Name] = nv
+			typed[d.Name] = d.typedValue(nv)
+			continue
+		}
+		v, err := d.normalize(v)
+		if err != nil {
+			return nil, err
+		}
+		if d.Type == AttrUser {
+			_, err := GetUser(db, v)
+			if err != nil {
+				if errors.As(err, &NotFoundError{}) {
+					return nil, BadRequest("attribute %s should be a user: %s", d.Name, v)
+				}
+				return nil, err
+			}
+		}
+		attrs[d.Name] = v
+		typed[d.Name] = d.typedValue(v)
+	}
+	for k, v := range attrs {
+		if defined[k] {
+			continue
+		}
+		if len(defs) != 0 && !stored(k, v) {
+			return nil, BadRequest("attribute not defined: %s", k)
+		}
+		typed[k] = v
+	}
+	return typed, nil
+}
+
+// typedAttrs는 저장된 커스텀 속성들을 검사 없이 타입이 정해진 속성 값으로 바꾼다.
+// 정의에 맞지 않거나 정의되지 않은 속성은 문자열로 둔다.
+func typedAttrs(defs []*AttrDef, attrs DBStringMap) DBJSONMap {
+	typed := make(DBJSONMap)
+	for k, v := range attrs {
+		typed[k] = v
+	}
+	for _, d := range defs {
+		v, ok := attrs[d.Name]
+		if !ok {
+			continue
+		}
+		nv, err := d.normalize(v)
+		if err != nil {
+			continue
+		}
+		typed[d.Name] = d.typedValue(nv)
+	}
+	return typed
+}
+
+// RebuildTypedAttrs는 쇼와 그 그룹, 유닛의 타입이 정해진 속성 값을 현재 속성 정의로 다시 만든다.
+// 속성 정의가 추가되기 전에 저장되었거나 정의가 바뀐 속성을 비교 검색할 수 있게 한다.
+func RebuildTypedAttrs(db *sql.DB, show string) error {
+	site, err := GetSite(db)
+	if err != nil {
+		return err
+	}
+	s, err := GetShow(db, show)
+	if err != nil {
+		return err
+	}
+	defer invalidateShowCache(show)
+	stmts := []dbStatement{
+		dbStmt("UPDATE shows SET typed_attrs=$1 WHERE show=$2", typedAttrs(attrDefs(site, nil, AttrKindShow), s.Attrs), show),
+	}
+	grps, err := ShowGroups(db, show)
+	if err != nil {
+		return err
+	}
+	defs := attrDefs(site, s, AttrKindGroup)
+	for _, g := range grps {
+		stmts = append(stmts, dbStmt("UPDATE groups SET typed_attrs=$1 WHERE show=$2 AND grp=$3", typedAttrs(defs, g.Attrs), show, g.Group))
+	}
+	units, err := searchUnits(db, show, &UnitQuery{})
+	if err != nil {
+		return err
+	}
+	defs = attrDefs(site, s, AttrKindUnit)
+	for _, u := range units {
+		stmts = append(stmts, dbStmt("UPDATE units SET typed_attrs=$1 WHERE show=$2 AND grp=$3 AND unit=$4", typedAttrs(defs, u.Attrs), show, u.Group, u.Unit))
+	}
+	return dbExec(db, stmts)
+}
@@ -0,0 +1,142 @@
+package roi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseAttrDef(t *testing.T) {
+	cases := []struct {
+		s    string
+		want *AttrDef
+	}{
+		{
+			s:    "fps: float, required, default=24",
+			want: &AttrDef{Name: "fps", Type: AttrFloat, Required: true, Default: "24"},
+		},
+		{
+			s:    "camera: enum(A | B|C), default=A",
+			want: &AttrDef{Name: "camera", Type: AttrEnum, Values: []string{"A", "B", "C"}, Default: "A"},
+		},
+		{
+			s:    "stereo: bool, default=1",
+			want: &AttrDef{Name: "stereo", Type: AttrBool, Default: "true"},
+		},
+		{
+			s:    "artist_in_charge: user",
+			want: &AttrDef{Name: "artist_in_charge", Type: AttrUser},
+		},
+	}
+	for _, c := range cases {
+		got, err := ParseAttrDef(c.s)
+		if err != nil {
+			t.Fatalf("%q: %v", c.s, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%q: got %+v, want %+v", c.s, got, c.want)
+		}
+		again, err := ParseAttrDef(got.String())
+		if err != nil || !reflect.DeepEqual(again, got) {
+			t.Fatalf("%q: could not parse its string %q again: %v", c.s, got.String(), err)
+		}
+	}
+	bads := []string{
+		"fps float",
+		"f ps: float",
+		"fps: number",
+		"fps: float, optional",
+		"fps: int, default=24.5",
+		"camera: enum()",
+		"camera: enum(A|B), default=C",
+	}
+	for _, b := range bads {
+		_, err := ParseAttrDef(b)
+		if !errors.As(err, &BadRequestError{}) {
+			t.Fatalf("%q: want bad request error, got %v", b, err)
+		}
+	}
+}
+
+func TestVerifyAttrs(t *testing.T) {
+	defs := mergeAttrDefs(
+		[]string{"fps: float, default=24", "camera: enum(A|B)", "frames: int, required"},
+		[]string{"camera: enum(A|B|C)", "stereo: bool"},
+	)
+	attrs := DBStringMap{"camera": "C", "frames": "0100", "stereo": "T"}
+	typed, err := verifyAttrs(nil, defs, &attrs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantAttrs := DBStringMap{"camera": "C", "frames": "100", "stereo": "true", "fps": "24"}
+	if !reflect.DeepEqual(attrs, wantAttrs) {
+		t.Fatalf("attrs: got %v, want %v", attrs, wantAttrs)
+	}
+	wantTyped := DBJSONMap{"camera": "C", "frames": int64(100), "stereo": true, "fps": float64(24)}
+	if !reflect.DeepEqual(typed, wantTyped) {
+		t.Fatalf("typed attrs: got %v, want %v", typed, wantTyped)
+	}
+
+	bads := []DBStringMap{
+		{"camera": "A"},
+		{"frames": "1", "camrea": "A"},
+		{"frames": "1", "fps": "fast"},
+		{"frames": "1\n2"},
+	}
+	for _, b := range bads {
+		_, err := verifyAttrs(nil, defs, &b, nil)
+		if !errors.As(err, &BadRequestError{}) {
+			t.Fatalf("%v: want bad request error, got %v", b, err)
+		}
+	}
+
+	// 정의가 없다면 어떤 속성이든 쓸 수 있다.
+	free := DBStringMap{"anything": "goes", "empty": ""}
+	typed, err = verifyAttrs(nil, nil, &free, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(typed, DBJSONMap{"anything": "goes"}) {
+		t.Fatalf("free attrs: got %v", typed)
+	}
+
+	// 정의가 생기기 전에 저장된 속성은 바뀌지 않았다면 다른 수정을 막지 않는다.
+	old := DBStringMap{"legacy": "x", "fps": "fast"}
+	legacy := DBStringMap{"legacy": "x", "fps": "fast", "camera": "B"}
+	typed, err = verifyAttrs(nil, defs, &legacy, &old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTyped = DBJSONMap{"legacy": "x", "fps": "fast", "camera": "B"}
+	if !reflect.DeepEqual(typed, wantTyped) {
+		t.Fatalf("legacy attrs: got %v, want %v", typed, wantTyped)
+	}
+	// 하지만 바뀐 속성은 정의에 맞아야 하며, 있던 필수 속성을 지울 수는 없다.
+	legacyBads := []DBStringMap{
+		{"legacy": "y", "fps": "fast"},
+		{"legacy": "x", "fps": "faster"},
+		{"legacy": "x", "fps": "fast", "camera": "D"},
+	}
+	for _, b := range legacyBads {
+		_, err := verifyAttrs(nil, defs, &b, &old)
+		if !errors.As(err, &BadRequestError{}) {
+			t.Fatalf("%v: want bad request error, got %v", b, err)
+		}
+	}
+	withFrames := DBStringMap{"frames": "100"}
+	noFrames := DBStringMap{}
+	_, err = verifyAttrs(nil, defs, &noFrames, &withFrames)
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("removing required attribute: want bad request error, got %v", err)
+	}
+}
+
+func TestTypedAttrs(t *testing.T) {
+	defs := mergeAttrDefs([]string{"fps: float", "frames: int", "stereo: bool, default=false"})
+	attrs := DBStringMap{"fps": "23.976", "frames": "many", "legacy": "x"}
+	got := typedAttrs(defs, attrs)
+	want := DBJSONMap{"fps": 23.976, "frames": "many", "legacy": "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
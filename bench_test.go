@@ -1,6 +1,10 @@
 package roi
 
-import "testing"
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+)
 
 func BenchmarkDBExecStmt(b *testing.B) {
 	db, err := testDB()
@@ -43,3 +47,167 @@ func BenchmarkDBExecFunc(b *testing.B) {
 		}
 	}
 }
+
+// benchShow는 유닛, 태스크 조회 벤치마크에 쓰이는 쇼이다.
+var benchShow = &Show{
+	Show:   "BENCH",
+	Status: "waiting",
+}
+
+// benchTasks는 벤치마크 유닛들이 가지는 태스크이다. 사이트에 정의되어 있어야 한다.
+var benchTasks = []string{"match", "ani", "fx", "lit", "comp"}
+
+// setupBenchUnits는 실제 쇼와 비슷한 크기의 데이터를 db에 만든다.
+// 4개의 그룹에 50개씩의 유닛이 있고, 유닛마다 태스크 5개, 태스크마다 버전 2개가 있다.
+// 만들어진 유닛들과 함께 데이터를 지우는 함수를 반환한다.
+func setupBenchUnits(b *testing.B) (*sql.DB, []*Unit, func()) {
+	db, err := testDB()
+	if err != nil {
+		b.Fatalf("could not open db: %v", err)
+	}
+	err = AddSite(db)
+	if err != nil {
+		b.Fatalf("could not add site: %v", err)
+	}
+	err = AddShow(db, benchShow)
+	if err != nil {
+		b.Fatalf("could not add show: %v", err)
+	}
+	cleanup := func() {
+		err := DeleteShow(db, benchShow.Show)
+		if err != nil {
+			b.Fatalf("could not delete show: %v", err)
+		}
+		err = DeleteSite(db)
+		if err != nil {
+			b.Fatalf("could not delete site: %v", err)
+		}
+	}
+	units := make([]*Unit, 0)
+	for i := 1; i <= 4; i++ {
+		g := &Group{
+			Show:  benchShow.Show,
+			Group: fmt.Sprintf("G%d", i),
+		}
+		err := AddGroup(db, g)
+		if err != nil {
+			cleanup()
+			b.Fatalf("could not add group: %v", err)
+		}
+		for j := 1; j <= 50; j++ {
+			u := &Unit{
+				Show:   g.Show,
+				Group:  g.Group,
+				Unit:   fmt.Sprintf("%04d", j*10),
+				Status: StatusInProgress,
+				Tasks:  benchTasks,
+			}
+			err := AddUnit(db, u)
+			if err != nil {
+				cleanup()
+				b.Fatalf("could not add unit: %v", err)
+			}
+			for _, task := range benchTasks {
+				for k := 1; k <= 2; k++ {
+					v := &Version{
+						Show:    u.Show,
+						Group:   u.Group,
+						Unit:    u.Unit,
+						Task:    task,
+						Version: fmt.Sprintf("v%03d", k),
+					}
+					err := AddVersion(db, v)
+					if err != nil {
+						cleanup()
+						b.Fatalf("could not add version: %v", err)
+					}
+				}
+			}
+			units = append(units, u)
+		}
+	}
+	return db, units, cleanup
+}
+
+func BenchmarkUnitTasks(b *testing.B) {
+	db, units, cleanup := setupBenchUnits(b)
+	defer cleanup()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, u := range units {
+			_, err := UnitTasks(db, u.Show, u.Group, u.Unit)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkTasksOfUnits(b *testing.B) {
+	db, units, cleanup := setupBenchUnits(b)
+	defer cleanup()
+	ids := make([]string, 0, len(units))
+	for _, u := range units {
+		ids = append(ids, u.ID())
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tasks, err := TasksOfUnits(db, benchShow.Show, ids)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(tasks) != len(units) {
+			b.Fatalf("got tasks of %d units, want %d", len(tasks), len(units))
+		}
+	}
+}
+
+func BenchmarkTaskVersions(b *testing.B) {
+	db, units, cleanup := setupBenchUnits(b)
+	defer cleanup()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, u := range units {
+			for _, task := range u.Tasks {
+				_, err := TaskVersions(db, u.Show, u.Group, u.Unit, task)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkVersionsOfTasks(b *testing.B) {
+	db, units, cleanup := setupBenchUnits(b)
+	defer cleanup()
+	ids := make([]string, 0)
+	for _, u := range units {
+		for _, task := range u.Tasks {
+			ids = append(ids, JoinTaskID(u.Show, u.Group, u.Unit, task))
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		versions, err := VersionsOfTasks(db, benchShow.Show, ids)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(versions) != len(ids) {
+			b.Fatalf("got versions of %d tasks, want %d", len(versions), len(ids))
+		}
+	}
+}
+
+func BenchmarkGetVersion(b *testing.B) {
+	db, units, cleanup := setupBenchUnits(b)
+	defer cleanup()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		u := units[i%len(units)]
+		_, err := GetVersion(db, u.Show, u.Group, u.Unit, benchTasks[0], "v001")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package roi
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// BreakdownAsset은 브레이크다운 표의 열인 애셋 하나이다.
+type BreakdownAsset struct {
+	// Ref는 쇼의 유닛이 이 애셋을 참조할 때 쓰는 이름이다.
+	// 같은 쇼의 애셋은 그룹/유닛, 라이브러리 애셋은 쇼/그룹/유닛 형식이다.
+	Ref   string
+	Unit  *Unit
+	Tasks []*Task
+}
+
+// Breakdown은 쇼의 샷이 어떤 애셋을 쓰는지 나타내는 표이다.
+// 샷은 편집 순서대로 행이 되고, 애셋은 열이 된다.
+type Breakdown struct {
+	Show   string
+	Shots  []*Unit
+	Assets []*BreakdownAsset
+}
+
+// Uses는 샷이 해당 애셋을 쓰는지를 반환한다.
+func (b *Breakdown) Uses(shot *Unit, ref string) bool {
+	return hasString(shot.Assets, ref)
+}
+
+// GetBreakdown은 쇼의 브레이크다운 표를 반환한다.
+// 애셋 열은 쇼의 애셋 그룹에 속한 유닛들과 샷이 쓰는 라이브러리 애셋들이며,
+// 같은 쇼의 애셋이 먼저, 라이브러리 애셋이 나중에 이름 순으로 온다.
+func GetBreakdown(db *sql.DB, show string) (*Breakdown, error) {
+	_, err := GetShow(db, show)
+	if err != nil {
+		return nil, err
+	}
+	units, err := searchUnits(db, show, &UnitQuery{})
+	if err != nil {
+		return nil, err
+	}
+	b := &Breakdown{
+		Show:   show,
+		Shots:  make([]*Unit, 0),
+		Assets: make([]*BreakdownAsset, 0),
+	}
+	// assetIDs는 쇼별로 열에 들어갈 애셋 아이디이다. TasksOfUnits는 한 쇼의 유닛만 받는다.
+	assetIDs := make(map[string][]string)
+	hasAsset := make(map[string]bool)
+	for _, u := range units {
+		if IsShotGroup(u.Group) {
+			b.Shots = append(b.Shots, u)
+			continue
+		}
+		b.Assets = append(b.Assets, &BreakdownAsset{Ref: assetRef(show, u.Show, u.Group, u.Unit), Unit: u})
+		assetIDs[show] = append(assetIDs[show], u.ID())
+		hasAsset[u.ID()] = true
+	}
+	sort.SliceStable(b.Shots, func(i, j int) bool {
+		return b.Shots[i].EditOrder < b.Shots[j].EditOrder
+	})
+	libAssets := make([]*BreakdownAsset, 0)
+	for _, s := range b.Shots {
+		for _, a := range s.Assets {
+			ash, agrp, aunit, ok := splitAssetRef(show, a)
+			if !ok {
+				continue
+			}
+			// 애셋 그룹에 속하지 않은 유닛이나 라이브러리 애셋도 샷이 쓴다면 열에 더한다.
+			id := JoinUnitID(ash, agrp, aunit)
+			if hasAsset[id] {
+				continue
+			}
+			u, err := GetUnit(db, ash, agrp, aunit)
+			if err != nil {
+				return nil, err
+			}
+			ba := &BreakdownAsset{Ref: a, Unit: u}
+			if ash == show {
+				b.Assets = append(b.Assets, ba)
+			} else {
+				libAssets = append(libAssets, ba)
+			}
+			assetIDs[ash] = append(assetIDs[ash], id)
+			hasAsset[id] = true
+		}
+	}
+	sort.SliceStable(b.Assets, func(i, j int) bool {
+		return b.Assets[i].Ref < b.Assets[j].Ref
+	})
+	sort.Slice(libAssets, func(i, j int) bool {
+		return libAssets[i].Ref < libAssets[j].Ref
+	})
+	b.Assets = append(b.Assets, libAssets...)
+	tasks := make(map[string][]*Task)
+	for sh, ids := range assetIDs {
+		ts, err := TasksOfUnits(db, sh, ids)
+		if err != nil {
+			return nil, err
+		}
+		for id, t := range ts {
+			tasks[id] = t
+		}
+	}
+	for _, a := range b.Assets {
+		a.Tasks = tasks[a.Unit.ID()]
+	}
+	return b, nil
+}
+
+// Table은 브레이크다운을 내보낼 표로 반환한다.
+// 첫 행은 애셋 이름, 둘째 행은 애셋의 "태스크: 상태" 목록이며,
+// 그 다음 행부터 샷마다 애셋을 쓰는 칸에 o를 적는다.
+func (b *Breakdown) Table() [][]string {
+	names := []string{"unit"}
+	statuses := []string{"tasks"}
+	for _, a := range b.Assets {
+		names = append(names, a.Ref)
+		ts := make([]string, 0, len(a.Tasks))
+		for _, t := range a.Tasks {
+			ts = append(ts, t.Task+": "+string(t.Status))
+		}
+		statuses = append(statuses, strings.Join(ts, ", "))
+	}
+	table := [][]string{names, statuses}
+	for _, s := range b.Shots {
+		row := []string{s.Group + "/" + s.Unit}
+		for _, a := range b.Assets {
+			cell := ""
+			if b.Uses(s, a.Ref) {
+				cell = "o"
+			}
+			row = append(row, cell)
+		}
+		table = append(table, row)
+	}
+	return table
+}
+
+// UpdateBreakdown은 쇼의 샷들이 쓰는 애셋을 하나의 트랜잭션으로 수정한다.
+// uses는 샷 아이디별로 그 샷이 쓰는 애셋 참조 전체이며, uses에 없는 샷은 수정하지 않는다.
+// loaded는 샷 아이디별로 사용자가 표를 불러왔을 때 그 샷이 쓰던 애셋 참조이다.
+// 그 사이 다른 사용자가 애셋을 바꾼 샷은 수정을 덮어쓰지 않도록 에러로 처리한다.
+// loaded가 nil이면 검사하지 않는다.
+// 에러가 있는 샷이 하나라도 있으면 아무 샷도 수정하지 않고 결과와 함께 BadRequest 에러를 반환한다.
+// author가 있다면 수정을 되돌릴 수 있도록 작업 기록(ChangeSet)을 함께 남긴다.
+func UpdateBreakdown(db *sql.DB, show string, uses, loaded map[string][]string, author string) ([]*BulkResult, error) {
+	ids := make([]string, 0, len(uses))
+	for id := range uses {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	results := make([]*BulkResult, 0, len(ids))
+	stmts := make([]dbStatement, 0)
+	cs := newChangeSetRecorder(author, "update-breakdown")
+	for _, id := range ids {
+		r := &BulkResult{ID: id}
+		results = append(results, r)
+		sh, grp, unit, err := SplitUnitID(id)
+		if err != nil {
+			r.Error = err.Error()
+			continue
+		}
+		if sh != show {
+			r.Error = fmt.Sprintf("unit not in show %s", show)
+			continue
+		}
+		old, err := GetUnit(db, sh, grp, unit)
+		if err != nil {
+			if !errors.As(err, &NotFoundError{}) {
+				return nil, err
+			}
+			r.Error = err.Error()
+			continue
+		}
+		if loaded != nil {
+			was := append([]string{}, loaded[id]...)
+			sort.Strings(was)
+			if !reflect.DeepEqual(was, old.Assets) {
+				r.Error = "assets changed since the breakdown was loaded"
+				continue
+			}
+		}
+		s := *old
+		s.Assets = append([]string{}, uses[id]...)
+		sort.Strings(s.Assets)
+		if reflect.DeepEqual(s.Assets, old.Assets) {
+			continue
+		}
+		st, err := updateUnitStmts(db, &s, author, "")
+		if err != nil {
+			r.Error = err.Error()
+			continue
+		}
+		err = cs.addUnit(db, &s, false)
+		if err != nil {
+			return nil, err
+		}
+		r.Changed = true
+		stmts = append(stmts, st...)
+	}
+	err := bulkError(results)
+	if err != nil {
+		return results, err
+	}
+	if len(stmts) == 0 {
+		return results, nil
+	}
+	stmts = append(stmts, cs.stmts(fmt.Sprintf("%d units: breakdown", changedResults(results)))...)
+	err = dbExec(db, stmts)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,144 @@
+package roi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBreakdownTable(t *testing.T) {
+	hero := &Unit{Show: "TEST", Group: "char", Unit: "hero"}
+	lamp := &Unit{Show: "LIB", Group: "prop", Unit: "lamp"}
+	b := &Breakdown{
+		Show: "TEST",
+		Shots: []*Unit{
+			{Show: "TEST", Group: "CG", Unit: "0010", Assets: []string{"LIB/prop/lamp", "char/hero"}},
+			{Show: "TEST", Group: "CG", Unit: "0020", Assets: []string{"char/hero"}},
+			{Show: "TEST", Group: "CG", Unit: "0030", Assets: []string{}},
+		},
+		Assets: []*BreakdownAsset{
+			{Ref: "char/hero", Unit: hero, Tasks: []*Task{{Task: "mod", Status: StatusDone}, {Task: "rig", Status: StatusInProgress}}},
+			{Ref: "LIB/prop/lamp", Unit: lamp, Tasks: []*Task{}},
+		},
+	}
+	want := [][]string{
+		{"unit", "char/hero", "LIB/prop/lamp"},
+		{"tasks", "mod: " + string(StatusDone) + ", rig: " + string(StatusInProgress), ""},
+		{"CG/0010", "o", "o"},
+		{"CG/0020", "o", ""},
+		{"CG/0030", "", ""},
+	}
+	got := b.Table()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestIsShotGroup(t *testing.T) {
+	for _, g := range []string{"CG", "SEQ_01", "A"} {
+		if !IsShotGroup(g) {
+			t.Fatalf("%s: want shot group", g)
+		}
+	}
+	for _, g := range []string{"char", "prop", "_CG", "0010"} {
+		if IsShotGroup(g) {
+			t.Fatalf("%s: want asset group", g)
+		}
+	}
+}
+
+func TestBreakdown(t *testing.T) {
+	db, err := testDB()
+	if err != nil {
+		t.Fatalf("could not connect to database: %v", err)
+	}
+	err = AddSite(db)
+	if err != nil {
+		t.Fatalf("could not add site: %s", err)
+	}
+	defer func() {
+		err := DeleteSite(db)
+		if err != nil {
+			t.Fatalf("could not delete site: %s", err)
+		}
+	}()
+	err = AddShow(db, testShow)
+	if err != nil {
+		t.Fatalf("could not add show: %s", err)
+	}
+	defer func() {
+		err = DeleteShow(db, testShow.Show)
+		if err != nil {
+			t.Fatalf("could not delete show: %s", err)
+		}
+	}()
+	assetGroup := &Group{Show: testShow.Show, Group: "char", DefaultTasks: []string{}, Attrs: DBStringMap{}}
+	for _, g := range []*Group{testGroup, assetGroup} {
+		err = AddGroup(db, g)
+		if err != nil {
+			t.Fatalf("could not add group: %s", err)
+		}
+	}
+	hero := &Unit{Show: testShow.Show, Group: "char", Unit: "hero", Status: StatusInProgress, Tags: []string{}, Assets: []string{}, Tasks: []string{"mod"}, Attrs: DBStringMap{}}
+	shotA := &Unit{Show: testShow.Show, Group: testGroup.Group, Unit: "0020", Status: StatusInProgress, EditOrder: 2, Tags: []string{}, Assets: []string{}, Tasks: []string{"fx"}, Attrs: DBStringMap{}}
+	shotB := &Unit{Show: testShow.Show, Group: testGroup.Group, Unit: "0010", Status: StatusInProgress, EditOrder: 1, Tags: []string{}, Assets: []string{"char/hero"}, Tasks: []string{"fx"}, Attrs: DBStringMap{}}
+	for _, u := range []*Unit{hero, shotA, shotB} {
+		err = AddUnit(db, u)
+		if err != nil {
+			t.Fatalf("could not add unit: %s", err)
+		}
+	}
+	b, err := GetBreakdown(db, testShow.Show)
+	if err != nil {
+		t.Fatalf("could not get breakdown: %s", err)
+	}
+	if len(b.Shots) != 2 || b.Shots[0].ID() != shotB.ID() || b.Shots[1].ID() != shotA.ID() {
+		t.Fatalf("shots should be in edit order: got %v", b.Shots)
+	}
+	if len(b.Assets) != 1 || b.Assets[0].Ref != "char/hero" || len(b.Assets[0].Tasks) != 1 {
+		t.Fatalf("assets: got %v", b.Assets)
+	}
+	uses := map[string][]string{
+		shotA.ID(): {"char/hero"},
+		shotB.ID(): {},
+	}
+	loaded := map[string][]string{
+		shotA.ID(): {},
+		shotB.ID(): {"char/hero"},
+	}
+	_, err = UpdateBreakdown(db, testShow.Show, uses, loaded, "")
+	if err != nil {
+		t.Fatalf("could not update breakdown: %s", err)
+	}
+	users, err := AssetUsers(db, hero.Show, hero.Group, hero.Unit)
+	if err != nil {
+		t.Fatalf("could not get asset users: %s", err)
+	}
+	if len(users) != 1 || users[0].ID() != shotA.ID() {
+		t.Fatalf("asset users after update: got %v", users)
+	}
+	// 없는 애셋이 있다면 어떤 샷도 수정하지 않는다.
+	uses = map[string][]string{
+		shotA.ID(): {},
+		shotB.ID(): {"char/villain"},
+	}
+	_, err = UpdateBreakdown(db, testShow.Show, uses, nil, "")
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error, got %v", err)
+	}
+	// 표를 불러온 후 애셋이 바뀐 샷은 덮어쓰지 않는다.
+	uses = map[string][]string{
+		shotA.ID(): {},
+	}
+	_, err = UpdateBreakdown(db, testShow.Show, uses, loaded, "")
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error for a stale breakdown, got %v", err)
+	}
+	got, err := GetUnit(db, shotA.Show, shotA.Group, shotA.Unit)
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	if !reflect.DeepEqual(got.Assets, []string{"char/hero"}) {
+		t.Fatalf("unit should not be updated: got %v", got.Assets)
+	}
+}
@@ -0,0 +1,427 @@
+package roi
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// BulkResult는 여러 항목을 한번에 수정할 때 항목 하나의 처리 결과이다.
+type BulkResult struct {
+	// ID는 항목의 아이디이다.
+	ID string
+	// Changed는 항목이 수정되는지를 나타낸다. 바뀌는 것이 없거나 건너뛴 항목은 false이다.
+	Changed bool
+	// Skipped는 수정할 대상이 없어 건너뛴 항목인지를 나타낸다.
+	// 예를 들어 여러 유닛의 태스크를 수정할 때 해당 태스크가 없는 유닛은 건너뛴다.
+	Skipped bool
+	// Error는 항목의 에러 메시지이다.
+	// 에러가 있는 항목이 하나라도 있으면 어떤 항목도 수정되지 않는다.
+	Error string
+}
+
+// bulkError는 결과 중 에러가 있는 항목이 있다면 첫 에러를 담은 BadRequest 에러를 반환한다.
+func bulkError(results []*BulkResult) error {
+	n := 0
+	var first *BulkResult
+	for _, r := range results {
+		if r.Error == "" {
+			continue
+		}
+		if first == nil {
+			first = r
+		}
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	return BadRequest("%d of %d items could not be updated: %s: %s", n, len(results), first.ID, first.Error)
+}
+
+// uniqueIDs는 처음 나타난 순서를 유지하며 중복된 아이디를 뺀 아이디들을 반환한다.
+// 같은 항목을 두번 수정하는 구문과 작업 기록이 만들어지지 않게 한다.
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	uniq := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		uniq = append(uniq, id)
+	}
+	return uniq
+}
+
+// changedResults는 결과 중 수정되는 항목의 수를 반환한다.
+func changedResults(results []*BulkResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Changed {
+			n++
+		}
+	}
+	return n
+}
+
+// ParsePatchList는 쉼표로 구분된 +값, -값 목록을 읽는다.
+// 각 값은 + 또는 -로 시작해야 하며, 그렇지 않다면 field 이름과 함께 BadRequest 에러를 반환한다.
+func ParsePatchList(field, s string) ([]string, error) {
+	vals := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		err := verifyPatch(field, v)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// verifyPatch는 값이 +나 -로 시작하고 그 뒤에 이름이 있는지 검사한다.
+func verifyPatch(field, v string) error {
+	if len(v) < 2 || (v[0] != '+' && v[0] != '-') {
+		return BadRequest("%s must be started with +/- got %s", field, v)
+	}
+	return nil
+}
+
+// applyPatchList는 +값은 vals에 없을 때 더하고, -값은 vals에서 지운 새 슬라이스를 반환한다.
+// 적용할 패치가 없다면 vals를 그대로 반환한다.
+func applyPatchList(vals []string, patches []string) []string {
+	if len(patches) == 0 {
+		return vals
+	}
+	vs := make([]string, len(vals))
+	copy(vs, vals)
+	for _, p := range patches {
+		v := p[1:]
+		if p[0] == '+' {
+			found := false
+			for _, x := range vs {
+				if x == v {
+					found = true
+					break
+				}
+			}
+			if !found {
+				vs = append(vs, v)
+			}
+			continue
+		}
+		nvs := make([]string, 0, len(vs))
+		for _, x := range vs {
+			if x != v {
+				nvs = append(nvs, x)
+			}
+		}
+		vs = nvs
+	}
+	return vs
+}
+
+// UnitPatch는 여러 유닛에 한번에 적용할 수정 사항이다. 비어있는 필드는 수정하지 않는다.
+type UnitPatch struct {
+	Status  Status
+	DueDate time.Time
+	// CutIn이 nil이 아니면 유닛의 컷 길이를 유지한 채 구간을 옮긴다.
+	// 구간이 정해지지 않은 유닛은 컷 아웃을 알 수 없으니 그대로 둔다.
+	CutIn      *int
+	HeadHandle *int
+	TailHandle *int
+	// Tags, Assets, Tasks는 +나 -로 시작하는 값들이다.
+	// +값은 유닛에 없다면 더하고, -값은 유닛에 있다면 지운다.
+	Tags   []string
+	Assets []string
+	Tasks  []string
+}
+
+// verify는 패치가 유효하지 않다면 에러를 반환한다.
+func (p *UnitPatch) verify() error {
+	if p == nil {
+		return BadRequest("nil unit patch")
+	}
+	if p.Status != "" {
+		// 사이트에서 쓸 수 있는 상태인지는 유닛을 검사할 때 확인한다.
+		err := verifyStatusName(p.Status)
+		if err != nil {
+			return err
+		}
+	}
+	for field, vals := range map[string][]string{"tag": p.Tags, "asset": p.Assets, "task": p.Tasks} {
+		for _, v := range vals {
+			err := verifyPatch(field, v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// String은 패치의 수정 사항을 사람이 읽을 수 있는 문자열로 반환한다.
+func (p *UnitPatch) String() string {
+	s := make([]string, 0)
+	if p.Status != "" {
+		s = append(s, "status "+string(p.Status))
+	}
+	if !p.DueDate.IsZero() {
+		s = append(s, "due "+p.DueDate.Format("2006-01-02"))
+	}
+	for _, f := range []struct {
+		name string
+		n    *int
+	}{{"cut_in", p.CutIn}, {"head_handle", p.HeadHandle}, {"tail_handle", p.TailHandle}} {
+		if f.n != nil {
+			s = append(s, fmt.Sprintf("%s %d", f.name, *f.n))
+		}
+	}
+	for _, f := range []struct {
+		name  string
+		patch []string
+	}{{"tags", p.Tags}, {"assets", p.Assets}, {"tasks", p.Tasks}} {
+		if len(f.patch) != 0 {
+			s = append(s, f.name+" "+strings.Join(f.patch, " "))
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// apply는 패치를 적용한 유닛의 복사본을 반환한다.
+func (p *UnitPatch) apply(u *Unit) *Unit {
+	s := *u
+	if !p.DueDate.IsZero() {
+		s.DueDate = p.DueDate
+	}
+	if p.Status != "" {
+		s.Status = p.Status
+	}
+	if p.CutIn != nil {
+		if dur := u.Duration(); dur != 0 {
+			s.CutIn = *p.CutIn
+			s.CutOut = *p.CutIn + dur - 1
+		}
+	}
+	if p.HeadHandle != nil {
+		s.HeadHandle = *p.HeadHandle
+	}
+	if p.TailHandle != nil {
+		s.TailHandle = *p.TailHandle
+	}
+	s.Tags = applyPatchList(u.Tags, p.Tags)
+	s.Assets = applyPatchList(u.Assets, p.Assets)
+	s.Tasks = applyPatchList(u.Tasks, p.Tasks)
+	return &s
+}
+
+// UpdateUnits는 여러 유닛에 같은 패치를 적용해 하나의 트랜잭션으로 수정한다.
+// ids는 유닛 아이디이며, 결과는 ids의 순서대로 유닛별로 반환된다. 중복된 아이디는 한번만 처리한다.
+// 에러가 있는 유닛이 하나라도 있으면 아무 유닛도 수정하지 않고 결과와 함께 BadRequest 에러를 반환한다.
+// 컷 정보가 바뀐 유닛은 author와 reason을 변경 기록에 남긴다.
+// author가 있다면 수정을 되돌릴 수 있도록 작업 기록(ChangeSet)을 함께 남긴다.
+func UpdateUnits(db *sql.DB, ids []string, p *UnitPatch, author, reason string) ([]*BulkResult, error) {
+	err := p.verify()
+	if err != nil {
+		return nil, err
+	}
+	ids = uniqueIDs(ids)
+	results := make([]*BulkResult, 0, len(ids))
+	stmts := make([]dbStatement, 0)
+	cs := newChangeSetRecorder(author, "update-units")
+	showTags := make(map[string][]string)
+	shows := make([]string, 0)
+	for _, id := range ids {
+		r := &BulkResult{ID: id}
+		results = append(results, r)
+		show, grp, unit, err := SplitUnitID(id)
+		if err != nil {
+			r.Error = err.Error()
+			continue
+		}
+		old, err := GetUnit(db, show, grp, unit)
+		if err != nil {
+			if !errors.As(err, &NotFoundError{}) {
+				return nil, err
+			}
+			r.Error = err.Error()
+			continue
+		}
+		s := p.apply(old)
+		if reflect.DeepEqual(s, old) {
+			continue
+		}
+		st, err := updateUnitStmts(db, s, author, reason)
+		if err != nil {
+			r.Error = err.Error()
+			continue
+		}
+		err = cs.addUnit(db, s, false)
+		if err != nil {
+			return nil, err
+		}
+		r.Changed = true
+		stmts = append(stmts, st...)
+		if _, ok := showTags[show]; !ok {
+			shows = append(shows, show)
+		}
+		showTags[show] = append(showTags[show], s.Tags...)
+	}
+	err = bulkError(results)
+	if err != nil {
+		return results, err
+	}
+	// 쇼의 태그는 쇼마다 한번에 업데이트해야 앞의 구문을 덮어쓰지 않는다.
+	for _, show := range shows {
+		st, err := addShowTagsStmts(db, show, showTags[show])
+		if err != nil {
+			return nil, err
+		}
+		if len(st) != 0 {
+			defer invalidateShowCache(show)
+		}
+		stmts = append(stmts, st...)
+	}
+	if len(stmts) == 0 {
+		return results, nil
+	}
+	stmts = append(stmts, cs.stmts(fmt.Sprintf("%d units: %s", changedResults(results), p))...)
+	err = dbExec(db, stmts)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// TaskPatch는 여러 태스크에 한번에 적용할 수정 사항이다. 비어있는 필드는 수정하지 않는다.
+type TaskPatch struct {
+	Status   Status
+	DueDate  time.Time
+	Assignee string
+}
+
+// verify는 패치가 유효하지 않다면 에러를 반환한다.
+func (p *TaskPatch) verify() error {
+	if p == nil {
+		return BadRequest("nil task patch")
+	}
+	if p.Status != "" {
+		return verifyStatusName(p.Status)
+	}
+	return nil
+}
+
+// String은 패치의 수정 사항을 사람이 읽을 수 있는 문자열로 반환한다.
+func (p *TaskPatch) String() string {
+	s := make([]string, 0)
+	if p.Status != "" {
+		s = append(s, "status "+string(p.Status))
+	}
+	if !p.DueDate.IsZero() {
+		s = append(s, "due "+p.DueDate.Format("2006-01-02"))
+	}
+	if p.Assignee != "" {
+		s = append(s, "assignee "+p.Assignee)
+	}
+	return strings.Join(s, ", ")
+}
+
+// apply는 패치를 적용한 태스크의 복사본을 반환한다.
+func (p *TaskPatch) apply(t *Task) *Task {
+	s := *t
+	if !p.DueDate.IsZero() {
+		s.DueDate = p.DueDate
+	}
+	if p.Status != "" {
+		s.Status = p.Status
+	}
+	if p.Assignee != "" {
+		s.Assignee = p.Assignee
+	}
+	return &s
+}
+
+// UpdateTasks는 여러 태스크에 같은 패치를 적용해 하나의 트랜잭션으로 수정한다.
+// ids는 태스크 아이디이며, 결과는 ids의 순서대로 태스크별로 반환된다. 중복된 아이디는 한번만 처리한다.
+// 여러 유닛의 같은 태스크를 한번에 수정할 때 어떤 유닛에는 그 태스크가 없을 수 있으므로
+// 유닛에 없는 태스크는 에러 대신 건너뛴다. 유닛이나 그룹이 없다면 그 태스크는 에러이다.
+// 에러가 있는 태스크가 하나라도 있으면 아무 태스크도 수정하지 않고 결과와 함께 BadRequest 에러를 반환한다.
+// 상태를 바꾼다면 author가 사이트 워크플로우의 그 전환을 일으킬 수 있어야 한다.
+// author가 있다면 수정을 되돌릴 수 있도록 작업 기록(ChangeSet)을 함께 남긴다.
+func UpdateTasks(db *sql.DB, ids []string, p *TaskPatch, author string) ([]*BulkResult, error) {
+	err := p.verify()
+	if err != nil {
+		return nil, err
+	}
+	ids = uniqueIDs(ids)
+	results := make([]*BulkResult, 0, len(ids))
+	stmts := make([]dbStatement, 0)
+	cs := newChangeSetRecorder(author, "update-tasks")
+	for _, id := range ids {
+		r := &BulkResult{ID: id}
+		results = append(results, r)
+		show, grp, unit, task, err := SplitTaskID(id)
+		if err != nil {
+			r.Error = err.Error()
+			continue
+		}
+		old, err := GetTask(db, show, grp, unit, task)
+		if err != nil {
+			if !errors.As(err, &NotFoundError{}) {
+				return nil, err
+			}
+			// GetTask는 부모 유닛이나 그룹이 없어도 NotFound 에러를 반환한다.
+			// 건너뛰는 것은 유닛에 태스크만 없을 때이며, 잘못 쓴 유닛은 에러로 알린다.
+			_, err = GetUnit(db, show, grp, unit)
+			if err != nil {
+				if !errors.As(err, &NotFoundError{}) {
+					return nil, err
+				}
+				r.Error = err.Error()
+				continue
+			}
+			r.Skipped = true
+			continue
+		}
+		t := p.apply(old)
+		if reflect.DeepEqual(t, old) {
+			continue
+		}
+		err = verifyTaskTransition(db, old, t, author)
+		if err != nil {
+			r.Error = err.Error()
+			continue
+		}
+		st, err := updateTaskStmts(db, t)
+		if err != nil {
+			r.Error = err.Error()
+			continue
+		}
+		err = cs.addTask(old, t)
+		if err != nil {
+			return nil, err
+		}
+		r.Changed = true
+		stmts = append(stmts, st...)
+	}
+	err = bulkError(results)
+	if err != nil {
+		return results, err
+	}
+	if len(stmts) == 0 {
+		return results, nil
+	}
+	stmts = append(stmts, cs.stmts(fmt.Sprintf("%d tasks: %s", changedResults(results), p))...)
+	err = dbExec(db, stmts)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,203 @@
+package roi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePatchList(t *testing.T) {
+	cases := []struct {
+		s       string
+		want    []string
+		wantErr bool
+	}{
+		{s: "", want: []string{}},
+		{s: "+a, -b,,+c ", want: []string{"+a", "-b", "+c"}},
+		{s: "+a, b", wantErr: true},
+		{s: "+", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := ParsePatchList("tag", c.s)
+		if c.wantErr {
+			if !errors.As(err, &BadRequestError{}) {
+				t.Fatalf("%q: want bad request error, got %v", c.s, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", c.s, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%q: got %v, want %v", c.s, got, c.want)
+		}
+	}
+}
+
+func TestApplyPatchList(t *testing.T) {
+	vals := []string{"a", "b"}
+	got := applyPatchList(vals, []string{"+c", "+a", "-b", "-x"})
+	want := []string{"a", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(vals, []string{"a", "b"}) {
+		t.Fatalf("original values modified: %v", vals)
+	}
+}
+
+func TestUniqueIDs(t *testing.T) {
+	got := uniqueIDs([]string{"a", "b", "a", "c", "b"})
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestUnitPatchApply(t *testing.T) {
+	u := &Unit{CutIn: 1001, CutOut: 1010, Tags: []string{"a"}}
+	cutIn := 101
+	p := &UnitPatch{CutIn: &cutIn, Tags: []string{"+b"}}
+	got := p.apply(u)
+	if got.CutIn != 101 || got.CutOut != 110 {
+		t.Fatalf("cut range not moved: got %d-%d, want 101-110", got.CutIn, got.CutOut)
+	}
+	if !reflect.DeepEqual(got.Tags, []string{"a", "b"}) {
+		t.Fatalf("got tags %v, want [a b]", got.Tags)
+	}
+	if u.CutIn != 1001 || len(u.Tags) != 1 {
+		t.Fatalf("original unit modified: %v", u)
+	}
+	// 구간이 없는 유닛은 옮기지 않는다.
+	got = p.apply(&Unit{})
+	if got.CutIn != 0 || got.CutOut != 0 {
+		t.Fatalf("unit without cut range moved: got %d-%d", got.CutIn, got.CutOut)
+	}
+}
+
+func TestBulkUpdate(t *testing.T) {
+	db, err := testDB()
+	if err != nil {
+		t.Fatalf("could not connect to database: %v", err)
+	}
+	err = AddSite(db)
+	if err != nil {
+		t.Fatalf("could not add site: %s", err)
+	}
+	defer func() {
+		err := DeleteSite(db)
+		if err != nil {
+			t.Fatalf("could not delete site: %s", err)
+		}
+	}()
+	err = AddShow(db, testShow)
+	if err != nil {
+		t.Fatalf("could not add show: %s", err)
+	}
+	defer func() {
+		err = DeleteShow(db, testShow.ID())
+		if err != nil {
+			t.Fatalf("could not delete show: %s", err)
+		}
+	}()
+	err = AddGroup(db, testGroup)
+	if err != nil {
+		t.Fatalf("could not add group: %s", err)
+	}
+	units := []*Unit{
+		{Show: testShow.Show, Group: testGroup.Group, Unit: "0010", Status: StatusInProgress, Tags: []string{"a"}, Assets: []string{}, Tasks: []string{"fx"}},
+		{Show: testShow.Show, Group: testGroup.Group, Unit: "0020", Status: StatusInProgress, Tags: []string{}, Assets: []string{}, Tasks: []string{"lit"}},
+	}
+	ids := make([]string, 0)
+	for _, u := range units {
+		err = AddUnit(db, u)
+		if err != nil {
+			t.Fatalf("could not add unit: %s", err)
+		}
+		ids = append(ids, u.ID())
+	}
+
+	// 한 유닛이라도 에러가 있으면 아무 유닛도 수정되지 않는다.
+	results, err := UpdateUnits(db, append(ids, JoinUnitID(testShow.Show, testGroup.Group, "0030")), &UnitPatch{Tags: []string{"+b"}}, "", "")
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error when updating non existing unit, got: %v", err)
+	}
+	if len(results) != 3 || results[2].Error == "" || !results[0].Changed {
+		t.Fatalf("unexpected results: %v", results)
+	}
+	u, err := GetUnit(db, testShow.Show, testGroup.Group, "0010")
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	if !reflect.DeepEqual(u.Tags, []string{"a"}) {
+		t.Fatalf("unit modified by failed bulk update: got tags %v", u.Tags)
+	}
+
+	// 중복된 아이디는 한번만 수정된다.
+	results, err = UpdateUnits(db, append(ids, ids[0]), &UnitPatch{Status: StatusHold, Tags: []string{"+b", "-a"}, Tasks: []string{"+comp"}}, "", "")
+	if err != nil {
+		t.Fatalf("could not update units: %s", err)
+	}
+	if len(results) != len(ids) {
+		t.Fatalf("got %d results for duplicated ids, want %d", len(results), len(ids))
+	}
+	for _, r := range results {
+		if !r.Changed {
+			t.Fatalf("unit not changed: %v", r)
+		}
+	}
+	for _, id := range ids {
+		show, grp, unit, _ := SplitUnitID(id)
+		u, err := GetUnit(db, show, grp, unit)
+		if err != nil {
+			t.Fatalf("could not get unit: %s", err)
+		}
+		if u.Status != StatusHold || !reflect.DeepEqual(u.Tags, []string{"b"}) {
+			t.Fatalf("unit not updated: %v", u)
+		}
+		_, err = GetTask(db, show, grp, unit, "comp")
+		if err != nil {
+			t.Fatalf("added task not created: %s", err)
+		}
+	}
+
+	// 태스크가 없는 유닛은 건너뛴다.
+	taskIDs := []string{
+		JoinTaskID(testShow.Show, testGroup.Group, "0010", "fx"),
+		JoinTaskID(testShow.Show, testGroup.Group, "0020", "fx"),
+	}
+	results, err = UpdateTasks(db, taskIDs, &TaskPatch{Assignee: "kybin"}, "")
+	if err != nil {
+		t.Fatalf("could not update tasks: %s", err)
+	}
+	if !results[0].Changed || !results[1].Skipped {
+		t.Fatalf("unexpected results: %v, %v", results[0], results[1])
+	}
+	task, err := GetTask(db, testShow.Show, testGroup.Group, "0010", "fx")
+	if err != nil {
+		t.Fatalf("could not get task: %s", err)
+	}
+	if task.Assignee != "kybin" {
+		t.Fatalf("task not updated: %v", task)
+	}
+	// 태스크와 달리 유닛이 없는 것은 건너뛰지 않는다.
+	results, err = UpdateTasks(db, []string{JoinTaskID(testShow.Show, testGroup.Group, "9999", "fx")}, &TaskPatch{Assignee: "kybin"}, "")
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error for missing unit, got: %v", err)
+	}
+	if results[0].Skipped || results[0].Error == "" {
+		t.Fatalf("missing unit should be an error: %v", results[0])
+	}
+	// 리뷰 버전 없이 리뷰 대기로 바꿀 수 없으므로 아무 태스크도 바뀌지 않는다.
+	_, err = UpdateTasks(db, taskIDs, &TaskPatch{Status: StatusNeedReview, Assignee: "admin"}, "admin")
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error, got: %v", err)
+	}
+	task, err = GetTask(db, testShow.Show, testGroup.Group, "0010", "fx")
+	if err != nil {
+		t.Fatalf("could not get task: %s", err)
+	}
+	if task.Assignee != "kybin" {
+		t.Fatalf("task modified by failed bulk update: %v", task)
+	}
+}
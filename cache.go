@@ -0,0 +1,181 @@
+package roi
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// recordCache는 사이트, 쇼, 그룹처럼 자주 읽히지만 드물게 바뀌는 레코드를
+// 프로세스 안에 보관하는 읽기 캐시이다. 여러 고루틴에서 동시에 사용해도 안전하다.
+//
+// 레코드를 바꾸거나 지우는 함수는 db에 반영한 뒤 해당 키를 무효화해야 한다.
+// 무효화할 때마다 세대(gen)가 올라가며, 캐시에서 찾지 못해 db에서 읽는 동안
+// 세대가 바뀌었다면 읽은 레코드는 이미 오래된 것일 수 있으므로 저장하지 않는다.
+type recordCache struct {
+	mu      sync.RWMutex
+	enabled bool
+	gen     uint64
+	items   map[string]interface{}
+	metrics map[string]*CacheMetric
+}
+
+// CacheMetric은 한 종류의 레코드에 대한 캐시 적중 통계이다.
+type CacheMetric struct {
+	Hits   int64
+	Misses int64
+}
+
+// cache는 로이가 사용하는 레코드 캐시이다.
+var cache = &recordCache{
+	enabled: true,
+	items:   make(map[string]interface{}),
+	metrics: make(map[string]*CacheMetric),
+}
+
+// SetCacheEnabled는 사이트, 쇼, 그룹 레코드 캐시를 켜거나 끈다.
+// 캐시를 끄면 저장된 레코드와 통계를 모두 지운다.
+// 캐시는 기본적으로 켜져 있으며, 다른 프로세스가 db를 함께 고치는 환경이나
+// db를 직접 확인해야 하는 테스트에서 끈다.
+func SetCacheEnabled(on bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.enabled = on
+	if !on {
+		cache.gen++
+		cache.items = make(map[string]interface{})
+		cache.metrics = make(map[string]*CacheMetric)
+	}
+}
+
+// CacheEnabled는 레코드 캐시가 켜져 있는지를 반환한다.
+func CacheEnabled() bool {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	return cache.enabled
+}
+
+// CacheMetrics는 레코드 종류(site, show, group)별 캐시 적중 통계의 복사본을 반환한다.
+func CacheMetrics() map[string]CacheMetric {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	m := make(map[string]CacheMetric)
+	for kind, c := range cache.metrics {
+		m[kind] = *c
+	}
+	return m
+}
+
+// get은 키에 해당하는 레코드의 복사본을 반환한다.
+// 찾지 못했다면 db에서 읽은 레코드를 put할 때 넘길 현재 세대를 함께 반환한다.
+// 키는 "종류/아이디" 형식이며 종류별로 적중 통계를 남긴다.
+func (c *recordCache) get(key string) (interface{}, uint64, bool) {
+	kind := key
+	if i := strings.Index(key, "/"); i != -1 {
+		kind = key[:i]
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled {
+		return nil, c.gen, false
+	}
+	m := c.metrics[kind]
+	if m == nil {
+		m = &CacheMetric{}
+		c.metrics[kind] = m
+	}
+	v, ok := c.items[key]
+	if !ok {
+		m.Misses++
+		return nil, c.gen, false
+	}
+	m.Hits++
+	return cloneRecord(v), c.gen, true
+}
+
+// put은 db에서 읽은 레코드의 복사본을 캐시에 저장한다.
+// get 이후로 무효화가 있었다면 저장하지 않는다.
+func (c *recordCache) put(key string, v interface{}, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled || c.gen != gen {
+		return
+	}
+	c.items[key] = cloneRecord(v)
+}
+
+// invalidate는 키에 해당하는 레코드를 캐시에서 지운다.
+// prefixes가 있다면 그 중 하나로 시작하는 키의 레코드들도 함께 지운다.
+func (c *recordCache) invalidate(key string, prefixes ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	delete(c.items, key)
+	if len(prefixes) == 0 {
+		return
+	}
+	for k := range c.items {
+		for _, p := range prefixes {
+			if strings.HasPrefix(k, p) {
+				delete(c.items, k)
+				break
+			}
+		}
+	}
+}
+
+// siteCacheKey, showCacheKey, groupCacheKey는 레코드의 캐시 키를 반환한다.
+func siteCacheKey() string {
+	return "site"
+}
+
+func showCacheKey(show string) string {
+	return "show/" + show
+}
+
+func groupCacheKey(show, grp string) string {
+	return "group/" + show + "/" + grp
+}
+
+// invalidateSiteCache는 캐시된 사이트를 지운다.
+func invalidateSiteCache() {
+	cache.invalidate(siteCacheKey())
+}
+
+// invalidateShowCache는 캐시된 쇼와 그 쇼에 속한 그룹들을 지운다.
+func invalidateShowCache(show string) {
+	cache.invalidate(showCacheKey(show), "group/"+show+"/")
+}
+
+// invalidateGroupCache는 캐시된 그룹을 지운다.
+func invalidateGroupCache(show, grp string) {
+	cache.invalidate(groupCacheKey(show, grp))
+}
+
+// cloneRecord는 레코드 구조체 포인터를 받아 그 복사본을 반환한다.
+// 슬라이스와 맵 필드도 복사해 캐시된 레코드가 호출자의 수정에 영향받지 않게 한다.
+func cloneRecord(v interface{}) interface{} {
+	src := reflect.ValueOf(v).Elem()
+	dst := reflect.New(src.Type()).Elem()
+	dst.Set(src)
+	for i := 0; i < dst.NumField(); i++ {
+		f := dst.Field(i)
+		if !f.CanSet() || f.IsZero() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.Slice:
+			c := reflect.MakeSlice(f.Type(), f.Len(), f.Len())
+			reflect.Copy(c, f)
+			f.Set(c)
+		case reflect.Map:
+			c := reflect.MakeMapWithSize(f.Type(), f.Len())
+			iter := f.MapRange()
+			for iter.Next() {
+				c.SetMapIndex(iter.Key(), iter.Value())
+			}
+			f.Set(c)
+		}
+	}
+	return dst.Addr().Interface()
+}
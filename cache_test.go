@@ -0,0 +1,125 @@
+package roi
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func newTestCache() *recordCache {
+	return &recordCache{
+		enabled: true,
+		items:   make(map[string]interface{}),
+		metrics: make(map[string]*CacheMetric),
+	}
+}
+
+func TestRecordCache(t *testing.T) {
+	c := newTestCache()
+	key := showCacheKey("TEST")
+	_, gen, ok := c.get(key)
+	if ok {
+		t.Fatalf("empty cache should not have %s", key)
+	}
+	s := &Show{Show: "TEST", Tags: []string{"a"}}
+	c.put(key, s, gen)
+	v, _, ok := c.get(key)
+	if !ok {
+		t.Fatalf("cache should have %s", key)
+	}
+	got := v.(*Show)
+	if !reflect.DeepEqual(got, s) {
+		t.Fatalf("got %v, want %v", got, s)
+	}
+	// 반환된 레코드를 고쳐도 캐시된 레코드는 바뀌지 않아야 한다.
+	got.Tags[0] = "b"
+	s.Tags[0] = "c"
+	v, _, _ = c.get(key)
+	if tag := v.(*Show).Tags[0]; tag != "a" {
+		t.Fatalf("cached record modified: got tag %q, want %q", tag, "a")
+	}
+	want := map[string]CacheMetric{"show": {Hits: 2, Misses: 1}}
+	m := make(map[string]CacheMetric)
+	for kind, cm := range c.metrics {
+		m[kind] = *cm
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("metrics: got %v, want %v", m, want)
+	}
+
+	// 쇼를 무효화하면 그 쇼의 그룹도 함께 지워진다.
+	gkey := groupCacheKey("TEST", "CG")
+	okey := groupCacheKey("TEST2", "CG")
+	_, gen, _ = c.get(gkey)
+	c.put(gkey, &Group{Show: "TEST", Group: "CG"}, gen)
+	c.put(okey, &Group{Show: "TEST2", Group: "CG"}, gen)
+	c.invalidate(key, "group/TEST/")
+	if _, _, ok := c.get(key); ok {
+		t.Fatalf("invalidated show should not be cached")
+	}
+	if _, _, ok := c.get(gkey); ok {
+		t.Fatalf("group of invalidated show should not be cached")
+	}
+	if _, _, ok := c.get(okey); !ok {
+		t.Fatalf("group of other show should be cached")
+	}
+
+	// 읽는 도중 무효화가 있었다면 읽은 레코드는 저장되지 않는다.
+	_, gen, _ = c.get(key)
+	c.invalidate(key)
+	c.put(key, s, gen)
+	if _, _, ok := c.get(key); ok {
+		t.Fatalf("stale record should not be cached")
+	}
+
+	c.enabled = false
+	_, gen, _ = c.get(key)
+	c.put(key, s, gen)
+	if _, _, ok := c.get(key); ok {
+		t.Fatalf("disabled cache should not have any record")
+	}
+}
+
+func TestRecordCacheConcurrent(t *testing.T) {
+	c := newTestCache()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := siteCacheKey()
+			for j := 0; j < 100; j++ {
+				_, gen, ok := c.get(key)
+				if !ok {
+					c.put(key, &Site{Tasks: []string{"comp"}}, gen)
+				}
+				if j%10 == i {
+					c.invalidate(key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	m := c.metrics["site"]
+	if m.Hits+m.Misses != 800 {
+		t.Fatalf("got %d lookups, want 800", m.Hits+m.Misses)
+	}
+}
+
+func TestCloneRecord(t *testing.T) {
+	g := &Group{
+		Show:         "TEST",
+		Group:        "CG",
+		DefaultTasks: []string{"comp"},
+		Attrs:        DBStringMap{"a": "1"},
+	}
+	c := cloneRecord(g).(*Group)
+	if !reflect.DeepEqual(c, g) {
+		t.Fatalf("got %v, want %v", c, g)
+	}
+	c.DefaultTasks[0] = "lit"
+	c.Attrs["a"] = "2"
+	if g.DefaultTasks[0] != "comp" || g.Attrs["a"] != "1" {
+		t.Fatalf("clone shares data with original: %v", g)
+	}
+}
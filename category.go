@@ -1 +1,12 @@
 package roi
+
+import "strings"
+
+// 쇼의 그룹은 샷 그룹과 애셋 그룹으로 나뉜다.
+// 샷 그룹은 시퀀스를 나타내므로 CG, SEQ_01 처럼 대문자로 시작하고,
+// 애셋 그룹은 char, prop 처럼 소문자로 시작한다.
+
+// IsShotGroup은 그룹이 샷 그룹이라면 참을, 애셋 그룹이라면 거짓을 반환한다.
+func IsShotGroup(grp string) bool {
+	return strings.IndexAny(grp, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == 0
+}
@@ -0,0 +1,474 @@
+package roi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateTableIfNotExistsChangeSetsStmt는 DB에 change_sets 테이블을 생성하는 sql 구문이다.
+// 테이블은 타입보다 많은 정보를 담고 있을수도 있다.
+var CreateTableIfNotExistsChangeSetsStmt = `CREATE TABLE IF NOT EXISTS change_sets (
+	author STRING NOT NULL CHECK (length(author) > 0),
+	id STRING NOT NULL CHECK (length(id) > 0),
+	created TIMESTAMPTZ NOT NULL,
+	op STRING NOT NULL,
+	summary STRING NOT NULL,
+	items INT NOT NULL,
+	undone TIMESTAMPTZ NOT NULL,
+	CONSTRAINT change_sets_pk PRIMARY KEY (author, id)
+)`
+
+// CreateTableIfNotExistsChangeSetItemsStmt는 DB에 change_set_items 테이블을 생성하는 sql 구문이다.
+// 테이블은 타입보다 많은 정보를 담고 있을수도 있다.
+var CreateTableIfNotExistsChangeSetItemsStmt = `CREATE TABLE IF NOT EXISTS change_set_items (
+	author STRING NOT NULL CHECK (length(author) > 0),
+	set_id STRING NOT NULL CHECK (length(set_id) > 0),
+	idx INT NOT NULL,
+	kind STRING NOT NULL,
+	show STRING NOT NULL,
+	grp STRING NOT NULL,
+	unit STRING NOT NULL,
+	task STRING NOT NULL,
+	before STRING NOT NULL,
+	after STRING NOT NULL,
+	CONSTRAINT change_set_items_pk PRIMARY KEY (author, set_id, idx)
+)`
+
+// ChangeSet은 여러 유닛이나 태스크를 한번에 바꾼 작업 하나의 기록이다.
+// 바뀐 행들의 이전 모습을 함께 저장해 작업을 되돌릴 수 있게 한다.
+type ChangeSet struct {
+	// Author는 작업을 한 사용자이다. 작업은 그 사용자만 되돌릴 수 있다.
+	Author  string    `db:"author"`
+	ID      string    `db:"id"`
+	Created time.Time `db:"created"`
+	// Op는 작업의 종류이다. 예) update-units, update-tasks, import-units, apply-edit
+	Op string `db:"op"`
+	// Summary는 작업 내용을 사람이 읽을 수 있게 요약한 것이다.
+	Summary string `db:"summary"`
+	// Items는 작업으로 바뀐 행의 수이다.
+	Items int `db:"items"`
+	// Undone은 작업을 되돌린 시간이다. 되돌리지 않았다면 zero 시간이다.
+	Undone time.Time `db:"undone"`
+}
+
+var changeSetDBKey string = strings.Join(dbKeys(&ChangeSet{}), ", ")
+var changeSetDBIdx string = strings.Join(dbIdxs(&ChangeSet{}), ", ")
+var _ []interface{} = dbVals(&ChangeSet{})
+
+// ChangeSetItem은 작업으로 바뀐 유닛 또는 태스크 행 하나의 전후 모습이다.
+type ChangeSetItem struct {
+	Author string `db:"author"`
+	SetID  string `db:"set_id"`
+	Idx    int    `db:"idx"`
+	// Kind는 행의 종류로 unit 또는 task이다.
+	Kind  string `db:"kind"`
+	Show  string `db:"show"`
+	Group string `db:"grp"`
+	Unit  string `db:"unit"`
+	// Task는 태스크 행일 때의 태스크 이름이다. 유닛 행이면 빈 문자열이다.
+	Task string `db:"task"`
+	// Before와 After는 행의 작업 전후 모습을 json으로 나타낸 것이다.
+	// 작업으로 새로 생긴 행은 Before가 빈 문자열이다.
+	Before string `db:"before"`
+	After  string `db:"after"`
+}
+
+var changeSetItemDBKey string = strings.Join(dbKeys(&ChangeSetItem{}), ", ")
+var changeSetItemDBIdx string = strings.Join(dbIdxs(&ChangeSetItem{}), ", ")
+var _ []interface{} = dbVals(&ChangeSetItem{})
+
+// ID는 행의 아이디를 반환한다.
+func (it *ChangeSetItem) ID() string {
+	if it.Kind == "task" {
+		return JoinTaskID(it.Show, it.Group, it.Unit, it.Task)
+	}
+	return JoinUnitID(it.Show, it.Group, it.Unit)
+}
+
+// Created는 작업으로 새로 생긴 행인지를 반환한다.
+func (it *ChangeSetItem) Created() bool {
+	return it.Before == ""
+}
+
+// rowImage는 유닛이나 태스크를 비교할 수 있는 json 문자열로 바꾼다.
+// db를 거치며 달라질 수 있는 부분은 db에서 읽은 모습으로 맞춘다.
+// 시간은 UTC, 마이크로초 단위로 바꾸고 nil 슬라이스와 맵은 빈 값으로 바꾼다.
+func rowImage(v interface{}) (string, error) {
+	c := reflect.ValueOf(cloneRecord(v)).Elem()
+	for i := 0; i < c.NumField(); i++ {
+		f := c.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.Slice:
+			if f.IsNil() {
+				f.Set(reflect.MakeSlice(f.Type(), 0, 0))
+			}
+		case reflect.Map:
+			if f.IsNil() {
+				f.Set(reflect.MakeMap(f.Type()))
+			}
+		case reflect.Struct:
+			if t, ok := f.Interface().(time.Time); ok {
+				f.Set(reflect.ValueOf(t.UTC().Truncate(time.Microsecond)))
+			}
+		}
+	}
+	b, err := json.Marshal(c.Interface())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// changeSetRecorder는 한 트랜잭션으로 여러 행을 바꾸기 전에 바뀔 행들의 전후 모습을 모은다.
+// 행을 바꾸는 구문을 만든 다음, 그 구문을 실행하기 전에 기록해야 한다.
+type changeSetRecorder struct {
+	set   *ChangeSet
+	items []*ChangeSetItem
+}
+
+// newChangeSetRecorder는 author의 새 작업 기록기를 만든다.
+// author가 없는 작업은 되돌릴 사용자가 없으므로 기록하지 않는다.
+func newChangeSetRecorder(author, op string) *changeSetRecorder {
+	now := time.Now()
+	return &changeSetRecorder{
+		set: &ChangeSet{
+			Author:  author,
+			ID:      strconv.FormatInt(now.UnixNano(), 36),
+			Created: now,
+			Op:      op,
+		},
+	}
+}
+
+// add는 행 하나의 전후 모습을 기록한다. old가 nil이면 새로 생기는 행이다.
+func (c *changeSetRecorder) add(kind, show, grp, unit, task string, old, new interface{}) error {
+	if c.set.Author == "" {
+		return nil
+	}
+	it := &ChangeSetItem{
+		Author: c.set.Author,
+		SetID:  c.set.ID,
+		Idx:    len(c.items),
+		Kind:   kind,
+		Show:   show,
+		Group:  grp,
+		Unit:   unit,
+		Task:   task,
+	}
+	var err error
+	if !reflect.ValueOf(old).IsNil() {
+		it.Before, err = rowImage(old)
+		if err != nil {
+			return err
+		}
+	}
+	it.After, err = rowImage(new)
+	if err != nil {
+		return err
+	}
+	c.items = append(c.items, it)
+	return nil
+}
+
+// addUnit은 바뀔 유닛 s를 기록한다. 새로 생기는 유닛이라면 created가 참이어야 한다.
+// 유닛에 새로 등록되어 함께 생기는 태스크도 기록한다.
+func (c *changeSetRecorder) addUnit(db *sql.DB, s *Unit, created bool) error {
+	if c.set.Author == "" {
+		return nil
+	}
+	var old *Unit
+	hasTask := make(map[string]bool)
+	if !created {
+		var err error
+		old, err = GetUnit(db, s.Show, s.Group, s.Unit)
+		if err != nil {
+			return err
+		}
+		stmt := dbStmt("SELECT task FROM tasks WHERE show=$1 AND grp=$2 AND unit=$3", s.Show, s.Group, s.Unit)
+		err = dbQuery(db, stmt, func(rows *sql.Rows) error {
+			var task string
+			err := rows.Scan(&task)
+			if err != nil {
+				return err
+			}
+			hasTask[task] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	err := c.add("unit", s.Show, s.Group, s.Unit, "", old, s)
+	if err != nil {
+		return err
+	}
+	for _, task := range s.Tasks {
+		if hasTask[task] {
+			continue
+		}
+		t, err := newUnitTask(db, s, task)
+		if err != nil {
+			return err
+		}
+		err = c.add("task", s.Show, s.Group, s.Unit, task, (*Task)(nil), t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTask는 바뀔 태스크를 기록한다. old는 db에 있는 바뀌기 전의 태스크이다.
+func (c *changeSetRecorder) addTask(old, t *Task) error {
+	return c.add("task", t.Show, t.Group, t.Unit, t.Task, old, t)
+}
+
+// updateTask는 db에 있거나 같은 작업으로 새로 생기는 태스크 t가 바뀌는 것을 기록한다.
+// 같은 작업으로 새로 생기는 태스크라면 이미 있는 기록의 작업 후 모습을 고친다.
+func (c *changeSetRecorder) updateTask(db *sql.DB, t *Task) error {
+	if c.set.Author == "" {
+		return nil
+	}
+	for _, it := range c.items {
+		if it.Kind == "task" && it.ID() == t.ID() {
+			after, err := rowImage(t)
+			if err != nil {
+				return err
+			}
+			it.After = after
+			return nil
+		}
+	}
+	old, err := GetTask(db, t.Show, t.Group, t.Unit, t.Task)
+	if err != nil {
+		return err
+	}
+	return c.addTask(old, t)
+}
+
+// stmts는 기록을 db에 저장하는 dbStatement를 반환한다.
+// 기록된 행이 없거나 작업자가 없다면 빈 슬라이스를 반환한다.
+func (c *changeSetRecorder) stmts(summary string) []dbStatement {
+	if c.set.Author == "" || len(c.items) == 0 {
+		return []dbStatement{}
+	}
+	c.set.Summary = summary
+	c.set.Items = len(c.items)
+	stmts := []dbStatement{
+		dbStmt(fmt.Sprintf("INSERT INTO change_sets (%s) VALUES (%s)", changeSetDBKey, changeSetDBIdx), dbVals(c.set)...),
+	}
+	for _, it := range c.items {
+		stmts = append(stmts, dbStmt(fmt.Sprintf("INSERT INTO change_set_items (%s) VALUES (%s)", changeSetItemDBKey, changeSetItemDBIdx), dbVals(it)...))
+	}
+	return stmts
+}
+
+// UserChangeSets는 사용자의 작업 기록을 최신 순으로 최대 n개 반환한다.
+func UserChangeSets(db *sql.DB, author string, n int) ([]*ChangeSet, error) {
+	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM change_sets WHERE author=$1 ORDER BY created DESC LIMIT $2", changeSetDBKey), author, n)
+	sets := make([]*ChangeSet, 0)
+	err := dbQuery(db, stmt, func(rows *sql.Rows) error {
+		s := &ChangeSet{}
+		err := scan(rows, s)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// GetChangeSet은 사용자의 작업 기록 하나를 반환한다.
+// 해당 기록이 없다면 nil과 NotFound 에러를 반환한다.
+func GetChangeSet(db *sql.DB, author, id string) (*ChangeSet, error) {
+	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM change_sets WHERE author=$1 AND id=$2", changeSetDBKey), author, id)
+	s := &ChangeSet{}
+	err := dbQueryRow(db, stmt, func(row *sql.Row) error {
+		return scan(row, s)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, NotFound("change set not found: %s/%s", author, id)
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// ChangeSetItems는 작업 기록으로 바뀐 행들을 기록된 순서대로 반환한다.
+func ChangeSetItems(db *sql.DB, author, id string) ([]*ChangeSetItem, error) {
+	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM change_set_items WHERE author=$1 AND set_id=$2 ORDER BY idx", changeSetItemDBKey), author, id)
+	items := make([]*ChangeSetItem, 0)
+	err := dbQuery(db, stmt, func(rows *sql.Rows) error {
+		it := &ChangeSetItem{}
+		err := scan(rows, it)
+		if err != nil {
+			return err
+		}
+		items = append(items, it)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// currentImage는 트랜잭션 안에서 행의 현재 모습을 반환한다. 행이 없다면 빈 문자열을 반환한다.
+// 트랜잭션이 끝날 때까지 다른 곳에서 행을 바꾸지 못하도록 행을 잠근다.
+func currentImage(tx *sql.Tx, it *ChangeSetItem) (string, error) {
+	var v interface{}
+	var stmt dbStatement
+	if it.Kind == "task" {
+		v = &Task{}
+		stmt = dbStmt(fmt.Sprintf("SELECT %s FROM tasks WHERE show=$1 AND grp=$2 AND unit=$3 AND task=$4 FOR UPDATE", taskDBKey), it.Show, it.Group, it.Unit, it.Task)
+	} else {
+		v = &Unit{}
+		stmt = dbStmt(fmt.Sprintf("SELECT %s FROM units WHERE show=$1 AND grp=$2 AND unit=$3 FOR UPDATE", unitDBKey), it.Show, it.Group, it.Unit)
+	}
+	err := scan(tx.QueryRow(stmt.s, stmt.vs...), v)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("%s: %w", it.ID(), err)
+	}
+	return rowImage(v)
+}
+
+// verifyChangeSetItems는 트랜잭션 안에서 작업 이후 다시 바뀌었거나 지워진 행이 있다면
+// BadRequest 에러를 반환한다.
+func verifyChangeSetItems(tx *sql.Tx, items []*ChangeSetItem) error {
+	changed := make([]string, 0)
+	for _, it := range items {
+		cur, err := currentImage(tx, it)
+		if err != nil {
+			return err
+		}
+		if cur != it.After {
+			changed = append(changed, it.ID())
+		}
+	}
+	if len(changed) != 0 {
+		return BadRequest("%d rows changed since the change set, cannot undo: %s", len(changed), strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// UndoChangeSet은 사용자의 작업을 하나의 트랜잭션으로 되돌린다.
+// 바뀐 행들은 작업 전의 모습으로, 작업으로 새로 생긴 행들은 지운다.
+// 작업 이후 다시 바뀌었거나 지워진 행이 하나라도 있다면
+// 아무것도 되돌리지 않고 BadRequest 에러를 반환한다.
+func UndoChangeSet(db *sql.DB, author, id string) error {
+	s, err := GetChangeSet(db, author, id)
+	if err != nil {
+		return err
+	}
+	if !s.Undone.IsZero() {
+		return BadRequest("change set already undone: %s", id)
+	}
+	items, err := ChangeSetItems(db, author, id)
+	if err != nil {
+		return err
+	}
+	verify := func(tx *sql.Tx) error {
+		return verifyChangeSetItems(tx, items)
+	}
+	// 되돌리는 구문을 만들기 전에 한번 검사해 바뀐 행이 있다면 바로 알린다.
+	err = dbExecIf(db, verify, nil)
+	if err != nil {
+		return err
+	}
+	stmts := make([]dbStatement, 0)
+	reason := "undo " + s.Op
+	// 새로 생긴 유닛을 지우면 그 태스크도 지워지므로 태스크는 따로 지우지 않는다.
+	removedUnit := make(map[string]bool)
+	restoredUnit := make(map[string]*Unit)
+	for _, it := range items {
+		if it.Kind != "unit" {
+			continue
+		}
+		if it.Created() {
+			removedUnit[it.ID()] = true
+			continue
+		}
+		u := &Unit{}
+		err := json.Unmarshal([]byte(it.Before), u)
+		if err != nil {
+			return fmt.Errorf("%s: %w", it.ID(), err)
+		}
+		restoredUnit[it.ID()] = u
+	}
+	for _, it := range items {
+		switch {
+		case it.Kind == "unit" && it.Created():
+			n := 0
+			stmt := dbStmt("SELECT count(*) FROM versions WHERE show=$1 AND grp=$2 AND unit=$3", it.Show, it.Group, it.Unit)
+			err := dbQueryRow(db, stmt, func(row *sql.Row) error {
+				return row.Scan(&n)
+			})
+			if err != nil {
+				return err
+			}
+			if n != 0 {
+				return BadRequest("unit has versions since the change set, cannot undo: %s", it.ID())
+			}
+			// 함께 지워지거나 참조하기 전으로 되돌려지는 유닛의 참조는 문제가 되지 않는다.
+			undone := func(u *Unit) bool {
+				if removedUnit[u.ID()] {
+					return true
+				}
+				r, ok := restoredUnit[u.ID()]
+				return ok && !hasString(r.Assets, assetRef(r.Show, it.Show, it.Group, it.Unit))
+			}
+			err = unitMustNotBeReferencedExcept(db, it.Show, it.Group, it.Unit, undone)
+			if err != nil {
+				return err
+			}
+			stmts = append(stmts, deleteUnitStmts(it.Show, it.Group, it.Unit)...)
+		case it.Kind == "unit":
+			u := restoredUnit[it.ID()]
+			st, err := updateUnitStmts(db, u, author, reason)
+			if err != nil {
+				return fmt.Errorf("%s: %w", it.ID(), err)
+			}
+			stmts = append(stmts, st...)
+		case it.Created():
+			if removedUnit[JoinUnitID(it.Show, it.Group, it.Unit)] {
+				continue
+			}
+			stmts = append(stmts,
+				dbStmt("DELETE FROM tasks WHERE show=$1 AND grp=$2 AND unit=$3 AND task=$4", it.Show, it.Group, it.Unit, it.Task),
+				dbStmt("DELETE FROM versions WHERE show=$1 AND grp=$2 AND unit=$3 AND task=$4", it.Show, it.Group, it.Unit, it.Task),
+			)
+		default:
+			t := &Task{}
+			err := json.Unmarshal([]byte(it.Before), t)
+			if err != nil {
+				return fmt.Errorf("%s: %w", it.ID(), err)
+			}
+			st, err := updateTaskStmts(db, t)
+			if err != nil {
+				return fmt.Errorf("%s: %w", it.ID(), err)
+			}
+			stmts = append(stmts, st...)
+		}
+	}
+	stmts = append(stmts, dbStmt("UPDATE change_sets SET undone=$1 WHERE author=$2 AND id=$3", time.Now(), author, id))
+	// 되돌리기 전에 행들이 다시 바뀌지 않았는지는 되돌리는 트랜잭션 안에서 검사해야
+	// 그 사이에 생긴 수정을 덮어쓰지 않는다.
+	return dbExecIf(db, verify, stmts)
+}
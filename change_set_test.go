@@ -0,0 +1,197 @@
+package roi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRowImage(t *testing.T) {
+	// db를 거친 모습과 메모리의 모습이 같은 이미지가 되어야 한다.
+	loc := time.FixedZone("KST", 9*60*60)
+	mem := &Task{Show: "TEST", DueDate: time.Date(2020, 1, 2, 9, 0, 0, 123456789, loc)}
+	db := &Task{Show: "TEST", DueDate: time.Date(2020, 1, 2, 0, 0, 0, 123456000, time.UTC)}
+	a, err := rowImage(mem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := rowImage(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Fatalf("images differ:\n%s\n%s", a, b)
+	}
+	u1, err := rowImage(&Unit{Tags: nil, Attrs: nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u2, err := rowImage(&Unit{Tags: []string{}, Attrs: DBStringMap{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u1 != u2 {
+		t.Fatalf("images differ:\n%s\n%s", u1, u2)
+	}
+	if mem.DueDate.Location() != loc {
+		t.Fatalf("original record modified")
+	}
+}
+
+func TestChangeSet(t *testing.T) {
+	db, err := testDB()
+	if err != nil {
+		t.Fatalf("could not connect to database: %v", err)
+	}
+	err = AddSite(db)
+	if err != nil {
+		t.Fatalf("could not add site: %s", err)
+	}
+	defer func() {
+		err := DeleteSite(db)
+		if err != nil {
+			t.Fatalf("could not delete site: %s", err)
+		}
+	}()
+	err = AddShow(db, testShow)
+	if err != nil {
+		t.Fatalf("could not add show: %s", err)
+	}
+	defer func() {
+		err = DeleteShow(db, testShow.ID())
+		if err != nil {
+			t.Fatalf("could not delete show: %s", err)
+		}
+	}()
+	err = AddGroup(db, testGroup)
+	if err != nil {
+		t.Fatalf("could not add group: %s", err)
+	}
+	u := &Unit{Show: testShow.Show, Group: testGroup.Group, Unit: "0010", Status: StatusInProgress, Tags: []string{"a"}, Assets: []string{}, Tasks: []string{"fx"}}
+	err = AddUnit(db, u)
+	if err != nil {
+		t.Fatalf("could not add unit: %s", err)
+	}
+	want, err := GetUnit(db, u.Show, u.Group, u.Unit)
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	_, err = UpdateUnits(db, []string{u.ID()}, &UnitPatch{Status: StatusHold, Tags: []string{"-a"}, Tasks: []string{"-fx", "+comp"}}, "admin", "")
+	if err != nil {
+		t.Fatalf("could not update units: %s", err)
+	}
+	sets, err := UserChangeSets(db, "admin", 10)
+	if err != nil {
+		t.Fatalf("could not get change sets: %s", err)
+	}
+	if len(sets) != 1 || sets[0].Items != 2 {
+		t.Fatalf("want 1 change set with 2 items (unit and created task), got: %v", sets)
+	}
+	err = UndoChangeSet(db, "admin", sets[0].ID)
+	if err != nil {
+		t.Fatalf("could not undo change set: %s", err)
+	}
+	got, err := GetUnit(db, u.Show, u.Group, u.Unit)
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unit not restored: got %v, want %v", got, want)
+	}
+	_, err = GetTask(db, u.Show, u.Group, u.Unit, "comp")
+	if !errors.As(err, &NotFoundError{}) {
+		t.Fatalf("created task should be removed by undo, got: %v", err)
+	}
+	err = UndoChangeSet(db, "admin", sets[0].ID)
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error when undoing twice, got: %v", err)
+	}
+
+	// 이후 다시 바뀐 항목이 있으면 되돌리지 않는다.
+	_, err = UpdateUnits(db, []string{u.ID()}, &UnitPatch{Tags: []string{"+b"}}, "admin", "")
+	if err != nil {
+		t.Fatalf("could not update units: %s", err)
+	}
+	sets, err = UserChangeSets(db, "admin", 1)
+	if err != nil {
+		t.Fatalf("could not get change sets: %s", err)
+	}
+	_, err = UpdateUnits(db, []string{u.ID()}, &UnitPatch{Status: StatusOmit}, "", "")
+	if err != nil {
+		t.Fatalf("could not update units: %s", err)
+	}
+	err = UndoChangeSet(db, "admin", sets[0].ID)
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error when rows changed since, got: %v", err)
+	}
+	got, err = GetUnit(db, u.Show, u.Group, u.Unit)
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	if got.Status != StatusOmit || !reflect.DeepEqual(got.Tags, []string{"a", "b"}) {
+		t.Fatalf("unit modified by failed undo: %v", got)
+	}
+	err = UndoChangeSet(db, "other", sets[0].ID)
+	if !errors.As(err, &NotFoundError{}) {
+		t.Fatalf("want not found error when undoing other's change set, got: %v", err)
+	}
+
+	// 새로 생긴 애셋을 참조하는 유닛이 함께 지워지거나 참조하기 전으로 되돌려진다면 되돌릴 수 있다.
+	asset := &Unit{Show: testShow.Show, Group: testGroup.Group, Unit: "0040", Status: StatusInProgress, Tags: []string{}, Assets: []string{}, Tasks: []string{}}
+	user := &Unit{Show: testShow.Show, Group: testGroup.Group, Unit: "0050", Status: StatusInProgress, Tags: []string{}, Assets: []string{testGroup.Group + "/0040"}, Tasks: []string{}}
+	cs := newChangeSetRecorder("admin", "test")
+	for _, nu := range []*Unit{asset, user} {
+		err = AddUnit(db, nu)
+		if err != nil {
+			t.Fatalf("could not add unit: %s", err)
+		}
+		added, err := GetUnit(db, nu.Show, nu.Group, nu.Unit)
+		if err != nil {
+			t.Fatalf("could not get unit: %s", err)
+		}
+		err = cs.add("unit", nu.Show, nu.Group, nu.Unit, "", (*Unit)(nil), added)
+		if err != nil {
+			t.Fatalf("could not record unit: %s", err)
+		}
+	}
+	before, err := GetUnit(db, u.Show, u.Group, u.Unit)
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	mod := *before
+	mod.Assets = []string{testGroup.Group + "/0040"}
+	err = UpdateUnit(db, &mod)
+	if err != nil {
+		t.Fatalf("could not update unit: %s", err)
+	}
+	after, err := GetUnit(db, u.Show, u.Group, u.Unit)
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	err = cs.add("unit", u.Show, u.Group, u.Unit, "", before, after)
+	if err != nil {
+		t.Fatalf("could not record unit: %s", err)
+	}
+	err = dbExec(db, cs.stmts("test"))
+	if err != nil {
+		t.Fatalf("could not save change set: %s", err)
+	}
+	err = UndoChangeSet(db, "admin", cs.set.ID)
+	if err != nil {
+		t.Fatalf("could not undo change set with referencing units: %s", err)
+	}
+	for _, nu := range []*Unit{asset, user} {
+		_, err = GetUnit(db, nu.Show, nu.Group, nu.Unit)
+		if !errors.As(err, &NotFoundError{}) {
+			t.Fatalf("created unit should be removed by undo: %s: %v", nu.ID(), err)
+		}
+	}
+	got, err = GetUnit(db, u.Show, u.Group, u.Unit)
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	if len(got.Assets) != 0 {
+		t.Fatalf("unit assets not restored: got %v", got.Assets)
+	}
+}
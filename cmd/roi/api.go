@@ -38,6 +38,27 @@ func apiBadRequest(w http.ResponseWriter, err error) {
 	http.Error(w, string(resp), http.StatusInternalServerError)
 }
 
+// apiUnauthorized는 로그인하지 않은 질의자에게 api를 사용할 수 없음을 알린다.
+func apiUnauthorized(w http.ResponseWriter) {
+	resp, _ := json.Marshal(roi.APIResponse{Err: "unauthorized"})
+	http.Error(w, string(resp), http.StatusUnauthorized)
+}
+
+// apiSessionUser는 api 질의자의 세션 사용자를 반환한다.
+// 세션 사용자가 없다면 질의자에게 이를 알리고 nil을 반환한다.
+// 사용자 이름은 질의자가 보낸 값이 아니라 항상 세션에서 가지고 와야 한다.
+func apiSessionUser(w http.ResponseWriter, r *http.Request) *roi.User {
+	u, err := sessionUser(r)
+	if err != nil {
+		if !errors.As(err, &roi.NotFoundError{}) {
+			log.Printf("could not get session user: %v", err)
+		}
+		apiUnauthorized(w)
+		return nil
+	}
+	return u
+}
+
 // addShowApiHander는 사용자가 api를 통해 프로젝트를 생성할수 있도록 한다.
 // 결과는 roi.APIResponse의 json 형식으로 반환된다.
 func addShowApiHandler(w http.ResponseWriter, r *http.Request) {
@@ -93,7 +114,7 @@ func addUnitApiHandler(w http.ResponseWriter, r *http.Request) {
 		apiInternalServerError(w)
 		return
 	}
-	status := "waiting"
+	status := string(roi.StatusInProgress)
 	v := r.PostFormValue("status")
 	if v != "" {
 		status = v
@@ -112,11 +133,25 @@ func addUnitApiHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		editOrder = int(f)
 	}
+	frames := make(map[string]int)
+	for _, field := range []string{"cut_in", "cut_out", "head_handle", "tail_handle"} {
+		v := r.PostFormValue(field)
+		if v == "" {
+			continue
+		}
+		// edit_order와 같은 이유로 실수형으로 받은 후 정수로 변환한다.
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			apiBadRequest(w, fmt.Errorf("could not convert %s to int: %s", field, v))
+			return
+		}
+		frames[field] = int(f)
+	}
 	tasks := fieldSplit(r.FormValue("tasks"))
 	if len(tasks) == 0 {
 		g, err := roi.GetGroup(DB, show, grp)
 		if err != nil {
-			handleError(w, err)
+			handleError(w, err, "")
 			return
 		}
 		tasks = g.DefaultTasks
@@ -140,6 +175,10 @@ func addUnitApiHandler(w http.ResponseWriter, r *http.Request) {
 		Unit:          unit,
 		Status:        roi.Status(status),
 		EditOrder:     editOrder,
+		CutIn:         frames["cut_in"],
+		CutOut:        frames["cut_out"],
+		HeadHandle:    frames["head_handle"],
+		TailHandle:    frames["tail_handle"],
 		Description:   r.PostFormValue("description"),
 		CGDescription: r.PostFormValue("cg_description"),
 		Tags:          fieldSplit(r.PostFormValue("tags")),
@@ -148,6 +187,10 @@ func addUnitApiHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	err = roi.AddUnit(DB, s)
 	if err != nil {
+		if errors.As(err, &roi.BadRequestError{}) {
+			apiBadRequest(w, err)
+			return
+		}
 		log.Printf("could not add unit: %v", err)
 		apiInternalServerError(w)
 		return
@@ -155,6 +198,13 @@ func addUnitApiHandler(w http.ResponseWriter, r *http.Request) {
 	apiOK(w, fmt.Sprintf("successfully add a unit: '%s'", unit))
 }
 
+// apiUnit은 api 응답에 사용되는 유닛 정보이다.
+// 프레임 구간에서 계산되는 컷 길이를 함께 반환한다.
+type apiUnit struct {
+	*roi.Unit
+	Duration int
+}
+
 // getUnitApiHander는 사용자가 api를 통해 샷 정보를 받을수 있도록 한다.
 // id 필드가 여럿 있다면 그 순서대로 샷 정보를 반환한다.
 // 결과는 roi.APIResponse의 json 형식으로 반환된다.
@@ -166,7 +216,7 @@ func getUnitApiHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ids := r.Form["id"]
-	ss := make(map[string]*roi.Unit)
+	ss := make(map[string]*apiUnit)
 	for _, id := range ids {
 		show, grp, unit, err := roi.SplitUnitID(id)
 		if err != nil {
@@ -178,7 +228,10 @@ func getUnitApiHandler(w http.ResponseWriter, r *http.Request) {
 			apiBadRequest(w, err)
 			return
 		}
-		ss[id] = s
+		ss[id] = &apiUnit{
+			Unit:     s,
+			Duration: s.Duration(),
+		}
 	}
 	apiOK(w, ss)
 }
@@ -195,19 +248,208 @@ func getUnitTasksApiHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ids := r.Form["id"]
-	allTs := make(map[string][]*roi.Task)
+	// 태스크는 쇼별로 한번에 불러온다.
+	showIDs := make(map[string][]string)
 	for _, id := range ids {
-		show, grp, unit, err := roi.SplitUnitID(id)
+		show, _, _, err := roi.SplitUnitID(id)
 		if err != nil {
 			apiBadRequest(w, fmt.Errorf("invalid unit id: %v", id))
 			return
 		}
-		ts, err := roi.UnitTasks(DB, show, grp, unit)
+		showIDs[show] = append(showIDs[show], id)
+	}
+	allTs := make(map[string][]*roi.Task)
+	for show, sids := range showIDs {
+		tm, err := roi.TasksOfUnits(DB, show, sids)
 		if err != nil {
 			apiBadRequest(w, err)
 			return
 		}
-		allTs[id] = ts
+		for _, id := range sids {
+			ts, ok := tm[id]
+			if !ok {
+				apiBadRequest(w, fmt.Errorf("unit not found: %s", id))
+				return
+			}
+			allTs[id] = ts
+		}
 	}
 	apiOK(w, allTs)
 }
+
+// apiVersion은 api 응답에 사용되는 버전 정보이다.
+// 결과물과 이미지는 문자열 대신 시퀀스 구조로 반환된다.
+type apiVersion struct {
+	*roi.Version
+	OutputFiles []*roi.Sequence
+	Images      []*roi.Sequence
+}
+
+// getVersionApiHander는 사용자가 api를 통해 버전 정보를 받을수 있도록 한다.
+// id 필드가 여럿 있다면 그 순서대로 버전 정보를 반환한다.
+// 결과는 roi.APIResponse의 json 형식으로 반환된다.
+func getVersionApiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := mustFields(r, "id")
+	if err != nil {
+		apiBadRequest(w, err)
+		return
+	}
+	ids := r.Form["id"]
+	vs := make(map[string]*apiVersion)
+	for _, id := range ids {
+		show, grp, unit, task, ver, err := roi.SplitVersionID(id)
+		if err != nil {
+			apiBadRequest(w, fmt.Errorf("invalid version id: %v", id))
+			return
+		}
+		v, err := roi.GetVersion(DB, show, grp, unit, task, ver)
+		if err != nil {
+			apiBadRequest(w, err)
+			return
+		}
+		vs[id] = &apiVersion{
+			Version:     v,
+			OutputFiles: v.OutputSequences(),
+			Images:      v.ImageSequences(),
+		}
+	}
+	apiOK(w, vs)
+}
+
+// apiCacheMetrics는 api 응답에 사용되는 레코드 캐시의 상태이다.
+type apiCacheMetrics struct {
+	Enabled bool
+	Metrics map[string]roi.CacheMetric
+}
+
+// cacheMetricsApiHandler는 사이트, 쇼, 그룹 레코드 캐시의 종류별 적중 통계를 반환한다.
+// 결과는 roi.APIResponse의 json 형식으로 반환된다.
+func cacheMetricsApiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	apiOK(w, apiCacheMetrics{
+		Enabled: roi.CacheEnabled(),
+		Metrics: roi.CacheMetrics(),
+	})
+}
+
+// apiStatuses는 api 응답에 사용되는 사이트의 상태 정의이다.
+type apiStatuses struct {
+	Statuses     []*roi.StatusDef
+	ShowStatuses []*roi.StatusDef
+}
+
+// statusesApiHandler는 사이트에 정의된 유닛 및 태스크 상태와 쇼 상태의
+// 레이블, 색상, 분류를 반환한다.
+// 결과는 roi.APIResponse의 json 형식으로 반환된다.
+func statusesApiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	site, err := roi.GetSite(DB)
+	if err != nil {
+		log.Printf("could not get site: %v", err)
+		apiInternalServerError(w)
+		return
+	}
+	apiOK(w, apiStatuses{
+		Statuses:     site.StatusDefs(),
+		ShowStatuses: site.ShowStatusDefs(),
+	})
+}
+
+// apiBulkResults는 여러 항목을 한번에 수정한 결과를 항목별로 반환한다.
+// 에러가 있는 항목이 있어 아무것도 수정되지 않았다면 항목별 결과와 함께 에러를 반환한다.
+func apiBulkResults(w http.ResponseWriter, results []*roi.BulkResult, err error) {
+	if err == nil {
+		apiOK(w, results)
+		return
+	}
+	if !errors.As(err, &roi.BadRequestError{}) {
+		log.Printf("could not update items: %v", err)
+		apiInternalServerError(w)
+		return
+	}
+	if results == nil {
+		apiBadRequest(w, err)
+		return
+	}
+	resp, _ := json.Marshal(roi.APIResponse{Msg: results, Err: err.Error()})
+	http.Error(w, string(resp), http.StatusBadRequest)
+}
+
+// updateUnitsApiHandler는 여러 유닛(id)에 같은 수정 사항을 하나의 트랜잭션으로 적용한다.
+// status, due_date, cut_in, head_handle, tail_handle 중 비어있지 않은 필드를 수정하며,
+// tags, assets, tasks는 쉼표로 구분된 +값, -값 목록으로 값을 더하거나 지운다.
+// 컷 정보가 바뀌면 세션 사용자와 reason을 변경 기록에 남긴다.
+// 결과는 유닛별 roi.BulkResult를 담은 roi.APIResponse의 json 형식으로 반환된다.
+func updateUnitsApiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	u := apiSessionUser(w, r)
+	if u == nil {
+		return
+	}
+	err := mustFields(r, "id")
+	if err != nil {
+		apiBadRequest(w, err)
+		return
+	}
+	tforms, err := parseTimeForms(r.Form, "due_date")
+	if err != nil {
+		apiBadRequest(w, err)
+		return
+	}
+	p := &roi.UnitPatch{
+		Status:  roi.Status(r.FormValue("status")),
+		DueDate: tforms["due_date"],
+	}
+	for field, ptr := range map[string]**int{"cut_in": &p.CutIn, "head_handle": &p.HeadHandle, "tail_handle": &p.TailHandle} {
+		v := strings.TrimSpace(r.FormValue(field))
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			apiBadRequest(w, fmt.Errorf("invalid %s: %s", field, v))
+			return
+		}
+		*ptr = &n
+	}
+	for field, list := range map[string]*[]string{"tag": &p.Tags, "asset": &p.Assets, "task": &p.Tasks} {
+		vals, err := roi.ParsePatchList(field, r.FormValue(field+"s"))
+		if err != nil {
+			apiBadRequest(w, err)
+			return
+		}
+		*list = vals
+	}
+	results, err := roi.UpdateUnits(DB, r.Form["id"], p, u.ID, r.FormValue("reason"))
+	apiBulkResults(w, results, err)
+}
+
+// updateTasksApiHandler는 여러 태스크(id)에 같은 수정 사항을 하나의 트랜잭션으로 적용한다.
+// status, due_date, assignee 중 비어있지 않은 필드를 수정하며, db에 없는 태스크는 건너뛴다.
+// 상태 전환은 세션 사용자의 권한으로 검사하며, 세션 사용자가 수정을 되돌릴 수 있다.
+// 결과는 태스크별 roi.BulkResult를 담은 roi.APIResponse의 json 형식으로 반환된다.
+func updateTasksApiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	u := apiSessionUser(w, r)
+	if u == nil {
+		return
+	}
+	err := mustFields(r, "id")
+	if err != nil {
+		apiBadRequest(w, err)
+		return
+	}
+	tforms, err := parseTimeForms(r.Form, "due_date")
+	if err != nil {
+		apiBadRequest(w, err)
+		return
+	}
+	p := &roi.TaskPatch{
+		Status:   roi.Status(r.FormValue("status")),
+		DueDate:  tforms["due_date"],
+		Assignee: r.FormValue("assignee"),
+	}
+	results, err := roi.UpdateTasks(DB, r.Form["id"], p, u.ID)
+	apiBulkResults(w, results, err)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/studio2l/roi"
+)
+
+// assetHandler는 유닛을 애셋으로 참조하는 유닛들을 보인다.
+// 라이브러리 애셋이라면 그를 쓰는 쇼로 복사할 수 있고,
+// 참조를 지우거나 참조와 함께 애셋을 지울 수 있다.
+func assetHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	err := mustFields(r, "id")
+	if err != nil {
+		return err
+	}
+	id := r.FormValue("id")
+	show, grp, unit, err := roi.SplitUnitID(id)
+	if err != nil {
+		return err
+	}
+	a, err := roi.GetUnit(DB, show, grp, unit)
+	if err != nil {
+		return err
+	}
+	users, err := roi.AssetUsers(DB, show, grp, unit)
+	if err != nil {
+		return err
+	}
+	si, err := roi.GetSite(DB)
+	if err != nil {
+		return err
+	}
+	library := si.LibraryShow != "" && si.LibraryShow == show
+	// 라이브러리 애셋은 그를 쓰는 다른 쇼로 복사할 수 있다.
+	// users는 쇼 이름 순으로 정렬되어 있다.
+	forkShows := []string{}
+	if library {
+		for _, u := range users {
+			if u.Show == show {
+				continue
+			}
+			if len(forkShows) == 0 || forkShows[len(forkShows)-1] != u.Show {
+				forkShows = append(forkShows, u.Show)
+			}
+		}
+	}
+	recipe := struct {
+		Env       *Env
+		Asset     *roi.Unit
+		Users     []*roi.Unit
+		Library   bool
+		ForkShows []string
+	}{
+		Env:       env,
+		Asset:     a,
+		Users:     users,
+		Library:   library,
+		ForkShows: forkShows,
+	}
+	return executeTemplate(w, "asset", recipe)
+}
+
+// forkAssetHandler는 라이브러리 애셋을 쇼의 애셋으로 복사하고
+// 그 쇼에서 라이브러리 애셋을 참조하던 유닛들이 복사된 애셋을 참조하게 한다.
+func forkAssetHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	if r.Method != "POST" {
+		return roi.BadRequest("only post method allowed")
+	}
+	err := mustFields(r, "id", "show")
+	if err != nil {
+		return err
+	}
+	u, err := roi.ForkLibraryAsset(DB, r.FormValue("id"), r.FormValue("show"))
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, r, "/update-unit?id="+u.ID(), http.StatusSeeOther)
+	return nil
+}
+
+// detachAssetHandler는 애셋을 참조하는 모든 유닛에서 그 참조를 지운다.
+func detachAssetHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	if r.Method != "POST" {
+		return roi.BadRequest("only post method allowed")
+	}
+	err := mustFields(r, "id")
+	if err != nil {
+		return err
+	}
+	id := r.FormValue("id")
+	show, grp, unit, err := roi.SplitUnitID(id)
+	if err != nil {
+		return err
+	}
+	err = roi.DetachAsset(DB, show, grp, unit)
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, r, "/asset?id="+id, http.StatusSeeOther)
+	return nil
+}
+
+// deleteUnitHandler는 유닛을 지운다.
+// 다른 유닛이 애셋으로 참조하고 있다면 detach 필드가 있을 때만 참조와 함께 지운다.
+func deleteUnitHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	if r.Method != "POST" {
+		return roi.BadRequest("only post method allowed")
+	}
+	err := mustFields(r, "id")
+	if err != nil {
+		return err
+	}
+	show, grp, unit, err := roi.SplitUnitID(r.FormValue("id"))
+	if err != nil {
+		return err
+	}
+	if r.FormValue("detach") != "" {
+		err = roi.DetachAndDeleteUnit(DB, show, grp, unit)
+	} else {
+		err = roi.DeleteUnit(DB, show, grp, unit)
+	}
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, r, "/units?show="+url.QueryEscape(show), http.StatusSeeOther)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/studio2l/roi"
+)
+
+// attrField는 폼에서 정의된 커스텀 속성 하나의 입력이다.
+type attrField struct {
+	Def   *roi.AttrDef
+	Value string
+}
+
+// attrForm은 attr-inputs 템플릿에서 커스텀 속성을 입력받기 위한 정보이다.
+// 정의된 속성은 타입에 맞는 입력으로, 정의되지 않은 속성은 Extra로 나누어 보인다.
+type attrForm struct {
+	Env    *Env
+	Fields []*attrField
+	Extra  roi.DBStringMap
+}
+
+// newAttrForm은 템플릿에서 속성 정의와 속성 값으로 attrForm을 만드는 함수이다.
+func newAttrForm(env *Env, defs []*roi.AttrDef, attrs roi.DBStringMap) *attrForm {
+	f := &attrForm{
+		Env:    env,
+		Fields: make([]*attrField, 0, len(defs)),
+		Extra:  make(roi.DBStringMap),
+	}
+	defined := make(map[string]bool)
+	for _, d := range defs {
+		defined[d.Name] = true
+		f.Fields = append(f.Fields, &attrField{Def: d, Value: attrs[d.Name]})
+	}
+	for k, v := range attrs {
+		if !defined[k] {
+			f.Extra[k] = v
+		}
+	}
+	return f
+}
+
+// attrsFromForm은 폼으로 받은 커스텀 속성을 반환한다.
+// 정의된 속성은 attr.<이름> 필드에서, 그 외의 속성은 여러줄의 키: 값 쌍인 attrs 필드에서 가져온다.
+// 값의 검사는 로이에서 저장할 때 한다.
+func attrsFromForm(r *http.Request, defs []*roi.AttrDef) roi.DBStringMap {
+	attrs := make(roi.DBStringMap)
+	for _, ln := range strings.Split(r.FormValue("attrs"), "\n") {
+		kv := strings.SplitN(ln, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		k := strings.TrimSpace(kv[0])
+		v := strings.TrimSpace(kv[1])
+		if k == "" || v == "" {
+			continue
+		}
+		attrs[k] = v
+	}
+	for _, d := range defs {
+		v := strings.TrimSpace(r.FormValue("attr." + d.Name))
+		if v == "" {
+			continue
+		}
+		attrs[d.Name] = v
+	}
+	return attrs
+}
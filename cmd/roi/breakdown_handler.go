@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/studio2l/roi"
+)
+
+// breakdownHandler는 쇼의 샷이 어떤 애셋을 쓰는지 표로 보인다.
+// 표에서 샷이 쓰는 애셋을 고친 뒤 POST로 보내면 한번에 수정한다.
+func breakdownHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	if r.Method == "POST" {
+		return breakdownPostHandler(w, r, env)
+	}
+	err := mustFields(r, "show")
+	if err != nil {
+		return err
+	}
+	b, err := roi.GetBreakdown(DB, r.FormValue("show"))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Cache-control", "no-cache")
+	recipe := struct {
+		Env       *Env
+		Breakdown *roi.Breakdown
+	}{
+		Env:       env,
+		Breakdown: b,
+	}
+	return executeTemplate(w, "breakdown", recipe)
+}
+
+// breakdownPostHandler는 표에 보였던 샷들이 쓰는 애셋을 하나의 트랜잭션으로 수정한다.
+// 각 샷은 shot 필드로, 샷이 쓰는 애셋은 assets.<샷 아이디> 필드로 전달된다.
+// 표를 불러왔을 때 샷이 쓰던 애셋은 old.<샷 아이디> 필드로 전달되어
+// 그 사이 다른 사용자가 바꾼 샷을 덮어쓰지 않도록 한다.
+func breakdownPostHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	err := mustFields(r, "show")
+	if err != nil {
+		return err
+	}
+	show := r.FormValue("show")
+	uses := make(map[string][]string)
+	loaded := make(map[string][]string)
+	for _, id := range r.Form["shot"] {
+		uses[id] = r.Form["assets."+id]
+		loaded[id] = r.Form["old."+id]
+	}
+	_, err = roi.UpdateBreakdown(DB, show, uses, loaded, env.User.ID)
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, r, "/breakdown?show="+url.QueryEscape(show), http.StatusSeeOther)
+	return nil
+}
+
+// exportBreakdownHandler는 쇼의 브레이크다운 표를 엑셀 파일로 내려받게 한다.
+func exportBreakdownHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	err := mustFields(r, "show")
+	if err != nil {
+		return err
+	}
+	show := r.FormValue("show")
+	b, err := roi.GetBreakdown(DB, show)
+	if err != nil {
+		return err
+	}
+	xl := excelize.NewFile()
+	for i, row := range b.Table() {
+		for j, cell := range row {
+			axis := excelize.ToAlphaString(j) + fmt.Sprint(i+1)
+			xl.SetCellStr("Sheet1", axis, cell)
+		}
+	}
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-breakdown.xlsx", show))
+	return xl.Write(w)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/studio2l/roi"
+)
+
+// changeSetsPageSize는 작업 기록 페이지에서 보여줄 최근 작업의 수이다.
+const changeSetsPageSize = 50
+
+// changeSetsHandler는 사용자의 최근 여러 항목 수정 작업들을 보여준다.
+// id가 있다면 그 작업으로 바뀐 항목들을 함께 보여주며,
+// POST 요청에서는 id의 작업을 되돌린다.
+func changeSetsHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	undoErr := ""
+	if r.Method == "POST" {
+		err := mustFields(r, "id")
+		if err != nil {
+			return err
+		}
+		err = roi.UndoChangeSet(DB, env.User.ID, r.FormValue("id"))
+		if err == nil {
+			http.Redirect(w, r, "/change-sets", http.StatusSeeOther)
+			return nil
+		}
+		if !errors.As(err, &roi.BadRequestError{}) {
+			return err
+		}
+		// 되돌릴 수 없는 작업은 어떤 항목 때문인지 페이지에 표시한다.
+		undoErr = err.Error()
+	}
+	sets, err := roi.UserChangeSets(DB, env.User.ID, changeSetsPageSize)
+	if err != nil {
+		return err
+	}
+	id := r.FormValue("id")
+	var items []*roi.ChangeSetItem
+	if id != "" {
+		items, err = roi.ChangeSetItems(DB, env.User.ID, id)
+		if err != nil {
+			return err
+		}
+	}
+	recipe := struct {
+		Env        *Env
+		ChangeSets []*roi.ChangeSet
+		ID         string
+		Items      []*roi.ChangeSetItem
+		UndoError  string
+	}{
+		Env:        env,
+		ChangeSets: sets,
+		ID:         id,
+		Items:      items,
+		UndoError:  undoErr,
+	}
+	return executeTemplate(w, "change-sets", recipe)
+}
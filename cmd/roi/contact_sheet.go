@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/studio2l/roi"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// contactSheetFont는 컨택트 시트의 글자를 그릴 때 사용할 폰트이다.
+// nil이면 영문만 그릴 수 있는 기본 폰트를 사용한다.
+// 한글 설명을 보이려면 프로그램 시작시 -font 플래그로 한글 폰트를 지정해야 한다.
+var contactSheetFont *opentype.Font
+
+// loadContactSheetFont는 ttf 또는 otf 폰트 파일을 읽어 컨택트 시트에서 사용하도록 한다.
+func loadContactSheetFont(fontFile string) error {
+	data, err := ioutil.ReadFile(fontFile)
+	if err != nil {
+		return err
+	}
+	ft, err := opentype.Parse(data)
+	if err != nil {
+		return fmt.Errorf("could not parse font: %w", err)
+	}
+	contactSheetFont = ft
+	return nil
+}
+
+// paperSizes는 컨택트 시트가 지원하는 종이 크기를 인치 단위로 정의한다.
+// 세로 방향 기준이다.
+var paperSizes = map[string][2]float64{
+	"a4":     {8.27, 11.69},
+	"a3":     {11.69, 16.54},
+	"letter": {8.5, 11},
+}
+
+// contactSheetOptions는 컨택트 시트의 레이아웃 설정이다.
+type contactSheetOptions struct {
+	Title     string
+	Cols      int
+	Rows      int
+	Paper     string
+	Landscape bool
+	DPI       int
+	// Lang은 상태 레이블을 번역할 언어이다.
+	Lang string
+}
+
+// maxContactSheetPixels는 한 컨택트 시트의 모든 페이지 픽셀 수의 합의 상한이다.
+// 유닛이 많을수록, DPI가 높을수록 페이지를 그리고 압축하는 데 메모리와 시간이 드므로
+// 이를 넘는 요청은 유닛 수나 DPI를 줄이도록 한다. 300 DPI A4 페이지 약 20장 정도이다.
+const maxContactSheetPixels = 180 * 1000 * 1000
+
+// verify는 설정이 유효하지 않다면 에러를 반환한다.
+func (o *contactSheetOptions) verify() error {
+	if o.Cols < 1 || o.Cols > 10 {
+		return roi.BadRequest("contact sheet columns should be in 1-10: got %d", o.Cols)
+	}
+	if o.Rows < 1 || o.Rows > 10 {
+		return roi.BadRequest("contact sheet rows should be in 1-10: got %d", o.Rows)
+	}
+	if _, ok := paperSizes[o.Paper]; !ok {
+		return roi.BadRequest("unknown paper size: %s", o.Paper)
+	}
+	if o.DPI < 72 || o.DPI > 300 {
+		return roi.BadRequest("contact sheet dpi should be in 72-300: got %d", o.DPI)
+	}
+	return nil
+}
+
+// numPages는 n개의 유닛을 그릴 때 필요한 페이지 수를 반환한다. 유닛이 없어도 한 페이지를 그린다.
+func (o *contactSheetOptions) numPages(n int) int {
+	perPage := o.Cols * o.Rows
+	nPages := (n + perPage - 1) / perPage
+	if nPages == 0 {
+		nPages = 1
+	}
+	return nPages
+}
+
+// verifySize는 n개의 유닛을 그린 컨택트 시트가 maxContactSheetPixels보다 크다면 에러를 반환한다.
+func (o *contactSheetOptions) verifySize(n int) error {
+	pw, ph := o.pageSize()
+	if o.numPages(n)*pw*ph > maxContactSheetPixels {
+		return roi.BadRequest("contact sheet too large: reduce the number of units (%d) or dpi (%d)", n, o.DPI)
+	}
+	return nil
+}
+
+// pageSize는 설정에 따른 페이지의 픽셀 크기를 반환한다.
+func (o *contactSheetOptions) pageSize() (int, int) {
+	sz := paperSizes[o.Paper]
+	w := int(sz[0] * float64(o.DPI))
+	h := int(sz[1] * float64(o.DPI))
+	if o.Landscape {
+		w, h = h, w
+	}
+	return w, h
+}
+
+// contactSheetUnit은 컨택트 시트의 한 칸에 들어갈 정보이다.
+type contactSheetUnit struct {
+	Unit  *roi.Unit
+	Tasks []*roi.Task
+}
+
+// cssColors는 상태 정의에 쓰이는 색상 이름의 실제 색상이다.
+// static/roi.css에 정의된 색상과 같다.
+var cssColors = map[string]color.RGBA{
+	"red":        {0xdb, 0x28, 0x28, 0xff},
+	"orange":     {0xf2, 0x71, 0x1c, 0xff},
+	"yellow":     {0xfb, 0xbd, 0x08, 0xff},
+	"olive":      {0xb5, 0xcc, 0x18, 0xff},
+	"green":      {0x21, 0xba, 0x45, 0xff},
+	"teal":       {0x00, 0xb5, 0xad, 0xff},
+	"blue":       {0x21, 0x85, 0xd0, 0xff},
+	"violet":     {0x64, 0x35, 0xc9, 0xff},
+	"purple":     {0xa3, 0x33, 0xc8, 0xff},
+	"pink":       {0xe0, 0x39, 0x97, 0xff},
+	"brown":      {0xa5, 0x67, 0x3f, 0xff},
+	"grey":       {0x76, 0x76, 0x76, 0xff},
+	"black":      {0x1b, 0x1c, 0x1d, 0xff},
+	"magenta":    {0xe0, 0x3c, 0xe0, 0xff},
+	"crimson":    {0xdc, 0x14, 0x3c, 0xff},
+	"aquamarine": {0x7f, 0xff, 0xd4, 0xff},
+}
+
+// statusText는 사이트에 정의된 상태의 레이블을 lang 언어로 번역해 반환한다.
+func statusText(site *roi.Site, s roi.Status, lang string) string {
+	return translate(lang, site.StatusDef(s).Label)
+}
+
+// statusRGBA는 사이트에 정의된 상태의 색상을 실제 색상으로 반환한다.
+func statusRGBA(site *roi.Site, s roi.Status) color.RGBA {
+	return cssColor(site.StatusDef(s).Color)
+}
+
+// cssColor는 색상 이름이나 #rrggbb 형식의 색상을 실제 색상으로 반환한다.
+// 알 수 없는 색상이면 밝은 회색을 반환한다.
+func cssColor(c string) color.RGBA {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(c, "#%02x%02x%02x", &r, &g, &b); err == nil {
+		return color.RGBA{r, g, b, 0xff}
+	}
+	rgba, ok := cssColors[c]
+	if !ok {
+		return color.RGBA{0xcc, 0xcc, 0xcc, 0xff}
+	}
+	return rgba
+}
+
+// contactSheetFace는 해당 픽셀 크기의 폰트 페이스를 반환한다.
+func contactSheetFace(px float64) (font.Face, error) {
+	if contactSheetFont == nil {
+		return basicfont.Face7x13, nil
+	}
+	return opentype.NewFace(contactSheetFont, &opentype.FaceOptions{
+		Size:    px,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+}
+
+// renderContactSheetPage는 유닛들을 설정에 맞게 배치한 페이지 중 p번째(0부터) 페이지만 그린다.
+// 페이지를 하나씩 그려 모든 페이지를 한번에 메모리에 두지 않게 한다.
+// 상태 색상과 레이블은 사이트의 상태 정의를 따른다.
+func renderContactSheetPage(site *roi.Site, units []*contactSheetUnit, opt *contactSheetOptions, p int) (*image.RGBA, error) {
+	err := opt.verify()
+	if err != nil {
+		return nil, err
+	}
+	nPages := opt.numPages(len(units))
+	if p < 0 || p >= nPages {
+		return nil, roi.BadRequest("contact sheet has %d pages: got page %d", nPages, p+1)
+	}
+	pw, ph := opt.pageSize()
+	margin := opt.DPI * 4 / 10
+	gap := opt.DPI / 8
+	headerH := opt.DPI / 3
+	cellW := (pw - 2*margin - (opt.Cols-1)*gap) / opt.Cols
+	cellH := (ph - 2*margin - headerH - (opt.Rows-1)*gap) / opt.Rows
+	titleFace, err := contactSheetFace(float64(opt.DPI) / 6)
+	if err != nil {
+		return nil, err
+	}
+	textFace, err := contactSheetFace(float64(opt.DPI) / 9)
+	if err != nil {
+		return nil, err
+	}
+	perPage := opt.Cols * opt.Rows
+	img := image.NewRGBA(image.Rect(0, 0, pw, ph))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	header := fmt.Sprintf("%s    %s    %d/%d", opt.Title, time.Now().Format("2006-01-02"), p+1, nPages)
+	drawText(img, titleFace, header, margin, margin+headerH/2, color.Black)
+	for i := 0; i < perPage; i++ {
+		n := p*perPage + i
+		if n >= len(units) {
+			break
+		}
+		col := i % opt.Cols
+		row := i / opt.Cols
+		x := margin + col*(cellW+gap)
+		y := margin + headerH + row*(cellH+gap)
+		drawContactSheetCell(img, image.Rect(x, y, x+cellW, y+cellH), site, units[n], opt.Lang, titleFace, textFace)
+	}
+	return img, nil
+}
+
+// drawContactSheetCell은 한 유닛의 정보를 r 영역에 그린다.
+// 상태는 lang 언어로 번역된 레이블로 보인다.
+func drawContactSheetCell(img *image.RGBA, r image.Rectangle, site *roi.Site, u *contactSheetUnit, lang string, titleFace, textFace font.Face) {
+	border := color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+	drawRect(img, r, border)
+	pad := r.Dx() / 40
+	if pad < 2 {
+		pad = 2
+	}
+	inner := r.Inset(pad)
+	// 썸네일은 16:9 비율로 그린다.
+	thumbH := inner.Dx() * 9 / 16
+	if thumbH > inner.Dy()/2 {
+		thumbH = inner.Dy() / 2
+	}
+	thumbR := image.Rect(inner.Min.X, inner.Min.Y, inner.Max.X, inner.Min.Y+thumbH)
+	drawThumbnail(img, thumbR, u.Unit.ID())
+
+	titleH := titleFace.Metrics().Height.Ceil()
+	lineH := textFace.Metrics().Height.Ceil()
+	y := thumbR.Max.Y + pad
+	// 유닛 상태 색상을 제목 옆의 막대로 표현한다.
+	barW := pad * 2
+	draw.Draw(img, image.Rect(inner.Min.X, y, inner.Min.X+barW, y+titleH), &image.Uniform{statusRGBA(site, u.Unit.Status)}, image.Point{}, draw.Src)
+	drawText(img, titleFace, u.Unit.Group+"/"+u.Unit.Unit, inner.Min.X+barW+pad, y+titleFace.Metrics().Ascent.Ceil(), color.Black)
+	y += titleH
+	drawText(img, textFace, statusText(site, u.Unit.Status, lang), inner.Min.X, y+textFace.Metrics().Ascent.Ceil(), color.RGBA{0x55, 0x55, 0x55, 0xff})
+	y += lineH
+	// 아래쪽에 태스크 줄을 위한 공간을 남겨두고 설명을 쓴다.
+	taskLines := (len(u.Tasks) + 1) / 2
+	descLines := (inner.Max.Y - y - taskLines*lineH) / lineH
+	for _, l := range wrapText(textFace, u.Unit.Description, inner.Dx(), descLines) {
+		drawText(img, textFace, l, inner.Min.X, y+textFace.Metrics().Ascent.Ceil(), color.RGBA{0x33, 0x33, 0x33, 0xff})
+		y += lineH
+	}
+	// 태스크는 두 열로 나누어 상태 색상과 함께 보인다.
+	y = inner.Max.Y - taskLines*lineH
+	colW := inner.Dx() / 2
+	dot := lineH / 2
+	for i, t := range u.Tasks {
+		x := inner.Min.X + (i%2)*colW
+		ty := y + (i/2)*lineH
+		dy := ty + (lineH-dot)/2
+		draw.Draw(img, image.Rect(x, dy, x+dot, dy+dot), &image.Uniform{statusRGBA(site, t.Status)}, image.Point{}, draw.Src)
+		label := t.Task + " " + statusText(site, t.Status, lang)
+		if t.Assignee != "" {
+			label += " (" + t.Assignee + ")"
+		}
+		drawText(img, textFace, label, x+dot+pad, ty+textFace.Metrics().Ascent.Ceil(), color.Black)
+	}
+}
+
+// drawThumbnail은 유닛의 썸네일을 r 영역에 비율을 유지하여 그린다.
+// 썸네일이 없다면 회색 상자를 그린다.
+func drawThumbnail(img *image.RGBA, r image.Rectangle, id string) {
+	draw.Draw(img, r, &image.Uniform{color.RGBA{0xbb, 0xbb, 0xbb, 0xff}}, image.Point{}, draw.Src)
+	f, err := os.Open(fmt.Sprintf("data/show/%s/thumbnail.png", id))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	thumb, err := png.Decode(f)
+	if err != nil {
+		return
+	}
+	tb := thumb.Bounds()
+	if tb.Dx() == 0 || tb.Dy() == 0 {
+		return
+	}
+	w := r.Dx()
+	h := tb.Dy() * w / tb.Dx()
+	if h > r.Dy() {
+		h = r.Dy()
+		w = tb.Dx() * h / tb.Dy()
+	}
+	x := r.Min.X + (r.Dx()-w)/2
+	y := r.Min.Y + (r.Dy()-h)/2
+	draw.CatmullRom.Scale(img, image.Rect(x, y, x+w, y+h), thumb, tb, draw.Src, nil)
+}
+
+// drawRect는 r 영역의 테두리를 그린다.
+func drawRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	for x := r.Min.X; x < r.Max.X; x++ {
+		img.Set(x, r.Min.Y, c)
+		img.Set(x, r.Max.Y-1, c)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		img.Set(r.Min.X, y, c)
+		img.Set(r.Max.X-1, y, c)
+	}
+}
+
+// drawText는 (x, y)를 베이스라인 시작점으로 하여 문자열을 그린다.
+func drawText(img *image.RGBA, face font.Face, s string, x, y int, c color.Color) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  &image.Uniform{c},
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+// wrapText는 문자열을 폭 width에 맞게 여러 줄로 나눈다.
+// 줄 수가 maxLines를 넘어가면 마지막 줄을 말줄임표로 끝낸다.
+func wrapText(face font.Face, s string, width, maxLines int) []string {
+	if maxLines <= 0 {
+		return nil
+	}
+	lines := make([]string, 0)
+	for _, para := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			try := word
+			if line != "" {
+				try = line + " " + word
+			}
+			if font.MeasureString(face, try).Ceil() <= width {
+				line = try
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			// 한 단어가 폭보다 길다면 글자 단위로 자른다.
+			line = ""
+			for _, r := range word {
+				if font.MeasureString(face, line+string(r)).Ceil() > width && line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				line += string(r)
+			}
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		last := []rune(lines[maxLines-1])
+		for len(last) > 0 && font.MeasureString(face, string(last)+"...").Ceil() > width {
+			last = last[:len(last)-1]
+		}
+		lines[maxLines-1] = string(last) + "..."
+	}
+	return lines
+}
+
+// writeContactSheetPDF는 nPages개의 페이지 이미지를 한 페이지에 하나씩 담은 pdf 파일을 쓴다.
+// 페이지 이미지는 page 함수로 하나씩 그린 뒤 바로 jpeg 이미지로 압축해 담으므로
+// 모든 페이지 이미지를 한번에 메모리에 두지 않는다.
+func writeContactSheetPDF(w io.Writer, nPages int, dpi int, page func(p int) (*image.RGBA, error)) error {
+	buf := &bytes.Buffer{}
+	offsets := make([]int, 0)
+	// obj는 새 pdf 오브젝트를 시작하고 그 번호를 반환한다.
+	obj := func() int {
+		offsets = append(offsets, buf.Len())
+		n := len(offsets)
+		fmt.Fprintf(buf, "%d 0 obj\n", n)
+		return n
+	}
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// 1번은 카탈로그, 2번은 페이지 트리이다.
+	// 각 페이지는 페이지, 내용, 이미지의 세 오브젝트로 구성된다.
+	obj()
+	buf.WriteString("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	obj()
+	kids := make([]string, nPages)
+	for i := range kids {
+		kids[i] = fmt.Sprintf("%d 0 R", 3+i*3)
+	}
+	fmt.Fprintf(buf, "<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), nPages)
+	for p := 0; p < nPages; p++ {
+		pg, err := page(p)
+		if err != nil {
+			return err
+		}
+		b := pg.Bounds()
+		// pdf의 단위는 1/72 인치이다.
+		pw := float64(b.Dx()) * 72 / float64(dpi)
+		ph := float64(b.Dy()) * 72 / float64(dpi)
+		n := obj()
+		fmt.Fprintf(buf, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Contents %d 0 R /Resources << /XObject << /Im0 %d 0 R >> >> >>\nendobj\n", pw, ph, n+1, n+2)
+		content := fmt.Sprintf("q %.2f 0 0 %.2f 0 0 cm /Im0 Do Q", pw, ph)
+		obj()
+		fmt.Fprintf(buf, "<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(content), content)
+		jpg := &bytes.Buffer{}
+		err = jpeg.Encode(jpg, pg, &jpeg.Options{Quality: 90})
+		if err != nil {
+			return err
+		}
+		obj()
+		fmt.Fprintf(buf, "<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n", b.Dx(), b.Dy(), jpg.Len())
+		buf.Write(jpg.Bytes())
+		buf.WriteString("\nendstream\nendobj\n")
+	}
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+
+	"github.com/studio2l/roi"
+)
+
+// contactSheetHandler는 검색된 유닛들의 컨택트 시트를 pdf 또는 png 파일로 내려받게 한다.
+// format이 지정되지 않았다면 컨택트 시트 설정 페이지를 보인다.
+func contactSheetHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	err := mustFields(r, "show")
+	if err != nil {
+		return err
+	}
+	show := r.FormValue("show")
+	query := r.FormValue("q")
+	format := r.FormValue("format")
+	if format == "" {
+		recipe := struct {
+			Env    *Env
+			Show   string
+			Query  string
+			Papers []string
+		}{
+			Env:    env,
+			Show:   show,
+			Query:  query,
+			Papers: []string{"a4", "a3", "letter"},
+		}
+		return executeTemplate(w, "contact-sheet", recipe)
+	}
+	if format != "pdf" && format != "png" {
+		return roi.BadRequest("unknown contact sheet format: %s", format)
+	}
+	opt := &contactSheetOptions{
+		Title:     show + "  " + query,
+		Cols:      atoi(r.FormValue("cols")),
+		Rows:      atoi(r.FormValue("rows")),
+		Paper:     r.FormValue("paper"),
+		Landscape: r.FormValue("landscape") != "",
+		DPI:       atoi(r.FormValue("dpi")),
+		Lang:      env.Language(),
+	}
+	if opt.DPI == 0 {
+		opt.DPI = 150
+	}
+	err = opt.verify()
+	if err != nil {
+		return err
+	}
+	us, err := roi.SearchUnitsQuery(DB, show, query)
+	if err != nil {
+		return err
+	}
+	if format == "pdf" {
+		// png는 한 페이지만 그리지만 pdf는 모든 페이지를 그려야 한다.
+		err = opt.verifySize(len(us))
+		if err != nil {
+			return err
+		}
+	}
+	ids := make([]string, 0, len(us))
+	for _, u := range us {
+		ids = append(ids, u.ID())
+	}
+	tasks, err := roi.TasksOfUnits(DB, show, ids)
+	if err != nil {
+		return err
+	}
+	units := make([]*contactSheetUnit, 0, len(us))
+	for _, u := range us {
+		units = append(units, &contactSheetUnit{Unit: u, Tasks: tasks[u.ID()]})
+	}
+	site, err := roi.GetSite(DB)
+	if err != nil {
+		return err
+	}
+	if format == "png" {
+		// png는 한 페이지만 담을 수 있으므로 page로 지정한 페이지만 그려서 내려받는다.
+		page := atoi(r.FormValue("page"))
+		if page == 0 {
+			page = 1
+		}
+		img, err := renderContactSheetPage(site, units, opt, page-1)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-contact-sheet-%d.png", show, page))
+		return png.Encode(w, img)
+	}
+	// pdf는 페이지를 모두 그린 후에 쓰므로 중간에 에러가 나도 응답을 망치지 않는다.
+	buf := &bytes.Buffer{}
+	err = writeContactSheetPDF(buf, opt.numPages(len(units)), opt.DPI, func(p int) (*image.RGBA, error) {
+		return renderContactSheetPage(site, units, opt, p)
+	})
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-contact-sheet.pdf", show))
+	_, err = w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/studio2l/roi"
+	"golang.org/x/image/font/basicfont"
+)
+
+func TestRenderContactSheet(t *testing.T) {
+	units := make([]*contactSheetUnit, 0)
+	for _, u := range []string{"0010", "0020", "0030", "0040", "0050"} {
+		units = append(units, &contactSheetUnit{
+			Unit: &roi.Unit{Show: "test", Group: "CG", Unit: u, Status: roi.StatusInProgress, Description: "a long description for the unit"},
+			Tasks: []*roi.Task{
+				{Task: "fx", Status: roi.StatusHold},
+				{Task: "comp", Status: roi.StatusDone, Assignee: "kybin"},
+			},
+		})
+	}
+	opt := &contactSheetOptions{Title: "test", Cols: 2, Rows: 2, Paper: "a4", Landscape: true, DPI: 72}
+	if n := opt.numPages(len(units)); n != 2 {
+		t.Fatalf("pages: want 2, got %d", n)
+	}
+	// png로 내려받을 때는 요청한 페이지만 그린다.
+	page, err := renderContactSheetPage(roi.DefaultSite, units, opt, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, h := opt.pageSize()
+	if b := page.Bounds(); b.Dx() != w || b.Dy() != h || w <= h {
+		t.Fatalf("page size: want %dx%d landscape, got %v", w, h, b)
+	}
+	_, err = renderContactSheetPage(roi.DefaultSite, units, opt, 2)
+	if err == nil {
+		t.Fatalf("want error for page out of range")
+	}
+	rendered := 0
+	buf := &bytes.Buffer{}
+	err = writeContactSheetPDF(buf, opt.numPages(len(units)), opt.DPI, func(p int) (*image.RGBA, error) {
+		rendered++
+		return renderContactSheetPage(roi.DefaultSite, units, opt, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered != 2 {
+		t.Fatalf("pdf rendered %d pages, want 2", rendered)
+	}
+	pdf := buf.String()
+	if !strings.HasPrefix(pdf, "%PDF-1.4") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatalf("invalid pdf header or trailer")
+	}
+	if strings.Count(pdf, "/Type /Page ") != 2 {
+		t.Fatalf("pdf should have 2 pages")
+	}
+
+	// 유닛 수와 DPI에 따라 페이지 픽셀의 합이 너무 크면 그리지 않는다.
+	err = opt.verifySize(len(units))
+	if err != nil {
+		t.Fatalf("small contact sheet: %v", err)
+	}
+	big := &contactSheetOptions{Cols: 1, Rows: 1, Paper: "a3", DPI: 300}
+	if err := big.verifySize(100); !errors.As(err, &roi.BadRequestError{}) {
+		t.Fatalf("want bad request error for too large contact sheet, got %v", err)
+	}
+
+	opt.Cols = 0
+	_, err = renderContactSheetPage(roi.DefaultSite, units, opt, 0)
+	if err == nil {
+		t.Fatalf("want error for invalid columns")
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	face := basicfont.Face7x13
+	lines := wrapText(face, "aaa bbb ccc ddd", 7*7, 10)
+	want := []string{"aaa bbb", "ccc ddd"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q, want %q", lines, want)
+	}
+	lines = wrapText(face, "aaa bbb ccc ddd", 7*7, 1)
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], "...") {
+		t.Fatalf("got %q, want one line ends with ...", lines)
+	}
+}
+
+func TestCSSColor(t *testing.T) {
+	cases := []struct {
+		c    string
+		want color.RGBA
+	}{
+		{c: "#ff8000", want: color.RGBA{0xff, 0x80, 0x00, 0xff}},
+		{c: "green", want: color.RGBA{0x21, 0xba, 0x45, 0xff}},
+		{c: "unknown", want: color.RGBA{0xcc, 0xcc, 0xcc, 0xff}},
+	}
+	for _, c := range cases {
+		got := cssColor(c.c)
+		if got != c.want {
+			t.Fatalf("%s: got %v, want %v", c.c, got, c.want)
+		}
+	}
+}
+
+func TestCSSColorsDefined(t *testing.T) {
+	// 컨택트 시트는 roi.css를 읽지 않으므로 모든 상태 색상이 같은 값으로 정의되어 있어야 한다.
+	css, err := ioutil.ReadFile("static/roi.css")
+	if err != nil {
+		t.Fatalf("could not read roi.css: %v", err)
+	}
+	for _, c := range roi.StatusColors {
+		rgba, ok := cssColors[c]
+		if !ok {
+			t.Fatalf("color not defined in cssColors: %s", c)
+		}
+		hex := fmt.Sprintf("--%s: #%02X%02X%02X;", c, rgba.R, rgba.G, rgba.B)
+		if !strings.Contains(string(css), hex) {
+			t.Fatalf("color differs from roi.css: %s", c)
+		}
+	}
+}
+
+func TestStatusText(t *testing.T) {
+	err := loadCatalogs()
+	if err != nil {
+		t.Fatalf("could not load catalogs: %v", err)
+	}
+	// 컨택트 시트에는 상태 키가 아니라 사이트에 정의된 레이블이 보여야 한다.
+	if got := statusText(roi.DefaultSite, roi.StatusInProgress, "ko"); got != "진행" {
+		t.Fatalf("ko: got %q, want %q", got, "진행")
+	}
+	if got := statusText(roi.DefaultSite, roi.StatusInProgress, "en"); got != translate("en", "진행") || got == "진행" {
+		t.Fatalf("en: got %q", got)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/studio2l/roi"
+)
+
+// cutChange는 컷 변경 보고서에서 한 유닛의 변경 기록과 영향받는 태스크들이다.
+type cutChange struct {
+	Unit      *roi.Unit
+	Revisions []*roi.CutRevision
+	Tasks     []*roi.Task
+}
+
+// cutChangesHandler는 쇼에서 특정 날짜 이후 컷 정보가 바뀐 샷들과
+// 그 태스크들의 상태를 보여주어 다시 작업해야 할 태스크를 찾을 수 있게 한다.
+// since가 없다면 일주일 전부터의 변경을 보인다.
+func cutChangesHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	w.Header().Set("Cache-control", "no-cache")
+	shows, err := roi.AllShows(DB)
+	if err != nil {
+		return err
+	}
+	if len(shows) == 0 {
+		recipe := struct {
+			Env *Env
+		}{
+			Env: env,
+		}
+		return executeTemplate(w, "no-shows", recipe)
+	}
+	show := r.FormValue("show")
+	if show == "" {
+		cfg, err := roi.GetUserConfig(DB, env.User.ID)
+		if err != nil {
+			return err
+		}
+		show = cfg.CurrentShow
+		if show == "" {
+			show = shows[0].Show
+		}
+	}
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -7)
+	if v := r.FormValue("since"); v != "" {
+		since, err = time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return roi.BadRequest("invalid since date: %s", v)
+		}
+	}
+	revs, err := roi.CutRevisionsSince(DB, show, since)
+	if err != nil {
+		return err
+	}
+	changes := make([]*cutChange, 0)
+	var last *cutChange
+	for _, rev := range revs {
+		// 기록은 그룹, 유닛 순으로 정렬되어 있다.
+		if last == nil || last.Unit.Group != rev.Group || last.Unit.Unit != rev.Unit {
+			u, err := roi.GetUnit(DB, rev.Show, rev.Group, rev.Unit)
+			if err != nil {
+				return err
+			}
+			ts, err := roi.UnitTasks(DB, rev.Show, rev.Group, rev.Unit)
+			if err != nil {
+				return err
+			}
+			last = &cutChange{
+				Unit:  u,
+				Tasks: ts,
+			}
+			changes = append(changes, last)
+		}
+		last.Revisions = append(last.Revisions, rev)
+	}
+	recipe := struct {
+		Env     *Env
+		Shows   []*roi.Show
+		Show    string
+		Since   time.Time
+		Changes []*cutChange
+	}{
+		Env:     env,
+		Shows:   shows,
+		Show:    show,
+		Since:   since,
+		Changes: changes,
+	}
+	return executeTemplate(w, "cut-changes", recipe)
+}
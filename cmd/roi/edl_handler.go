@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/studio2l/roi"
+)
+
+// uploadEDLHandler는 /upload-edl 페이지로 사용자가 접속했을때 페이지를 반환한다.
+// 만일 POST로 EDL 또는 OTIO 파일이 오면 편집본을 그룹에 적용했을 때의 미리보기를 보인다.
+func uploadEDLHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	if r.Method == "POST" {
+		return uploadEDLPostHandler(w, r, env)
+	}
+	w.Header().Set("Cache-control", "no-cache")
+	cfg, err := roi.GetUserConfig(DB, env.User.ID)
+	if err != nil {
+		return err
+	}
+	show := r.FormValue("show")
+	if show == "" {
+		show = cfg.CurrentShow
+		if show == "" {
+			// 사용자의 현재 프로젝트 정보가 없을때는
+			// 첫번째 프로젝트를 가리킨다.
+			shows, err := roi.AllShows(DB)
+			if err != nil {
+				return err
+			}
+			if len(shows) == 0 {
+				recipe := struct {
+					Env *Env
+				}{
+					Env: env,
+				}
+				return executeTemplate(w, "no-shows", recipe)
+			}
+			show = shows[0].Show
+		}
+	}
+	grps, err := roi.ShowGroups(DB, show)
+	if err != nil {
+		return err
+	}
+	recipe := struct {
+		Env    *Env
+		Show   string
+		Groups []*roi.Group
+	}{
+		Env:    env,
+		Show:   show,
+		Groups: grps,
+	}
+	return executeTemplate(w, "upload-edl", recipe)
+}
+
+// uploadEDLPostHandler는 업로드된 EDL을 읽어 편집본 적용 미리보기를 보인다.
+// 파일의 확장자가 .otio라면 OTIO 타임라인으로 읽는다.
+func uploadEDLPostHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	r.ParseMultipartForm(200000) // 사용하는 최대 메모리 사이즈: 200KB
+	err := mustFields(r, "show", "group")
+	if err != nil {
+		return err
+	}
+	if r.MultipartForm == nil || len(r.MultipartForm.File["edl"]) == 0 {
+		return roi.BadRequest("edl file not uploaded")
+	}
+	fh := r.MultipartForm.File["edl"][0]
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if strings.ToLower(filepath.Ext(fh.Filename)) == ".otio" {
+		clips, err := roi.ParseOTIO(f)
+		if err != nil {
+			return err
+		}
+		cuts, unnamed := roi.OTIOEditCuts(clips)
+		skipped := make([]string, 0, len(unnamed))
+		for _, c := range unnamed {
+			skipped = append(skipped, fmt.Sprintf("%s (%d frames)", c.Name, c.Duration))
+		}
+		return executeEditPreview(w, env, r.FormValue("show"), r.FormValue("group"), fh.Filename, cuts, skipped)
+	}
+	edl, err := roi.ParseEDL(f)
+	if err != nil {
+		return err
+	}
+	from := r.FormValue("name_from")
+	if from == "" {
+		from = roi.EDLNameFromClip
+	}
+	if from != roi.EDLNameFromClip && from != roi.EDLNameFromLocator {
+		return roi.BadRequest("unknown unit name source: %s", from)
+	}
+	cuts, unnamed := edl.EditCuts(from)
+	// 유닛 이름을 찾을 수 없는 이벤트는 적용하지 않지만 미리보기에서 보여준다.
+	skipped := make([]string, 0, len(unnamed))
+	for _, ev := range unnamed {
+		skipped = append(skipped, fmt.Sprintf("%03d %s %s %s-%s", ev.Num, ev.Reel, ev.ClipName, ev.RecordIn, ev.RecordOut))
+	}
+	return executeEditPreview(w, env, r.FormValue("show"), r.FormValue("group"), fh.Filename, cuts, skipped)
+}
+
+// executeEditPreview는 편집본의 컷들을 그룹에 적용했을 때
+// 추가, 제외, 순서가 바뀌는 유닛들을 보여주는 페이지를 그린다.
+// skipped는 유닛으로 변환하지 못해 적용되지 않는 편집본의 항목들이다.
+func executeEditPreview(w http.ResponseWriter, env *Env, show, grp, filename string, cuts []*roi.EditCut, skipped []string) error {
+	p, err := roi.PlanEdit(DB, show, grp, cuts)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(cuts)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Cache-control", "no-cache")
+	recipe := struct {
+		Env      *Env
+		Filename string
+		Cuts     string
+		Plan     *roi.EditPlan
+		Skipped  []string
+	}{
+		Env:      env,
+		Filename: filename,
+		Cuts:     string(data),
+		Plan:     p,
+		Skipped:  skipped,
+	}
+	return executeTemplate(w, "edit-preview", recipe)
+}
+
+// applyEditHandler는 미리보기에서 확인한 편집본을 하나의 트랜잭션으로 그룹에 적용한다.
+// 미리보기 이후 db가 바뀌었을 수 있으므로 계획을 다시 세우며,
+// 그 사이 에러가 생겼다면 적용하지 않고 미리보기를 다시 보인다.
+func applyEditHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	if r.Method != "POST" {
+		return roi.BadRequest("only post method allowed")
+	}
+	err := mustFields(r, "show", "group", "cuts")
+	if err != nil {
+		return err
+	}
+	show := r.FormValue("show")
+	grp := r.FormValue("group")
+	cuts := make([]*roi.EditCut, 0)
+	err = json.Unmarshal([]byte(r.FormValue("cuts")), &cuts)
+	if err != nil {
+		return roi.BadRequest("invalid cuts: %v", err)
+	}
+	p, err := roi.PlanEdit(DB, show, grp, cuts)
+	if err != nil {
+		return err
+	}
+	if len(p.Invalid()) != 0 {
+		return executeEditPreview(w, env, show, grp, r.FormValue("filename"), cuts, nil)
+	}
+	err = roi.ApplyEdit(DB, p, r.FormValue("omit_removed") != "", env.User.ID)
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, r, "/units?show="+url.QueryEscape(show)+"&q="+url.QueryEscape(grp+"/"), http.StatusSeeOther)
+	return nil
+}
+
+// exportOTIOHandler는 그룹의 유닛들을 편집 순서대로 늘어놓은 OTIO 파일을 내려받게 한다.
+// fps가 없다면 쇼의 fps 속성을 쓴다.
+func exportOTIOHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	err := mustFields(r, "show", "group")
+	if err != nil {
+		return err
+	}
+	show := r.FormValue("show")
+	grp := r.FormValue("group")
+	sh, err := roi.GetShow(DB, show)
+	if err != nil {
+		return err
+	}
+	rate := roi.ShowFrameRate(sh)
+	if v := r.FormValue("fps"); v != "" {
+		rate, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return roi.BadRequest("invalid fps: %s", v)
+		}
+	}
+	tl, err := roi.GroupOTIO(DB, show, grp, rate)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s.otio", show, grp))
+	return roi.WriteOTIO(w, tl)
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
-	"errors"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/360EntSecGroup-Skylar/excelize"
 	"github.com/studio2l/roi"
@@ -33,8 +39,15 @@ func uploadExcelHandler(w http.ResponseWriter, r *http.Request, env *Env) error
 	return executeTemplate(w, "upload-excel", recipe)
 }
 
+// uploadExcelPostHandler는 업로드된 엑셀 파일을 읽어 가져오기 미리보기를 보인다.
+// 파일 확장자가 csv, tsv, txt 또는 json이라면 해당 형식으로 읽는다.
+// 이 단계에서는 db를 수정하지 않는다. 미리보기 페이지에서 확인을 누르면
+// 같은 표가 uploadExcelApplyHandler로 전달되어 적용된다.
 func uploadExcelPostHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 	r.ParseMultipartForm(200000) // 사용하는 최대 메모리 사이즈: 200KB
+	if r.MultipartForm == nil {
+		return roi.BadRequest("excel file not uploaded")
+	}
 	fileHeaders := r.MultipartForm.File["excel"]
 	if len(fileHeaders) == 0 {
 		return roi.BadRequest("excel file not uploaded")
@@ -44,107 +57,227 @@ func uploadExcelPostHandler(w http.ResponseWriter, r *http.Request, env *Env) er
 	if err != nil {
 		return err
 	}
+	defer f.Close()
+	var table [][]string
+	switch ext := strings.ToLower(filepath.Ext(fh.Filename)); ext {
+	case ".csv", ".tsv", ".txt":
+		d := r.FormValue("delimiter")
+		if d == "" && ext == ".tsv" {
+			d = "tab"
+		}
+		delim, err := roi.ParseDelimiter(d)
+		if err != nil {
+			return err
+		}
+		table, err = roi.ReadCSVTable(f, delim)
+		if err != nil {
+			return err
+		}
+	case ".json":
+		table, err = roi.ReadJSONTable(f)
+		if err != nil {
+			return err
+		}
+	default:
+		table, err = readExcelTable(f, r.FormValue("sheet"))
+		if err != nil {
+			return err
+		}
+	}
+	if len(table) == 0 {
+		return roi.BadRequest("no rows in %s", fh.Filename)
+	}
+	return executeUnitImportPreview(w, env, fh.Filename, table)
+}
+
+// readExcelTable은 엑셀 파일의 한 시트를 표로 읽는다.
+// 시트가 지정되지 않았다면 파일을 열었을 때 보이는 시트를 읽는다.
+func readExcelTable(f io.Reader, sheet string) ([][]string, error) {
 	xl, err := excelize.OpenReader(f)
 	if err != nil {
-		return err
-	}
-	rows := xl.GetRows("Sheet1")
-	if len(rows) == 0 {
-		http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
-		return nil
+		return nil, roi.BadRequest("invalid excel file: %v", err)
 	}
-	row0 := rows[0]
-	title := make(map[int]string)
-	for j, cell := range row0 {
-		if cell != "" {
-			title[j] = cell
+	sheets := excelSheets(xl)
+	sheet = strings.TrimSpace(sheet)
+	if sheet == "" {
+		sheet = xl.GetSheetName(xl.GetActiveSheetIndex())
+		if sheet == "" && len(sheets) != 0 {
+			sheet = sheets[0]
 		}
 	}
+	if xl.GetSheetIndex(sheet) == 0 {
+		return nil, roi.BadRequest("sheet %q not found: available sheets are %s", sheet, strings.Join(sheets, ", "))
+	}
+	return xl.GetRows(sheet), nil
+}
 
-	attr := make(map[string]string)
+// excelSheets는 엑셀 파일의 시트 이름들을 파일 안의 순서대로 반환한다.
+func excelSheets(xl *excelize.File) []string {
+	m := xl.GetSheetMap()
+	idx := make([]int, 0, len(m))
+	for i := range m {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	sheets := make([]string, 0, len(idx))
+	for _, i := range idx {
+		sheets = append(sheets, m[i])
+	}
+	return sheets
+}
 
-	// pop은 attr에 해당 속성이 있으면 지우고 반환한다.
-	pop := func(m map[string]string, key string) string {
-		v, ok := m[key]
-		if ok {
-			delete(m, key)
-		}
-		return v
+// executeUnitImportPreview는 표를 가져왔을 때 어떤 변화가 생기는지 보여주는 페이지를 그린다.
+func executeUnitImportPreview(w http.ResponseWriter, env *Env, filename string, table [][]string) error {
+	im, err := roi.PlanUnitImport(DB, table, env.User.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(table)
+	if err != nil {
+		return err
 	}
+	w.Header().Set("Cache-control", "no-cache")
+	recipe := struct {
+		Env      *Env
+		Filename string
+		Table    string
+		Import   *roi.UnitImport
+	}{
+		Env:      env,
+		Filename: filename,
+		Table:    string(data),
+		Import:   im,
+	}
+	return executeTemplate(w, "upload-excel-preview", recipe)
+}
 
-	for _, row := range rows[1:] {
-		for i := range title {
-			attr[title[i]] = row[i]
-		}
+// importTable은 미리보기 페이지에서 전달된 표를 읽는다.
+func importTable(r *http.Request) ([][]string, error) {
+	err := mustFields(r, "table")
+	if err != nil {
+		return nil, err
+	}
+	table := make([][]string, 0)
+	err = json.Unmarshal([]byte(r.FormValue("table")), &table)
+	if err != nil {
+		return nil, roi.BadRequest("invalid table: %v", err)
+	}
+	return table, nil
+}
 
-		show := pop(attr, "show")
-		grp := pop(attr, "group")
-		unit := pop(attr, "unit")
+// uploadExcelApplyHandler는 미리보기에서 확인한 표를 하나의 트랜잭션으로 적용한다.
+// 미리보기 이후 db가 바뀌었을 수 있으므로 계획을 다시 세우며,
+// 그 사이 에러가 생겼다면 적용하지 않고 미리보기를 다시 보인다.
+func uploadExcelApplyHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	if r.Method != "POST" {
+		return roi.BadRequest("only post method allowed")
+	}
+	table, err := importTable(r)
+	if err != nil {
+		return err
+	}
+	im, err := roi.PlanUnitImport(DB, table, env.User.ID)
+	if err != nil {
+		return err
+	}
+	if len(im.Invalid()) != 0 {
+		return executeUnitImportPreview(w, env, r.FormValue("filename"), table)
+	}
+	err = roi.ApplyUnitImport(DB, im, env.User.ID)
+	if err != nil {
+		return err
+	}
+	show := ""
+	if len(im.Rows) != 0 {
+		show = im.Rows[0].Show
+	}
+	http.Redirect(w, r, "/units?show="+url.QueryEscape(show), http.StatusSeeOther)
+	return nil
+}
 
-		if unit == "" {
-			// 엑셀의 뒤쪽 열들은 비어있더라도 rows에 추가되는 경우가 있다.
-			// unit의 유무로 해당 줄이 비어있는지를 판단한다.
-			continue
-		}
+// uploadExcelReportHandler는 미리보기에서 확인한 표의 검증 에러를 csv 파일로 내려받게 한다.
+func uploadExcelReportHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	if r.Method != "POST" {
+		return roi.BadRequest("only post method allowed")
+	}
+	table, err := importTable(r)
+	if err != nil {
+		return err
+	}
+	im, err := roi.PlanUnitImport(DB, table, env.User.ID)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=import-errors.csv")
+	// 엑셀이 한글을 제대로 읽을 수 있도록 BOM을 붙인다.
+	return roi.WriteCSVTable(w, im.ErrorTable(), ',', true)
+}
 
-		add := false
-		u, err := roi.GetUnit(DB, show, grp, unit)
-		if err != nil {
-			if !errors.As(err, &roi.NotFoundError{}) {
-				return err
-			}
-			u = &roi.Unit{
-				Show:  show,
-				Group: grp,
-				Unit:  unit,
-			}
-			add = true
-		}
-		status := pop(attr, "status")
-		if status != "" {
-			u.Status = roi.Status(status)
-		}
-		desc := pop(attr, "description")
-		if desc != "" {
-			u.Description = desc
+// exportExcelHandler는 검색된 유닛들을 엑셀 파일로 내려받게 한다.
+// 파일의 열 이름은 uploadExcelHandler가 이해하는 이름과 같으므로
+// 수정후 다시 업로드 할 수 있다.
+func exportExcelHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	show, table, err := searchUnitTable(r)
+	if err != nil {
+		return err
+	}
+	site, err := roi.GetSite(DB)
+	if err != nil {
+		return err
+	}
+	xl := excelize.NewFile()
+	// 상태 열은 사이트에 정의된 상태 색상으로 칠한다.
+	isStatus := make(map[int]bool)
+	for j, col := range table[0] {
+		if _, ok := roi.SplitAttrColumn(col); ok {
+			// 커스텀 속성은 이름이 status로 끝나더라도 상태가 아니다.
+			continue
 		}
-		cg := pop(attr, "cg_description")
-		if cg != "" {
-			u.CGDescription = cg
+		if col == "status" || strings.HasSuffix(col, ".status") {
+			isStatus[j] = true
 		}
-		tags := fieldSplit(pop(attr, "tags"))
-		if len(tags) != 0 {
-			// 태그는 덮어쓰지 않고 추가한다.
-			has := make(map[string]bool)
-			for _, t := range u.Tags {
-				has[t] = true
+	}
+	styles := make(map[string]int)
+	for i, row := range table {
+		for j, cell := range row {
+			axis := excelize.ToAlphaString(j) + fmt.Sprint(i+1)
+			xl.SetCellStr("Sheet1", axis, cell)
+			if i == 0 || !isStatus[j] || cell == "" {
+				continue
 			}
-			for _, t := range tags {
-				if !has[t] {
-					u.Tags = append(u.Tags, t)
+			c := cssColor(site.StatusDef(roi.Status(cell)).Color)
+			hex := fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+			style, ok := styles[hex]
+			if !ok {
+				style, err = xl.NewStyle(fmt.Sprintf(`{"fill":{"type":"pattern","color":["%s"],"pattern":1}}`, hex))
+				if err != nil {
+					return err
 				}
+				styles[hex] = style
 			}
+			xl.SetCellStyle("Sheet1", axis, axis, style)
 		}
-		// 남은 속성들은 커스텀 속성에 업데이트 한다.
-		if u.Attrs == nil {
-			u.Attrs = make(map[string]string)
-		}
-		for k, v := range attr {
-			u.Attrs[k] = v
-		}
+	}
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-units.xlsx", show))
+	return xl.Write(w)
+}
 
-		if add {
-			err = roi.AddUnit(DB, u)
-			if err != nil {
-				return err
-			}
-		} else {
-			err = roi.UpdateUnit(DB, u)
-			if err != nil {
-				return err
-			}
-		}
+// searchUnitTable은 요청의 show와 q로 유닛을 검색해 내보낼 표를 만든다.
+func searchUnitTable(r *http.Request) (string, [][]string, error) {
+	err := mustFields(r, "show")
+	if err != nil {
+		return "", nil, err
 	}
-	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
-	return nil
+	show := r.FormValue("show")
+	us, err := roi.SearchUnitsQuery(DB, show, r.FormValue("q"))
+	if err != nil {
+		return "", nil, err
+	}
+	table, err := roi.UnitTable(DB, us)
+	if err != nil {
+		return "", nil, err
+	}
+	return show, table, nil
 }
@@ -109,12 +109,18 @@ func updateGroupHandler(w http.ResponseWriter, r *http.Request, env *Env) error
 	if err != nil {
 		return err
 	}
+	defs, err := roi.AttrDefs(DB, roi.AttrKindGroup, show)
+	if err != nil {
+		return err
+	}
 	recipe := struct {
-		Env   *Env
-		Group *roi.Group
+		Env      *Env
+		Group    *roi.Group
+		AttrDefs []*roi.AttrDef
 	}{
-		Env:   env,
-		Group: p,
+		Env:      env,
+		Group:    p,
+		AttrDefs: defs,
 	}
 	return executeTemplate(w, "update-group", recipe)
 }
@@ -133,22 +139,13 @@ func updateGroupPostHandler(w http.ResponseWriter, r *http.Request, env *Env) er
 	if err != nil {
 		return err
 	}
+	defs, err := roi.AttrDefs(DB, roi.AttrKindGroup, show)
+	if err != nil {
+		return err
+	}
 	s.DefaultTasks = fieldSplit(r.FormValue("default_tasks"))
 	s.Notes = r.FormValue("notes")
-	s.Attrs = make(roi.DBStringMap)
-
-	for _, ln := range strings.Split(r.FormValue("attrs"), "\n") {
-		kv := strings.SplitN(ln, ":", 2)
-		if len(kv) != 2 {
-			continue
-		}
-		k := strings.TrimSpace(kv[0])
-		v := strings.TrimSpace(kv[1])
-		if k == "" || v == "" {
-			continue
-		}
-		s.Attrs[k] = v
-	}
+	s.Attrs = attrsFromForm(r, defs)
 
 	err = roi.UpdateGroup(DB, s)
 	if err != nil {
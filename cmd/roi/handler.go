@@ -17,6 +17,12 @@ import (
 
 type Env struct {
 	User *roi.User
+	// PinnedSearches는 사용자가 메뉴에 고정한 저장된 검색들이다.
+	PinnedSearches []*roi.SavedSearch
+	// Notifications는 사용자가 아직 지우지 않은 알림의 수이다.
+	Notifications int
+	// Lang은 사용자에게 보일 페이지와 에러 메시지의 언어이다.
+	Lang string
 }
 
 // HandlerFunc는 이 패키지에서 사용하는 핸들 함수이다.
@@ -26,45 +32,95 @@ type HandlerFunc func(w http.ResponseWriter, r *http.Request, env *Env) error
 func handle(serve HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var u *roi.User
+		var cfg *roi.UserConfig
 		if !(r.URL.Path == "/login" || r.URL.Path == "/login/" || r.URL.Path == "/signup") {
 			var err error
-			u, err = sessionUser(r)
+			u, cfg, err = sessionUserConfig(r)
 			if err != nil {
 				if errors.As(err, &roi.NotFoundError{}) {
 					http.Redirect(w, r, "/login", http.StatusSeeOther)
 					return
 				}
 				clearSession(w)
-				handleError(w, err)
+				handleError(w, err, requestLanguage(r, ""))
 				return
 			}
 		}
 		env := &Env{
 			User: u,
+			Lang: requestLanguage(r, ""),
+		}
+		if u != nil {
+			env.Lang = requestLanguage(r, cfg.Language)
+			var err error
+			env.PinnedSearches, err = roi.ConfigPinnedSearches(DB, u.ID, cfg)
+			if err != nil {
+				handleError(w, err, env.Lang)
+				return
+			}
+			env.Notifications, err = roi.CountUserNotifications(DB, u.ID)
+			if err != nil {
+				handleError(w, err, env.Lang)
+				return
+			}
 		}
 		err := serve(w, r, env)
 		if err != nil {
-			handleError(w, err)
+			handleError(w, err, env.Lang)
 		}
 	}
 }
 
 // handleError는 handle에서 요청을 처리하던 도중 에러가 났을 때 에러 메시지를 답신한다.
-func handleError(w http.ResponseWriter, err error) {
+// 사용자에게 보이는 에러 메시지는 lang 언어로 번역된다.
+func handleError(w http.ResponseWriter, err error, lang string) {
 	if errors.As(err, &roi.BadRequestError{}) {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, localizeError(lang, err), http.StatusBadRequest)
 		return
 	}
 	if errors.As(err, &roi.NotFoundError{}) {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, localizeError(lang, err), http.StatusNotFound)
 		return
 	}
 	if errors.As(err, &roi.AuthError{}) {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		http.Error(w, localizeError(lang, err), http.StatusUnauthorized)
 		return
 	}
 	log.Println(err)
-	http.Error(w, "internal error", http.StatusInternalServerError)
+	http.Error(w, translate(lang, "internal error"), http.StatusInternalServerError)
+}
+
+// executeBulkResults는 여러 항목을 한번에 수정한 결과를 항목별로 보여준다.
+// title은 페이지 제목으로 사용자의 언어로 번역된다.
+// 에러가 있는 항목 때문에 아무것도 수정되지 않았다면 err와 함께 각 항목의 에러를 보여주며,
+// 항목별 결과가 없는 에러는 그대로 반환한다.
+func executeBulkResults(w http.ResponseWriter, env *Env, title string, results []*roi.BulkResult, err error) error {
+	if err != nil && results == nil {
+		return err
+	}
+	changed := 0
+	for _, r := range results {
+		if r.Changed {
+			changed++
+		}
+	}
+	recipe := struct {
+		Env     *Env
+		Title   string
+		Results []*roi.BulkResult
+		Changed int
+		Error   string
+	}{
+		Env:     env,
+		Title:   title,
+		Results: results,
+		Changed: changed,
+	}
+	if err != nil {
+		recipe.Error = localizeError(env.Language(), err)
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	return executeTemplate(w, "bulk-results", recipe)
 }
 
 func mustFields(r *http.Request, keys ...string) error {
@@ -80,24 +136,32 @@ func mustFields(r *http.Request, keys ...string) error {
 // 그 아이디에 해당하는 유저를 반환한다.
 // 세션에 userid 정보가 없다면 nil 유저를 반환한다.
 func sessionUser(r *http.Request) (*roi.User, error) {
+	u, _, err := sessionUserConfig(r)
+	return u, err
+}
+
+// sessionUserConfig는 세션 유저와 그 설정을 함께 불러온다.
+// 매 요청마다 유저와 설정을 따로 불러오지 않도록 handle에서 사용한다.
+func sessionUserConfig(r *http.Request) (*roi.User, *roi.UserConfig, error) {
 	session, err := getSession(r)
 	if err != nil {
-		return nil, fmt.Errorf("could not get session: %w", err)
+		return nil, nil, fmt.Errorf("could not get session: %w", err)
 	}
 	user := session["userid"]
 	if user == "" {
-		return nil, roi.NotFound("user not set in session")
+		return nil, nil, roi.NotFound("user not set in session")
 	}
-	u, err := roi.GetUser(DB, user)
+	u, cfg, err := roi.GetUserAndConfig(DB, user)
 	if err != nil {
 		if errors.As(err, &roi.NotFoundError{}) {
 			// 일반적으로 db에 사용자가 없는 것은 NotFound 에러를 내지만,
 			// 존재하지 않는 사용자가 세션 유저로 등록되어 있는 것은 해킹일 가능성이 높다.
 			// 로그에 남도록 Internal 에러를 내고 %v 포매팅을 사용해 NotFound 타입정보는 지운다.
-			return nil, fmt.Errorf("warn: invalid session user (malicious attack?): %s: %v", user, err)
+			return nil, nil, fmt.Errorf("warn: invalid session user (malicious attack?): %s: %v", user, err)
 		}
+		return nil, nil, err
 	}
-	return u, nil
+	return u, cfg, nil
 }
 
 func saveImageFormFile(r *http.Request, field string, dst string) error {
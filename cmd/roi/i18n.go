@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/studio2l/roi"
+)
+
+// defaultLanguage는 사용자와 브라우저 모두 언어를 정하지 않았을 때 사용하는 언어이다.
+const defaultLanguage = "ko"
+
+// languageNames는 언어 선택시 보일 각 언어의 이름이다. 이름은 그 언어로 쓴다.
+var languageNames = map[string]string{
+	"ko": "한국어",
+	"en": "English",
+}
+
+// languageName은 언어의 이름을 반환한다. 이름이 정해지지 않은 언어는 언어 코드를 반환한다.
+func languageName(lang string) string {
+	if n, ok := languageNames[lang]; ok {
+		return n
+	}
+	return lang
+}
+
+// catalogs는 언어별 메시지 카탈로그로 원문 메시지를 그 언어로 번역한 메시지에 대응한다.
+// 원문은 UI 문자열이라면 대부분 한국어, 에러 메시지라면 영어이므로
+// 각 언어의 카탈로그는 그 언어로 쓰이지 않은 원문만 담는다.
+// 카탈로그에 없는 메시지는 원문을 그대로 사용한다.
+var catalogs = make(map[string]map[string]string)
+
+// loadCatalogs는 i18n 디렉토리의 <언어>.json 파일들을 읽어 메시지 카탈로그로 사용한다.
+// 파일이 없는 언어는 원문을 그대로 사용한다.
+func loadCatalogs() error {
+	cs := make(map[string]map[string]string)
+	for _, lang := range roi.AllLanguages {
+		data, err := ioutil.ReadFile(filepath.Join("i18n", lang+".json"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		c := make(map[string]string)
+		err = json.Unmarshal(data, &c)
+		if err != nil {
+			return fmt.Errorf("invalid catalog: %s: %w", lang, err)
+		}
+		cs[lang] = c
+	}
+	catalogs = cs
+	return nil
+}
+
+// translate는 메시지를 해당 언어로 번역한다. 번역이 없다면 원문을 반환한다.
+func translate(lang, msg string) string {
+	if m, ok := catalogs[lang][msg]; ok {
+		return m
+	}
+	return msg
+}
+
+// tr은 템플릿에서 사용자의 언어로 번역된 메시지를 쓰는 함수이다.
+// args가 있다면 번역된 메시지를 형식 문자열로 사용한다.
+func tr(env *Env, msg string, args ...interface{}) string {
+	m := translate(env.Language(), msg)
+	if len(args) != 0 {
+		return fmt.Sprintf(m, args...)
+	}
+	return m
+}
+
+// notificationMessage는 알림 메시지를 사용자의 언어로 번역한 뒤 인자를 채워 반환한다.
+// 인자 없이 저장된 알림은 메시지를 그대로 번역한다.
+func notificationMessage(env *Env, n *roi.Notification) string {
+	args := make([]interface{}, len(n.Args))
+	for i, a := range n.Args {
+		args[i] = a
+	}
+	return tr(env, n.Message, args...)
+}
+
+// Language는 사용자가 UI에서 사용할 언어를 반환한다.
+func (env *Env) Language() string {
+	if env == nil || env.Lang == "" {
+		return defaultLanguage
+	}
+	return env.Lang
+}
+
+// requestLanguage는 사용자 설정이나 요청의 Accept-Language 헤더에서 UI 언어를 정한다.
+// 사용자 설정이 우선이며 둘 다 지원하는 언어가 아니라면 기본 언어를 반환한다.
+func requestLanguage(r *http.Request, pref string) string {
+	if pref != "" {
+		return pref
+	}
+	for _, l := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		// 예) ko-KR;q=0.9
+		l = strings.TrimSpace(strings.SplitN(l, ";", 2)[0])
+		l = strings.ToLower(strings.SplitN(l, "-", 2)[0])
+		for _, lang := range roi.AllLanguages {
+			if l == lang {
+				return lang
+			}
+		}
+	}
+	return defaultLanguage
+}
+
+// messager는 번역할 수 있도록 형식 문자열과 인자를 제공하는 로이의 에러이다.
+type messager interface {
+	Message() (string, []interface{})
+}
+
+// localizeError는 사용자에게 보일 에러 메시지를 해당 언어로 번역한다.
+// 에러가 감싸고 있는 로이 에러의 메시지만 번역하며, 번역이 없다면 원래 메시지를 반환한다.
+func localizeError(lang string, err error) string {
+	msg := err.Error()
+	var m messager
+	if !errors.As(err, &m) {
+		return msg
+	}
+	format, vals := m.Message()
+	t := translate(lang, format)
+	if t == format {
+		return msg
+	}
+	return strings.Replace(msg, fmt.Sprintf(format, vals...), fmt.Sprintf(t, vals...), 1)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/studio2l/roi"
+)
+
+func TestLocalizeError(t *testing.T) {
+	err := loadCatalogs()
+	if err != nil {
+		t.Fatalf("could not load catalogs: %v", err)
+	}
+	cases := []struct {
+		lang string
+		err  error
+		want string
+	}{
+		{
+			lang: "ko",
+			err:  roi.NotFound("unit not found: %s", "TEST/CG/0010"),
+			want: "유닛을 찾을 수 없습니다: TEST/CG/0010",
+		},
+		{
+			lang: "ko",
+			err:  fmt.Errorf("could not update: %w", roi.NotFound("unit not found: %s", "TEST/CG/0010")),
+			want: "could not update: 유닛을 찾을 수 없습니다: TEST/CG/0010",
+		},
+		{
+			lang: "en",
+			err:  roi.NotFound("unit not found: %s", "TEST/CG/0010"),
+			want: "unit not found: TEST/CG/0010",
+		},
+		{
+			lang: "ko",
+			err:  roi.BadRequest("no translation: %s", "x"),
+			want: "no translation: x",
+		},
+		{
+			lang: "ko",
+			err:  fmt.Errorf("not a roi error"),
+			want: "not a roi error",
+		},
+	}
+	for _, c := range cases {
+		got := localizeError(c.lang, c.err)
+		if got != c.want {
+			t.Fatalf("%s: %v: got %q, want %q", c.lang, c.err, got, c.want)
+		}
+	}
+}
+
+func TestRequestLanguage(t *testing.T) {
+	cases := []struct {
+		accept string
+		pref   string
+		want   string
+	}{
+		{accept: "", pref: "", want: "ko"},
+		{accept: "en-US,en;q=0.9", pref: "", want: "en"},
+		{accept: "fr-FR, ko-KR;q=0.8", pref: "", want: "ko"},
+		{accept: "en-US", pref: "ko", want: "ko"},
+		{accept: "ja", pref: "", want: "ko"},
+	}
+	for _, c := range cases {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Language", c.accept)
+		got := requestLanguage(r, c.pref)
+		if got != c.want {
+			t.Fatalf("%q, %q: got %q, want %q", c.accept, c.pref, got, c.want)
+		}
+	}
+}
+
+func TestNotificationMessage(t *testing.T) {
+	err := loadCatalogs()
+	if err != nil {
+		t.Fatalf("could not load catalogs: %v", err)
+	}
+	cases := []struct {
+		lang string
+		n    *roi.Notification
+		want string
+	}{
+		{
+			lang: "en",
+			n:    &roi.Notification{Message: "검색 '%s' 결과가 바뀌었습니다. 추가 %s, 제외 %s\n추가: %s", Args: []string{"hero", "1", "0", "TEST/CG/0010"}},
+			want: "Results of the search 'hero' changed. 1 added, 0 removed\nAdded: TEST/CG/0010",
+		},
+		{
+			lang: "ko",
+			n:    &roi.Notification{Message: "검색 '%s' 결과가 바뀌었습니다. 추가 %s, 제외 %s", Args: []string{"hero", "1", "0"}},
+			want: "검색 'hero' 결과가 바뀌었습니다. 추가 1, 제외 0",
+		},
+		{
+			// 인자 없이 저장된 알림은 그대로 보인다.
+			lang: "en",
+			n:    &roi.Notification{Message: "100% 완료"},
+			want: "100% 완료",
+		},
+	}
+	for _, c := range cases {
+		got := notificationMessage(&Env{Lang: c.lang}, c.n)
+		if got != c.want {
+			t.Fatalf("%s: got %q, want %q", c.lang, got, c.want)
+		}
+	}
+}
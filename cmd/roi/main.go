@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gorilla/securecookie"
 	"github.com/studio2l/roi"
@@ -45,6 +46,8 @@ func main() {
 		dbCA     string
 		dbCert   string
 		dbKey    string
+		fontFile string
+		noCache  bool
 	)
 	addrDefault := "localhost:80:443"
 	addrHelp := `binding address and it's http/https port.
@@ -87,8 +90,12 @@ when ROI_DB_ADDR environment variable is not empty, it will use the value as def
 	flag.StringVar(&dbCA, "db-ca", "db-cert/ca.crt", "root certificate authority file of the database.")
 	flag.StringVar(&dbCert, "db-cert", "db-cert/client.root.crt", "client certificate file of database.")
 	flag.StringVar(&dbKey, "db-key", "db-cert/client.root.key", "client key file of database.")
+	flag.StringVar(&fontFile, "font", "", "ttf or otf font file used to draw texts on contact sheets. without it, only latin characters are drawn.")
+	flag.BoolVar(&noCache, "no-cache", false, "do not cache site, show and group records. use it when other processes also modify the database.")
 	flag.Parse()
 
+	roi.SetCacheEnabled(!noCache)
+
 	hashFile := "cert/cookie.hash"
 	blockFile := "cert/cookie.block"
 	blockFileExist, err := anyFileExist(hashFile, blockFile)
@@ -136,7 +143,20 @@ when ROI_DB_ADDR environment variable is not empty, it will use the value as def
 		}
 	}
 
+	if fontFile != "" {
+		err := loadContactSheetFont(fontFile)
+		if err != nil {
+			log.Fatalf("could not load font: %v", err)
+		}
+	}
+
 	parseTemplate()
+	err = loadCatalogs()
+	if err != nil {
+		log.Fatalf("could not load message catalogs: %v", err)
+	}
+
+	go checkSearchSubscriptions(5 * time.Minute)
 
 	hashKey, err := ioutil.ReadFile(hashFile)
 	if err != nil {
@@ -156,6 +176,7 @@ when ROI_DB_ADDR environment variable is not empty, it will use the value as def
 	mux.HandleFunc("/login", handle(loginHandler))
 	mux.HandleFunc("/logout", handle(logoutHandler))
 	mux.HandleFunc("/settings/profile", handle(profileHandler))
+	mux.HandleFunc("/settings/language", handle(updateLanguageHandler))
 	mux.HandleFunc("/update-password", handle(updatePasswordHandler))
 	mux.HandleFunc("/signup", handle(signupHandler))
 	mux.HandleFunc("/site", handle(siteHandler))
@@ -167,6 +188,10 @@ when ROI_DB_ADDR environment variable is not empty, it will use the value as def
 	mux.HandleFunc("/update-group", handle(updateGroupHandler))
 	mux.HandleFunc("/add-unit", handle(addUnitHandler))
 	mux.HandleFunc("/update-unit", handle(updateUnitHandler))
+	mux.HandleFunc("/delete-unit", handle(deleteUnitHandler))
+	mux.HandleFunc("/asset", handle(assetHandler))
+	mux.HandleFunc("/fork-asset", handle(forkAssetHandler))
+	mux.HandleFunc("/detach-asset", handle(detachAssetHandler))
 	mux.HandleFunc("/update-multi-units", handle(updateMultiUnitsHandler))
 	mux.HandleFunc("/update-task", handle(updateTaskHandler))
 	mux.HandleFunc("/update-multi-tasks", handle(updateMultiTasksHandler))
@@ -175,12 +200,39 @@ when ROI_DB_ADDR environment variable is not empty, it will use the value as def
 	mux.HandleFunc("/update-version", handle(updateVersionHandler))
 	mux.HandleFunc("/review/", handle(reviewHandler))
 	mux.HandleFunc("/upload-excel", handle(uploadExcelHandler))
+	mux.HandleFunc("/upload-excel-apply", handle(uploadExcelApplyHandler))
+	mux.HandleFunc("/upload-excel-report", handle(uploadExcelReportHandler))
+	mux.HandleFunc("/export-excel", handle(exportExcelHandler))
+	mux.HandleFunc("/breakdown", handle(breakdownHandler))
+	mux.HandleFunc("/export-breakdown", handle(exportBreakdownHandler))
+	mux.HandleFunc("/export-csv", handle(exportCSVHandler))
+	mux.HandleFunc("/export-json", handle(exportJSONHandler))
+	mux.HandleFunc("/upload-edl", handle(uploadEDLHandler))
+	mux.HandleFunc("/apply-edit", handle(applyEditHandler))
+	mux.HandleFunc("/export-otio", handle(exportOTIOHandler))
+	mux.HandleFunc("/contact-sheet", handle(contactSheetHandler))
+	mux.HandleFunc("/cut-changes", handle(cutChangesHandler))
+	mux.HandleFunc("/saved-searches", handle(savedSearchesHandler))
+	mux.HandleFunc("/update-saved-search", handle(updateSavedSearchHandler))
+	mux.HandleFunc("/notifications", handle(notificationsHandler))
+	mux.HandleFunc("/change-sets", handle(changeSetsHandler))
+	mux.HandleFunc("/text-search", handle(textSearchHandler))
 	mux.HandleFunc("/user/", handle(userHandler))
 	mux.HandleFunc("/users", handle(usersHandler))
 	mux.HandleFunc("/api/v1/show/add", addShowApiHandler)
 	mux.HandleFunc("/api/v1/unit/add", addUnitApiHandler)
 	mux.HandleFunc("/api/v1/unit/get", getUnitApiHandler)
 	mux.HandleFunc("/api/v1/unit-tasks/get", getUnitTasksApiHandler)
+	mux.HandleFunc("/api/v1/version/get", getVersionApiHandler)
+	mux.HandleFunc("/api/v1/units/export", exportUnitsApiHandler)
+	mux.HandleFunc("/api/v1/units/search", searchUnitsApiHandler)
+	mux.HandleFunc("/api/v1/units/import", importUnitsApiHandler)
+	mux.HandleFunc("/api/v1/units/update", updateUnitsApiHandler)
+	mux.HandleFunc("/api/v1/tasks/update", updateTasksApiHandler)
+	mux.HandleFunc("/api/v1/saved-searches", savedSearchesApiHandler)
+	mux.HandleFunc("/api/v1/saved-search/run", runSavedSearchApiHandler)
+	mux.HandleFunc("/api/v1/cache/metrics", cacheMetricsApiHandler)
+	mux.HandleFunc("/api/v1/statuses", statusesApiHandler)
 	fs := http.FileServer(http.Dir("static"))
 	mux.Handle("/static/", http.StripPrefix("/static/", fs))
 	thumbfs := http.FileServer(http.Dir("data"))
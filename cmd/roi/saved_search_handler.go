@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/studio2l/roi"
+)
+
+// savedSearchItem은 저장된 검색 페이지에서 검색 하나와 그에 대한 사용자의 설정이다.
+type savedSearchItem struct {
+	Search     *roi.SavedSearch
+	Mine       bool
+	Pinned     bool
+	Subscribed bool
+}
+
+// savedSearchesHandler는 쇼에서 사용자가 볼 수 있는 저장된 검색들을 보여주고,
+// POST 요청에서는 새 검색을 저장한다.
+// q가 있다면 새로 저장할 검색어로 미리 채운다.
+func savedSearchesHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	if r.Method == "POST" {
+		err := mustFields(r, "show", "name")
+		if err != nil {
+			return err
+		}
+		s := &roi.SavedSearch{
+			Show:   r.FormValue("show"),
+			Owner:  env.User.ID,
+			Name:   r.FormValue("name"),
+			Query:  r.FormValue("query"),
+			Shared: r.FormValue("shared") != "",
+		}
+		err = roi.AddSavedSearch(DB, s)
+		if err != nil {
+			return err
+		}
+		if r.FormValue("pin") != "" {
+			err = roi.PinSavedSearch(DB, env.User.ID, s, true)
+			if err != nil {
+				return err
+			}
+		}
+		http.Redirect(w, r, "/saved-searches?show="+s.Show, http.StatusSeeOther)
+		return nil
+	}
+	shows, err := roi.AllShows(DB)
+	if err != nil {
+		return err
+	}
+	if len(shows) == 0 {
+		recipe := struct {
+			Env *Env
+		}{
+			Env: env,
+		}
+		return executeTemplate(w, "no-shows", recipe)
+	}
+	cfg, err := roi.GetUserConfig(DB, env.User.ID)
+	if err != nil {
+		return err
+	}
+	show := r.FormValue("show")
+	if show == "" {
+		show = cfg.CurrentShow
+		if show == "" {
+			show = shows[0].Show
+		}
+	}
+	ss, err := roi.UserSavedSearches(DB, show, env.User.ID)
+	if err != nil {
+		return err
+	}
+	subs, err := roi.UserSearchSubscriptions(DB, show, env.User.ID)
+	if err != nil {
+		return err
+	}
+	pinned := make(map[string]bool)
+	for _, id := range cfg.PinnedSearches {
+		pinned[id] = true
+	}
+	subscribed := make(map[string]bool)
+	for _, sub := range subs {
+		subscribed[sub.Show+"/"+sub.Owner+"/"+sub.Name] = true
+	}
+	items := make([]*savedSearchItem, 0, len(ss))
+	for _, s := range ss {
+		items = append(items, &savedSearchItem{
+			Search:     s,
+			Mine:       s.Owner == env.User.ID,
+			Pinned:     pinned[s.ID()],
+			Subscribed: subscribed[s.ID()],
+		})
+	}
+	recipe := struct {
+		Env      *Env
+		Shows    []*roi.Show
+		Show     string
+		Query    string
+		Searches []*savedSearchItem
+	}{
+		Env:      env,
+		Shows:    shows,
+		Show:     show,
+		Query:    r.FormValue("q"),
+		Searches: items,
+	}
+	return executeTemplate(w, "saved-searches", recipe)
+}
+
+// updateSavedSearchHandler는 저장된 검색에 대한 사용자의 요청(op)을 처리한다.
+// pin, unpin, subscribe, unsubscribe는 검색을 볼 수 있는 사용자 누구나,
+// share, unshare, update, delete는 검색의 소유자만 할 수 있다.
+func updateSavedSearchHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	if r.Method != "POST" {
+		return roi.BadRequest("only post method allowed")
+	}
+	err := mustFields(r, "id", "op")
+	if err != nil {
+		return err
+	}
+	show, owner, name, err := roi.SplitSavedSearchID(r.FormValue("id"))
+	if err != nil {
+		return err
+	}
+	s, err := roi.GetSavedSearch(DB, show, owner, name)
+	if err != nil {
+		return err
+	}
+	if !s.VisibleTo(env.User.ID) {
+		return roi.NotFound("saved search not found: %s", s.ID())
+	}
+	op := r.FormValue("op")
+	switch op {
+	case "share", "unshare", "update", "delete":
+		if s.Owner != env.User.ID {
+			return roi.Auth("only owner can %s saved search: %s", op, s.ID())
+		}
+	}
+	switch op {
+	case "pin", "unpin":
+		err = roi.PinSavedSearch(DB, env.User.ID, s, op == "pin")
+	case "subscribe":
+		err = roi.SubscribeSavedSearch(DB, env.User.ID, s)
+	case "unsubscribe":
+		err = roi.UnsubscribeSavedSearch(DB, env.User.ID, s)
+	case "share", "unshare":
+		s.Shared = op == "share"
+		err = roi.UpdateSavedSearch(DB, s)
+	case "update":
+		s.Query = r.FormValue("query")
+		err = roi.UpdateSavedSearch(DB, s)
+	case "delete":
+		err = roi.DeleteSavedSearch(DB, s.Show, s.Owner, s.Name)
+		if err == nil {
+			err = roi.PinSavedSearch(DB, env.User.ID, s, false)
+		}
+	default:
+		return roi.BadRequest("unknown saved search operation: %s", op)
+	}
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, r, "/saved-searches?show="+s.Show, http.StatusSeeOther)
+	return nil
+}
+
+// notificationsHandler는 사용자의 알림들을 보여주고, POST 요청에서는 알림을 모두 지운다.
+func notificationsHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	if r.Method == "POST" {
+		err := roi.ClearNotifications(DB, env.User.ID)
+		if err != nil {
+			return err
+		}
+		http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+		return nil
+	}
+	ns, err := roi.UserNotifications(DB, env.User.ID)
+	if err != nil {
+		return err
+	}
+	recipe := struct {
+		Env           *Env
+		Notifications []*roi.Notification
+	}{
+		Env:           env,
+		Notifications: ns,
+	}
+	return executeTemplate(w, "notifications", recipe)
+}
+
+// checkSearchSubscriptions는 interval마다 구독중인 검색들의 결과를 확인해
+// 바뀐 검색이 있으면 구독자에게 알림을 보낸다. 서버가 꺼질 때까지 반환하지 않는다.
+func checkSearchSubscriptions(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		// 일부 구독의 확인에 실패했더라도 나머지 구독의 알림은 보내졌다.
+		n, err := roi.CheckSearchSubscriptions(DB)
+		if err != nil {
+			log.Printf("could not check search subscriptions: %v", err)
+		}
+		if n != 0 {
+			log.Printf("sent %d search subscription notifications", n)
+		}
+	}
+}
+
+// savedSearchesApiHandler는 쇼에서 세션 사용자가 볼 수 있는 저장된 검색들을 반환한다.
+// show를 쿼리로 받는다.
+// 결과는 roi.APIResponse의 json 형식으로 반환된다.
+func savedSearchesApiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	u := apiSessionUser(w, r)
+	if u == nil {
+		return
+	}
+	show := r.FormValue("show")
+	if show == "" {
+		apiBadRequest(w, fmt.Errorf("'show' not specified"))
+		return
+	}
+	ss, err := roi.UserSavedSearches(DB, show, u.ID)
+	if err != nil {
+		log.Printf("could not get saved searches: %v", err)
+		apiInternalServerError(w)
+		return
+	}
+	apiOK(w, ss)
+}
+
+// runSavedSearchApiHandler는 저장된 검색을 실행해 그 결과 유닛들을 표 형식으로 반환한다.
+// 검색 아이디(id)를 쿼리로 받으며, 공유되지 않은 검색은 소유자만 실행할 수 있다.
+// 결과는 roi.APIResponse의 json 형식으로 반환된다.
+func runSavedSearchApiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	u := apiSessionUser(w, r)
+	if u == nil {
+		return
+	}
+	show, owner, name, err := roi.SplitSavedSearchID(r.FormValue("id"))
+	if err != nil {
+		apiBadRequest(w, err)
+		return
+	}
+	s, err := roi.GetSavedSearch(DB, show, owner, name)
+	if err != nil {
+		if errors.As(err, &roi.NotFoundError{}) {
+			apiBadRequest(w, err)
+			return
+		}
+		log.Printf("could not get saved search: %v", err)
+		apiInternalServerError(w)
+		return
+	}
+	if !s.VisibleTo(u.ID) {
+		apiBadRequest(w, fmt.Errorf("saved search not found: %s", s.ID()))
+		return
+	}
+	us, err := roi.RunSavedSearch(DB, s)
+	if err != nil {
+		if errors.As(err, &roi.BadRequestError{}) {
+			apiBadRequest(w, err)
+			return
+		}
+		log.Printf("could not run saved search: %v", err)
+		apiInternalServerError(w)
+		return
+	}
+	table, err := roi.UnitTable(DB, us)
+	if err != nil {
+		log.Printf("could not run saved search: %v", err)
+		apiInternalServerError(w)
+		return
+	}
+	apiOK(w, roi.JSONTable(table))
+}
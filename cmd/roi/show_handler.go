@@ -3,7 +3,6 @@ package main
 import (
 	"errors"
 	"net/http"
-	"strings"
 
 	"github.com/studio2l/roi"
 )
@@ -32,7 +31,7 @@ func showsHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 		sgrps := make([]*roi.Group, 0)
 		agrps := make([]*roi.Group, 0)
 		for _, g := range gs {
-			if strings.IndexAny(g.Group, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == 0 {
+			if roi.IsShotGroup(g.Group) {
 				sgrps = append(sgrps, g)
 			} else {
 				agrps = append(agrps, g)
@@ -113,14 +112,24 @@ func updateShowHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 	if err != nil {
 		return err
 	}
+	site, err := roi.GetSite(DB)
+	if err != nil {
+		return err
+	}
+	defs, err := roi.AttrDefs(DB, roi.AttrKindShow, "")
+	if err != nil {
+		return err
+	}
 	recipe := struct {
-		Env           *Env
-		Show          *roi.Show
-		AllShowStatus []roi.ShowStatus
+		Env          *Env
+		Show         *roi.Show
+		ShowStatuses []*roi.StatusDef
+		AttrDefs     []*roi.AttrDef
 	}{
-		Env:           env,
-		Show:          p,
-		AllShowStatus: roi.AllShowStatus,
+		Env:          env,
+		Show:         p,
+		ShowStatuses: site.ShowStatusDefs(),
+		AttrDefs:     defs,
 	}
 	return executeTemplate(w, "update-show", recipe)
 }
@@ -141,6 +150,10 @@ func updateShowPostHandler(w http.ResponseWriter, r *http.Request, env *Env) err
 	if err != nil {
 		return err
 	}
+	defs, err := roi.AttrDefs(DB, roi.AttrKindShow, "")
+	if err != nil {
+		return err
+	}
 	s.Status = r.FormValue("status")
 	s.Supervisor = r.FormValue("supervisor")
 	s.CGSupervisor = r.FormValue("cg_supervisor")
@@ -149,20 +162,9 @@ func updateShowPostHandler(w http.ResponseWriter, r *http.Request, env *Env) err
 	s.DueDate = timeForms["due_date"]
 	s.Tags = fieldSplit(r.FormValue("tags"))
 	s.Notes = r.FormValue("notes")
-	s.Attrs = make(roi.DBStringMap)
-
-	for _, ln := range strings.Split(r.FormValue("attrs"), "\n") {
-		kv := strings.SplitN(ln, ":", 2)
-		if len(kv) != 2 {
-			continue
-		}
-		k := strings.TrimSpace(kv[0])
-		v := strings.TrimSpace(kv[1])
-		if k == "" || v == "" {
-			continue
-		}
-		s.Attrs[k] = v
-	}
+	s.Attrs = attrsFromForm(r, defs)
+	s.GroupAttrDefs = lineSplit(r.FormValue("group_attr_defs"))
+	s.UnitAttrDefs = lineSplit(r.FormValue("unit_attr_defs"))
 
 	err = roi.UpdateShow(DB, s)
 	if err != nil {
@@ -19,14 +19,34 @@ func siteHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 	if err != nil {
 		return err
 	}
+	shows, err := roi.AllShows(DB)
+	if err != nil {
+		return err
+	}
+	// 쇼, 그룹, 유닛의 속성 정의는 같은 형식이므로 템플릿에서 반복해 보인다.
+	type attrDefField struct {
+		Name  string
+		Label string
+		Defs  []string
+	}
 	recipe := struct {
-		Env   *Env
-		Site  *roi.Site
-		Users []*roi.User
+		Env             *Env
+		Site            *roi.Site
+		Users           []*roi.User
+		Shows           []*roi.Show
+		DefaultWorkflow string
+		AttrDefFields   []attrDefField
 	}{
-		Env:   env,
-		Site:  s,
-		Users: us,
+		Env:             env,
+		Site:            s,
+		Users:           us,
+		Shows:           shows,
+		DefaultWorkflow: roi.DefaultWorkflow,
+		AttrDefFields: []attrDefField{
+			{Name: "show_attr_defs", Label: "쇼 속성 정의", Defs: s.ShowAttrDefs},
+			{Name: "group_attr_defs", Label: "그룹 속성 정의", Defs: s.GroupAttrDefs},
+			{Name: "unit_attr_defs", Label: "유닛 속성 정의", Defs: s.UnitAttrDefs},
+		},
 	}
 	return executeTemplate(w, "site", recipe)
 }
@@ -43,6 +63,13 @@ func sitePostHander(w http.ResponseWriter, r *http.Request, env *Env) error {
 		Leads:             formValues(r, "leads"),
 		Notes:             r.FormValue("notes"),
 		Attrs:             make(roi.DBStringMap),
+		Workflow:          r.FormValue("workflow"),
+		Statuses:          lineSplit(r.FormValue("statuses")),
+		ShowStatuses:      lineSplit(r.FormValue("show_statuses")),
+		ShowAttrDefs:      lineSplit(r.FormValue("show_attr_defs")),
+		GroupAttrDefs:     lineSplit(r.FormValue("group_attr_defs")),
+		UnitAttrDefs:      lineSplit(r.FormValue("unit_attr_defs")),
+		LibraryShow:       r.FormValue("library_show"),
 	}
 
 	for _, ln := range strings.Split(r.FormValue("attrs"), "\n") {
@@ -58,6 +85,11 @@ func sitePostHander(w http.ResponseWriter, r *http.Request, env *Env) error {
 		s.Attrs[k] = v
 	}
 
+	// 기본 워크플로우를 그대로 둔 경우 사이트에는 빈 워크플로우로 저장해
+	// 이후 기본 워크플로우가 바뀌면 그를 따르도록 한다.
+	if strings.TrimSpace(s.Workflow) == strings.TrimSpace(roi.DefaultWorkflow) {
+		s.Workflow = ""
+	}
 	err := roi.UpdateSite(DB, s)
 	if err != nil {
 		return err
@@ -22,6 +22,28 @@ func fieldSplit(s string) []string {
 	return fs
 }
 
+// lineSplit은 문자열을 줄 단위로 잘라 슬라이스로 반환한다.
+// 잘린 문자열 양 옆의 빈 문자열은 함께 지워진다.
+// 혹시 줄이 빈 문자열이라면 그 항목은 포함되지 않는다.
+//
+// 예) lineSplit("a\n b\n\n") => []string{"a", "b"}
+func lineSplit(s string) []string {
+	ss := strings.Split(s, "\n")
+	ls := make([]string, 0, len(ss))
+	for _, l := range ss {
+		l = strings.TrimSpace(l)
+		if l != "" {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+// lineJoin은 문자열 슬라이스를 줄바꿈으로 이은 문자열을 반환한다.
+func lineJoin(ss []string) string {
+	return strings.Join(ss, "\n")
+}
+
 // fieldJoin은 문자열 슬라이스를 콤마로 이은 문자열을 반환한다.
 func fieldJoin(ss []string) string {
 	return strings.Join(ss, ", ")
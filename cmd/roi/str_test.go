@@ -28,6 +28,28 @@ func TestFieldSplit(t *testing.T) {
 	}
 }
 
+func TestLineSplit(t *testing.T) {
+	cases := []struct {
+		s    string
+		want []string
+	}{
+		{
+			s:    "a\n b \r\n\n c, d\n",
+			want: []string{"a", "b", "c, d"},
+		},
+		{
+			s:    "",
+			want: []string{},
+		},
+	}
+	for _, c := range cases {
+		got := lineSplit(c.s)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("lineSplit: got: %v, want: %v", got, c.want)
+		}
+	}
+}
+
 func TestAtoi(t *testing.T) {
 	cases := []struct {
 		s    string
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/studio2l/roi"
+)
+
+// exportCSVHandler는 검색된 유닛들을 csv 파일로 내려받게 한다.
+// delimiter로 구분자를 정할 수 있으며, 엑셀에서 한글이 깨지지 않도록 기본적으로 BOM을 붙인다.
+// bom=0 이면 BOM을 붙이지 않는다.
+func exportCSVHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	delim, err := roi.ParseDelimiter(r.FormValue("delimiter"))
+	if err != nil {
+		return err
+	}
+	show, table, err := searchUnitTable(r)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-units.csv", show))
+	return roi.WriteCSVTable(w, table, delim, r.FormValue("bom") != "0")
+}
+
+// exportJSONHandler는 검색된 유닛들을 json 파일로 내려받게 한다.
+func exportJSONHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	show, table, err := searchUnitTable(r)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-units.json", show))
+	return roi.WriteJSONTable(w, table)
+}
+
+// exportUnitsApiHandler는 검색된 유닛들을 가져오기와 같은 열 이름을 키로 하는 json 배열로 반환한다.
+// 결과는 roi.APIResponse의 json 형식으로 반환된다.
+func exportUnitsApiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	_, table, err := searchUnitTable(r)
+	if err != nil {
+		if errors.As(err, &roi.BadRequestError{}) || errors.As(err, &roi.NotFoundError{}) {
+			apiBadRequest(w, err)
+			return
+		}
+		log.Printf("could not export units: %v", err)
+		apiInternalServerError(w)
+		return
+	}
+	apiOK(w, roi.JSONTable(table))
+}
+
+// apiUnitSearchResult는 유닛 검색 결과의 한 페이지를 api 응답으로 나타낸다.
+type apiUnitSearchResult struct {
+	Total  int
+	Offset int
+	Limit  int
+	Units  []map[string]string
+}
+
+// defaultApiUnitsLimit과 maxApiUnitsLimit은 유닛 검색 api에서 한번에 반환하는 기본, 최대 유닛 수이다.
+const (
+	defaultApiUnitsLimit = 100
+	maxApiUnitsLimit     = 1000
+)
+
+// searchUnitsApiHandler는 검색된 유닛들을 정렬해 한 페이지를 반환한다.
+// show, q, sort, offset, limit을 쿼리로 받는다. sort는 - 로 시작하면 역순이며,
+// limit이 없다면 100개, 최대 1000개까지 반환한다.
+// 결과는 roi.APIResponse의 json 형식으로 반환된다.
+func searchUnitsApiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	res, err := searchUnitsApi(r)
+	if err != nil {
+		if errors.As(err, &roi.BadRequestError{}) || errors.As(err, &roi.NotFoundError{}) {
+			apiBadRequest(w, err)
+			return
+		}
+		log.Printf("could not search units: %v", err)
+		apiInternalServerError(w)
+		return
+	}
+	apiOK(w, res)
+}
+
+func searchUnitsApi(r *http.Request) (*apiUnitSearchResult, error) {
+	err := mustFields(r, "show")
+	if err != nil {
+		return nil, err
+	}
+	sortKey, desc, err := roi.ParseUnitSort(r.FormValue("sort"))
+	if err != nil {
+		return nil, err
+	}
+	opt := roi.UnitSearchOptions{
+		Sort:  sortKey,
+		Desc:  desc,
+		Limit: defaultApiUnitsLimit,
+	}
+	if v := r.FormValue("offset"); v != "" {
+		opt.Offset, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, roi.BadRequest("invalid offset: %s", v)
+		}
+	}
+	if v := r.FormValue("limit"); v != "" {
+		opt.Limit, err = strconv.Atoi(v)
+		if err != nil || opt.Limit < 1 || opt.Limit > maxApiUnitsLimit {
+			return nil, roi.BadRequest("limit should be between 1 and %d: %s", maxApiUnitsLimit, v)
+		}
+	}
+	res, err := roi.SearchUnitsPage(DB, r.FormValue("show"), r.FormValue("q"), opt)
+	if err != nil {
+		return nil, err
+	}
+	table, err := roi.UnitTable(DB, res.Units)
+	if err != nil {
+		return nil, err
+	}
+	return &apiUnitSearchResult{
+		Total:  res.Total,
+		Offset: res.Offset,
+		Limit:  res.Limit,
+		Units:  roi.JSONTable(table),
+	}, nil
+}
+
+// apiImportError는 가져오기 중 에러가 난 줄을 api 응답으로 나타낸다.
+type apiImportError struct {
+	Line  int
+	Unit  string
+	Error string
+}
+
+// apiImportResult는 가져오기 계획 또는 적용 결과를 api 응답으로 나타낸다.
+type apiImportResult struct {
+	Applied   bool
+	Added     []string
+	Changed   []string
+	Unchanged int
+	Errors    []apiImportError
+}
+
+// importUnitsApiHandler는 요청 바디의 csv 또는 json 표로 유닛을 가져온다.
+// format(csv 또는 json)과 csv의 delimiter를 쿼리로 받는다.
+// apply=1 이 아니라면 db를 수정하지 않고 가져오기 계획만 반환하며,
+// apply=1 이더라도 에러가 있는 줄이 있다면 아무것도 적용하지 않는다.
+// 태스크 상태의 전환은 세션 사용자의 권한으로 검사한다.
+// 결과는 roi.APIResponse의 json 형식으로 반환된다.
+func importUnitsApiHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	u := apiSessionUser(w, r)
+	if u == nil {
+		return
+	}
+	if r.Method != "POST" {
+		apiBadRequest(w, fmt.Errorf("only post method allowed"))
+		return
+	}
+	q := r.URL.Query()
+	delim, err := roi.ParseDelimiter(q.Get("delimiter"))
+	if err != nil {
+		apiBadRequest(w, err)
+		return
+	}
+	table, err := roi.ReadTable(r.Body, q.Get("format"), delim)
+	if err != nil {
+		apiBadRequest(w, err)
+		return
+	}
+	im, err := roi.PlanUnitImport(DB, table, u.ID)
+	if err != nil {
+		if errors.As(err, &roi.BadRequestError{}) {
+			apiBadRequest(w, err)
+			return
+		}
+		log.Printf("could not plan unit import: %v", err)
+		apiInternalServerError(w)
+		return
+	}
+	res := apiImportResult{
+		Added:     make([]string, 0),
+		Changed:   make([]string, 0),
+		Unchanged: im.Unchanged(),
+		Errors:    make([]apiImportError, 0),
+	}
+	for _, row := range im.Added() {
+		res.Added = append(res.Added, roi.JoinUnitID(row.Show, row.Group, row.Unit))
+	}
+	for _, row := range im.Changed() {
+		res.Changed = append(res.Changed, roi.JoinUnitID(row.Show, row.Group, row.Unit))
+	}
+	for _, row := range im.Invalid() {
+		res.Errors = append(res.Errors, apiImportError{
+			Line:  row.Line,
+			Unit:  roi.JoinUnitID(row.Show, row.Group, row.Unit),
+			Error: row.Err.Error(),
+		})
+	}
+	if q.Get("apply") == "1" && len(res.Errors) == 0 {
+		err = roi.ApplyUnitImport(DB, im, u.ID)
+		if err != nil {
+			log.Printf("could not apply unit import: %v", err)
+			apiInternalServerError(w)
+			return
+		}
+		res.Applied = true
+	}
+	apiOK(w, res)
+}
@@ -1,9 +1,7 @@
 package main
 
 import (
-	"errors"
 	"net/http"
-	"strings"
 
 	"github.com/studio2l/roi"
 )
@@ -33,6 +31,14 @@ func updateTaskHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 	if err != nil {
 		return err
 	}
+	site, err := roi.GetSite(DB)
+	if err != nil {
+		return err
+	}
+	wf, err := roi.SiteWorkflow(DB)
+	if err != nil {
+		return err
+	}
 	recipe := struct {
 		Env           *Env
 		Task          *roi.Task
@@ -40,9 +46,10 @@ func updateTaskHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 		Versions      []*roi.Version
 		Users         []*roi.User
 	}{
-		Env:           env,
-		Task:          t,
-		AllTaskStatus: roi.AllTaskStatus,
+		Env:  env,
+		Task: t,
+		// 사용자가 바꿀 수 있는 상태만 보인다.
+		AllTaskStatus: wf.NextStatuses(site, t, env.User.ID),
 		Versions:      vers,
 		Users:         us,
 	}
@@ -82,7 +89,7 @@ func updateTaskPostHandler(w http.ResponseWriter, r *http.Request, env *Env) err
 	t.ReviewVersion = r.FormValue("review_version")
 	t.WorkingVersion = r.FormValue("working_version")
 
-	err = roi.UpdateTask(DB, t)
+	err = roi.UpdateTaskAs(DB, t, env.User.ID)
 	if err != nil {
 		return err
 	}
@@ -112,6 +119,10 @@ func updateMultiTasksHandler(w http.ResponseWriter, r *http.Request, env *Env) e
 	if err != nil {
 		return err
 	}
+	wf, err := roi.SiteWorkflow(DB)
+	if err != nil {
+		return err
+	}
 	recipe := struct {
 		Env           *Env
 		Show          string
@@ -123,7 +134,7 @@ func updateMultiTasksHandler(w http.ResponseWriter, r *http.Request, env *Env) e
 		Show:          show,
 		IDs:           ids,
 		Tasks:         site.Tasks,
-		AllTaskStatus: roi.AllTaskStatus,
+		AllTaskStatus: wf.Statuses(),
 	}
 	return executeTemplate(w, "update-multi-tasks", recipe)
 }
@@ -143,42 +154,23 @@ func updateMultiTasksPostHandler(w http.ResponseWriter, r *http.Request, env *En
 	dueDate := tforms["due_date"]
 	status := r.FormValue("status")
 	assignee := r.FormValue("assignee")
+	// 여러 샷의 태스크를 한꺼번에 처리할 때는 어떤 샷에는
+	// 해당 태스크가 없을수도 있으며, 이런 샷은 건너뛴다.
+	taskIDs := make([]string, 0, len(ids))
 	for _, id := range ids {
 		show, grp, unit, err := roi.SplitUnitID(id)
 		if err != nil {
 			return err
 		}
-		s, err := roi.GetTask(DB, show, grp, unit, task)
-		if err != nil {
-			if errors.As(err, &roi.NotFoundError{}) {
-				// 여러 샷의 태스크를 한꺼번에 처리할 때는 어떤 샷에는
-				// 해당 태스크가 없을수도 있다.
-				// 이럴 경우 에러를 내지 않기로 한다.
-				continue
-			}
-			return err
-		}
-		if !dueDate.IsZero() {
-			s.DueDate = dueDate
-		}
-		if status != "" {
-			s.Status = roi.Status(status)
-		}
-		if assignee != "" {
-			s.Assignee = assignee
-		}
-		roi.UpdateTask(DB, s)
+		taskIDs = append(taskIDs, roi.JoinTaskID(show, grp, unit, task))
 	}
-	q := ""
-	for i, id := range ids {
-		if i != 0 {
-			q += " "
-		}
-		shot := strings.Split(id, "/")[1]
-		q += shot
+	p := &roi.TaskPatch{
+		Status:   roi.Status(status),
+		DueDate:  dueDate,
+		Assignee: assignee,
 	}
-	// 여러 샷 수정 페이지 전인 shots 페이지로 돌아간다.
-	return executeTemplate(w, "history-go", -2)
+	results, err := roi.UpdateTasks(DB, taskIDs, p, env.User.ID)
+	return executeBulkResults(w, env, "태스크 수정", results, err)
 }
 
 func reviewTaskHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
@@ -220,17 +212,23 @@ func reviewTaskHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 		}
 		reviews[v.ID()] = rvs
 	}
+	wf, err := roi.SiteWorkflow(DB)
+	if err != nil {
+		return err
+	}
 	recipe := struct {
 		Env             *Env
 		Task            *roi.Task
 		Versions        []*roi.Version
 		Reviews         map[string][]*roi.Review
+		ReviewStatus    []roi.Status
 		ShowAllVersions bool
 	}{
 		Env:             env,
 		Task:            t,
 		Versions:        vs,
 		Reviews:         reviews,
+		ReviewStatus:    wf.Review,
 		ShowAllVersions: showAllVersions,
 	}
 	return executeTemplate(w, "review-task", recipe)
@@ -260,29 +258,11 @@ func reviewTaskPostHandler(w http.ResponseWriter, r *http.Request, env *Env) err
 		Msg:       r.FormValue("msg"),
 		Status:    status,
 	}
-	err = roi.AddReview(DB, rv)
+	// 리뷰 상태에 따른 태스크 수정은 사이트 워크플로우를 따른다.
+	err = roi.ReviewTask(DB, rv)
 	if err != nil {
 		return err
 	}
-	t, err := roi.GetTask(DB, show, grp, unit, task)
-	if err != nil {
-		return err
-	}
-	if status != "" {
-		switch status {
-		case roi.StatusApproved:
-			t.ReviewVersion = ""
-			t.ApprovedVersion = ver
-		case roi.StatusRetake:
-			t.ReviewVersion = ""
-		default:
-			return roi.BadRequest("invalid review status: %s", status)
-		}
-		err = roi.UpdateTask(DB, t)
-		if err != nil {
-			return err
-		}
-	}
 	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
 	return nil
 }
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/kybin/bml"
+	"github.com/studio2l/roi"
 )
 
 // templates에는 사용자에게 보일 페이지의 템플릿이 담긴다.
@@ -22,6 +23,10 @@ var templates *template.Template
 func executeTemplate(w http.ResponseWriter, name string, data interface{}) error {
 	if dev {
 		parseTemplate()
+		err := loadCatalogs()
+		if err != nil {
+			return err
+		}
 	}
 	err := templates.ExecuteTemplate(w, name, data)
 	if err != nil {
@@ -44,8 +49,16 @@ func parseTemplate() {
 		"sub":                 func(a, b int) int { return a - b },
 		"fieldJoin":           fieldJoin,
 		"spaceJoin":           func(words []string) string { return strings.Join(words, " ") },
+		"lineJoin":            lineJoin,
 		"versionPreviewFiles": versionPreviewFiles,
 		"basename":            filepath.Base,
+		"tr":                  tr,
+		"notificationMessage": notificationMessage,
+		"languageName":        languageName,
+		"attrForm":            newAttrForm,
+		"sequenceList":        newSequenceList,
+		"statusLabel":         statusLabel,
+		"statusColor":         statusColor,
 	}
 	templates = template.New("").Funcs(fmap)
 	templates = template.Must(bml.ToHTMLTemplate(templates, "tmpl/*"))
@@ -54,6 +67,46 @@ func parseTemplate() {
 // 아래는 템플릿 안에서 사용되는 함수들이다.
 //
 
+// sequenceList는 version-sequences 템플릿에서 시퀀스들을 사용자의 언어로 보이기 위한 정보이다.
+type sequenceList struct {
+	Env       *Env
+	Sequences []*roi.Sequence
+}
+
+// newSequenceList는 템플릿에서 sequenceList를 만드는 함수이다.
+func newSequenceList(env *Env, seqs []*roi.Sequence) *sequenceList {
+	return &sequenceList{Env: env, Sequences: seqs}
+}
+
+// templateSite는 템플릿 함수에서 상태 정의 등을 찾을 사이트를 반환한다.
+// 사이트를 가지고 올 수 없다면 기본 사이트를 반환한다.
+func templateSite() *roi.Site {
+	if DB == nil {
+		return roi.DefaultSite
+	}
+	s, err := roi.GetSite(DB)
+	if err != nil {
+		return roi.DefaultSite
+	}
+	return s
+}
+
+// statusLabel은 사이트에 정의된 상태의 레이블을 사용자의 언어로 번역해 반환한다.
+func statusLabel(env *Env, s roi.Status) string {
+	return translate(env.Language(), templateSite().StatusDef(s).Label)
+}
+
+// statusColor는 사이트에 정의된 상태의 색상을 css 값으로 반환한다.
+// 상태 색상은 색상 이름이나 #rrggbb 형식으로 검사된 값이므로 그대로 css에 사용한다.
+// 색상 이름은 roi.css에 정의된 변수를 가리킨다.
+func statusColor(s roi.Status) template.CSS {
+	c := templateSite().StatusDef(s).Color
+	if strings.HasPrefix(c, "#") {
+		return template.CSS(c)
+	}
+	return template.CSS("var(--" + c + ")")
+}
+
 // hasThumbnail은 해당 특정 프로젝트 샷에 썸네일이 있는지 검사한다.
 //
 // 주의: 만일 썸네일 파일 검사시 에러가 나면 이 함수는 썸네일이 있다고 판단한다.
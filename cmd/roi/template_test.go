@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/studio2l/roi"
+)
+
+func TestStatusColorsDefined(t *testing.T) {
+	// statusColor는 색상 이름을 roi.css의 변수로 바꾸므로 모든 색상 이름이 정의되어 있어야 한다.
+	css, err := ioutil.ReadFile("static/roi.css")
+	if err != nil {
+		t.Fatalf("could not read roi.css: %v", err)
+	}
+	for _, c := range roi.StatusColors {
+		if !strings.Contains(string(css), "--"+c+":") {
+			t.Fatalf("color not defined in roi.css: %s", c)
+		}
+	}
+}
+
+func TestUpdateVersionTemplate(t *testing.T) {
+	parseTemplate()
+	err := loadCatalogs()
+	if err != nil {
+		t.Fatalf("could not load catalogs: %v", err)
+	}
+	v := &roi.Version{
+		Show:        "TEST",
+		Group:       "CG",
+		Unit:        "0010",
+		Task:        "comp",
+		Version:     "v001",
+		OutputFiles: []string{"/path/comp.####.exr 1001-1010"},
+	}
+	recipe := struct {
+		Env              *Env
+		Version          *roi.Version
+		IsWorkingVersion bool
+		IsPublishVersion bool
+	}{
+		Env:     &Env{User: &roi.User{ID: "admin"}, Lang: "en"},
+		Version: v,
+	}
+	var b bytes.Buffer
+	err = templates.ExecuteTemplate(&b, "update-version", recipe)
+	if err != nil {
+		t.Fatalf("could not execute update-version: %v", err)
+	}
+	if !strings.Contains(b.String(), "1001-1010 (10 frames)") {
+		t.Fatalf("sequence range not rendered in the user's language")
+	}
+}
+
+func TestLoginTemplate(t *testing.T) {
+	// 로그인 페이지는 사용자 없이 그려지지만 요청 언어로 번역되어야 한다.
+	parseTemplate()
+	err := loadCatalogs()
+	if err != nil {
+		t.Fatalf("could not load catalogs: %v", err)
+	}
+	for _, name := range []string{"login", "signup"} {
+		recipe := struct {
+			Env *Env
+		}{
+			Env: &Env{Lang: "en"},
+		}
+		var b bytes.Buffer
+		err = templates.ExecuteTemplate(&b, name, recipe)
+		if err != nil {
+			t.Fatalf("could not execute %s: %v", name, err)
+		}
+		if !strings.Contains(b.String(), "Password") {
+			t.Fatalf("%s: not rendered in the user's language", name)
+		}
+	}
+}
+
+func TestTemplateMessagesTranslated(t *testing.T) {
+	// 템플릿에서 tr로 감싼 한글 메시지는 모두 영어 카탈로그에 있어야 한다.
+	err := loadCatalogs()
+	if err != nil {
+		t.Fatalf("could not load catalogs: %v", err)
+	}
+	files, err := filepath.Glob("tmpl/*.bml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`\{\{tr \$?\.?Env "([^"]*)"`)
+	hangul := regexp.MustCompile(`\p{Hangul}`)
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatalf("could not read %s: %v", f, err)
+		}
+		for _, m := range re.FindAllStringSubmatch(string(data), -1) {
+			msg := m[1]
+			if !hangul.MatchString(msg) {
+				continue
+			}
+			if _, ok := catalogs["en"][msg]; !ok {
+				t.Fatalf("%s: no english translation: %s", f, msg)
+			}
+		}
+	}
+}
+
+func TestBulkResultsTemplate(t *testing.T) {
+	parseTemplate()
+	err := loadCatalogs()
+	if err != nil {
+		t.Fatalf("could not load catalogs: %v", err)
+	}
+	env := &Env{User: &roi.User{ID: "admin"}, Lang: "en"}
+	results := []*roi.BulkResult{
+		{ID: "TEST/CG/0010", Changed: true},
+		{ID: "TEST/CG/0020"},
+	}
+	rec := httptest.NewRecorder()
+	err = executeBulkResults(rec, env, "유닛 수정", results, nil)
+	if err != nil {
+		t.Fatalf("could not execute bulk-results: %v", err)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "1 of 2 items were updated.") || !strings.Contains(body, "Unchanged") {
+		t.Fatalf("bulk results not rendered")
+	}
+	// 에러가 있는 항목이 있다면 항목별 에러를 BadRequest 상태로 보여준다.
+	results = append(results, &roi.BulkResult{ID: "TEST/CG/0030", Error: "unit not found"})
+	rec = httptest.NewRecorder()
+	err = executeBulkResults(rec, env, "유닛 수정", results, roi.BadRequest("1 of 3 items could not be updated"))
+	if err != nil {
+		t.Fatalf("could not execute bulk-results: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unit not found") {
+		t.Fatalf("bulk errors not rendered: %d", rec.Code)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/studio2l/roi"
+)
+
+// textSearchSection은 본문 검색 결과 중 한 종류의 항목들이다.
+type textSearchSection struct {
+	Title string
+	Hits  []*textSearchHit
+}
+
+// textSearchHit은 본문 검색에서 찾은 항목과 보여줄 글들이다.
+type textSearchHit struct {
+	Hit    *roi.TextSearchHit
+	Fields []*textSearchField
+}
+
+// textSearchField는 찾은 항목의 글 하나와 그 글을 설명하는 이름이다.
+type textSearchField struct {
+	Label string
+	Text  string
+}
+
+// textSearchTitles는 본문 검색 결과의 종류별 제목이다.
+var textSearchTitles = map[string]string{
+	roi.SearchKindShow:   "쇼",
+	roi.SearchKindGroup:  "그룹",
+	roi.SearchKindUnit:   "유닛",
+	roi.SearchKindReview: "리뷰",
+}
+
+// textSearchFieldLabel은 검색된 글의 필드를 사용자가 읽을 수 있는 이름으로 바꾼다.
+func textSearchFieldLabel(kind, field string) string {
+	if kind == roi.SearchKindReview {
+		// 리뷰의 필드는 작성 시간이다.
+		t, err := time.Parse(time.RFC3339Nano, field)
+		if err != nil {
+			return field
+		}
+		return t.Local().Format("2006-01-02 15:04")
+	}
+	switch field {
+	case "description":
+		return "내용"
+	case "cg_description":
+		return "작업 내용"
+	case "notes":
+		return "노트"
+	}
+	return strings.TrimPrefix(field, "attr.")
+}
+
+// textSearchHandler는 쇼의 설명, 노트, 리뷰, 커스텀 속성에서 검색어의 단어들을 찾아
+// 항목의 종류별로 나누어 보여준다.
+func textSearchHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	shows, err := roi.AllShows(DB)
+	if err != nil {
+		return err
+	}
+	if len(shows) == 0 {
+		recipe := struct {
+			Env *Env
+		}{
+			Env: env,
+		}
+		return executeTemplate(w, "no-shows", recipe)
+	}
+	show := r.FormValue("show")
+	if show == "" {
+		cfg, err := roi.GetUserConfig(DB, env.User.ID)
+		if err != nil {
+			return err
+		}
+		show = cfg.CurrentShow
+		if show == "" {
+			show = shows[0].Show
+		}
+	}
+	query := r.FormValue("q")
+	sections := make([]*textSearchSection, 0)
+	if strings.TrimSpace(query) != "" {
+		hits, err := roi.SearchText(DB, show, query)
+		if err != nil {
+			return err
+		}
+		var last *textSearchSection
+		lastKind := ""
+		for _, h := range hits {
+			// 검색 결과는 종류별로 정렬되어 있다.
+			if last == nil || lastKind != h.Kind {
+				last = &textSearchSection{Title: textSearchTitles[h.Kind]}
+				lastKind = h.Kind
+				sections = append(sections, last)
+			}
+			hit := &textSearchHit{Hit: h}
+			for _, f := range h.Fields {
+				hit.Fields = append(hit.Fields, &textSearchField{
+					Label: textSearchFieldLabel(h.Kind, f.Field),
+					Text:  f.Text,
+				})
+			}
+			last.Hits = append(last.Hits, hit)
+		}
+	}
+	recipe := struct {
+		Env      *Env
+		Shows    []*roi.Show
+		Show     string
+		Query    string
+		Sections []*textSearchSection
+	}{
+		Env:      env,
+		Shows:    shows,
+		Show:     show,
+		Query:    query,
+		Sections: sections,
+	}
+	return executeTemplate(w, "text-search", recipe)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/studio2l/roi"
@@ -111,6 +112,26 @@ func updateUnitHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 	for _, t := range ts {
 		tm[t.Task] = t
 	}
+	revs, err := roi.UnitCutRevisions(DB, show, grp, unit)
+	if err != nil {
+		return err
+	}
+	site, err := roi.GetSite(DB)
+	if err != nil {
+		return err
+	}
+	wf, err := roi.SiteWorkflow(DB)
+	if err != nil {
+		return err
+	}
+	defs, err := roi.AttrDefs(DB, roi.AttrKindUnit, show)
+	if err != nil {
+		return err
+	}
+	users, err := roi.AssetUsers(DB, show, grp, unit)
+	if err != nil {
+		return err
+	}
 	recipe := struct {
 		Env           *Env
 		Unit          *roi.Unit
@@ -118,13 +139,19 @@ func updateUnitHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 		Tasks         map[string]*roi.Task
 		AllTaskStatus []roi.Status
 		Thumbnail     string
+		CutRevisions  []*roi.CutRevision
+		AttrDefs      []*roi.AttrDef
+		AssetUsers    []*roi.Unit
 	}{
 		Env:           env,
 		Unit:          s,
-		AllUnitStatus: roi.AllUnitStatus,
+		AllUnitStatus: site.AvailableUnitStatuses(),
 		Tasks:         tm,
-		AllTaskStatus: roi.AllTaskStatus,
+		AllTaskStatus: wf.Statuses(),
 		Thumbnail:     "data/show/" + id + "/thumbnail.png",
+		CutRevisions:  revs,
+		AttrDefs:      defs,
+		AssetUsers:    users,
 	}
 	return executeTemplate(w, "update-unit", recipe)
 }
@@ -147,30 +174,25 @@ func updateUnitPostHandler(w http.ResponseWriter, r *http.Request, env *Env) err
 	if err != nil {
 		return err
 	}
+	defs, err := roi.AttrDefs(DB, roi.AttrKindUnit, show)
+	if err != nil {
+		return err
+	}
 	s.Status = roi.Status(r.FormValue("status"))
 	s.EditOrder = atoi(r.FormValue("edit_order"))
+	s.CutIn = atoi(r.FormValue("cut_in"))
+	s.CutOut = atoi(r.FormValue("cut_out"))
+	s.HeadHandle = atoi(r.FormValue("head_handle"))
+	s.TailHandle = atoi(r.FormValue("tail_handle"))
 	s.Description = r.FormValue("description")
 	s.CGDescription = r.FormValue("cg_description")
 	s.Tags = fieldSplit(r.FormValue("tags"))
 	s.Assets = fieldSplit(r.FormValue("assets"))
 	s.Tasks = fieldSplit(r.FormValue("tasks"))
 	s.DueDate = tforms["due_date"]
-	s.Attrs = make(roi.DBStringMap)
-
-	for _, ln := range strings.Split(r.FormValue("attrs"), "\n") {
-		kv := strings.SplitN(ln, ":", 2)
-		if len(kv) != 2 {
-			continue
-		}
-		k := strings.TrimSpace(kv[0])
-		v := strings.TrimSpace(kv[1])
-		if k == "" || v == "" {
-			continue
-		}
-		s.Attrs[k] = v
-	}
+	s.Attrs = attrsFromForm(r, defs)
 
-	err = roi.UpdateUnit(DB, s)
+	err = roi.UpdateUnitWithReason(DB, s, env.User.ID, r.FormValue("cut_reason"))
 	if err != nil {
 		return err
 	}
@@ -198,6 +220,10 @@ func updateMultiUnitsHandler(w http.ResponseWriter, r *http.Request, env *Env) e
 	if err != nil {
 		return err
 	}
+	site, err := roi.GetSite(DB)
+	if err != nil {
+		return err
+	}
 	recipe := struct {
 		Env           *Env
 		Show          string
@@ -207,7 +233,7 @@ func updateMultiUnitsHandler(w http.ResponseWriter, r *http.Request, env *Env) e
 		Env:           env,
 		Show:          show,
 		IDs:           ids,
-		AllUnitStatus: roi.AllUnitStatus,
+		AllUnitStatus: site.AvailableUnitStatuses(),
 	}
 	return executeTemplate(w, "update-multi-units", recipe)
 }
@@ -224,95 +250,47 @@ func updateMultiUnitsPostHandler(w http.ResponseWriter, r *http.Request, env *En
 	}
 	dueDate := tforms["due_date"]
 	status := r.FormValue("status")
-	tags := make([]string, 0)
-	for _, tag := range strings.Split(r.FormValue("tags"), ",") {
-		tag = strings.TrimSpace(tag)
-		if tag == "" {
+	// 빈 프레임 필드는 수정하지 않는다.
+	frames := make(map[string]int)
+	for _, field := range []string{"cut_in", "head_handle", "tail_handle"} {
+		v := strings.TrimSpace(r.FormValue(field))
+		if v == "" {
 			continue
 		}
-		if tag[0] != '+' && tag[0] != '-' {
-			roi.BadRequest("tag must be started with +/- got %s", tag)
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return roi.BadRequest("invalid %s: %s", field, v)
 		}
-		tags = append(tags, tag)
+		frames[field] = n
 	}
-	assets := make([]string, 0)
-	for _, asset := range strings.Split(r.FormValue("assets"), ",") {
-		asset = strings.TrimSpace(asset)
-		if asset == "" {
-			continue
-		}
-		if asset[0] != '+' && asset[0] != '-' {
-			return roi.BadRequest("asset must be started with +/- got %s", asset)
-		}
-		assets = append(assets, asset)
+	tags, err := roi.ParsePatchList("tag", r.FormValue("tags"))
+	if err != nil {
+		return err
 	}
-	workingTasks := make([]string, 0)
-	for _, task := range strings.Split(r.FormValue("tasks"), ",") {
-		task = strings.TrimSpace(task)
-		if task == "" {
-			continue
-		}
-		if task[0] != '+' && task[0] != '-' {
-			return roi.BadRequest("task must be started with +/- got %s", task)
-		}
-		workingTasks = append(workingTasks, task)
+	assets, err := roi.ParsePatchList("asset", r.FormValue("assets"))
+	if err != nil {
+		return err
 	}
-	for _, id := range ids {
-		show, grp, unit, err := roi.SplitUnitID(id)
-		if err != nil {
-			return err
-		}
-		s, err := roi.GetUnit(DB, show, grp, unit)
-		if err != nil {
-			return err
-		}
-		if !dueDate.IsZero() {
-			s.DueDate = dueDate
-		}
-		if status != "" {
-			s.Status = roi.Status(status)
-		}
-		for _, tag := range tags {
-			prefix := tag[0]
-			tag = tag[1:]
-			if prefix == '+' {
-				s.Tags = appendIfNotExist(s.Tags, tag)
-			} else if prefix == '-' {
-				s.Tags = removeIfExist(s.Tags, tag)
-			}
-		}
-		for _, asset := range assets {
-			prefix := asset[0]
-			asset = asset[1:]
-			if prefix == '+' {
-				s.Assets = appendIfNotExist(s.Assets, asset)
-			} else if prefix == '-' {
-				s.Assets = removeIfExist(s.Assets, asset)
-			}
-		}
-		for _, task := range workingTasks {
-			prefix := task[0]
-			task = task[1:]
-			if prefix == '+' {
-				s.Tasks = appendIfNotExist(s.Tasks, task)
-			} else if prefix == '-' {
-				s.Tasks = removeIfExist(s.Tasks, task)
-			}
-		}
-		err = roi.UpdateUnit(DB, s)
-		if err != nil {
-			return err
-		}
+	tasks, err := roi.ParsePatchList("task", r.FormValue("tasks"))
+	if err != nil {
+		return err
 	}
-	// 할일: 아래코드는 어디서 왔는가? 이상하다...
-	q := ""
-	for i, id := range ids {
-		if i != 0 {
-			q += " "
-		}
-		unit := strings.Split(id, "/")[1]
-		q += unit
+	p := &roi.UnitPatch{
+		Status:  roi.Status(status),
+		DueDate: dueDate,
+		Tags:    tags,
+		Assets:  assets,
+		Tasks:   tasks,
+	}
+	if n, ok := frames["cut_in"]; ok {
+		p.CutIn = &n
+	}
+	if n, ok := frames["head_handle"]; ok {
+		p.HeadHandle = &n
+	}
+	if n, ok := frames["tail_handle"]; ok {
+		p.TailHandle = &n
 	}
-	// 여러 샷 수정 페이지 전인 units 페이지로 돌아간다.
-	return executeTemplate(w, "history-go", -2)
+	results, err := roi.UpdateUnits(DB, ids, p, env.User.ID, r.FormValue("cut_reason"))
+	return executeBulkResults(w, env, "유닛 수정", results, err)
 }
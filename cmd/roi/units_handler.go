@@ -1,13 +1,53 @@
 package main
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/studio2l/roi"
 )
 
+// unitsPageSize는 유닛 페이지에서 한번에 보여주는 유닛 수이다.
+const unitsPageSize = 50
+
+// sortOption은 유닛 정렬 선택지 하나이다.
+type sortOption struct {
+	Value string
+	Label string
+}
+
+// unitSortOptions는 유닛 페이지에서 고를 수 있는 정렬 기준들이다.
+// 사이트에 정의된 태스크마다 그 태스크의 상태와 담당자로 정렬할 수 있다.
+// 레이블은 사용자의 언어로 번역된다.
+func unitSortOptions(env *Env, site *roi.Site) []sortOption {
+	opts := []sortOption{
+		{Value: "", Label: tr(env, "이름")},
+		{Value: "-name", Label: tr(env, "이름 (역순)")},
+		{Value: "edit-order", Label: tr(env, "편집 순서")},
+		{Value: "due", Label: tr(env, "마감일")},
+		{Value: "-due", Label: tr(env, "마감일 (역순)")},
+		{Value: "status", Label: tr(env, "상태")},
+		{Value: "-status", Label: tr(env, "상태 (역순)")},
+	}
+	for _, t := range site.Tasks {
+		opts = append(opts,
+			sortOption{Value: "task." + t + ".status", Label: tr(env, "%s 상태", t)},
+			sortOption{Value: "task." + t + ".assignee", Label: tr(env, "%s 담당자", t)},
+		)
+	}
+	return opts
+}
+
+// queryError는 검색어의 문법 에러를 보일 때 틀린 항목과 그 앞뒤를 나누어 담는다.
+type queryError struct {
+	Before string
+	Term   string
+	After  string
+	Msg    string
+}
+
 // unitsHandler는 /units/ 페이지로 사용자가 접속했을때 페이지를 반환한다.
 func unitsHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 	shows, err := roi.AllShows(DB)
@@ -83,38 +123,53 @@ func unitsHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 		return executeTemplate(w, "search-help", recipe)
 	}
 
-	grps := make([]string, 0)
-	shots := make([]string, 0)
-	f := make(map[string]string)
-	for _, v := range strings.Fields(query) {
-		kv := strings.Split(v, ":")
-		if len(kv) == 1 {
-			if v[len(v)-1] == '/' {
-				grps = append(grps, v[:len(v)-1])
-			} else {
-				shots = append(shots, v)
-			}
-		} else {
-			f[kv[0]] = kv[1]
+	sortBy := r.FormValue("sort")
+	sortKey, desc, err := roi.ParseUnitSort(sortBy)
+	if err != nil {
+		return err
+	}
+	page := 1
+	if v := r.FormValue("page"); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return roi.BadRequest("invalid page: %s", v)
 		}
 	}
-	// toTime은 문자열을 time.Time으로 변경하되 에러가 나면 버린다.
-	toTime := func(s string) time.Time {
-		t, _ := timeFromString(s)
-		return t
+	opt := roi.UnitSearchOptions{
+		Sort:   sortKey,
+		Desc:   desc,
+		Offset: (page - 1) * unitsPageSize,
+		Limit:  unitsPageSize,
 	}
-	ss, err := roi.SearchUnits(DB, show, grps, shots, f["tag"], f["status"], f["task"], f["assignee"], f["task-status"], toTime(f["due"]))
+	var qerr *queryError
+	res, err := roi.SearchUnitsPage(DB, show, query, opt)
+	if err != nil {
+		e := roi.QueryError{}
+		if !errors.As(err, &e) {
+			return err
+		}
+		// 검색어의 문법 에러는 검색창 아래에 틀린 부분을 표시한다.
+		qerr = &queryError{
+			Before: query[:e.Pos],
+			Term:   e.Term,
+			After:  query[e.Pos+len(e.Term):],
+			Msg:    e.Msg,
+		}
+		res = &roi.UnitSearchResult{Units: []*roi.Unit{}}
+	}
+	ss := res.Units
+	ids := make([]string, 0, len(ss))
+	for _, s := range ss {
+		ids = append(ids, s.ID())
+	}
+	unitTasks, err := roi.TasksOfUnits(DB, show, ids)
 	if err != nil {
 		return err
 	}
 	tasks := make(map[string]map[string]*roi.Task)
 	for _, s := range ss {
-		ts, err := roi.UnitTasks(DB, s.Show, s.Group, s.Unit)
-		if err != nil {
-			return err
-		}
 		tm := make(map[string]*roi.Task)
-		for _, t := range ts {
+		for _, t := range unitTasks[s.ID()] {
 			tm[t.Task] = t
 		}
 		tasks[s.Unit] = tm
@@ -123,6 +178,10 @@ func unitsHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 	if err != nil {
 		return err
 	}
+	wf, err := roi.SiteWorkflow(DB)
+	if err != nil {
+		return err
+	}
 	recipe := struct {
 		Env           *Env
 		Site          *roi.Site
@@ -133,16 +192,28 @@ func unitsHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 		Tasks         map[string]map[string]*roi.Task
 		AllTaskStatus []roi.Status
 		Query         string
+		QueryError    *queryError
+		Sort          string
+		SortOptions   []sortOption
+		Total         int
+		Page          int
+		Pages         int
 	}{
 		Env:           env,
 		Site:          site,
 		Shows:         shows,
 		Show:          show,
 		Units:         ss,
-		AllUnitStatus: roi.AllUnitStatus,
+		AllUnitStatus: site.AvailableUnitStatuses(),
 		Tasks:         tasks,
-		AllTaskStatus: roi.AllTaskStatus,
+		AllTaskStatus: wf.Statuses(),
 		Query:         query,
+		QueryError:    qerr,
+		Sort:          sortBy,
+		SortOptions:   unitSortOptions(env, site),
+		Total:         res.Total,
+		Page:          page,
+		Pages:         (res.Total + unitsPageSize - 1) / unitsPageSize,
 	}
 	return executeTemplate(w, "units", recipe)
 }
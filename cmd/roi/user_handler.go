@@ -36,7 +36,12 @@ func loginHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return nil
 	}
-	return executeTemplate(w, "login", nil)
+	recipe := struct {
+		Env *Env
+	}{
+		Env: env,
+	}
+	return executeTemplate(w, "login", recipe)
 }
 
 // logoutHandler는 /logout 페이지로 사용자가 접속했을때 사용자를 로그아웃 시킨다.
@@ -77,7 +82,12 @@ func signupHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return nil
 	}
-	return executeTemplate(w, "signup", nil)
+	recipe := struct {
+		Env *Env
+	}{
+		Env: env,
+	}
+	return executeTemplate(w, "signup", recipe)
 }
 
 // profileHandler는 /profile 페이지로 사용자가 접속했을 때 사용자 프로필 페이지를 반환한다.
@@ -106,16 +116,43 @@ func profileHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 		http.Redirect(w, r, "/settings/profile", http.StatusSeeOther)
 		return nil
 	}
+	cfg, err := roi.GetUserConfig(DB, env.User.ID)
+	if err != nil {
+		return err
+	}
 	recipe := struct {
-		Env  *Env
-		User *roi.User
+		Env       *Env
+		User      *roi.User
+		Language  string
+		Languages []string
 	}{
-		Env:  env,
-		User: env.User,
+		Env:       env,
+		User:      env.User,
+		Language:  cfg.Language,
+		Languages: roi.AllLanguages,
 	}
 	return executeTemplate(w, "profile", recipe)
 }
 
+// updateLanguageHandler는 /settings/language 페이지로 사용자가 선택한 언어를 받아
+// 사용자가 UI에서 사용할 언어로 저장한다. 빈 문자열은 브라우저 설정을 따른다는 뜻이다.
+func updateLanguageHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
+	if r.Method != "POST" {
+		return roi.BadRequest("only post method allowed")
+	}
+	cfg, err := roi.GetUserConfig(DB, env.User.ID)
+	if err != nil {
+		return err
+	}
+	cfg.Language = r.FormValue("language")
+	err = roi.UpdateUserConfig(DB, env.User.ID, cfg)
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, r, "/settings/profile", http.StatusSeeOther)
+	return nil
+}
+
 // updatePasswordHandler는 /update-password 페이지로 사용자가 패스워드 변경과 관련된 정보를 보내면
 // 사용자 패스워드를 변경한다.
 func updatePasswordHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
@@ -215,6 +252,10 @@ func userHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 		}
 		numTasks[t.Show][t.Status] += 1
 	}
+	wf, err := roi.SiteWorkflow(DB)
+	if err != nil {
+		return err
+	}
 	recipe := struct {
 		Env           *Env
 		User          string
@@ -230,7 +271,7 @@ func userHandler(w http.ResponseWriter, r *http.Request, env *Env) error {
 		NumTasks:      numTasks,
 		TaskFromID:    taskFromID,
 		TasksOfDay:    tasksOfDay,
-		AllTaskStatus: roi.AllTaskStatus,
+		AllTaskStatus: wf.Statuses(),
 	}
 	return executeTemplate(w, "user", recipe)
 }
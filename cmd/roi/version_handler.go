@@ -117,8 +117,9 @@ func updateVersionPostHandler(w http.ResponseWriter, r *http.Request, env *Env)
 	if err != nil {
 		return err
 	}
-	v.OutputFiles = fieldSplit(r.FormValue("output_files"))
-	v.Images = fieldSplit(r.FormValue("images"))
+	// 시퀀스 문자열에는 콤마가 들어갈 수 있으므로 줄 단위로 나눈다.
+	v.OutputFiles = lineSplit(r.FormValue("output_files"))
+	v.Images = lineSplit(r.FormValue("images"))
 	v.WorkFile = r.FormValue("work_file")
 
 	err = roi.UpdateVersion(DB, v)
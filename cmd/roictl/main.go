@@ -0,0 +1,284 @@
+// roictl은 로이 db의 유닛을 csv 또는 json 파일로 내보내거나 가져오는 명령이다.
+//
+//	roictl export -show test -q "CG_0010 tag:로이" -format csv -o units.csv
+//	roictl import -apply units.csv
+//	roictl reindex -show test
+//
+// 가져오기는 웹의 엑셀 업로드와 같은 열 이름과 검증을 사용한다.
+// -apply 없이 실행하면 db를 수정하지 않고 바뀔 내용만 출력한다.
+//
+// reindex는 쇼의 본문 검색 색인과 애셋 참조 색인, 타입이 정해진 커스텀 속성 값을 다시 만든다.
+// 색인이 추가되기 전에 만들어진 쇼는 한번 실행해야 검색되고 애셋 사용처가 보인다.
+// 커스텀 속성의 정의를 추가하거나 바꾼 후에도 실행해야 속성 값을 비교 검색할 수 있다.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/studio2l/roi"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = exportMain(os.Args[2:])
+	case "import":
+		err = importMain(os.Args[2:])
+	case "reindex":
+		err = reindexMain(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: roictl <command> [flags]
+
+commands:
+  export  export searched units as csv or json
+  import  import units from a csv or json file
+  reindex rebuild the full-text search, asset reference and typed attribute indexes of shows
+
+run 'roictl <command> -h' for the command's flags.`)
+}
+
+// dbFlags는 db 접속에 필요한 플래그이다. roi 서버와 같은 기본값을 사용한다.
+type dbFlags struct {
+	addr string
+	ca   string
+	cert string
+	key  string
+}
+
+func (d *dbFlags) register(fs *flag.FlagSet) {
+	addr := "localhost:26257"
+	if env := os.Getenv("ROI_DB_ADDR"); env != "" {
+		addr = env
+	}
+	fs.StringVar(&d.addr, "db-addr", addr, "host url and port of database. default is from ROI_DB_ADDR if it is not empty.")
+	fs.StringVar(&d.ca, "db-ca", "db-cert/ca.crt", "root certificate authority file of the database.")
+	fs.StringVar(&d.cert, "db-cert", "db-cert/client.root.crt", "client certificate file of database.")
+	fs.StringVar(&d.key, "db-key", "db-cert/client.root.key", "client key file of database.")
+}
+
+func (d *dbFlags) open() (*sql.DB, error) {
+	db, err := roi.InitDB(d.addr, d.ca, d.cert, d.key)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize database: %w", err)
+	}
+	return db, nil
+}
+
+// tableFormat은 -format 플래그가 비어있을 때 파일 확장자로 형식을 정한다.
+func tableFormat(format, file string) string {
+	if format != "" {
+		return format
+	}
+	if strings.ToLower(filepath.Ext(file)) == ".json" {
+		return "json"
+	}
+	return "csv"
+}
+
+func exportMain(args []string) error {
+	var (
+		dbf       dbFlags
+		show      string
+		query     string
+		format    string
+		delimiter string
+		bom       bool
+		out       string
+	)
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbf.register(fs)
+	fs.StringVar(&show, "show", "", "show to export. required.")
+	fs.StringVar(&query, "q", "", "units search query. same as the search bar of the units page.")
+	fs.StringVar(&format, "format", "", "csv or json. default is decided by the extension of -o, or csv.")
+	fs.StringVar(&delimiter, "delimiter", "", "csv delimiter. comma, tab, semicolon, pipe or a single character.")
+	fs.BoolVar(&bom, "bom", true, "write utf-8 bom at the start of csv, so excel could read korean.")
+	fs.StringVar(&out, "o", "", "output file. default is stdout.")
+	fs.Parse(args)
+	if show == "" {
+		return fmt.Errorf("need -show flag")
+	}
+	format = tableFormat(format, out)
+	delim, err := roi.ParseDelimiter(delimiter)
+	if err != nil {
+		return err
+	}
+	db, err := dbf.open()
+	if err != nil {
+		return err
+	}
+	us, err := roi.SearchUnitsQuery(db, show, query)
+	if err != nil {
+		return err
+	}
+	table, err := roi.UnitTable(db, us)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return roi.WriteTable(w, table, format, delim, bom)
+}
+
+func importMain(args []string) error {
+	var (
+		dbf       dbFlags
+		format    string
+		delimiter string
+		apply     bool
+		report    string
+		author    string
+	)
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dbf.register(fs)
+	fs.StringVar(&format, "format", "", "csv or json. default is decided by the extension of the file.")
+	fs.StringVar(&delimiter, "delimiter", "", "csv delimiter. comma, tab, semicolon, pipe or a single character. default is tab for .tsv files, comma for others.")
+	fs.BoolVar(&apply, "apply", false, "apply the import. without it, only prints what will be changed.")
+	fs.StringVar(&report, "report", "", "write invalid rows to this csv file.")
+	fs.StringVar(&author, "author", os.Getenv("USER"), "user id recorded in the cut change history.")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: roictl import [flags] file")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	file := fs.Arg(0)
+	format = tableFormat(format, file)
+	if delimiter == "" && strings.ToLower(filepath.Ext(file)) == ".tsv" {
+		delimiter = "tab"
+	}
+	delim, err := roi.ParseDelimiter(delimiter)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	table, err := roi.ReadTable(f, format, delim)
+	if err != nil {
+		return err
+	}
+	db, err := dbf.open()
+	if err != nil {
+		return err
+	}
+	im, err := roi.PlanUnitImport(db, table, author)
+	if err != nil {
+		return err
+	}
+	printUnitImport(os.Stdout, im)
+	invalid := im.Invalid()
+	if report != "" {
+		rf, err := os.Create(report)
+		if err != nil {
+			return err
+		}
+		defer rf.Close()
+		err = roi.WriteCSVTable(rf, im.ErrorTable(), ',', true)
+		if err != nil {
+			return err
+		}
+	}
+	if len(invalid) != 0 {
+		return fmt.Errorf("%d invalid rows: nothing imported", len(invalid))
+	}
+	if !apply {
+		fmt.Println("dry run: use -apply flag to import")
+		return nil
+	}
+	return roi.ApplyUnitImport(db, im, author)
+}
+
+// printUnitImport는 가져오기로 바뀔 내용을 사람이 읽을 수 있게 출력한다.
+func printUnitImport(w io.Writer, im *roi.UnitImport) {
+	for _, r := range im.Added() {
+		fmt.Fprintf(w, "add %s\n", roi.JoinUnitID(r.Show, r.Group, r.Unit))
+		for _, c := range r.Changes {
+			fmt.Fprintf(w, "\t%s: %s\n", c.Field, c.New)
+		}
+	}
+	for _, r := range im.Changed() {
+		fmt.Fprintf(w, "change %s\n", roi.JoinUnitID(r.Show, r.Group, r.Unit))
+		for _, c := range r.Changes {
+			fmt.Fprintf(w, "\t%s: %q -> %q\n", c.Field, c.Old, c.New)
+		}
+	}
+	for _, r := range im.Invalid() {
+		fmt.Fprintf(w, "error: line %d: %s: %v\n", r.Line, roi.JoinUnitID(r.Show, r.Group, r.Unit), r.Err)
+	}
+	fmt.Fprintf(w, "%d added, %d changed, %d unchanged, %d invalid\n", len(im.Added()), len(im.Changed()), im.Unchanged(), len(im.Invalid()))
+}
+
+func reindexMain(args []string) error {
+	var (
+		dbf  dbFlags
+		show string
+	)
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	dbf.register(fs)
+	fs.StringVar(&show, "show", "", "show to reindex. default is all shows.")
+	fs.Parse(args)
+	db, err := dbf.open()
+	if err != nil {
+		return err
+	}
+	shows := []string{show}
+	if show == "" {
+		ss, err := roi.AllShows(db)
+		if err != nil {
+			return err
+		}
+		shows = shows[:0]
+		for _, s := range ss {
+			shows = append(shows, s.Show)
+		}
+	}
+	for _, s := range shows {
+		err := roi.RebuildSearchIndex(db, s)
+		if err != nil {
+			return fmt.Errorf("reindex %s: %w", s, err)
+		}
+		err = roi.RebuildAssetRefs(db, s)
+		if err != nil {
+			return fmt.Errorf("reindex asset refs %s: %w", s, err)
+		}
+		err = roi.RebuildTypedAttrs(db, s)
+		if err != nil {
+			return fmt.Errorf("reindex typed attrs %s: %w", s, err)
+		}
+		fmt.Println("reindexed", s)
+	}
+	return nil
+}
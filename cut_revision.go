@@ -0,0 +1,149 @@
+package roi
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CreateTableIfNotExistsCutRevisionsStmt는 DB에 cut_revisions 테이블을 생성하는 sql 구문이다.
+// 테이블은 타입보다 많은 정보를 담고 있을수도 있다.
+var CreateTableIfNotExistsCutRevisionsStmt = `CREATE TABLE IF NOT EXISTS cut_revisions (
+	show STRING NOT NULL CHECK (length(show) > 0) CHECK (show NOT LIKE '% %'),
+	grp STRING NOT NULL CHECK (length(grp) > 0) CHECK (grp NOT LIKE '% %'),
+	unit STRING NOT NULL CHECK (length(unit) > 0) CHECK (unit NOT LIKE '% %'),
+	created TIMESTAMPTZ NOT NULL,
+	author STRING NOT NULL,
+	reason STRING NOT NULL,
+	old_cut_in INT NOT NULL,
+	old_cut_out INT NOT NULL,
+	old_head_handle INT NOT NULL,
+	old_tail_handle INT NOT NULL,
+	cut_in INT NOT NULL,
+	cut_out INT NOT NULL,
+	head_handle INT NOT NULL,
+	tail_handle INT NOT NULL,
+	CONSTRAINT cut_revisions_pk PRIMARY KEY (show, grp, unit, created)
+)`
+
+// CutRevision은 유닛의 컷 정보(프레임 구간과 핸들)가 한번 바뀐 기록이다.
+// 유닛이 수정될 때 컷 정보가 바뀌었다면 자동으로 기록된다.
+type CutRevision struct {
+	Show    string    `db:"show"`
+	Group   string    `db:"grp"` // group이 sql 구문이기 때문에 줄여서 씀.
+	Unit    string    `db:"unit"`
+	Created time.Time `db:"created"` // 기록 시간; 항목 생성시 자동으로 입력된다.
+
+	Author string `db:"author"` // 컷 정보를 바꾼 사람의 아이디, 알 수 없다면 빈 문자열이다.
+	Reason string `db:"reason"` // 컷 정보를 바꾼 이유
+
+	OldCutIn      int `db:"old_cut_in"`
+	OldCutOut     int `db:"old_cut_out"`
+	OldHeadHandle int `db:"old_head_handle"`
+	OldTailHandle int `db:"old_tail_handle"`
+
+	CutIn      int `db:"cut_in"`
+	CutOut     int `db:"cut_out"`
+	HeadHandle int `db:"head_handle"`
+	TailHandle int `db:"tail_handle"`
+}
+
+var cutRevisionDBKey string = strings.Join(dbKeys(&CutRevision{}), ", ")
+var cutRevisionDBIdx string = strings.Join(dbIdxs(&CutRevision{}), ", ")
+var _ []interface{} = dbVals(&CutRevision{})
+
+// OldDuration은 바뀌기 전의 컷 길이이다.
+func (r *CutRevision) OldDuration() int {
+	return cutDuration(r.OldCutIn, r.OldCutOut)
+}
+
+// Duration은 바뀐 후의 컷 길이이다.
+func (r *CutRevision) Duration() int {
+	return cutDuration(r.CutIn, r.CutOut)
+}
+
+// DurationDelta는 컷 길이가 얼마나 바뀌었는지를 반환한다. 줄었다면 음수이다.
+func (r *CutRevision) DurationDelta() int {
+	return r.Duration() - r.OldDuration()
+}
+
+// cutDuration은 컷 인부터 컷 아웃까지, 두 프레임을 포함한 길이를 반환한다.
+// 둘 다 0이면 구간이 정해지지 않은 것이므로 0을 반환한다.
+func cutDuration(in, out int) int {
+	if in == 0 && out == 0 {
+		return 0
+	}
+	return out - in + 1
+}
+
+// cutChanged는 두 유닛의 컷 정보가 다른지 검사한다.
+func cutChanged(old, new *Unit) bool {
+	return old.CutIn != new.CutIn || old.CutOut != new.CutOut ||
+		old.HeadHandle != new.HeadHandle || old.TailHandle != new.TailHandle
+}
+
+// addCutRevisionStmts는 유닛의 컷 정보가 바뀌었다면 그 기록을 추가하는 dbStatement를 반환한다.
+// 바뀌지 않았다면 빈 슬라이스를 반환한다.
+func addCutRevisionStmts(old, new *Unit, author, reason string) []dbStatement {
+	if !cutChanged(old, new) {
+		return []dbStatement{}
+	}
+	r := &CutRevision{
+		Show:          new.Show,
+		Group:         new.Group,
+		Unit:          new.Unit,
+		Created:       time.Now(),
+		Author:        author,
+		Reason:        reason,
+		OldCutIn:      old.CutIn,
+		OldCutOut:     old.CutOut,
+		OldHeadHandle: old.HeadHandle,
+		OldTailHandle: old.TailHandle,
+		CutIn:         new.CutIn,
+		CutOut:        new.CutOut,
+		HeadHandle:    new.HeadHandle,
+		TailHandle:    new.TailHandle,
+	}
+	return []dbStatement{
+		dbStmt(fmt.Sprintf("INSERT INTO cut_revisions (%s) VALUES (%s)", cutRevisionDBKey, cutRevisionDBIdx), dbVals(r)...),
+	}
+}
+
+// UnitCutRevisions는 해당 유닛의 컷 정보 변경 기록을 최근 것부터 반환한다.
+func UnitCutRevisions(db *sql.DB, show, grp, unit string) ([]*CutRevision, error) {
+	_, err := GetUnit(db, show, grp, unit)
+	if err != nil {
+		return nil, err
+	}
+	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM cut_revisions WHERE show=$1 AND grp=$2 AND unit=$3 ORDER BY created DESC", cutRevisionDBKey), show, grp, unit)
+	return queryCutRevisions(db, stmt)
+}
+
+// CutRevisionsSince는 쇼에서 since 이후에 기록된 컷 정보 변경 기록을
+// 그룹, 유닛, 기록 시간 순서로 정렬해 반환한다.
+func CutRevisionsSince(db *sql.DB, show string, since time.Time) ([]*CutRevision, error) {
+	_, err := GetShow(db, show)
+	if err != nil {
+		return nil, err
+	}
+	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM cut_revisions WHERE show=$1 AND created>=$2 ORDER BY grp, unit, created", cutRevisionDBKey), show, since)
+	return queryCutRevisions(db, stmt)
+}
+
+func queryCutRevisions(db *sql.DB, stmt dbStatement) ([]*CutRevision, error) {
+	revs := make([]*CutRevision, 0)
+	err := dbQuery(db, stmt, func(rows *sql.Rows) error {
+		r := &CutRevision{}
+		err := scan(rows, r)
+		if err != nil {
+			return err
+		}
+		revs = append(revs, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
@@ -0,0 +1,105 @@
+package roi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCutRevision(t *testing.T) {
+	db, err := testDB()
+	if err != nil {
+		t.Fatalf("could not connect to database: %v", err)
+	}
+	err = AddSite(db)
+	if err != nil {
+		t.Fatalf("could not add site: %s", err)
+	}
+	defer func() {
+		err := DeleteSite(db)
+		if err != nil {
+			t.Fatalf("could not delete site: %s", err)
+		}
+	}()
+	err = AddShow(db, testShow)
+	if err != nil {
+		t.Fatalf("could not add show: %s", err)
+	}
+	defer func() {
+		err = DeleteShow(db, testShow.ID())
+		if err != nil {
+			t.Fatalf("could not delete show: %s", err)
+		}
+	}()
+	err = AddGroup(db, testGroup)
+	if err != nil {
+		t.Fatalf("could not add group: %s", err)
+	}
+	defer func() {
+		err = DeleteGroup(db, testGroup.Show, testGroup.Group)
+		if err != nil {
+			t.Fatalf("could not delete group: %s", err)
+		}
+	}()
+	// 다른 테스트와 공유하는 유닛이므로 복사해서 쓴다.
+	u := *testUnitA
+	err = AddUnit(db, &u)
+	if err != nil {
+		t.Fatalf("could not add unit: %s", err)
+	}
+	defer func() {
+		err = DeleteUnit(db, u.Show, u.Group, u.Unit)
+		if err != nil {
+			t.Fatalf("could not delete unit: %s", err)
+		}
+	}()
+	start := time.Now().Add(-time.Second)
+
+	// 컷 정보가 바뀌지 않는 수정은 기록하지 않는다.
+	u.Description = "창 밖을 바라보는 로이."
+	err = UpdateUnitWithReason(db, &u, "kybin", "")
+	if err != nil {
+		t.Fatalf("could not update unit: %s", err)
+	}
+	revs, err := UnitCutRevisions(db, u.Show, u.Group, u.Unit)
+	if err != nil {
+		t.Fatalf("could not get cut revisions: %s", err)
+	}
+	if len(revs) != 0 {
+		t.Fatalf("got %d cut revisions, want 0", len(revs))
+	}
+
+	u.CutOut = 1112
+	err = UpdateUnitWithReason(db, &u, "kybin", "편집 트림")
+	if err != nil {
+		t.Fatalf("could not update unit: %s", err)
+	}
+	revs, err = UnitCutRevisions(db, u.Show, u.Group, u.Unit)
+	if err != nil {
+		t.Fatalf("could not get cut revisions: %s", err)
+	}
+	if len(revs) != 1 {
+		t.Fatalf("got %d cut revisions, want 1", len(revs))
+	}
+	r := revs[0]
+	if r.Author != "kybin" || r.Reason != "편집 트림" {
+		t.Fatalf("got author %q, reason %q", r.Author, r.Reason)
+	}
+	if r.OldCutOut != 1132 || r.CutOut != 1112 || r.DurationDelta() != -20 {
+		t.Fatalf("got cut out %d -> %d, delta %d", r.OldCutOut, r.CutOut, r.DurationDelta())
+	}
+
+	revs, err = CutRevisionsSince(db, u.Show, start)
+	if err != nil {
+		t.Fatalf("could not get cut revisions: %s", err)
+	}
+	if len(revs) != 1 {
+		t.Fatalf("got %d cut revisions since %v, want 1", len(revs), start)
+	}
+	revs, err = CutRevisionsSince(db, u.Show, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("could not get cut revisions: %s", err)
+	}
+	if len(revs) != 0 {
+		t.Fatalf("got %d cut revisions from future, want 0", len(revs))
+	}
+}
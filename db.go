@@ -3,6 +3,7 @@ package roi
 import (
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	_ "image/jpeg"
 	"reflect"
@@ -37,11 +38,37 @@ func initDB(url string) (*sql.DB, error) {
 		dbStmt(CreateTableIfNotExistsVersionsStmt),
 		dbStmt(CreateTableIfNotExistsReviewsStmt),
 		dbStmt(CreateTableIfNotExistsUsersStmt),
+		dbStmt(CreateTableIfNotExistsCutRevisionsStmt),
+		dbStmt(CreateTableIfNotExistsSavedSearchesStmt),
+		dbStmt(CreateTableIfNotExistsSearchSubscriptionsStmt),
+		dbStmt(CreateTableIfNotExistsNotificationsStmt),
+		dbStmt(CreateTableIfNotExistsSearchDocsStmt),
+		dbStmt(CreateTableIfNotExistsSearchIndexStmt),
+		dbStmt(CreateTableIfNotExistsChangeSetsStmt),
+		dbStmt(CreateTableIfNotExistsChangeSetItemsStmt),
+		dbStmt(CreateTableIfNotExistsAssetRefsStmt),
 	}
 	err = dbExec(db, stmts)
 	if err != nil {
 		return nil, err
 	}
+	// 스키마 변경은 다른 구문과 같은 트랜잭션에서 실행할 때 제약이 있어 따로 실행한다.
+	alters := make([]string, 0)
+	alters = append(alters, AlterTableShowsStmts...)
+	alters = append(alters, AlterTableGroupsStmts...)
+	alters = append(alters, AlterTableUnitsStmts...)
+	alters = append(alters, AlterTableUsersStmts...)
+	alters = append(alters, AlterTableSitesStmts...)
+	for _, alter := range alters {
+		err = dbExec(db, []dbStatement{dbStmt(alter)})
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = backfillAssetRefs(db)
+	if err != nil {
+		return nil, err
+	}
 	return db, nil
 }
 
@@ -115,6 +142,28 @@ func dbExec(db *sql.DB, stmts []dbStatement) error {
 	return tx.Commit()
 }
 
+// dbExecIf는 dbExec과 같지만 구문들을 실행하기 전에 같은 트랜잭션 안에서 check를 실행한다.
+// check가 에러를 반환하면 아무 구문도 실행하지 않고 그 에러를 반환한다.
+// 검사한 행이 구문을 실행하기 전에 다른 곳에서 바뀌지 않아야 할 때 사용한다.
+func dbExecIf(db *sql.DB, check func(tx *sql.Tx) error, stmts []dbStatement) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = check(tx)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		_, err := tx.Exec(stmt.s, stmt.vs...)
+		if err != nil {
+			return fmt.Errorf("dbExec: %q %v: %w", stmt.s, stmt.vs, err)
+		}
+	}
+	return tx.Commit()
+}
+
 // dbKeys는 임의의 타입인 v에 대해서 그 db 키 슬라이스를 반환한다.
 func dbKeys(v interface{}) []string {
 	var typ reflect.Type
@@ -262,3 +311,34 @@ func (m *DBStringMap) Scan(src interface{}) error {
 	}
 	return nil
 }
+
+// DBJSONMap은 db에 JSONB로 저장되는 맵이다. 값의 타입이 db에 그대로 저장되어
+// 숫자는 숫자로 비교할 수 있다.
+type DBJSONMap map[string]interface{}
+
+// Value는 db에 저장될 값이다.
+func (m DBJSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("DBJSONMap: %w", err)
+	}
+	return string(b), nil
+}
+
+// Scan은 db의 JSONB 값을 맵으로 가져온다.
+func (m *DBJSONMap) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("DBJSONMap: src should be string or bytes")
+	}
+	*m = make(DBJSONMap)
+	return json.Unmarshal(b, m)
+}
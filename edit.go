@@ -0,0 +1,299 @@
+package roi
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// EditCut은 편집본에서 하나의 유닛이 차지하는 구간이다.
+// EDL이나 OTIO 같은 편집 파일은 EditCut의 목록으로 변환된 후 유닛들에 맞춰진다.
+type EditCut struct {
+	Unit string
+	// Duration은 편집본에서 쓰인 컷의 길이(프레임)이다.
+	// 0이면 길이를 알 수 없는 것으로 보고 유닛의 프레임 구간을 바꾸지 않는다.
+	Duration int
+	// CutIn은 컷의 시작 프레임이다. 0이면 유닛의 기존 컷 인을 유지하며,
+	// 유닛에 프레임 구간이 없다면 DefaultCutIn을 쓴다.
+	CutIn int
+	// Attrs는 유닛의 커스텀 속성에 기록될 편집 정보이다. 예) 타임코드
+	Attrs map[string]string
+}
+
+// EditPlan은 편집본을 한 그룹의 유닛들에 맞추는 계획이다.
+// PlanEdit으로 만들어 내용을 확인한 후 ApplyEdit으로 적용한다.
+type EditPlan struct {
+	Show  string
+	Group string
+	// Cuts는 편집본의 순서대로 정렬된 컷들의 계획이다.
+	Cuts []*EditCutPlan
+	// Removed는 그룹에 있지만 편집본에서 빠진 유닛들이다. 오밋 분류에 속하는 상태의 유닛은 포함하지 않는다.
+	Removed []*Unit
+}
+
+// EditCutPlan은 하나의 컷이 유닛에 어떻게 적용될지를 나타낸다.
+type EditCutPlan struct {
+	Cut *EditCut
+	// EditOrder는 적용 후의 편집 순서이고, OldEditOrder는 기존 유닛의 편집 순서이다.
+	EditOrder    int
+	OldEditOrder int
+	// New는 db에 없는 새 유닛을 만드는지를 나타낸다.
+	New bool
+	// Moved는 기존 유닛들 사이에서 이 유닛의 상대적인 순서가 바뀌었는지를 나타낸다.
+	// 다른 샷이 추가되거나 빠져서 편집 순서 숫자만 바뀐 경우는 포함하지 않는다.
+	Moved bool
+	// Changes는 편집 정보 중 바뀌는 커스텀 속성들이다.
+	Changes []*FieldChange
+	// Err는 이 컷의 검증 에러이다. 에러가 있는 계획은 적용할 수 없다.
+	Err error
+
+	unit *Unit
+}
+
+// Added는 편집본으로 새로 생성될 유닛의 컷들을 반환한다.
+func (p *EditPlan) Added() []*EditCutPlan {
+	return p.filter(func(c *EditCutPlan) bool { return c.Err == nil && c.New })
+}
+
+// Moved는 순서가 바뀌는 기존 유닛의 컷들을 반환한다.
+func (p *EditPlan) Moved() []*EditCutPlan {
+	return p.filter(func(c *EditCutPlan) bool { return c.Err == nil && c.Moved })
+}
+
+// Changed는 편집 정보가 바뀌는 기존 유닛의 컷들을 반환한다.
+func (p *EditPlan) Changed() []*EditCutPlan {
+	return p.filter(func(c *EditCutPlan) bool { return c.Err == nil && !c.New && len(c.Changes) != 0 })
+}
+
+// Invalid는 에러가 있는 컷들을 반환한다.
+func (p *EditPlan) Invalid() []*EditCutPlan {
+	return p.filter(func(c *EditCutPlan) bool { return c.Err != nil })
+}
+
+func (p *EditPlan) filter(fn func(c *EditCutPlan) bool) []*EditCutPlan {
+	cuts := make([]*EditCutPlan, 0)
+	for _, c := range p.Cuts {
+		if fn(c) {
+			cuts = append(cuts, c)
+		}
+	}
+	return cuts
+}
+
+// DefaultCutIn은 프레임 구간이 없는 유닛에 편집본의 컷 길이를 적용할 때 쓰는 시작 프레임이다.
+const DefaultCutIn = 1001
+
+// editOrderStep은 편집 순서 사이의 간격이다.
+// 간격을 두어 나중에 사이에 샷을 끼워넣을 수 있게 한다.
+const editOrderStep = 10
+
+// PlanEdit은 편집본의 컷들을 그룹의 유닛들에 맞추는 계획을 만든다. 이 함수는 db를 수정하지 않는다.
+// 각 컷의 유닛에는 편집본의 순서대로 편집 순서가 매겨지며, 컷의 Attrs가 커스텀 속성에 기록된다.
+// 컷의 길이를 알 수 있다면 유닛의 프레임 구간도 그 길이에 맞춘다.
+func PlanEdit(db *sql.DB, show, grp string, cuts []*EditCut) (*EditPlan, error) {
+	_, err := GetGroup(db, show, grp)
+	if err != nil {
+		return nil, err
+	}
+	site, err := GetSite(db)
+	if err != nil {
+		return nil, err
+	}
+	us, err := SearchUnits(db, show, []string{grp}, []string{}, "", "", "", "", "", "", time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	unitOf := make(map[string]*Unit)
+	for _, u := range us {
+		unitOf[u.Unit] = u
+	}
+	p := &EditPlan{
+		Show:    show,
+		Group:   grp,
+		Cuts:    make([]*EditCutPlan, 0, len(cuts)),
+		Removed: make([]*Unit, 0),
+	}
+	inEdit := make(map[string]bool)
+	for i, cut := range cuts {
+		c := &EditCutPlan{
+			Cut:       cut,
+			EditOrder: (i + 1) * editOrderStep,
+		}
+		p.Cuts = append(p.Cuts, c)
+		if inEdit[cut.Unit] {
+			c.Err = BadRequest("unit %s appears more than once in the edit", cut.Unit)
+			continue
+		}
+		inEdit[cut.Unit] = true
+		err := verifyUnitName(cut.Unit)
+		if err != nil {
+			c.Err = err
+			continue
+		}
+		u, ok := unitOf[cut.Unit]
+		if !ok {
+			u = &Unit{
+				Show:   show,
+				Group:  grp,
+				Unit:   cut.Unit,
+				Status: StatusInProgress,
+				Attrs:  make(DBStringMap),
+			}
+			c.New = true
+		} else {
+			// 검색된 유닛은 여러 컷이 공유하지 않으므로 그대로 수정해도 된다.
+			c.OldEditOrder = u.EditOrder
+		}
+		if u.Attrs == nil {
+			u.Attrs = make(DBStringMap)
+		}
+		keys := make([]string, 0, len(cut.Attrs))
+		for k := range cut.Attrs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := cut.Attrs[k]
+			if v == "" || u.Attrs[k] == v {
+				continue
+			}
+			c.Changes = append(c.Changes, &FieldChange{Field: k, Old: u.Attrs[k], New: v})
+			u.Attrs[k] = v
+		}
+		if cut.Duration > 0 {
+			in := cut.CutIn
+			if in == 0 {
+				in = u.CutIn
+				if u.Duration() == 0 {
+					in = DefaultCutIn
+				}
+			}
+			out := in + cut.Duration - 1
+			if in != u.CutIn {
+				c.Changes = append(c.Changes, &FieldChange{Field: "cut_in", Old: tableInt(u.CutIn), New: tableInt(in)})
+				u.CutIn = in
+			}
+			if out != u.CutOut {
+				c.Changes = append(c.Changes, &FieldChange{Field: "cut_out", Old: tableInt(u.CutOut), New: tableInt(out)})
+				u.CutOut = out
+			}
+		}
+		u.EditOrder = c.EditOrder
+		c.unit = u
+	}
+	markMovedCuts(p.Cuts)
+	for _, u := range us {
+		if !inEdit[u.Unit] && !site.IsOmitted(u.Status) {
+			p.Removed = append(p.Removed, u)
+		}
+	}
+	return p, nil
+}
+
+// markMovedCuts는 기존 유닛들 중 상대적인 순서가 바뀐 컷들을 표시한다.
+// 기존 편집 순서의 최장 증가 부분 수열에 속한 유닛들은 제자리에 있는 것으로 보고,
+// 나머지 유닛들을 옮겨진 것으로 본다. 이렇게 하면 한 샷을 옮겼을 때 그 샷만 표시된다.
+func markMovedCuts(cuts []*EditCutPlan) {
+	old := make([]*EditCutPlan, 0)
+	for _, c := range cuts {
+		if c.Err == nil && !c.New {
+			old = append(old, c)
+		}
+	}
+	n := len(old)
+	if n == 0 {
+		return
+	}
+	// length[i]는 old[i]로 끝나는 최장 증가 부분 수열의 길이이다.
+	length := make([]int, n)
+	prev := make([]int, n)
+	best := 0
+	for i := range old {
+		length[i] = 1
+		prev[i] = -1
+		for j := 0; j < i; j++ {
+			if old[j].OldEditOrder < old[i].OldEditOrder && length[j]+1 > length[i] {
+				length[i] = length[j] + 1
+				prev[i] = j
+			}
+		}
+		if length[i] > length[best] {
+			best = i
+		}
+	}
+	inPlace := make(map[int]bool)
+	for i := best; i >= 0; i = prev[i] {
+		inPlace[i] = true
+	}
+	for i, c := range old {
+		c.Moved = !inPlace[i]
+	}
+}
+
+// ApplyEdit은 편집 계획을 하나의 트랜잭션으로 db에 적용한다.
+// omitRemoved가 참이면 편집본에서 빠진 유닛들을 사이트의 오밋 상태(Site.OmitStatus)로 바꾼다.
+// 에러가 있는 컷이 하나라도 있으면 아무것도 적용하지 않고 에러를 반환한다.
+// 유닛의 컷 정보가 바뀌면 author를 작성자로 변경 기록을 남긴다.
+// author가 있다면 적용을 되돌릴 수 있도록 작업 기록(ChangeSet)을 함께 남긴다.
+func ApplyEdit(db *sql.DB, p *EditPlan, omitRemoved bool, author string) error {
+	if p == nil {
+		return fmt.Errorf("nil edit plan")
+	}
+	if invalid := p.Invalid(); len(invalid) != 0 {
+		return BadRequest("edit has %d invalid cuts: %s: %v", len(invalid), invalid[0].Cut.Unit, invalid[0].Err)
+	}
+	stmts := make([]dbStatement, 0)
+	cs := newChangeSetRecorder(author, "apply-edit")
+	units := 0
+	for _, c := range p.Cuts {
+		if !c.New && c.EditOrder == c.OldEditOrder && len(c.Changes) == 0 {
+			continue
+		}
+		var st []dbStatement
+		var err error
+		if c.New {
+			st, err = addUnitStmts(db, c.unit)
+		} else {
+			st, err = updateUnitStmts(db, c.unit, author, "edit import")
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.Cut.Unit, err)
+		}
+		err = cs.addUnit(db, c.unit, c.New)
+		if err != nil {
+			return err
+		}
+		units++
+		stmts = append(stmts, st...)
+	}
+	if omitRemoved {
+		site, err := GetSite(db)
+		if err != nil {
+			return err
+		}
+		for _, u := range p.Removed {
+			u.Status = site.OmitStatus()
+			st, err := updateUnitStmts(db, u, author, "edit import")
+			if err != nil {
+				if errors.As(err, &NotFoundError{}) {
+					// 계획 이후 지워진 유닛이다.
+					continue
+				}
+				return fmt.Errorf("%s: %w", u.Unit, err)
+			}
+			err = cs.addUnit(db, u, false)
+			if err != nil {
+				return err
+			}
+			units++
+			stmts = append(stmts, st...)
+		}
+	}
+	if len(stmts) == 0 {
+		return nil
+	}
+	stmts = append(stmts, cs.stmts(fmt.Sprintf("%d units", units))...)
+	return dbExec(db, stmts)
+}
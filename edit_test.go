@@ -0,0 +1,156 @@
+package roi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarkMovedCuts(t *testing.T) {
+	// 기존 순서 10, 20, 30, 40 중 40을 맨 앞으로 옮기고 새 샷을 끼워넣었다.
+	cuts := []*EditCutPlan{
+		{OldEditOrder: 40},
+		{OldEditOrder: 10},
+		{New: true},
+		{OldEditOrder: 20},
+		{OldEditOrder: 30},
+	}
+	markMovedCuts(cuts)
+	got := make([]bool, 0)
+	for _, c := range cuts {
+		got = append(got, c.Moved)
+	}
+	want := []bool{true, false, false, false, false}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	db, err := testDB()
+	if err != nil {
+		t.Fatalf("could not connect to database: %v", err)
+	}
+	err = AddSite(db)
+	if err != nil {
+		t.Fatalf("could not add site: %s", err)
+	}
+	defer func() {
+		err := DeleteSite(db)
+		if err != nil {
+			t.Fatalf("could not delete site: %s", err)
+		}
+	}()
+	err = AddShow(db, testShow)
+	if err != nil {
+		t.Fatalf("could not add show: %s", err)
+	}
+	defer func() {
+		err = DeleteShow(db, testShow.ID())
+		if err != nil {
+			t.Fatalf("could not delete show: %s", err)
+		}
+	}()
+	err = AddGroup(db, testGroup)
+	if err != nil {
+		t.Fatalf("could not add group: %s", err)
+	}
+	defer func() {
+		err = DeleteGroup(db, testGroup.Show, testGroup.Group)
+		if err != nil {
+			t.Fatalf("could not delete group: %s", err)
+		}
+	}()
+	for _, u := range testUnits {
+		err = AddUnit(db, u)
+		if err != nil {
+			t.Fatalf("could not add unit: %s", err)
+		}
+	}
+	defer func() {
+		for _, u := range testUnits {
+			err = DeleteUnit(db, u.Show, u.Group, u.Unit)
+			if err != nil {
+				t.Fatalf("could not delete unit: %s", err)
+			}
+		}
+	}()
+
+	// 0010을 맨 뒤로 옮기고, 0015를 추가한다.
+	// 0020은 길이가 늘었고, 새로 추가되는 0015는 기본 시작 프레임을 쓴다.
+	cuts := []*EditCut{
+		{Unit: "0020", Duration: 20},
+		{Unit: "0030", Attrs: map[string]string{"rec_tc_in": "01:00:00:00"}},
+		{Unit: "0015", Duration: 48},
+		{Unit: "0010"},
+	}
+	p, err := PlanEdit(db, testShow.Show, testGroup.Group, cuts)
+	if err != nil {
+		t.Fatalf("could not plan edit: %s", err)
+	}
+	if added := p.Added(); len(added) != 1 || added[0].Cut.Unit != "0015" {
+		t.Fatalf("added: got %v", added)
+	}
+	if moved := p.Moved(); len(moved) != 1 || moved[0].Cut.Unit != "0010" {
+		t.Fatalf("moved: got %v", moved)
+	}
+	if len(p.Removed) != 0 {
+		t.Fatalf("removed: got %v", p.Removed)
+	}
+	err = ApplyEdit(db, p, true, "")
+	if err != nil {
+		t.Fatalf("could not apply edit: %s", err)
+	}
+	defer func() {
+		err = DeleteUnit(db, testShow.Show, testGroup.Group, "0015")
+		if err != nil {
+			t.Fatalf("could not delete unit: %s", err)
+		}
+	}()
+	want := map[string]int{"0020": 10, "0030": 20, "0015": 30, "0010": 40}
+	for unit, order := range want {
+		u, err := GetUnit(db, testShow.Show, testGroup.Group, unit)
+		if err != nil {
+			t.Fatalf("could not get unit: %s", err)
+		}
+		if u.EditOrder != order {
+			t.Fatalf("%s: edit order: got %d, want %d", unit, u.EditOrder, order)
+		}
+	}
+	u, err := GetUnit(db, testShow.Show, testGroup.Group, "0030")
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	if u.Attrs["rec_tc_in"] != "01:00:00:00" {
+		t.Fatalf("attrs: got %v", u.Attrs)
+	}
+	frames := map[string][2]int{"0020": {1001, 1020}, "0015": {DefaultCutIn, DefaultCutIn + 47}}
+	for unit, want := range frames {
+		u, err := GetUnit(db, testShow.Show, testGroup.Group, unit)
+		if err != nil {
+			t.Fatalf("could not get unit: %s", err)
+		}
+		if got := [2]int{u.CutIn, u.CutOut}; got != want {
+			t.Fatalf("%s: cut range: got %v, want %v", unit, got, want)
+		}
+	}
+
+	// 편집본에서 빠진 0010은 Omit 상태가 된다.
+	p, err = PlanEdit(db, testShow.Show, testGroup.Group, cuts[:3])
+	if err != nil {
+		t.Fatalf("could not plan edit: %s", err)
+	}
+	if len(p.Removed) != 1 || p.Removed[0].Unit != "0010" {
+		t.Fatalf("removed: got %v", p.Removed)
+	}
+	err = ApplyEdit(db, p, true, "")
+	if err != nil {
+		t.Fatalf("could not apply edit: %s", err)
+	}
+	u, err = GetUnit(db, testShow.Show, testGroup.Group, "0010")
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	if u.Status != StatusOmit {
+		t.Fatalf("removed unit status: got %s, want %s", u.Status, StatusOmit)
+	}
+}
@@ -0,0 +1,207 @@
+package roi
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EDL은 CMX3600 형식의 편집 결정 목록이다.
+type EDL struct {
+	Title string
+	// FCM은 프레임 코드 모드이다. DROP FRAME 또는 NON-DROP FRAME.
+	FCM    string
+	Events []*EDLEvent
+}
+
+// EDLEvent는 EDL의 이벤트 하나를 나타낸다.
+// 타임코드는 EDL에 적힌 그대로의 문자열이다. 예) 01:00:00:00
+type EDLEvent struct {
+	Num        int
+	Reel       string
+	Track      string // V, A, A2, AA/V 등
+	Transition string // C(컷), D(디졸브), W001(와이프) 등
+
+	SourceIn  string
+	SourceOut string
+	RecordIn  string
+	RecordOut string
+
+	// ClipName은 "* FROM CLIP NAME:" 코멘트의 값이다.
+	ClipName string
+	// Locators는 "* LOC:" 코멘트의 값들이다. 예) 01:00:00:10 RED CG0010
+	Locators []string
+	// Comments는 위 두 코멘트를 제외한 나머지 코멘트이다.
+	Comments []string
+}
+
+// IsVideo는 이벤트가 비디오 트랙을 포함하는지를 검사한다.
+func (e *EDLEvent) IsVideo() bool {
+	return strings.Contains(e.Track, "V")
+}
+
+// reTimecode는 EDL의 타임코드를 나타낸다. 드롭 프레임은 마지막 구분자로 ; 를 쓴다.
+var reTimecode = regexp.MustCompile(`^\d\d:\d\d:\d\d[:;.]\d\d$`)
+
+// ParseEDL은 CMX3600 형식의 EDL을 읽는다.
+// 알 수 없는 줄은 무시하지만, 이벤트 줄의 형식이 잘못되었다면 에러를 반환한다.
+func ParseEDL(r io.Reader) (*EDL, error) {
+	edl := &EDL{Events: make([]*EDLEvent, 0)}
+	var ev *EDLEvent
+	sc := bufio.NewScanner(r)
+	n := 0
+	for sc.Scan() {
+		n++
+		line := strings.TrimSpace(sc.Text())
+		if n == 1 {
+			line = strings.TrimPrefix(line, utf8BOM)
+		}
+		if line == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "TITLE:"):
+			edl.Title = strings.TrimSpace(strings.TrimPrefix(line, "TITLE:"))
+			continue
+		case strings.HasPrefix(line, "FCM:"):
+			edl.FCM = strings.TrimSpace(strings.TrimPrefix(line, "FCM:"))
+			continue
+		case strings.HasPrefix(line, "*"):
+			if ev == nil {
+				continue
+			}
+			c := strings.TrimSpace(strings.TrimPrefix(line, "*"))
+			switch {
+			case strings.HasPrefix(c, "FROM CLIP NAME:"):
+				ev.ClipName = strings.TrimSpace(strings.TrimPrefix(c, "FROM CLIP NAME:"))
+			case strings.HasPrefix(c, "LOC:"):
+				ev.Locators = append(ev.Locators, strings.TrimSpace(strings.TrimPrefix(c, "LOC:")))
+			default:
+				ev.Comments = append(ev.Comments, c)
+			}
+			continue
+		}
+		f := strings.Fields(line)
+		num, err := strconv.Atoi(f[0])
+		if err != nil {
+			// M2(모션 효과)처럼 이벤트 번호로 시작하지 않는 줄은 처리하지 않는다.
+			continue
+		}
+		if len(f) < 8 {
+			return nil, BadRequest("edl line %d: invalid event: %s", n, line)
+		}
+		tc := f[len(f)-4:]
+		for _, t := range tc {
+			if !reTimecode.MatchString(t) {
+				return nil, BadRequest("edl line %d: invalid timecode: %s", n, t)
+			}
+		}
+		if ev != nil && ev.Num == num {
+			// 디졸브 같은 트랜지션은 같은 번호의 이벤트 두 줄로 표현된다.
+			// 편집본에서 보이는 것은 뒤쪽 클립이므로 뒤쪽 줄의 정보를 사용한다.
+			// 이 때 코멘트는 보통 두 줄 모두에 대한 것이므로 유지한다.
+			ev.Reel = f[1]
+			ev.Track = f[2]
+			ev.Transition = f[3]
+			ev.SourceIn, ev.SourceOut, ev.RecordIn, ev.RecordOut = tc[0], tc[1], tc[2], tc[3]
+			continue
+		}
+		ev = &EDLEvent{
+			Num:        num,
+			Reel:       f[1],
+			Track:      f[2],
+			Transition: f[3],
+			SourceIn:   tc[0],
+			SourceOut:  tc[1],
+			RecordIn:   tc[2],
+			RecordOut:  tc[3],
+		}
+		edl.Events = append(edl.Events, ev)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return edl, nil
+}
+
+// EDL 이벤트에서 유닛 이름을 찾는 방법
+const (
+	EDLNameFromClip    = "clip"
+	EDLNameFromLocator = "locator"
+)
+
+// UnitName은 이벤트에 해당하는 유닛 이름을 반환한다.
+// from이 EDLNameFromClip이면 클립 이름에서 확장자와 공백, 점 뒤를 떼어낸 이름을,
+// EDLNameFromLocator이면 로케이터 코멘트의 마지막 단어를 사용한다.
+// 유효한 유닛 이름을 찾지 못하면 빈 문자열을 반환한다.
+func (e *EDLEvent) UnitName(from string) string {
+	switch from {
+	case EDLNameFromClip:
+		return unitNameFromClip(e.ClipName)
+	case EDLNameFromLocator:
+		for _, loc := range e.Locators {
+			f := strings.Fields(loc)
+			if len(f) == 0 {
+				continue
+			}
+			name := f[len(f)-1]
+			if reUnitName.MatchString(name) && !reTimecode.MatchString(name) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// unitNameFromClip은 편집본의 클립 이름에서 확장자와 공백, 점 뒤를 떼어낸 유닛 이름을 반환한다.
+// 유효한 유닛 이름이 아니라면 빈 문자열을 반환한다.
+func unitNameFromClip(clip string) string {
+	name := strings.TrimSuffix(clip, filepath.Ext(clip))
+	if i := strings.IndexAny(name, " ."); i >= 0 {
+		name = name[:i]
+	}
+	if !reUnitName.MatchString(name) {
+		return ""
+	}
+	return name
+}
+
+// EditCuts는 EDL의 비디오 이벤트를 편집본의 컷 목록으로 변환한다.
+// 같은 유닛의 이벤트가 여러개라면 처음 나온 위치를 순서로 하고,
+// 레코드 타임코드는 처음 이벤트의 시작부터 마지막 이벤트의 끝까지로 한다.
+// 유닛 이름을 찾지 못한 이벤트는 따로 반환한다.
+func (edl *EDL) EditCuts(from string) ([]*EditCut, []*EDLEvent) {
+	cuts := make([]*EditCut, 0)
+	unnamed := make([]*EDLEvent, 0)
+	cutOf := make(map[string]*EditCut)
+	for _, ev := range edl.Events {
+		if !ev.IsVideo() {
+			continue
+		}
+		name := ev.UnitName(from)
+		if name == "" {
+			unnamed = append(unnamed, ev)
+			continue
+		}
+		if c, ok := cutOf[name]; ok {
+			c.Attrs["rec_tc_out"] = ev.RecordOut
+			continue
+		}
+		c := &EditCut{
+			Unit: name,
+			Attrs: map[string]string{
+				"edl_reel":   ev.Reel,
+				"src_tc_in":  ev.SourceIn,
+				"src_tc_out": ev.SourceOut,
+				"rec_tc_in":  ev.RecordIn,
+				"rec_tc_out": ev.RecordOut,
+			},
+		}
+		cutOf[name] = c
+		cuts = append(cuts, c)
+	}
+	return cuts, unnamed
+}
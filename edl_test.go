@@ -0,0 +1,81 @@
+package roi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testEDL = `TITLE: ROI_REEL1_V3
+FCM: NON-DROP FRAME
+
+001  A001C003 V     C        13:20:11:04 13:20:13:10 01:00:00:00 01:00:02:06
+* FROM CLIP NAME: CG0010.mov
+* LOC: 01:00:01:00 RED CG0010
+
+002  A001C007 V     C        14:02:00:00 14:02:01:12 01:00:02:06 01:00:03:18
+* FROM CLIP NAME: A001C007_200301.mov
+
+003  BL       V     C        00:00:00:00 00:00:00:00 01:00:03:18 01:00:03:18
+003  A002C001 V     D    012 15:10:00:00 15:10:03:00 01:00:03:18 01:00:06:18
+* FROM CLIP NAME: CG0030 comp v002.mov
+* LOC: 01:00:04:00 YELLOW CG0030
+
+004  A002C001 A     C        15:10:00:00 15:10:03:00 01:00:03:18 01:00:06:18
+
+005  A001C003 V     C        13:20:14:00 13:20:15:00 01:00:06:18 01:00:07:18
+* FROM CLIP NAME: CG0010.mov
+* LOC: 01:00:07:00 RED CG0010
+`
+
+func TestParseEDL(t *testing.T) {
+	edl, err := ParseEDL(strings.NewReader(testEDL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edl.Title != "ROI_REEL1_V3" || edl.FCM != "NON-DROP FRAME" {
+		t.Fatalf("header: got %q, %q", edl.Title, edl.FCM)
+	}
+	if len(edl.Events) != 5 {
+		t.Fatalf("events: got %d, want 5", len(edl.Events))
+	}
+	dissolve := edl.Events[2]
+	if dissolve.Reel != "A002C001" || dissolve.Transition != "D" || dissolve.SourceIn != "15:10:00:00" || dissolve.RecordOut != "01:00:06:18" {
+		t.Fatalf("dissolve event: got %+v", dissolve)
+	}
+	if edl.Events[3].IsVideo() {
+		t.Fatalf("audio event is considered as video")
+	}
+
+	cuts, unnamed := edl.EditCuts(EDLNameFromClip)
+	names := make([]string, 0)
+	for _, c := range cuts {
+		names = append(names, c.Unit)
+	}
+	if want := []string{"CG0010", "A001C007_200301", "CG0030"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("clip names: got %v, want %v", names, want)
+	}
+	if len(unnamed) != 0 {
+		t.Fatalf("unnamed: got %v", unnamed)
+	}
+	if c := cuts[0]; c.Attrs["rec_tc_in"] != "01:00:00:00" || c.Attrs["rec_tc_out"] != "01:00:07:18" {
+		t.Fatalf("merged cut: got %v", c.Attrs)
+	}
+
+	cuts, unnamed = edl.EditCuts(EDLNameFromLocator)
+	names = names[:0]
+	for _, c := range cuts {
+		names = append(names, c.Unit)
+	}
+	if want := []string{"CG0010", "CG0030"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("locator names: got %v, want %v", names, want)
+	}
+	if len(unnamed) != 1 || unnamed[0].Num != 2 {
+		t.Fatalf("unnamed: got %v", unnamed)
+	}
+
+	_, err = ParseEDL(strings.NewReader("001  A001 V C 01:00:00:00 01:00:01:00 01:00:00:00 1:00\n"))
+	if err == nil {
+		t.Fatalf("want error for invalid timecode")
+	}
+}
@@ -6,12 +6,14 @@ import (
 
 // NotFoundError는 로이에서 특정 항목을 검색했지만 해당 항목이 없음을 의미하는 에러이다.
 type NotFoundError struct {
-	err error
+	err  error
+	msg  string
+	vals []interface{}
 }
 
 // NotFound는 NotFoundError를 반환한다.
 func NotFound(msg string, vals ...interface{}) NotFoundError {
-	return NotFoundError{err: fmt.Errorf(msg, vals...)}
+	return NotFoundError{err: fmt.Errorf(msg, vals...), msg: msg, vals: vals}
 }
 
 func (e NotFoundError) Error() string {
@@ -22,14 +24,22 @@ func (e NotFoundError) Unwrap() error {
 	return e.err
 }
 
+// Message는 에러 메시지의 형식 문자열과 인자를 반환한다.
+// 사용자에게 보일 에러 메시지를 번역할 때 사용한다.
+func (e NotFoundError) Message() (string, []interface{}) {
+	return e.msg, e.vals
+}
+
 // BadRequestError는 로이의 함수를 호출했지만 그와 관련된 정보가 잘못되었음을 의미하는 에러이다.
 type BadRequestError struct {
-	err error
+	err  error
+	msg  string
+	vals []interface{}
 }
 
 // BadRequest는 BadRequestError를 반환한다.
 func BadRequest(msg string, vals ...interface{}) BadRequestError {
-	return BadRequestError{err: fmt.Errorf(msg, vals...)}
+	return BadRequestError{err: fmt.Errorf(msg, vals...), msg: msg, vals: vals}
 }
 
 func (e BadRequestError) Error() string {
@@ -40,14 +50,22 @@ func (e BadRequestError) Unwrap() error {
 	return e.err
 }
 
+// Message는 에러 메시지의 형식 문자열과 인자를 반환한다.
+// 사용자에게 보일 에러 메시지를 번역할 때 사용한다.
+func (e BadRequestError) Message() (string, []interface{}) {
+	return e.msg, e.vals
+}
+
 // AuthError는 특정 사용자가 허락되지 않은 행동을 요청했음을 의미하는 에러이다.
 type AuthError struct {
-	err error
+	err  error
+	msg  string
+	vals []interface{}
 }
 
 // Auth는 AuthError를 반환한다.
 func Auth(msg string, vals ...interface{}) AuthError {
-	return AuthError{fmt.Errorf(msg, vals...)}
+	return AuthError{err: fmt.Errorf(msg, vals...), msg: msg, vals: vals}
 }
 
 func (e AuthError) Error() string {
@@ -57,3 +75,9 @@ func (e AuthError) Error() string {
 func (e AuthError) Unwrap() error {
 	return e.err
 }
+
+// Message는 에러 메시지의 형식 문자열과 인자를 반환한다.
+// 사용자에게 보일 에러 메시지를 번역할 때 사용한다.
+func (e AuthError) Message() (string, []interface{}) {
+	return e.msg, e.vals
+}
@@ -7,6 +7,7 @@ require (
 	github.com/lib/pq v1.0.0
 	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
 	golang.org/x/crypto v0.0.0-20180904163835-0709b304e793
+	golang.org/x/image v0.0.0-20200927104501-e162460cd6b5
 )
 
 go 1.13
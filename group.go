@@ -16,10 +16,17 @@ var CreateTableIfNotExistsGroupsStmt = `CREATE TABLE IF NOT EXISTS groups (
 	default_tasks STRING[] NOT NULL,
 	notes STRING NOT NULL,
 	attrs STRING NOT NULL,
+	typed_attrs JSONB NOT NULL DEFAULT '{}',
 	UNIQUE(show, grp),
 	CONSTRAINT groups_pk PRIMARY KEY (show, grp)
 )`
 
+// AlterTableGroupsStmts는 이전 버전에서 만들어진 groups 테이블에
+// 새로 추가된 열을 더하는 sql 구문들이다. 여러번 실행해도 안전하다.
+var AlterTableGroupsStmts = []string{
+	"ALTER TABLE groups ADD COLUMN IF NOT EXISTS typed_attrs JSONB NOT NULL DEFAULT '{}'",
+}
+
 type Group struct {
 	Show  string `db:"show"`
 	Group string `db:"grp"` // group이 sql 구문이기 때문에 줄여서 씀.
@@ -30,6 +37,9 @@ type Group struct {
 
 	// Attrs는 커스텀 속성으로 db에는 여러줄의 문자열로 저장된다. 각 줄은 키: 값의 쌍이다.
 	Attrs DBStringMap `db:"attrs"`
+	// TypedAttrs는 커스텀 속성을 정의된 타입의 값으로 저장한 것으로, db에서 비교할 때 쓴다.
+	// 그룹을 저장할 때 Attrs로부터 다시 만들어진다.
+	TypedAttrs DBJSONMap `db:"typed_attrs"`
 }
 
 var groupDBKey string = strings.Join(dbKeys(&Group{}), ", ")
@@ -108,6 +118,18 @@ func verifyGroup(db *sql.DB, s *Group) error {
 			return BadRequest("task not defined in site: %s", t)
 		}
 	}
+	sh, err := GetShow(db, s.Show)
+	if err != nil {
+		return err
+	}
+	old, err := storedAttrs(db, "groups", "show=$1 AND grp=$2", s.Show, s.Group)
+	if err != nil {
+		return err
+	}
+	s.TypedAttrs, err = verifyAttrs(db, attrDefs(si, sh, AttrKindGroup), &s.Attrs, old)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -125,6 +147,7 @@ func AddGroup(db *sql.DB, s *Group) error {
 	stmts := []dbStatement{
 		dbStmt(fmt.Sprintf("INSERT INTO groups (%s) VALUES (%s)", groupDBKey, groupDBIdx), dbVals(s)...),
 	}
+	stmts = append(stmts, groupIndexStmts(s)...)
 	return dbExec(db, stmts)
 }
 
@@ -135,9 +158,11 @@ func GetGroup(db *sql.DB, show, grp string) (*Group, error) {
 	if err != nil {
 		return nil, err
 	}
-	_, err = GetShow(db, show)
-	if err != nil {
-		return nil, err
+	// 부모를 먼저 검사하지 않고 바로 찾는다. 부모가 지워질 때 자식도 함께 지워지므로
+	// 그룹을 찾았다면 부모도 있다. 찾지 못했을 때만 어느 부모가 없는지 검사한다.
+	v, gen, ok := cache.get(groupCacheKey(show, grp))
+	if ok {
+		return v.(*Group), nil
 	}
 	s := &Group{}
 	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM groups WHERE show=$1 AND grp=$2 LIMIT 1", groupDBKey), show, grp)
@@ -146,10 +171,15 @@ func GetGroup(db *sql.DB, show, grp string) (*Group, error) {
 	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			_, err = GetShow(db, show)
+			if err != nil {
+				return nil, err
+			}
 			return nil, NotFound("group not found: %s", JoinGroupID(show, grp))
 		}
 		return nil, err
 	}
+	cache.put(groupCacheKey(show, grp), s, gen)
 	return s, err
 }
 
@@ -188,21 +218,33 @@ func UpdateGroup(db *sql.DB, s *Group) error {
 	stmts := []dbStatement{
 		dbStmt(fmt.Sprintf("UPDATE groups SET (%s) = (%s) WHERE show='%s' AND grp='%s'", groupDBKey, groupDBIdx, s.Show, s.Group), dbVals(s)...),
 	}
+	stmts = append(stmts, groupIndexStmts(s)...)
+	defer invalidateGroupCache(s.Show, s.Group)
 	return dbExec(db, stmts)
 }
 
 // DeleteGroup은 해당 그룹과 그 하위의 모든 데이터를 db에서 지운다.
+// 그룹 밖의 유닛이 그룹의 유닛을 애셋으로 참조하고 있다면 지우지 않고 에러를 반환한다.
 // 만일 처리 중간에 에러가 나면 아무 데이터도 지우지 않고 에러를 반환한다.
 func DeleteGroup(db *sql.DB, show, grp string) error {
 	_, err := GetGroup(db, show, grp)
 	if err != nil {
 		return err
 	}
+	err = groupMustNotBeReferenced(db, show, grp)
+	if err != nil {
+		return err
+	}
 	stmts := []dbStatement{
 		dbStmt("DELETE FROM groups WHERE show=$1 AND grp=$2", show, grp),
 		dbStmt("DELETE FROM units WHERE show=$1 AND grp=$2", show, grp),
 		dbStmt("DELETE FROM tasks WHERE show=$1 AND grp=$2", show, grp),
 		dbStmt("DELETE FROM versions WHERE show=$1 AND grp=$2", show, grp),
+		dbStmt("DELETE FROM cut_revisions WHERE show=$1 AND grp=$2", show, grp),
+		dbStmt("DELETE FROM search_docs WHERE show=$1 AND grp=$2", show, grp),
+		dbStmt("DELETE FROM search_index WHERE show=$1 AND grp=$2", show, grp),
+		dbStmt("DELETE FROM asset_refs WHERE show=$1 AND grp=$2", show, grp),
 	}
+	defer invalidateGroupCache(show, grp)
 	return dbExec(db, stmts)
 }
@@ -0,0 +1,191 @@
+package roi
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// resolveAssetRef는 show의 유닛이 가진 애셋 참조를 애셋의 쇼, 그룹, 유닛으로 나눈다.
+// 같은 쇼의 애셋은 그룹/유닛, 라이브러리 쇼의 애셋은 쇼/그룹/유닛 형식으로 참조한다.
+// 같은 쇼나 라이브러리 쇼가 아닌 다른 쇼의 애셋은 참조할 수 없다.
+func resolveAssetRef(show, library, ref string) (string, string, string, error) {
+	a := strings.Split(ref, "/")
+	switch len(a) {
+	case 2:
+		err := verifyUnitPrimaryKeys(show, a[0], a[1])
+		if err != nil {
+			return "", "", "", err
+		}
+		return show, a[0], a[1], nil
+	case 3:
+		err := verifyUnitPrimaryKeys(a[0], a[1], a[2])
+		if err != nil {
+			return "", "", "", err
+		}
+		if a[0] != show && a[0] != library {
+			return "", "", "", BadRequest("asset should be in the same show or the library show: %s", ref)
+		}
+		return a[0], a[1], a[2], nil
+	}
+	return "", "", "", BadRequest("need asset name as group/unit or show/group/unit: %s", ref)
+}
+
+// splitAssetRef는 show의 유닛에 저장된 애셋 참조를 애셋의 쇼, 그룹, 유닛으로 나눈다.
+// 저장된 참조는 verifyUnit에서 검사되었으므로 다시 검사하지 않으며 형식이 맞지 않으면 false를 반환한다.
+func splitAssetRef(show, ref string) (string, string, string, bool) {
+	ns := strings.Split(ref, "/")
+	if len(ns) == 2 {
+		return show, ns[0], ns[1], true
+	}
+	if len(ns) == 3 {
+		return ns[0], ns[1], ns[2], true
+	}
+	return "", "", "", false
+}
+
+// assetRef는 show의 유닛이 해당 애셋을 참조할 때 쓰는 이름을 반환한다.
+// 같은 쇼의 애셋은 그룹/유닛, 다른 쇼의 애셋은 쇼/그룹/유닛 형식이다.
+func assetRef(show, assetShow, grp, unit string) string {
+	if assetShow == show {
+		return grp + "/" + unit
+	}
+	return JoinUnitID(assetShow, grp, unit)
+}
+
+// ForkLibraryAsset은 라이브러리 애셋을 show의 애셋으로 복사하고
+// show에서 라이브러리 애셋을 참조하던 유닛들이 복사된 애셋을 참조하도록 바꾼다.
+// 복사된 애셋은 라이브러리와 같은 그룹, 유닛 이름을 가지며 그 그룹이 show에 없다면 함께 만든다.
+// 태스크는 새로 작업하도록 워크플로우의 시작 상태로 만들어진다.
+// 만일 처리 중간에 에러가 나면 아무 데이터도 바꾸지 않고 에러를 반환한다.
+func ForkLibraryAsset(db *sql.DB, id, show string) (*Unit, error) {
+	lib, grp, unit, err := SplitUnitID(id)
+	if err != nil {
+		return nil, err
+	}
+	si, err := GetSite(db)
+	if err != nil {
+		return nil, err
+	}
+	if si.LibraryShow == "" || lib != si.LibraryShow {
+		return nil, BadRequest("not a library asset: %s", id)
+	}
+	if show == lib {
+		return nil, BadRequest("could not fork a library asset into the library show: %s", id)
+	}
+	src, err := GetUnit(db, lib, grp, unit)
+	if err != nil {
+		return nil, err
+	}
+	_, err = GetShow(db, show)
+	if err != nil {
+		return nil, err
+	}
+	_, err = GetUnit(db, show, grp, unit)
+	if err == nil {
+		return nil, BadRequest("unit already exist: %s", JoinUnitID(show, grp, unit))
+	}
+	if !errors.As(err, &NotFoundError{}) {
+		return nil, err
+	}
+	stmts := []dbStatement{}
+	_, err = GetGroup(db, show, grp)
+	if err != nil {
+		if !errors.As(err, &NotFoundError{}) {
+			return nil, err
+		}
+		srcGrp, err := GetGroup(db, lib, grp)
+		if err != nil {
+			return nil, err
+		}
+		g := &Group{
+			Show:         show,
+			Group:        grp,
+			DefaultTasks: srcGrp.DefaultTasks,
+			Notes:        srcGrp.Notes,
+			Attrs:        make(DBStringMap),
+		}
+		err = verifyGroup(db, g)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, dbStmt(fmt.Sprintf("INSERT INTO groups (%s) VALUES (%s)", groupDBKey, groupDBIdx), dbVals(g)...))
+		stmts = append(stmts, groupIndexStmts(g)...)
+	}
+	u := &Unit{}
+	*u = *src
+	u.Show = show
+	u.Tags = append([]string{}, src.Tags...)
+	u.Tasks = append([]string{}, src.Tasks...)
+	u.Attrs = make(DBStringMap)
+	for k, v := range src.Attrs {
+		u.Attrs[k] = v
+	}
+	// 라이브러리 애셋이 참조하던 애셋은 라이브러리에 있으므로 전체 아이디로 참조한다.
+	u.Assets = make([]string, 0, len(src.Assets))
+	for _, a := range src.Assets {
+		ash, agrp, aunit, err := resolveAssetRef(lib, lib, a)
+		if err != nil {
+			return nil, err
+		}
+		u.Assets = append(u.Assets, assetRef(show, ash, agrp, aunit))
+	}
+	err = verifyUnit(db, u)
+	if err != nil {
+		return nil, err
+	}
+	stmts = append(stmts, dbStmt(fmt.Sprintf("INSERT INTO units (%s) VALUES (%s)", unitDBKey, unitDBIdx), dbVals(u)...))
+	stmts = append(stmts, unitIndexStmts(u)...)
+	stmts = append(stmts, assetRefStmts(u)...)
+	for _, task := range u.Tasks {
+		t, err := newUnitTask(db, u, task)
+		if err != nil {
+			return nil, err
+		}
+		st, err := addTaskStmts(db, t)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, st...)
+	}
+	users, err := AssetUsers(db, lib, grp, unit)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range users {
+		if s.Show != show {
+			continue
+		}
+		// 복사된 애셋은 아직 db에 없으므로 참조를 바꾸기 전에 검사한다.
+		err := verifyUnit(db, s)
+		if err != nil {
+			return nil, err
+		}
+		for i, a := range s.Assets {
+			if a == id {
+				s.Assets[i] = assetRef(show, show, grp, unit)
+			}
+		}
+		sort.Strings(s.Assets)
+		st, err := updateVerifiedUnitStmts(db, s, "", "")
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, st...)
+	}
+	st, err := addShowTagsStmts(db, show, u.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(st) != 0 {
+		defer invalidateShowCache(show)
+	}
+	stmts = append(stmts, st...)
+	err = dbExec(db, stmts)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
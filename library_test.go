@@ -0,0 +1,165 @@
+package roi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestResolveAssetRef(t *testing.T) {
+	cases := []struct {
+		show string
+		ref  string
+		want string
+	}{
+		{show: "TEST", ref: "char/hero", want: "TEST/char/hero"},
+		{show: "TEST", ref: "TEST/char/hero", want: "TEST/char/hero"},
+		{show: "TEST", ref: "LIB/char/hero", want: "LIB/char/hero"},
+		{show: "LIB", ref: "char/hero", want: "LIB/char/hero"},
+	}
+	for _, c := range cases {
+		show, grp, unit, err := resolveAssetRef(c.show, "LIB", c.ref)
+		if err != nil {
+			t.Fatalf("%s: %q: %v", c.show, c.ref, err)
+		}
+		got := JoinUnitID(show, grp, unit)
+		if got != c.want {
+			t.Fatalf("%s: %q: got %q, want %q", c.show, c.ref, got, c.want)
+		}
+	}
+	bads := []string{
+		"hero",
+		"OTHER/char/hero",
+		"LIB/char/hero/mod",
+		"char/he ro",
+	}
+	for _, b := range bads {
+		_, _, _, err := resolveAssetRef("TEST", "LIB", b)
+		if !errors.As(err, &BadRequestError{}) {
+			t.Fatalf("%q: want bad request error, got %v", b, err)
+		}
+	}
+	// 라이브러리가 정해지지 않았다면 다른 쇼의 애셋은 참조할 수 없다.
+	_, _, _, err := resolveAssetRef("TEST", "", "LIB/char/hero")
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error without library, got %v", err)
+	}
+}
+
+func TestAssetRef(t *testing.T) {
+	if got := assetRef("TEST", "TEST", "char", "hero"); got != "char/hero" {
+		t.Fatalf("same show: got %q", got)
+	}
+	if got := assetRef("TEST", "LIB", "char", "hero"); got != "LIB/char/hero" {
+		t.Fatalf("library: got %q", got)
+	}
+}
+
+func TestForkLibraryAsset(t *testing.T) {
+	db, err := testDB()
+	if err != nil {
+		t.Fatalf("could not connect to database: %v", err)
+	}
+	err = AddSite(db)
+	if err != nil {
+		t.Fatalf("could not add site: %s", err)
+	}
+	defer func() {
+		err := DeleteSite(db)
+		if err != nil {
+			t.Fatalf("could not delete site: %s", err)
+		}
+	}()
+	lib := &Show{}
+	*lib = *testShow
+	lib.Show = "LIB"
+	for _, sh := range []*Show{testShow, lib} {
+		err = AddShow(db, sh)
+		if err != nil {
+			t.Fatalf("could not add show: %s", err)
+		}
+	}
+	defer func() {
+		for _, sh := range []*Show{testShow, lib} {
+			err = DeleteShow(db, sh.Show)
+			if err != nil {
+				t.Fatalf("could not delete show: %s", err)
+			}
+		}
+	}()
+	site, err := GetSite(db)
+	if err != nil {
+		t.Fatalf("could not get site: %s", err)
+	}
+	withLib := *site
+	withLib.LibraryShow = lib.Show
+	err = UpdateSite(db, &withLib)
+	if err != nil {
+		t.Fatalf("could not set library show: %s", err)
+	}
+	noLib := withLib
+	noLib.LibraryShow = ""
+	defer func() {
+		err := UpdateSite(db, &noLib)
+		if err != nil {
+			t.Fatalf("could not clear library show: %s", err)
+		}
+	}()
+	libGroup := &Group{Show: lib.Show, Group: "char", DefaultTasks: []string{}, Attrs: DBStringMap{}}
+	for _, g := range []*Group{libGroup, testGroup} {
+		err = AddGroup(db, g)
+		if err != nil {
+			t.Fatalf("could not add group: %s", err)
+		}
+	}
+	asset := &Unit{Show: lib.Show, Group: libGroup.Group, Unit: "hero", Status: StatusInProgress, Tags: []string{}, Assets: []string{}, Tasks: []string{"fx"}, Attrs: DBStringMap{}}
+	shot := &Unit{Show: testShow.Show, Group: testGroup.Group, Unit: "0010", Status: StatusInProgress, Tags: []string{}, Assets: []string{asset.ID()}, Tasks: []string{"fx"}, Attrs: DBStringMap{}}
+	for _, u := range []*Unit{asset, shot} {
+		err = AddUnit(db, u)
+		if err != nil {
+			t.Fatalf("could not add unit: %s", err)
+		}
+	}
+	// 다른 쇼에서 참조하는 동안은 라이브러리 쇼를 바꿀 수 없다.
+	err = UpdateSite(db, &noLib)
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error when clearing a used library show, got %v", err)
+	}
+	forked, err := ForkLibraryAsset(db, asset.ID(), testShow.Show)
+	if err != nil {
+		t.Fatalf("could not fork library asset: %s", err)
+	}
+	if forked.ID() != JoinUnitID(testShow.Show, libGroup.Group, asset.Unit) {
+		t.Fatalf("forked asset: got %s", forked.ID())
+	}
+	_, err = GetGroup(db, testShow.Show, libGroup.Group)
+	if err != nil {
+		t.Fatalf("group of the forked asset should be created: %s", err)
+	}
+	got, err := GetUnit(db, shot.Show, shot.Group, shot.Unit)
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	want := []string{libGroup.Group + "/" + asset.Unit}
+	if !reflect.DeepEqual(got.Assets, want) {
+		t.Fatalf("shot assets: got %v, want %v", got.Assets, want)
+	}
+	users, err := AssetUsers(db, asset.Show, asset.Group, asset.Unit)
+	if err != nil {
+		t.Fatalf("could not get asset users: %s", err)
+	}
+	if len(users) != 0 {
+		t.Fatalf("library asset should not be used after fork: got %v", users)
+	}
+	users, err = AssetUsers(db, forked.Show, forked.Group, forked.Unit)
+	if err != nil {
+		t.Fatalf("could not get asset users: %s", err)
+	}
+	if len(users) != 1 || users[0].ID() != shot.ID() {
+		t.Fatalf("forked asset users: got %v, want [%s]", users, shot.ID())
+	}
+	_, err = ForkLibraryAsset(db, asset.ID(), testShow.Show)
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error when forking twice, got %v", err)
+	}
+}
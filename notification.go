@@ -0,0 +1,93 @@
+package roi
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CreateTableIfNotExistsNotificationsStmt는 DB에 notifications 테이블을 생성하는 sql 구문이다.
+// 테이블은 타입보다 많은 정보를 담고 있을수도 있다.
+var CreateTableIfNotExistsNotificationsStmt = `CREATE TABLE IF NOT EXISTS notifications (
+	id UUID NOT NULL DEFAULT gen_random_uuid(),
+	user_id STRING NOT NULL CHECK (length(user_id) > 0),
+	created TIMESTAMPTZ NOT NULL,
+	message STRING NOT NULL,
+	args STRING[] NOT NULL DEFAULT ARRAY[],
+	link STRING NOT NULL,
+	CONSTRAINT notifications_pk PRIMARY KEY (user_id, created, id)
+)`
+
+// Notification은 사용자에게 보내는 알림이다.
+type Notification struct {
+	User    string    `db:"user_id"`
+	Created time.Time `db:"created"` // 알림 시간; 항목 생성시 자동으로 입력된다.
+	// Message는 알림 메시지이다. Args가 있다면 형식 문자열이며,
+	// UI에서 사용자의 언어로 번역한 뒤 Args를 채워 보여준다.
+	Message string `db:"message"`
+	// Args는 Message의 형식 문자열에 들어갈 인자이다.
+	Args []string `db:"args"`
+	// Link는 알림과 관련된 페이지의 주소이다. 없다면 빈 문자열이다.
+	Link string `db:"link"`
+}
+
+var notificationDBKey string = strings.Join(dbKeys(&Notification{}), ", ")
+var notificationDBIdx string = strings.Join(dbIdxs(&Notification{}), ", ")
+var _ []interface{} = dbVals(&Notification{})
+
+// addNotificationStmts는 알림을 추가하는 dbStatement를 반환한다.
+func addNotificationStmts(n *Notification) []dbStatement {
+	n.Created = time.Now()
+	return []dbStatement{
+		dbStmt(fmt.Sprintf("INSERT INTO notifications (%s) VALUES (%s)", notificationDBKey, notificationDBIdx), dbVals(n)...),
+	}
+}
+
+// AddNotification은 db에 사용자 알림을 추가한다.
+func AddNotification(db *sql.DB, n *Notification) error {
+	if n.User == "" {
+		return BadRequest("notification needs a user")
+	}
+	return dbExec(db, addNotificationStmts(n))
+}
+
+// UserNotifications는 사용자의 알림들을 최신 순으로 반환한다.
+func UserNotifications(db *sql.DB, user string) ([]*Notification, error) {
+	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM notifications WHERE user_id=$1 ORDER BY created DESC", notificationDBKey), user)
+	ns := make([]*Notification, 0)
+	err := dbQuery(db, stmt, func(rows *sql.Rows) error {
+		n := &Notification{}
+		err := scan(rows, n)
+		if err != nil {
+			return err
+		}
+		ns = append(ns, n)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// CountUserNotifications는 사용자의 알림 수를 반환한다.
+func CountUserNotifications(db *sql.DB, user string) (int, error) {
+	n := 0
+	stmt := dbStmt("SELECT count(*) FROM notifications WHERE user_id=$1", user)
+	err := dbQueryRow(db, stmt, func(row *sql.Row) error {
+		return row.Scan(&n)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// ClearNotifications는 사용자의 알림들을 모두 지운다.
+func ClearNotifications(db *sql.DB, user string) error {
+	stmts := []dbStatement{
+		dbStmt("DELETE FROM notifications WHERE user_id=$1", user),
+	}
+	return dbExec(db, stmts)
+}
@@ -0,0 +1,393 @@
+package roi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenTimelineIO(OTIO) json 파일을 읽고 쓰는데 필요한 최소한의 구조이다.
+// 각 오브젝트의 OTIO_SCHEMA 필드가 오브젝트의 종류와 버전을 나타낸다.
+// 자세한 형식은 https://opentimelineio.readthedocs.io 를 참고한다.
+
+// OTIOTimeline은 OTIO 파일로 내보낼 타임라인이다.
+type OTIOTimeline struct {
+	Schema   string                 `json:"OTIO_SCHEMA"`
+	Name     string                 `json:"name"`
+	Metadata map[string]interface{} `json:"metadata"`
+	Tracks   *otioStack             `json:"tracks"`
+}
+
+type otioStack struct {
+	Schema   string                 `json:"OTIO_SCHEMA"`
+	Name     string                 `json:"name"`
+	Metadata map[string]interface{} `json:"metadata"`
+	Children []*otioTrack           `json:"children"`
+}
+
+type otioTrack struct {
+	Schema   string                 `json:"OTIO_SCHEMA"`
+	Name     string                 `json:"name"`
+	Kind     string                 `json:"kind"`
+	Metadata map[string]interface{} `json:"metadata"`
+	Children []*otioClip            `json:"children"`
+}
+
+type otioClip struct {
+	Schema         string                 `json:"OTIO_SCHEMA"`
+	Name           string                 `json:"name"`
+	Metadata       map[string]interface{} `json:"metadata"`
+	SourceRange    *otioTimeRange         `json:"source_range"`
+	MediaReference *otioMediaReference    `json:"media_reference"`
+}
+
+type otioMediaReference struct {
+	Schema    string                 `json:"OTIO_SCHEMA"`
+	Name      string                 `json:"name"`
+	Metadata  map[string]interface{} `json:"metadata"`
+	TargetURL string                 `json:"target_url,omitempty"`
+
+	// 아래는 ImageSequenceReference.1 스키마에서만 쓰인다.
+	TargetURLBase    string  `json:"target_url_base,omitempty"`
+	NamePrefix       string  `json:"name_prefix,omitempty"`
+	NameSuffix       string  `json:"name_suffix,omitempty"`
+	StartFrame       *int    `json:"start_frame,omitempty"`
+	FrameStep        int     `json:"frame_step,omitempty"`
+	Rate             float64 `json:"rate,omitempty"`
+	FrameZeroPadding int     `json:"frame_zero_padding,omitempty"`
+}
+
+// otioSequenceReference는 시퀀스를 가리키는 미디어 레퍼런스를 반환한다.
+// 하나의 파일이라면 ExternalReference.1, 이미지 시퀀스라면 ImageSequenceReference.1 이다.
+func otioSequenceReference(name string, seq *Sequence, rate float64) *otioMediaReference {
+	if !seq.IsSequence() {
+		return &otioMediaReference{
+			Schema:    "ExternalReference.1",
+			Name:      name,
+			Metadata:  map[string]interface{}{},
+			TargetURL: seq.Pattern,
+		}
+	}
+	dir := ""
+	file := seq.Pattern
+	if i := strings.LastIndex(file, "/"); i != -1 {
+		dir = file[:i+1]
+		file = file[i+1:]
+	}
+	prefix := file
+	suffix := ""
+	if loc := reSeqHash.FindStringIndex(file); loc != nil {
+		prefix = file[:loc[0]]
+		suffix = file[loc[1]:]
+	}
+	start := seq.First
+	return &otioMediaReference{
+		Schema:           "ImageSequenceReference.1",
+		Name:             name,
+		Metadata:         map[string]interface{}{},
+		TargetURLBase:    dir,
+		NamePrefix:       prefix,
+		NameSuffix:       suffix,
+		StartFrame:       &start,
+		FrameStep:        1,
+		Rate:             rate,
+		FrameZeroPadding: seq.Padding,
+	}
+}
+
+type otioTimeRange struct {
+	Schema    string            `json:"OTIO_SCHEMA"`
+	StartTime *otioRationalTime `json:"start_time"`
+	Duration  *otioRationalTime `json:"duration"`
+}
+
+type otioRationalTime struct {
+	Schema string  `json:"OTIO_SCHEMA"`
+	Rate   float64 `json:"rate"`
+	Value  float64 `json:"value"`
+}
+
+func otioTime(frame int, rate float64) *otioRationalTime {
+	return &otioRationalTime{Schema: "RationalTime.1", Rate: rate, Value: float64(frame)}
+}
+
+// DefaultFrameRate는 쇼에 프레임 레이트가 정해지지 않았을 때 쓰는 값이다.
+const DefaultFrameRate = 24
+
+// ShowFrameRate는 쇼의 커스텀 속성 fps에 적힌 프레임 레이트를 반환한다.
+// 속성이 없거나 잘못되었다면 DefaultFrameRate를 반환한다.
+func ShowFrameRate(sh *Show) float64 {
+	fps, err := strconv.ParseFloat(sh.Attrs["fps"], 64)
+	if err != nil || fps <= 0 {
+		return DefaultFrameRate
+	}
+	return fps
+}
+
+// GroupOTIO는 그룹의 유닛들을 편집 순서대로 클립으로 늘어놓은 OTIO 타임라인을 만든다.
+// 클립의 소스 구간은 유닛의 프레임 구간이며, 미디어는 유닛의 태스크 중
+// 마지막 태스크부터 찾은 승인된 버전의 영상(없다면 첫 결과물 시퀀스)이다.
+// 오밋 분류에 속하는 상태의 유닛은 포함하지 않는다.
+func GroupOTIO(db *sql.DB, show, grp string, rate float64) (*OTIOTimeline, error) {
+	_, err := GetGroup(db, show, grp)
+	if err != nil {
+		return nil, err
+	}
+	site, err := GetSite(db)
+	if err != nil {
+		return nil, err
+	}
+	if rate <= 0 {
+		return nil, BadRequest("invalid frame rate: %v", rate)
+	}
+	us, err := SearchUnits(db, show, []string{grp}, []string{}, "", "", "", "", "", "", time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(us, func(i, j int) bool {
+		return us[i].EditOrder < us[j].EditOrder
+	})
+	clips := make([]*otioClip, 0, len(us))
+	for _, u := range us {
+		if site.IsOmitted(u.Status) {
+			continue
+		}
+		roiMeta := map[string]interface{}{
+			"id":          u.ID(),
+			"unit":        u.Unit,
+			"edit_order":  u.EditOrder,
+			"cut_in":      u.CutIn,
+			"cut_out":     u.CutOut,
+			"head_handle": u.HeadHandle,
+			"tail_handle": u.TailHandle,
+		}
+		ref := &otioMediaReference{
+			Schema:   "MissingReference.1",
+			Metadata: map[string]interface{}{},
+		}
+		v, err := approvedVersion(db, u)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			roiMeta["task"] = v.Task
+			roiMeta["version"] = v.Version
+			name := v.Task + "/" + v.Version
+			if v.Mov != "" {
+				ref = &otioMediaReference{
+					Schema:    "ExternalReference.1",
+					Name:      name,
+					Metadata:  map[string]interface{}{},
+					TargetURL: v.Mov,
+				}
+			} else if seqs := v.OutputSequences(); len(seqs) != 0 {
+				// 결과물은 프레임 범위가 붙은 시퀀스 문자열일 수 있으므로 그대로 쓰지 않는다.
+				ref = otioSequenceReference(name, seqs[0], rate)
+			}
+		}
+		// 프레임 구간이 없는 유닛은 길이가 0인 클립이 된다.
+		in := u.CutIn
+		if u.Duration() == 0 {
+			in = 0
+		}
+		clips = append(clips, &otioClip{
+			Schema:   "Clip.1",
+			Name:     u.Unit,
+			Metadata: map[string]interface{}{"roi": roiMeta},
+			SourceRange: &otioTimeRange{
+				Schema:    "TimeRange.1",
+				StartTime: otioTime(in, rate),
+				Duration:  otioTime(u.Duration(), rate),
+			},
+			MediaReference: ref,
+		})
+	}
+	tl := &OTIOTimeline{
+		Schema:   "Timeline.1",
+		Name:     show + "/" + grp,
+		Metadata: map[string]interface{}{"roi": map[string]interface{}{"show": show, "group": grp}},
+		Tracks: &otioStack{
+			Schema:   "Stack.1",
+			Name:     "tracks",
+			Metadata: map[string]interface{}{},
+			Children: []*otioTrack{
+				{
+					Schema:   "Track.1",
+					Name:     "V1",
+					Kind:     "Video",
+					Metadata: map[string]interface{}{},
+					Children: clips,
+				},
+			},
+		},
+	}
+	return tl, nil
+}
+
+// approvedVersion은 유닛의 태스크 중 뒤쪽 태스크부터 찾은 승인된 버전을 반환한다.
+// 태스크는 사이트에 정의된 작업 순서로 정렬되어 있으므로 뒤쪽 태스크의 결과물이 최종에 가깝다.
+// 승인된 버전이 없다면 nil을 반환한다.
+func approvedVersion(db *sql.DB, u *Unit) (*Version, error) {
+	for i := len(u.Tasks) - 1; i >= 0; i-- {
+		t, err := GetTask(db, u.Show, u.Group, u.Unit, u.Tasks[i])
+		if err != nil {
+			return nil, err
+		}
+		if t.ApprovedVersion == "" {
+			continue
+		}
+		return GetVersion(db, t.Show, t.Group, t.Unit, t.Task, t.ApprovedVersion)
+	}
+	return nil, nil
+}
+
+// WriteOTIO는 타임라인을 OTIO json 형식으로 쓴다.
+func WriteOTIO(w io.Writer, tl *OTIOTimeline) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(tl)
+}
+
+// OTIOClip은 OTIO 타임라인에서 읽은 비디오 클립 하나이다.
+type OTIOClip struct {
+	Name string
+	// Unit은 roi에서 내보낸 클립의 메타데이터에 기록된 유닛 이름이다. 없다면 빈 문자열이다.
+	Unit string
+	// CutIn은 roi에서 내보낸 클립의 메타데이터에 기록된 컷 인이다. 없다면 0이다.
+	CutIn int
+	// RecordIn은 타임라인에서 클립이 시작하는 시간(초)이다.
+	RecordIn float64
+	// Duration은 클립의 길이(프레임)이다.
+	Duration int
+}
+
+// otioItem은 OTIO 파일을 읽을 때 쓰는 구조로, 필요한 필드만 가지고 모든 종류의 오브젝트를 나타낸다.
+type otioItem struct {
+	Schema      string                 `json:"OTIO_SCHEMA"`
+	Name        string                 `json:"name"`
+	Kind        string                 `json:"kind"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	SourceRange *otioTimeRange         `json:"source_range"`
+	Children    []*otioItem            `json:"children"`
+	Tracks      *otioItem              `json:"tracks"`
+}
+
+// is는 오브젝트의 스키마 이름이 name인지 검사한다. 스키마 버전은 검사하지 않는다.
+func (it *otioItem) is(name string) bool {
+	return strings.HasPrefix(it.Schema, name+".")
+}
+
+// duration은 오브젝트의 소스 구간의 길이를 프레임과 초로 반환한다.
+func (it *otioItem) duration() (int, float64) {
+	if it.SourceRange == nil || it.SourceRange.Duration == nil {
+		return 0, 0
+	}
+	d := it.SourceRange.Duration
+	frames := int(math.Round(d.Value))
+	if d.Rate <= 0 {
+		return frames, 0
+	}
+	return frames, d.Value / d.Rate
+}
+
+// ParseOTIO는 OTIO json 파일에서 비디오 트랙의 클립들을 읽어 타임라인의 시간 순서로 반환한다.
+// 최상위 오브젝트는 Timeline, Stack 또는 Track이어야 한다.
+func ParseOTIO(r io.Reader) ([]*OTIOClip, error) {
+	root := &otioItem{}
+	err := json.NewDecoder(r).Decode(root)
+	if err != nil {
+		return nil, BadRequest("invalid otio: %v", err)
+	}
+	if root.is("Timeline") {
+		if root.Tracks == nil {
+			return nil, BadRequest("invalid otio: timeline has no tracks")
+		}
+		root = root.Tracks
+	}
+	var tracks []*otioItem
+	switch {
+	case root.is("Stack"):
+		tracks = root.Children
+	case root.is("Track"):
+		tracks = []*otioItem{root}
+	default:
+		return nil, BadRequest("invalid otio: unsupported top level schema: %s", root.Schema)
+	}
+	clips := make([]*OTIOClip, 0)
+	for _, tr := range tracks {
+		if !tr.is("Track") || (tr.Kind != "" && tr.Kind != "Video") {
+			continue
+		}
+		pos := 0.0
+		for _, it := range tr.Children {
+			frames, secs := it.duration()
+			switch {
+			case it.is("Transition"):
+				// 트랜지션은 앞뒤 클립에 겹쳐 있어 타임라인의 길이를 차지하지 않는다.
+				continue
+			case it.is("Clip"):
+				c := &OTIOClip{
+					Name:     it.Name,
+					RecordIn: pos,
+					Duration: frames,
+				}
+				if meta, ok := it.Metadata["roi"].(map[string]interface{}); ok {
+					c.Unit, _ = meta["unit"].(string)
+					if in, ok := meta["cut_in"].(float64); ok {
+						c.CutIn = int(in)
+					}
+				}
+				clips = append(clips, c)
+			}
+			pos += secs
+		}
+	}
+	sort.SliceStable(clips, func(i, j int) bool {
+		return clips[i].RecordIn < clips[j].RecordIn
+	})
+	return clips, nil
+}
+
+// UnitName은 클립에 해당하는 유닛 이름을 반환한다.
+// roi에서 내보낸 클립이라면 메타데이터의 유닛 이름을, 아니라면 클립 이름에서 찾은 이름을 쓴다.
+// 유효한 유닛 이름을 찾지 못하면 빈 문자열을 반환한다.
+func (c *OTIOClip) UnitName() string {
+	if c.Unit != "" && reUnitName.MatchString(c.Unit) {
+		return c.Unit
+	}
+	return unitNameFromClip(c.Name)
+}
+
+// OTIOEditCuts는 OTIO 클립들을 편집본의 컷 목록으로 변환한다.
+// 같은 유닛의 클립이 여러개라면 처음 나온 위치를 순서로 하고 처음 클립의 길이를 쓴다.
+// 유닛 이름을 찾지 못한 클립은 따로 반환한다.
+func OTIOEditCuts(clips []*OTIOClip) ([]*EditCut, []*OTIOClip) {
+	cuts := make([]*EditCut, 0)
+	unnamed := make([]*OTIOClip, 0)
+	seen := make(map[string]bool)
+	for _, c := range clips {
+		name := c.UnitName()
+		if name == "" {
+			unnamed = append(unnamed, c)
+			continue
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		cuts = append(cuts, &EditCut{
+			Unit:     name,
+			Duration: c.Duration,
+			CutIn:    c.CutIn,
+			Attrs: map[string]string{
+				"otio_clip": c.Name,
+			},
+		})
+	}
+	return cuts, unnamed
+}
@@ -0,0 +1,196 @@
+package roi
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testOTIO = `{
+    "OTIO_SCHEMA": "Timeline.1",
+    "name": "reel1",
+    "tracks": {
+        "OTIO_SCHEMA": "Stack.1",
+        "children": [
+            {
+                "OTIO_SCHEMA": "Track.1",
+                "kind": "Video",
+                "children": [
+                    {
+                        "OTIO_SCHEMA": "Clip.1",
+                        "name": "CG_0010.mov",
+                        "source_range": {
+                            "OTIO_SCHEMA": "TimeRange.1",
+                            "start_time": {"OTIO_SCHEMA": "RationalTime.1", "rate": 24, "value": 86400},
+                            "duration": {"OTIO_SCHEMA": "RationalTime.1", "rate": 24, "value": 48}
+                        }
+                    },
+                    {
+                        "OTIO_SCHEMA": "Transition.1",
+                        "name": "dissolve"
+                    },
+                    {
+                        "OTIO_SCHEMA": "Gap.1",
+                        "source_range": {
+                            "OTIO_SCHEMA": "TimeRange.1",
+                            "start_time": {"OTIO_SCHEMA": "RationalTime.1", "rate": 24, "value": 0},
+                            "duration": {"OTIO_SCHEMA": "RationalTime.1", "rate": 24, "value": 24}
+                        }
+                    },
+                    {
+                        "OTIO_SCHEMA": "Clip.1",
+                        "name": "renamed in editorial",
+                        "metadata": {"roi": {"unit": "CG_0030", "cut_in": 1001}},
+                        "source_range": {
+                            "OTIO_SCHEMA": "TimeRange.1",
+                            "start_time": {"OTIO_SCHEMA": "RationalTime.1", "rate": 24, "value": 1001},
+                            "duration": {"OTIO_SCHEMA": "RationalTime.1", "rate": 24, "value": 36}
+                        }
+                    }
+                ]
+            },
+            {
+                "OTIO_SCHEMA": "Track.1",
+                "kind": "Video",
+                "children": [
+                    {
+                        "OTIO_SCHEMA": "Gap.1",
+                        "source_range": {
+                            "OTIO_SCHEMA": "TimeRange.1",
+                            "start_time": {"OTIO_SCHEMA": "RationalTime.1", "rate": 24, "value": 0},
+                            "duration": {"OTIO_SCHEMA": "RationalTime.1", "rate": 24, "value": 100}
+                        }
+                    },
+                    {
+                        "OTIO_SCHEMA": "Clip.1",
+                        "name": "CG_0020 v003",
+                        "source_range": {
+                            "OTIO_SCHEMA": "TimeRange.1",
+                            "start_time": {"OTIO_SCHEMA": "RationalTime.1", "rate": 24, "value": 0},
+                            "duration": {"OTIO_SCHEMA": "RationalTime.1", "rate": 24, "value": 10}
+                        }
+                    },
+                    {
+                        "OTIO_SCHEMA": "Clip.1",
+                        "name": "slate!",
+                        "source_range": {
+                            "OTIO_SCHEMA": "TimeRange.1",
+                            "start_time": {"OTIO_SCHEMA": "RationalTime.1", "rate": 24, "value": 0},
+                            "duration": {"OTIO_SCHEMA": "RationalTime.1", "rate": 24, "value": 12}
+                        }
+                    }
+                ]
+            },
+            {
+                "OTIO_SCHEMA": "Track.1",
+                "kind": "Audio",
+                "children": [
+                    {
+                        "OTIO_SCHEMA": "Clip.1",
+                        "name": "A_0010"
+                    }
+                ]
+            }
+        ]
+    }
+}`
+
+func TestParseOTIO(t *testing.T) {
+	clips, err := ParseOTIO(strings.NewReader(testOTIO))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0)
+	for _, c := range clips {
+		got = append(got, c.UnitName())
+	}
+	// 두번째 트랙의 클립들은 앞의 갭 때문에 첫 트랙의 클립들 뒤에 온다.
+	want := []string{"CG_0010", "CG_0030", "CG_0020", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	cuts, unnamed := OTIOEditCuts(clips)
+	if len(unnamed) != 1 || unnamed[0].Name != "slate!" {
+		t.Fatalf("unnamed: got %v", unnamed)
+	}
+	wantCuts := []*EditCut{
+		{Unit: "CG_0010", Duration: 48, Attrs: map[string]string{"otio_clip": "CG_0010.mov"}},
+		{Unit: "CG_0030", Duration: 36, CutIn: 1001, Attrs: map[string]string{"otio_clip": "renamed in editorial"}},
+		{Unit: "CG_0020", Duration: 10, Attrs: map[string]string{"otio_clip": "CG_0020 v003"}},
+	}
+	if !reflect.DeepEqual(cuts, wantCuts) {
+		t.Fatalf("cuts: got %v, want %v", cuts, wantCuts)
+	}
+}
+
+func TestOTIORoundTrip(t *testing.T) {
+	// GroupOTIO가 만드는 형식의 타임라인을 다시 읽을 수 있어야 한다.
+	tl := &OTIOTimeline{
+		Schema:   "Timeline.1",
+		Name:     "test/CG",
+		Metadata: map[string]interface{}{},
+		Tracks: &otioStack{
+			Schema:   "Stack.1",
+			Metadata: map[string]interface{}{},
+			Children: []*otioTrack{
+				{
+					Schema:   "Track.1",
+					Kind:     "Video",
+					Metadata: map[string]interface{}{},
+					Children: []*otioClip{
+						{
+							Schema:   "Clip.1",
+							Name:     "0010",
+							Metadata: map[string]interface{}{"roi": map[string]interface{}{"unit": "0010", "cut_in": 1001}},
+							SourceRange: &otioTimeRange{
+								Schema:    "TimeRange.1",
+								StartTime: otioTime(1001, 24),
+								Duration:  otioTime(132, 24),
+							},
+							MediaReference: &otioMediaReference{Schema: "MissingReference.1", Metadata: map[string]interface{}{}},
+						},
+					},
+				},
+			},
+		},
+	}
+	buf := &bytes.Buffer{}
+	err := WriteOTIO(buf, tl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clips, err := ParseOTIO(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*OTIOClip{{Name: "0010", Unit: "0010", CutIn: 1001, Duration: 132}}
+	if !reflect.DeepEqual(clips, want) {
+		t.Fatalf("got %v, want %v", clips, want)
+	}
+}
+
+func TestOTIOSequenceReference(t *testing.T) {
+	seq := ParseSequence("/show/render/comp.####.exr 1001-1100")
+	got := otioSequenceReference("comp/v001", seq, 24)
+	start := 1001
+	want := &otioMediaReference{
+		Schema:           "ImageSequenceReference.1",
+		Name:             "comp/v001",
+		Metadata:         map[string]interface{}{},
+		TargetURLBase:    "/show/render/",
+		NamePrefix:       "comp.",
+		NameSuffix:       ".exr",
+		StartFrame:       &start,
+		FrameStep:        1,
+		Rate:             24,
+		FrameZeroPadding: 4,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	got = otioSequenceReference("comp/v001", ParseSequence("/show/render/comp.mov"), 24)
+	if got.Schema != "ExternalReference.1" || got.TargetURL != "/show/render/comp.mov" {
+		t.Fatalf("got %+v, want external reference to the file", got)
+	}
+}
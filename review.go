@@ -74,7 +74,11 @@ func verifyReview(db *sql.DB, r *Review) error {
 	// 리뷰가 태스크의 상태를 변경한다면 그 상태에 대한 문자열,
 	// 변경하지 않는다면 빈 문자열이다.
 	if r.Status != "" {
-		err = verifyReviewStatus(r.Status)
+		w, err := SiteWorkflow(db)
+		if err != nil {
+			return err
+		}
+		err = w.verifyReviewStatus(r.Status)
 		if err != nil {
 			return err
 		}
@@ -84,19 +88,73 @@ func verifyReview(db *sql.DB, r *Review) error {
 }
 
 // AddReview는 db의 특정 버전에 리뷰를 하나 추가한다.
+// 리뷰 상태와 관계없이 태스크는 수정하지 않는다. ReviewTask를 참고한다.
 func AddReview(db *sql.DB, r *Review) error {
-	err := verifyReview(db, r)
+	stmts, err := addReviewStmts(db, r)
 	if err != nil {
 		return err
 	}
+	return dbExec(db, stmts)
+}
+
+// addReviewStmts는 리뷰를 추가하는 db 구문을 반환한다.
+func addReviewStmts(db *sql.DB, r *Review) ([]dbStatement, error) {
+	err := verifyReview(db, r)
+	if err != nil {
+		return nil, err
+	}
 	// 부모가 있는지 검사
 	_, err = GetVersion(db, r.Show, r.Group, r.Unit, r.Task, r.Version)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	stmts := []dbStatement{
 		dbStmt(fmt.Sprintf("INSERT INTO reviews (%s) VALUES (%s)", reviewDBKey, reviewDBIdx), dbVals(r)...),
 	}
+	stmts = append(stmts, reviewIndexStmts(r)...)
+	return stmts, nil
+}
+
+// ReviewTask는 db의 특정 버전에 리뷰를 추가하고 리뷰 상태에 따라 태스크를 수정한다.
+// 승인이나 리테이크라면 태스크의 리뷰 버전을 비우고, 승인이라면 리뷰한 버전을 승인 버전으로 한다.
+// 리뷰 상태가 사이트 워크플로우에 정의된 태스크 상태이기도 하다면 태스크를 그 상태로 바꾸며,
+// 이 때 리뷰어가 그 전환을 일으킬 수 있어야 한다.
+// 리뷰 추가와 태스크 수정은 하나의 트랜잭션으로 처리된다.
+func ReviewTask(db *sql.DB, r *Review) error {
+	stmts, err := addReviewStmts(db, r)
+	if err != nil {
+		return err
+	}
+	if r.Status != "" {
+		old, err := GetTask(db, r.Show, r.Group, r.Unit, r.Task)
+		if err != nil {
+			return err
+		}
+		t := *old
+		switch r.Status {
+		case StatusApproved:
+			t.ReviewVersion = ""
+			t.ApprovedVersion = r.Version
+		case StatusRetake:
+			t.ReviewVersion = ""
+		}
+		s, w, err := siteWorkflow(db)
+		if err != nil {
+			return err
+		}
+		if w.State(r.Status) != nil {
+			t.Status = r.Status
+		}
+		err = w.verifyTransition(s, old.Status, t.Status, old, r.Reviewer)
+		if err != nil {
+			return err
+		}
+		st, err := updateTaskStmts(db, &t)
+		if err != nil {
+			return err
+		}
+		stmts = append(stmts, st...)
+	}
 	return dbExec(db, stmts)
 }
 
@@ -122,3 +180,22 @@ func VersionReviews(db *sql.DB, show, grp, unit, task, ver string) ([]*Review, e
 	}
 	return reviews, nil
 }
+
+// ShowReviews는 쇼의 모든 리뷰를 작성 시간 순으로 반환한다.
+func ShowReviews(db *sql.DB, show string) ([]*Review, error) {
+	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM reviews WHERE show=$1 ORDER BY created", reviewDBKey), show)
+	reviews := make([]*Review, 0)
+	err := dbQuery(db, stmt, func(rows *sql.Rows) error {
+		r := &Review{}
+		err := scan(rows, r)
+		if err != nil {
+			return err
+		}
+		reviews = append(reviews, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
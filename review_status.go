@@ -1,16 +1,8 @@
 package roi
 
+// AllReviewStatus는 기본 워크플로우(DefaultWorkflow)에서 리뷰시 고를 수 있는 상태이다.
+// 사이트에서 사용하는 상태는 SiteWorkflow의 Review 필드로 얻는다.
 var AllReviewStatus = []Status{
 	StatusRetake,
 	StatusApproved,
 }
-
-// verifyReviewStatus는 받아들인 리뷰 상태가 유효하지 않다면 에러를 반환한다.
-func verifyReviewStatus(rs Status) error {
-	for _, s := range AllReviewStatus {
-		if rs == s {
-			return nil
-		}
-	}
-	return BadRequest("invalid review status: '%s'", rs)
-}
@@ -0,0 +1,455 @@
+package roi
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateTableIfNotExistsSavedSearchesStmt는 DB에 saved_searches 테이블을 생성하는 sql 구문이다.
+// 테이블은 타입보다 많은 정보를 담고 있을수도 있다.
+var CreateTableIfNotExistsSavedSearchesStmt = `CREATE TABLE IF NOT EXISTS saved_searches (
+	show STRING NOT NULL CHECK (length(show) > 0) CHECK (show NOT LIKE '% %'),
+	owner STRING NOT NULL CHECK (length(owner) > 0),
+	name STRING NOT NULL CHECK (length(name) > 0),
+	query STRING NOT NULL,
+	shared BOOL NOT NULL,
+	updated TIMESTAMPTZ NOT NULL,
+	CONSTRAINT saved_searches_pk PRIMARY KEY (show, owner, name)
+)`
+
+// CreateTableIfNotExistsSearchSubscriptionsStmt는 DB에 search_subscriptions 테이블을 생성하는 sql 구문이다.
+// 구독자가 마지막으로 확인한 검색 결과를 함께 저장한다.
+var CreateTableIfNotExistsSearchSubscriptionsStmt = `CREATE TABLE IF NOT EXISTS search_subscriptions (
+	user_id STRING NOT NULL CHECK (length(user_id) > 0),
+	show STRING NOT NULL CHECK (length(show) > 0) CHECK (show NOT LIKE '% %'),
+	owner STRING NOT NULL CHECK (length(owner) > 0),
+	name STRING NOT NULL CHECK (length(name) > 0),
+	last_result STRING[] NOT NULL,
+	checked TIMESTAMPTZ NOT NULL,
+	CONSTRAINT search_subscriptions_pk PRIMARY KEY (user_id, show, owner, name)
+)`
+
+// SavedSearch는 이름을 붙여 저장한 유닛 검색어이다.
+// 소유자만 볼 수 있으며 Shared가 참이면 쇼의 다른 사용자도 볼 수 있다.
+type SavedSearch struct {
+	Show  string `db:"show"`
+	Owner string `db:"owner"`
+	Name  string `db:"name"`
+	// Query는 SearchUnitsQuery에서 사용하는 유닛 검색어이다.
+	Query   string    `db:"query"`
+	Shared  bool      `db:"shared"`
+	Updated time.Time `db:"updated"` // 마지막 수정 시간; 저장할 때 자동으로 입력된다.
+}
+
+var savedSearchDBKey string = strings.Join(dbKeys(&SavedSearch{}), ", ")
+var savedSearchDBIdx string = strings.Join(dbIdxs(&SavedSearch{}), ", ")
+var _ []interface{} = dbVals(&SavedSearch{})
+
+// ID는 저장된 검색을 가리키는 아이디이다. 쇼, 소유자, 이름을 / 로 이은 형식이다.
+func (s *SavedSearch) ID() string {
+	return s.Show + "/" + s.Owner + "/" + s.Name
+}
+
+// SplitSavedSearchID는 저장된 검색의 아이디를 쇼, 소유자, 이름으로 나눈다.
+func SplitSavedSearchID(id string) (string, string, string, error) {
+	ns := strings.SplitN(id, "/", 3)
+	if len(ns) != 3 || ns[0] == "" || ns[1] == "" || ns[2] == "" {
+		return "", "", "", BadRequest("invalid saved search id: %s", id)
+	}
+	return ns[0], ns[1], ns[2], nil
+}
+
+// VisibleTo는 해당 사용자가 이 검색을 볼 수 있는지를 반환한다.
+func (s *SavedSearch) VisibleTo(user string) bool {
+	return s.Owner == user || s.Shared
+}
+
+// verifySavedSearch는 저장할 검색이 유효한지 검사한다.
+func verifySavedSearch(db *sql.DB, s *SavedSearch) error {
+	if s == nil {
+		return fmt.Errorf("nil saved search")
+	}
+	_, err := GetShow(db, s.Show)
+	if err != nil {
+		return err
+	}
+	if s.Owner == "" {
+		return BadRequest("saved search needs an owner")
+	}
+	err = verifySavedSearchName(s.Name)
+	if err != nil {
+		return err
+	}
+	_, err = ParseUnitQuery(s.Query)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// verifySavedSearchName은 검색 이름이 유효한지 검사한다.
+// 이름은 아이디의 일부이므로 / 를 포함할 수 없다.
+func verifySavedSearchName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return BadRequest("saved search name empty")
+	}
+	if name != strings.TrimSpace(name) {
+		return BadRequest("saved search name has leading or trailing spaces: %q", name)
+	}
+	if strings.ContainsAny(name, "/\n") {
+		return BadRequest("saved search name cannot have '/' or newline: %q", name)
+	}
+	return nil
+}
+
+// AddSavedSearch는 db에 검색을 저장한다. 같은 이름의 검색이 이미 있다면 에러를 반환한다.
+func AddSavedSearch(db *sql.DB, s *SavedSearch) error {
+	err := verifySavedSearch(db, s)
+	if err != nil {
+		return err
+	}
+	_, err = GetSavedSearch(db, s.Show, s.Owner, s.Name)
+	if err == nil {
+		return BadRequest("saved search already exists: %s", s.ID())
+	} else if !errors.As(err, &NotFoundError{}) {
+		return err
+	}
+	s.Updated = time.Now()
+	stmts := []dbStatement{
+		dbStmt(fmt.Sprintf("INSERT INTO saved_searches (%s) VALUES (%s)", savedSearchDBKey, savedSearchDBIdx), dbVals(s)...),
+	}
+	return dbExec(db, stmts)
+}
+
+// UpdateSavedSearch는 db에 저장된 검색의 검색어와 공유 여부를 수정한다.
+func UpdateSavedSearch(db *sql.DB, s *SavedSearch) error {
+	err := verifySavedSearch(db, s)
+	if err != nil {
+		return err
+	}
+	_, err = GetSavedSearch(db, s.Show, s.Owner, s.Name)
+	if err != nil {
+		return err
+	}
+	s.Updated = time.Now()
+	stmts := []dbStatement{
+		dbStmt(fmt.Sprintf("UPSERT INTO saved_searches (%s) VALUES (%s)", savedSearchDBKey, savedSearchDBIdx), dbVals(s)...),
+	}
+	if !s.Shared {
+		// 공유를 끊으면 소유자가 아닌 사용자는 더 이상 구독할 수 없다.
+		stmts = append(stmts, dbStmt("DELETE FROM search_subscriptions WHERE show=$1 AND owner=$2 AND name=$3 AND user_id!=$2", s.Show, s.Owner, s.Name))
+	}
+	return dbExec(db, stmts)
+}
+
+// GetSavedSearch는 db에서 저장된 검색을 불러온다.
+// 해당 검색이 없다면 NotFoundError를 반환한다.
+func GetSavedSearch(db *sql.DB, show, owner, name string) (*SavedSearch, error) {
+	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM saved_searches WHERE show=$1 AND owner=$2 AND name=$3", savedSearchDBKey), show, owner, name)
+	s := &SavedSearch{}
+	err := dbQueryRow(db, stmt, func(row *sql.Row) error {
+		return scan(row, s)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, NotFound("saved search not found: %s/%s/%s", show, owner, name)
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// UserSavedSearches는 쇼에서 사용자가 볼 수 있는 검색들을 이름 순으로 반환한다.
+// 사용자가 저장한 검색과 다른 사용자가 공유한 검색이 포함된다.
+func UserSavedSearches(db *sql.DB, show, user string) ([]*SavedSearch, error) {
+	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM saved_searches WHERE show=$1 AND (owner=$2 OR shared)", savedSearchDBKey), show, user)
+	ss := make([]*SavedSearch, 0)
+	err := dbQuery(db, stmt, func(rows *sql.Rows) error {
+		s := &SavedSearch{}
+		err := scan(rows, s)
+		if err != nil {
+			return err
+		}
+		ss = append(ss, s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		if ss[i].Name != ss[j].Name {
+			return ss[i].Name < ss[j].Name
+		}
+		return ss[i].Owner < ss[j].Owner
+	})
+	return ss, nil
+}
+
+// DeleteSavedSearch는 db에서 저장된 검색과 그 구독을 지운다.
+func DeleteSavedSearch(db *sql.DB, show, owner, name string) error {
+	_, err := GetSavedSearch(db, show, owner, name)
+	if err != nil {
+		return err
+	}
+	stmts := []dbStatement{
+		dbStmt("DELETE FROM saved_searches WHERE show=$1 AND owner=$2 AND name=$3", show, owner, name),
+		dbStmt("DELETE FROM search_subscriptions WHERE show=$1 AND owner=$2 AND name=$3", show, owner, name),
+	}
+	return dbExec(db, stmts)
+}
+
+// RunSavedSearch는 저장된 검색어로 유닛을 검색한다.
+func RunSavedSearch(db *sql.DB, s *SavedSearch) ([]*Unit, error) {
+	return SearchUnitsQuery(db, s.Show, s.Query)
+}
+
+// PinSavedSearch는 사용자 설정에 검색을 고정해 메뉴에서 바로 쓸 수 있게 한다.
+// pin이 거짓이면 고정을 푼다.
+func PinSavedSearch(db *sql.DB, user string, s *SavedSearch, pin bool) error {
+	if pin && !s.VisibleTo(user) {
+		return Auth("saved search is not shared: %s", s.ID())
+	}
+	cfg, err := GetUserConfig(db, user)
+	if err != nil {
+		return err
+	}
+	pinned := make([]string, 0, len(cfg.PinnedSearches)+1)
+	for _, id := range cfg.PinnedSearches {
+		if id != s.ID() {
+			pinned = append(pinned, id)
+		}
+	}
+	if pin {
+		pinned = append(pinned, s.ID())
+	}
+	cfg.PinnedSearches = pinned
+	return UpdateUserConfig(db, user, cfg)
+}
+
+// PinnedSearches는 사용자가 고정한 검색들을 고정한 순서대로 반환한다.
+// 고정한 후 지워졌거나 공유가 끊긴 검색은 건너뛴다.
+func PinnedSearches(db *sql.DB, user string) ([]*SavedSearch, error) {
+	cfg, err := GetUserConfig(db, user)
+	if err != nil {
+		return nil, err
+	}
+	return ConfigPinnedSearches(db, user, cfg)
+}
+
+// ConfigPinnedSearches는 PinnedSearches와 같지만 이미 불러온 사용자 설정을 사용한다.
+func ConfigPinnedSearches(db *sql.DB, user string, cfg *UserConfig) ([]*SavedSearch, error) {
+	ss := make([]*SavedSearch, 0, len(cfg.PinnedSearches))
+	for _, id := range cfg.PinnedSearches {
+		show, owner, name, err := SplitSavedSearchID(id)
+		if err != nil {
+			continue
+		}
+		s, err := GetSavedSearch(db, show, owner, name)
+		if err != nil {
+			if errors.As(err, &NotFoundError{}) {
+				continue
+			}
+			return nil, err
+		}
+		if !s.VisibleTo(user) {
+			continue
+		}
+		ss = append(ss, s)
+	}
+	return ss, nil
+}
+
+// SearchSubscription은 사용자가 저장된 검색을 구독한 정보이다.
+// 검색 결과가 LastResult와 달라지면 사용자에게 알림을 보낸다.
+type SearchSubscription struct {
+	User  string `db:"user_id"`
+	Show  string `db:"show"`
+	Owner string `db:"owner"`
+	Name  string `db:"name"`
+	// LastResult는 마지막으로 확인한 검색 결과 유닛들의 아이디이다.
+	LastResult []string  `db:"last_result"`
+	Checked    time.Time `db:"checked"`
+}
+
+var searchSubscriptionDBKey string = strings.Join(dbKeys(&SearchSubscription{}), ", ")
+var searchSubscriptionDBIdx string = strings.Join(dbIdxs(&SearchSubscription{}), ", ")
+var _ []interface{} = dbVals(&SearchSubscription{})
+
+// SubscribeSavedSearch는 사용자가 저장된 검색을 구독하게 한다.
+// 구독할 때의 검색 결과를 저장해 이후 바뀐 부분만 알린다.
+func SubscribeSavedSearch(db *sql.DB, user string, s *SavedSearch) error {
+	if !s.VisibleTo(user) {
+		return Auth("saved search is not shared: %s", s.ID())
+	}
+	us, err := RunSavedSearch(db, s)
+	if err != nil {
+		return err
+	}
+	sub := &SearchSubscription{
+		User:       user,
+		Show:       s.Show,
+		Owner:      s.Owner,
+		Name:       s.Name,
+		LastResult: unitIDs(us),
+		Checked:    time.Now(),
+	}
+	stmts := []dbStatement{
+		dbStmt(fmt.Sprintf("UPSERT INTO search_subscriptions (%s) VALUES (%s)", searchSubscriptionDBKey, searchSubscriptionDBIdx), dbVals(sub)...),
+	}
+	return dbExec(db, stmts)
+}
+
+// UnsubscribeSavedSearch는 사용자의 검색 구독을 취소한다.
+func UnsubscribeSavedSearch(db *sql.DB, user string, s *SavedSearch) error {
+	stmts := []dbStatement{
+		dbStmt("DELETE FROM search_subscriptions WHERE user_id=$1 AND show=$2 AND owner=$3 AND name=$4", user, s.Show, s.Owner, s.Name),
+	}
+	return dbExec(db, stmts)
+}
+
+// UserSearchSubscriptions는 쇼에서 사용자가 구독중인 검색들의 구독 정보를 반환한다.
+func UserSearchSubscriptions(db *sql.DB, show, user string) ([]*SearchSubscription, error) {
+	return searchSubscriptions(db, dbStmt(fmt.Sprintf("SELECT %s FROM search_subscriptions WHERE show=$1 AND user_id=$2", searchSubscriptionDBKey), show, user))
+}
+
+func searchSubscriptions(db *sql.DB, stmt dbStatement) ([]*SearchSubscription, error) {
+	subs := make([]*SearchSubscription, 0)
+	err := dbQuery(db, stmt, func(rows *sql.Rows) error {
+		s := &SearchSubscription{}
+		err := scan(rows, s)
+		if err != nil {
+			return err
+		}
+		subs = append(subs, s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// CheckSearchSubscriptions는 모든 검색 구독의 결과를 다시 검색해
+// 마지막으로 확인한 결과와 달라졌다면 구독자에게 알림을 추가한다.
+// 추가한 알림의 수를 반환한다.
+// 한 구독의 확인에 실패하더라도 다른 구독은 계속 확인하며,
+// 실패한 구독이 있었다면 모든 구독을 확인한 뒤 그 에러들을 모아 반환한다.
+func CheckSearchSubscriptions(db *sql.DB) (int, error) {
+	subs, err := searchSubscriptions(db, dbStmt(fmt.Sprintf("SELECT %s FROM search_subscriptions", searchSubscriptionDBKey)))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	errs := make([]string, 0)
+	for _, sub := range subs {
+		added, err := checkSearchSubscription(db, sub)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s/%s/%s: %v", sub.Show, sub.Owner, sub.Name, err))
+			continue
+		}
+		if added {
+			n++
+		}
+	}
+	if len(errs) != 0 {
+		return n, fmt.Errorf("could not check %d search subscriptions: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return n, nil
+}
+
+// checkSearchSubscription은 구독 하나의 검색 결과를 확인해 기록하고,
+// 결과가 바뀌었다면 구독자에게 알림을 추가한다. 알림을 추가했는지를 반환한다.
+func checkSearchSubscription(db *sql.DB, sub *SearchSubscription) (bool, error) {
+	s, err := GetSavedSearch(db, sub.Show, sub.Owner, sub.Name)
+	if err != nil {
+		return false, err
+	}
+	us, err := RunSavedSearch(db, s)
+	if err != nil {
+		// 검색 문법이 바뀌어 더 이상 해석할 수 없는 검색어는 에러로 보지 않는다.
+		if errors.As(err, &BadRequestError{}) {
+			return false, nil
+		}
+		return false, err
+	}
+	result := unitIDs(us)
+	added, removed := diffStrings(sub.LastResult, result)
+	sub.LastResult = result
+	sub.Checked = time.Now()
+	stmts := []dbStatement{
+		dbStmt(fmt.Sprintf("UPSERT INTO search_subscriptions (%s) VALUES (%s)", searchSubscriptionDBKey, searchSubscriptionDBIdx), dbVals(sub)...),
+	}
+	changed := len(added) != 0 || len(removed) != 0
+	if changed {
+		// 메시지는 UI에서 사용자의 언어로 번역될 수 있도록 형식 문자열과 인자로 나누어 저장한다.
+		msg := "검색 '%s' 결과가 바뀌었습니다. 추가 %s, 제외 %s"
+		args := []string{s.Name, strconv.Itoa(len(added)), strconv.Itoa(len(removed))}
+		if len(added) != 0 {
+			msg += "\n추가: %s"
+			args = append(args, strings.Join(added, ", "))
+		}
+		if len(removed) != 0 {
+			msg += "\n제외: %s"
+			args = append(args, strings.Join(removed, ", "))
+		}
+		stmts = append(stmts, addNotificationStmts(&Notification{
+			User:    sub.User,
+			Message: msg,
+			Args:    args,
+			Link:    savedSearchLink(s),
+		})...)
+	}
+	err = dbExec(db, stmts)
+	if err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// savedSearchLink는 저장된 검색의 결과를 보여주는 유닛 페이지 주소를 반환한다.
+func savedSearchLink(s *SavedSearch) string {
+	q := url.Values{}
+	q.Set("show", s.Show)
+	q.Set("q", s.Query)
+	return "/units?" + q.Encode()
+}
+
+// unitIDs는 유닛들의 아이디를 반환한다.
+func unitIDs(us []*Unit) []string {
+	ids := make([]string, 0, len(us))
+	for _, u := range us {
+		ids = append(ids, u.ID())
+	}
+	return ids
+}
+
+// diffStrings는 old에 없다가 new에 생긴 항목과, old에 있다가 new에서 사라진 항목을 반환한다.
+func diffStrings(old, new []string) ([]string, []string) {
+	inOld := make(map[string]bool)
+	for _, v := range old {
+		inOld[v] = true
+	}
+	inNew := make(map[string]bool)
+	for _, v := range new {
+		inNew[v] = true
+	}
+	added := make([]string, 0)
+	for _, v := range new {
+		if !inOld[v] {
+			added = append(added, v)
+		}
+	}
+	removed := make([]string, 0)
+	for _, v := range old {
+		if !inNew[v] {
+			removed = append(removed, v)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,186 @@
+package roi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSavedSearch(t *testing.T) {
+	db, err := testDB()
+	if err != nil {
+		t.Fatalf("could not connect to database: %v", err)
+	}
+	err = AddSite(db)
+	if err != nil {
+		t.Fatalf("could not add site: %s", err)
+	}
+	defer func() {
+		err := DeleteSite(db)
+		if err != nil {
+			t.Fatalf("could not delete site: %s", err)
+		}
+	}()
+	err = AddShow(db, testShow)
+	if err != nil {
+		t.Fatalf("could not add show: %s", err)
+	}
+	defer func() {
+		err = DeleteShow(db, testShow.ID())
+		if err != nil {
+			t.Fatalf("could not delete show: %s", err)
+		}
+	}()
+	err = AddGroup(db, testGroup)
+	if err != nil {
+		t.Fatalf("could not add group: %s", err)
+	}
+	defer func() {
+		err = DeleteGroup(db, testGroup.Show, testGroup.Group)
+		if err != nil {
+			t.Fatalf("could not delete group: %s", err)
+		}
+	}()
+	// 다른 테스트와 공유하는 유닛이므로 복사해서 쓴다.
+	u := *testUnitA
+	err = AddUnit(db, &u)
+	if err != nil {
+		t.Fatalf("could not add unit: %s", err)
+	}
+	defer func() {
+		err = DeleteUnit(db, u.Show, u.Group, u.Unit)
+		if err != nil {
+			t.Fatalf("could not delete unit: %s", err)
+		}
+	}()
+	for _, id := range []string{"kybin", "kim"} {
+		err = AddUser(db, id, "password")
+		if err != nil {
+			t.Fatalf("could not add user: %s", err)
+		}
+		defer func(id string) {
+			err = DeleteUser(db, id)
+			if err != nil {
+				t.Fatalf("could not delete user: %s", err)
+			}
+		}(id)
+	}
+
+	s := &SavedSearch{
+		Show:  testShow.Show,
+		Owner: "kybin",
+		Name:  "로이",
+		Query: "tag:로이",
+	}
+	err = AddSavedSearch(db, &SavedSearch{Show: testShow.Show, Owner: "kybin", Name: "bad", Query: "foo:bar"})
+	if err == nil {
+		t.Fatalf("want error when saving invalid query, got nil")
+	}
+	err = AddSavedSearch(db, s)
+	if err != nil {
+		t.Fatalf("could not add saved search: %s", err)
+	}
+	ss, err := UserSavedSearches(db, testShow.Show, "kim")
+	if err != nil {
+		t.Fatalf("could not get saved searches: %s", err)
+	}
+	if len(ss) != 0 {
+		t.Fatalf("got %d saved searches of other user before share, want 0", len(ss))
+	}
+	err = PinSavedSearch(db, "kim", s, true)
+	if err == nil {
+		t.Fatalf("want error when pinning not shared search, got nil")
+	}
+	s.Shared = true
+	err = UpdateSavedSearch(db, s)
+	if err != nil {
+		t.Fatalf("could not update saved search: %s", err)
+	}
+	err = PinSavedSearch(db, "kim", s, true)
+	if err != nil {
+		t.Fatalf("could not pin saved search: %s", err)
+	}
+	pinned, err := PinnedSearches(db, "kim")
+	if err != nil {
+		t.Fatalf("could not get pinned searches: %s", err)
+	}
+	if len(pinned) != 1 || pinned[0].ID() != s.ID() {
+		t.Fatalf("got pinned searches %v, want [%v]", pinned, s)
+	}
+
+	err = SubscribeSavedSearch(db, "kim", s)
+	if err != nil {
+		t.Fatalf("could not subscribe saved search: %s", err)
+	}
+	n, err := CheckSearchSubscriptions(db)
+	if err != nil {
+		t.Fatalf("could not check search subscriptions: %s", err)
+	}
+	if n != 0 {
+		t.Fatalf("got %d notifications before change, want 0", n)
+	}
+	u.Tags = []string{"리무브"}
+	err = UpdateUnit(db, &u)
+	if err != nil {
+		t.Fatalf("could not update unit: %s", err)
+	}
+	n, err = CheckSearchSubscriptions(db)
+	if err != nil {
+		t.Fatalf("could not check search subscriptions: %s", err)
+	}
+	if n != 1 {
+		t.Fatalf("got %d notifications after change, want 1", n)
+	}
+	ns, err := UserNotifications(db, "kim")
+	if err != nil {
+		t.Fatalf("could not get notifications: %s", err)
+	}
+	if len(ns) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(ns))
+	}
+	// 메시지는 번역될 수 있도록 인자와 따로 저장된다.
+	if args := ns[0].Args; len(args) != 4 || args[0] != s.Name || args[1] != "0" || args[2] != "1" {
+		t.Fatalf("notification args: got %v", args)
+	}
+	cnt, err := CountUserNotifications(db, "kim")
+	if err != nil {
+		t.Fatalf("could not count notifications: %s", err)
+	}
+	if cnt != len(ns) {
+		t.Fatalf("got notification count %d, want %d", cnt, len(ns))
+	}
+	err = ClearNotifications(db, "kim")
+	if err != nil {
+		t.Fatalf("could not clear notifications: %s", err)
+	}
+
+	err = DeleteSavedSearch(db, s.Show, s.Owner, s.Name)
+	if err != nil {
+		t.Fatalf("could not delete saved search: %s", err)
+	}
+	pinned, err = PinnedSearches(db, "kim")
+	if err != nil {
+		t.Fatalf("could not get pinned searches: %s", err)
+	}
+	if len(pinned) != 0 {
+		t.Fatalf("got pinned searches %v after delete, want none", pinned)
+	}
+}
+
+func TestDiffStrings(t *testing.T) {
+	added, removed := diffStrings([]string{"a", "b", "c"}, []string{"b", "c", "d", "e"})
+	if !reflect.DeepEqual(added, []string{"d", "e"}) {
+		t.Fatalf("got added %v, want [d e]", added)
+	}
+	if !reflect.DeepEqual(removed, []string{"a"}) {
+		t.Fatalf("got removed %v, want [a]", removed)
+	}
+}
+
+func TestSavedSearchLink(t *testing.T) {
+	s := &SavedSearch{Show: "test", Owner: "admin", Name: "hero", Query: "tag:hero & status:in-progress #1+2"}
+	got := savedSearchLink(s)
+	want := "/units?q=tag%3Ahero+%26+status%3Ain-progress+%231%2B2&show=test"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
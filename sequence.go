@@ -0,0 +1,393 @@
+package roi
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sequence는 하나의 파일, 또는 프레임 번호로만 구분되는 파일들의 묶음인 이미지 시퀀스이다.
+//
+// 시퀀스는 db에 "패턴 프레임범위" 형식의 문자열로 저장된다.
+// 예) /show/render/comp.####.exr 1001-1004,1006-1240
+type Sequence struct {
+	// Pattern은 프레임 번호 자리를 그 자릿수만큼의 #으로 나타낸 경로이다.
+	// 시퀀스가 아닌 하나의 파일이라면 그 파일 경로이다.
+	Pattern string
+
+	// Padding은 프레임 번호의 자릿수이다. 하나의 파일이라면 0이다.
+	Padding int
+
+	// First와 Last는 시퀀스의 첫 프레임과 마지막 프레임이다.
+	// 패턴만 알고 프레임 범위를 모를때는 둘 다 0이다.
+	First int
+	Last  int
+
+	// Missing은 First와 Last 사이의 프레임 중 존재하지 않는 프레임이다.
+	Missing []int
+}
+
+var (
+	// reSeqHash는 ####, @@@@ 형식의 프레임 자리를 나타낸다.
+	reSeqHash = regexp.MustCompile(`#+|@+`)
+	// reSeqPrintf는 %04d 형식의 프레임 자리를 나타낸다.
+	reSeqPrintf = regexp.MustCompile(`%(0?\d+)?d`)
+	// reSeqHoudini는 $F4 형식의 프레임 자리를 나타낸다.
+	reSeqHoudini = regexp.MustCompile(`\$F(\d*)`)
+	// reSeqFrame은 파일 이름 중 프레임 번호를 나타낸다.
+	// 프레임 번호는 점 또는 언더바와 확장자 사이에 위치해야 한다.
+	// 예) comp.1001.exr, comp_1001.exr
+	reSeqFrame = regexp.MustCompile(`^(.*[._])(\d+)(\.[a-zA-Z0-9]+)$`)
+)
+
+// ParseFramePattern은 경로에 ####, %04d, $F4 같은 프레임 자리가 있다면
+// 이를 #으로 통일한 패턴과 그 자릿수를 반환한다.
+// 프레임 자리가 없다면 ok로 false를 반환한다.
+func ParseFramePattern(path string) (pattern string, padding int, ok bool) {
+	dir, base := filepath.Split(path)
+	if loc := reSeqHash.FindStringIndex(base); loc != nil {
+		padding = loc[1] - loc[0]
+		if base[loc[0]] == '@' {
+			// @는 자릿수가 없는 프레임 번호를 뜻한다.
+			padding = 1
+		}
+		return dir + base[:loc[0]] + strings.Repeat("#", padding) + base[loc[1]:], padding, true
+	}
+	if m := reSeqPrintf.FindStringSubmatchIndex(base); m != nil {
+		padding = 1
+		if m[2] != -1 {
+			padding, _ = strconv.Atoi(base[m[2]:m[3]])
+			if padding == 0 {
+				padding = 1
+			}
+		}
+		return dir + base[:m[0]] + strings.Repeat("#", padding) + base[m[1]:], padding, true
+	}
+	if m := reSeqHoudini.FindStringSubmatchIndex(base); m != nil {
+		padding = 1
+		if m[3] > m[2] {
+			padding, _ = strconv.Atoi(base[m[2]:m[3]])
+			if padding == 0 {
+				padding = 1
+			}
+		}
+		return dir + base[:m[0]] + strings.Repeat("#", padding) + base[m[1]:], padding, true
+	}
+	return "", 0, false
+}
+
+// ParseSequence는 db에 저장된 시퀀스 문자열, 또는 패턴을 포함한 경로를 시퀀스로 변환한다.
+// 프레임 자리를 찾을 수 없다면 하나의 파일로 취급한다.
+func ParseSequence(s string) *Sequence {
+	s = strings.TrimSpace(s)
+	path := s
+	var frames []int
+	if i := strings.LastIndex(s, " "); i != -1 {
+		fs, err := ParseFrameRanges(s[i+1:])
+		if err == nil {
+			path = strings.TrimSpace(s[:i])
+			frames = fs
+		}
+	}
+	pattern, padding, ok := ParseFramePattern(path)
+	if !ok {
+		return &Sequence{Pattern: s}
+	}
+	seq := &Sequence{Pattern: pattern, Padding: padding}
+	// 범위가 지나치게 넓다면 프레임 범위를 모르는 패턴으로 취급한다.
+	seq.setFrames(frames)
+	return seq
+}
+
+// setFrames는 받아들인 프레임들로 시퀀스의 프레임 범위와 빠진 프레임을 설정한다.
+// 첫 프레임과 마지막 프레임의 차이가 maxFrameRangeFrames 이상이면
+// 빠진 프레임이 메모리를 고갈시킬 수 있으므로 시퀀스를 바꾸지 않고 false를 반환한다.
+func (s *Sequence) setFrames(frames []int) bool {
+	fs := make([]int, len(frames))
+	copy(fs, frames)
+	sort.Ints(fs)
+	if len(fs) != 0 && fs[len(fs)-1]-fs[0] >= maxFrameRangeFrames {
+		return false
+	}
+	s.First = 0
+	s.Last = 0
+	s.Missing = nil
+	if len(fs) == 0 {
+		return true
+	}
+	s.First = fs[0]
+	s.Last = fs[len(fs)-1]
+	has := make(map[int]bool, len(fs))
+	for _, f := range fs {
+		has[f] = true
+	}
+	for f := s.First; f <= s.Last; f++ {
+		if !has[f] {
+			s.Missing = append(s.Missing, f)
+		}
+	}
+	return true
+}
+
+// IsSequence는 이 항목이 하나의 파일이 아닌 시퀀스인지를 반환한다.
+func (s *Sequence) IsSequence() bool {
+	return s.Padding != 0
+}
+
+// HasRange는 시퀀스의 프레임 범위를 알고 있는지를 반환한다.
+func (s *Sequence) HasRange() bool {
+	return s.IsSequence() && !(s.First == 0 && s.Last == 0)
+}
+
+// Frames는 시퀀스에 존재하는 프레임들을 반환한다.
+// 프레임 범위가 maxFrameRangeFrames 이상이면 nil을 반환한다.
+func (s *Sequence) Frames() []int {
+	if !s.HasRange() || s.Last-s.First >= maxFrameRangeFrames {
+		return nil
+	}
+	missing := make(map[int]bool, len(s.Missing))
+	for _, f := range s.Missing {
+		missing[f] = true
+	}
+	frames := make([]int, 0, s.Last-s.First+1-len(s.Missing))
+	for f := s.First; f <= s.Last; f++ {
+		if !missing[f] {
+			frames = append(frames, f)
+		}
+	}
+	return frames
+}
+
+// Len은 시퀀스에 존재하는 프레임의 수를 반환한다. 하나의 파일이라면 1이다.
+func (s *Sequence) Len() int {
+	if !s.IsSequence() {
+		return 1
+	}
+	if !s.HasRange() {
+		return 0
+	}
+	return s.Last - s.First + 1 - len(s.Missing)
+}
+
+// Path는 시퀀스 중 해당 프레임의 파일 경로를 반환한다.
+// 하나의 파일이라면 그 경로를 반환한다.
+func (s *Sequence) Path(frame int) string {
+	if !s.IsSequence() {
+		return s.Pattern
+	}
+	loc := reSeqHash.FindStringIndex(filepath.Base(s.Pattern))
+	if loc == nil {
+		return s.Pattern
+	}
+	off := len(s.Pattern) - len(filepath.Base(s.Pattern))
+	return s.Pattern[:off+loc[0]] + fmt.Sprintf("%0*d", s.Padding, frame) + s.Pattern[off+loc[1]:]
+}
+
+// MissingRanges는 빠진 프레임을 범위 문자열로 반환한다.
+func (s *Sequence) MissingRanges() string {
+	return FrameRanges(s.Missing)
+}
+
+// String은 db에 저장되는 시퀀스 문자열이다.
+func (s *Sequence) String() string {
+	frames := s.Frames()
+	if len(frames) == 0 {
+		return s.Pattern
+	}
+	return s.Pattern + " " + FrameRanges(frames)
+}
+
+// FrameRanges는 프레임들을 1001-1004,1006 형식의 범위 문자열로 반환한다.
+func FrameRanges(frames []int) string {
+	if len(frames) == 0 {
+		return ""
+	}
+	fs := make([]int, len(frames))
+	copy(fs, frames)
+	sort.Ints(fs)
+	rngs := make([]string, 0)
+	start := fs[0]
+	end := fs[0]
+	flush := func() {
+		if start == end {
+			rngs = append(rngs, strconv.Itoa(start))
+		} else {
+			rngs = append(rngs, strconv.Itoa(start)+"-"+strconv.Itoa(end))
+		}
+	}
+	for _, f := range fs[1:] {
+		if f == end {
+			continue
+		}
+		if f == end+1 {
+			end = f
+			continue
+		}
+		flush()
+		start = f
+		end = f
+	}
+	flush()
+	return strings.Join(rngs, ",")
+}
+
+// maxFrameRangeFrames는 ParseFrameRanges가 받아들이는 최대 프레임 수이자
+// 시퀀스의 첫 프레임과 마지막 프레임 사이의 최대 차이이다.
+// 폼이나 API로 들어온 지나치게 큰 범위가 메모리를 고갈시키지 않도록 한다.
+const maxFrameRangeFrames = 100000
+
+// ParseFrameRanges는 1001-1004,1006 형식의 범위 문자열을 프레임들로 변환한다.
+// 전체 프레임 수가 maxFrameRangeFrames보다 많으면 에러를 반환한다.
+func ParseFrameRanges(s string) ([]int, error) {
+	if s == "" {
+		return nil, BadRequest("empty frame range")
+	}
+	frames := make([]int, 0)
+	for _, rng := range strings.Split(s, ",") {
+		se := strings.SplitN(rng, "-", 2)
+		start, err := strconv.Atoi(se[0])
+		if err != nil {
+			return nil, BadRequest("invalid frame range: %s", s)
+		}
+		end := start
+		if len(se) == 2 {
+			end, err = strconv.Atoi(se[1])
+			if err != nil {
+				return nil, BadRequest("invalid frame range: %s", s)
+			}
+		}
+		if end < start {
+			return nil, BadRequest("invalid frame range: %s", s)
+		}
+		if end-start >= maxFrameRangeFrames-len(frames) {
+			return nil, BadRequest("too many frames in range: %s", s)
+		}
+		for f := start; f <= end; f++ {
+			frames = append(frames, f)
+		}
+	}
+	return frames, nil
+}
+
+// CollapseSequences는 파일 리스트에서 프레임 번호만 다른 파일들을 묶어 시퀀스로 만든다.
+// 이미 시퀀스 문자열이나 패턴으로 된 항목은 그대로 시퀀스로 변환한다.
+// 같은 패턴의 항목이 여럿이면 그 프레임들을 합친다.
+// 반환되는 시퀀스는 받아들인 파일이 처음 나타난 순서를 따른다.
+func CollapseSequences(files []string) []*Sequence {
+	type group struct {
+		prefix string
+		suffix string
+		digits []string
+		files  []string
+	}
+	seqs := make([]*Sequence, 0)
+	patternSeq := make(map[string]*Sequence)
+	groups := make(map[string]*group)
+	// order는 결과를 받아들인 순서대로 정렬하기 위해 사용한다.
+	// 각 항목은 patternSeq 또는 groups의 키이다.
+	order := make([]string, 0)
+	for _, f := range files {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		seq := ParseSequence(f)
+		if seq.IsSequence() {
+			key := "p:" + seq.Pattern
+			old, ok := patternSeq[key]
+			if !ok {
+				patternSeq[key] = seq
+				order = append(order, key)
+				continue
+			}
+			if !old.setFrames(append(old.Frames(), seq.Frames()...)) {
+				// 합친 범위가 너무 넓으면 합치지 않고 따로 둔다.
+				key = "f:" + f
+				if _, ok := patternSeq[key]; !ok {
+					patternSeq[key] = seq
+					order = append(order, key)
+				}
+			}
+			continue
+		}
+		dir, base := filepath.Split(f)
+		m := reSeqFrame.FindStringSubmatch(base)
+		if m == nil {
+			key := "f:" + f
+			if _, ok := patternSeq[key]; !ok {
+				patternSeq[key] = seq
+				order = append(order, key)
+			}
+			continue
+		}
+		key := "g:" + dir + m[1] + "\x00" + m[3]
+		g, ok := groups[key]
+		if !ok {
+			g = &group{prefix: dir + m[1], suffix: m[3]}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.digits = append(g.digits, m[2])
+		g.files = append(g.files, f)
+	}
+	for _, key := range order {
+		if seq, ok := patternSeq[key]; ok {
+			seqs = append(seqs, seq)
+			continue
+		}
+		g := groups[key]
+		if len(g.files) == 1 {
+			// 프레임 번호처럼 보이는 숫자가 있더라도 파일이 하나라면
+			// 시퀀스로 보지 않는다.
+			seqs = append(seqs, &Sequence{Pattern: g.files[0]})
+			continue
+		}
+		padding := len(g.digits[0])
+		frames := make([]int, 0, len(g.digits))
+		for _, d := range g.digits {
+			if len(d) < padding {
+				padding = len(d)
+			}
+			f, _ := strconv.Atoi(d)
+			frames = append(frames, f)
+		}
+		seq := &Sequence{
+			Pattern: g.prefix + strings.Repeat("#", padding) + g.suffix,
+			Padding: padding,
+		}
+		if !seq.setFrames(frames) {
+			// 프레임 범위가 너무 넓으면 시퀀스로 묶지 않고 각 파일을 그대로 둔다.
+			for _, f := range g.files {
+				seqs = append(seqs, &Sequence{Pattern: f})
+			}
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	return seqs
+}
+
+// collapseSequenceStrings는 파일 리스트를 시퀀스로 묶은 후 db에 저장될 문자열로 반환한다.
+func collapseSequenceStrings(files []string) []string {
+	seqs := CollapseSequences(files)
+	ss := make([]string, len(seqs))
+	for i, seq := range seqs {
+		ss[i] = seq.String()
+	}
+	return ss
+}
+
+// parseSequences는 db에 저장된 시퀀스 문자열들을 시퀀스로 변환한다.
+func parseSequences(ss []string) []*Sequence {
+	seqs := make([]*Sequence, 0, len(ss))
+	for _, s := range ss {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		seqs = append(seqs, ParseSequence(s))
+	}
+	return seqs
+}
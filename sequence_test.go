@@ -0,0 +1,138 @@
+package roi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFramePattern(t *testing.T) {
+	cases := []struct {
+		path    string
+		pattern string
+		padding int
+		ok      bool
+	}{
+		{path: "/show/comp.####.exr", pattern: "/show/comp.####.exr", padding: 4, ok: true},
+		{path: "/show/comp.%04d.exr", pattern: "/show/comp.####.exr", padding: 4, ok: true},
+		{path: "/show/comp.%d.exr", pattern: "/show/comp.#.exr", padding: 1, ok: true},
+		{path: "/show/comp.$F4.exr", pattern: "/show/comp.####.exr", padding: 4, ok: true},
+		{path: "/show/comp.$F.exr", pattern: "/show/comp.#.exr", padding: 1, ok: true},
+		{path: "/show/comp.@.exr", pattern: "/show/comp.#.exr", padding: 1, ok: true},
+		{path: "/show/#dir/comp.1001.exr", ok: false},
+		{path: "/show/comp.v001.hip", ok: false},
+	}
+	for _, c := range cases {
+		pattern, padding, ok := ParseFramePattern(c.path)
+		if ok != c.ok || pattern != c.pattern || padding != c.padding {
+			t.Fatalf("%s: got (%q, %d, %v), want (%q, %d, %v)", c.path, pattern, padding, ok, c.pattern, c.padding, c.ok)
+		}
+	}
+}
+
+func TestFrameRanges(t *testing.T) {
+	cases := []struct {
+		frames []int
+		want   string
+	}{
+		{frames: []int{}, want: ""},
+		{frames: []int{1}, want: "1"},
+		{frames: []int{3, 1, 2}, want: "1-3"},
+		{frames: []int{1001, 1002, 1004, 1006, 1007}, want: "1001-1002,1004,1006-1007"},
+	}
+	for _, c := range cases {
+		got := FrameRanges(c.frames)
+		if got != c.want {
+			t.Fatalf("%v: got %q, want %q", c.frames, got, c.want)
+		}
+		if got == "" {
+			continue
+		}
+		frames, err := ParseFrameRanges(got)
+		if err != nil {
+			t.Fatalf("%q: %v", got, err)
+		}
+		if len(frames) != len(c.frames) {
+			t.Fatalf("%q: got %v, want %v", got, frames, c.frames)
+		}
+	}
+	for _, s := range []string{"", "a", "3-1", "1-", "1-2000000000", "1-60000,100001-160000"} {
+		_, err := ParseFrameRanges(s)
+		if err == nil {
+			t.Fatalf("%q: want error, got nil", s)
+		}
+	}
+}
+
+func TestCollapseSequences(t *testing.T) {
+	files := []string{
+		"/show/render/comp.1001.exr",
+		"/show/scene/comp.v001.hip",
+		"/show/render/comp.1002.exr",
+		"/show/render/comp.1004.exr",
+		"/show/render/single.0001.jpg",
+		"/show/render/fx.%04d.exr 1-10",
+		"/show/render/fx.$F4.exr 11-12",
+	}
+	want := []*Sequence{
+		{Pattern: "/show/render/comp.####.exr", Padding: 4, First: 1001, Last: 1004, Missing: []int{1003}},
+		{Pattern: "/show/scene/comp.v001.hip"},
+		{Pattern: "/show/render/single.0001.jpg"},
+		{Pattern: "/show/render/fx.####.exr", Padding: 4, First: 1, Last: 12},
+	}
+	got := CollapseSequences(files)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	wantStrs := []string{
+		"/show/render/comp.####.exr 1001-1002,1004",
+		"/show/scene/comp.v001.hip",
+		"/show/render/single.0001.jpg",
+		"/show/render/fx.####.exr 1-12",
+	}
+	gotStrs := collapseSequenceStrings(files)
+	if !reflect.DeepEqual(gotStrs, wantStrs) {
+		t.Fatalf("got %v, want %v", gotStrs, wantStrs)
+	}
+	// 저장된 문자열을 다시 읽어도 같은 시퀀스여야 한다.
+	if reparsed := parseSequences(gotStrs); !reflect.DeepEqual(reparsed, want) {
+		t.Fatalf("reparsed: got %v, want %v", reparsed, want)
+	}
+	if p := want[0].Path(1003); p != "/show/render/comp.1003.exr" {
+		t.Fatalf("path: got %q", p)
+	}
+	if n := want[0].Len(); n != 3 {
+		t.Fatalf("len: got %d, want 3", n)
+	}
+}
+
+func TestCollapseSequencesWideRange(t *testing.T) {
+	// 첫 프레임과 마지막 프레임의 차이가 너무 크면 빠진 프레임으로 메모리를 고갈시키지 않도록
+	// 시퀀스로 묶지 않아야 한다.
+	files := []string{
+		"/show/render/comp.1.exr",
+		"/show/render/comp.999999999.exr",
+		"/show/render/fx.####.exr 1-2",
+		"/show/render/fx.####.exr 999999999",
+	}
+	want := []*Sequence{
+		{Pattern: "/show/render/comp.1.exr"},
+		{Pattern: "/show/render/comp.999999999.exr"},
+		{Pattern: "/show/render/fx.####.exr", Padding: 4, First: 1, Last: 2},
+		{Pattern: "/show/render/fx.####.exr", Padding: 4, First: 999999999, Last: 999999999},
+	}
+	got := CollapseSequences(files)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	seq := ParseSequence("/show/render/comp.####.exr 1,999999999")
+	if seq.HasRange() || seq.Missing != nil {
+		t.Fatalf("wide range should not be set: %v", seq)
+	}
+	if s := seq.String(); s != "/show/render/comp.####.exr" {
+		t.Fatalf("string: got %q", s)
+	}
+	seq = &Sequence{Pattern: "/show/render/comp.####.exr", Padding: 4, First: 1, Last: 1 << 40}
+	if fs := seq.Frames(); fs != nil {
+		t.Fatalf("frames of wide range: got %d frames, want nil", len(fs))
+	}
+}
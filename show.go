@@ -23,9 +23,20 @@ var CreateTableIfNotExistsShowsStmt = `CREATE TABLE IF NOT EXISTS shows (
 	tags STRING[] NOT NULL,
 	notes STRING NOT NULL,
 	attrs STRING NOT NULL,
+	typed_attrs JSONB NOT NULL DEFAULT '{}',
+	group_attr_defs STRING[] NOT NULL DEFAULT ARRAY[],
+	unit_attr_defs STRING[] NOT NULL DEFAULT ARRAY[],
 	CONSTRAINT shows_pk PRIMARY KEY (show)
 )`
 
+// AlterTableShowsStmts는 이전 버전에서 만들어진 shows 테이블에
+// 새로 추가된 열을 더하는 sql 구문들이다. 여러번 실행해도 안전하다.
+var AlterTableShowsStmts = []string{
+	"ALTER TABLE shows ADD COLUMN IF NOT EXISTS typed_attrs JSONB NOT NULL DEFAULT '{}'",
+	"ALTER TABLE shows ADD COLUMN IF NOT EXISTS group_attr_defs STRING[] NOT NULL DEFAULT ARRAY[]",
+	"ALTER TABLE shows ADD COLUMN IF NOT EXISTS unit_attr_defs STRING[] NOT NULL DEFAULT ARRAY[]",
+}
+
 type Show struct {
 	// 쇼 아이디. 로이 내에서 고유해야 한다.
 	Show string `db:"show"`
@@ -43,6 +54,14 @@ type Show struct {
 
 	// Attrs는 커스텀 속성으로 db에는 여러줄의 문자열로 저장된다. 각 줄은 키: 값의 쌍이다.
 	Attrs DBStringMap `db:"attrs"`
+	// TypedAttrs는 커스텀 속성을 정의된 타입의 값으로 저장한 것으로, db에서 비교할 때 쓴다.
+	// 쇼를 저장할 때 Attrs로부터 다시 만들어진다.
+	TypedAttrs DBJSONMap `db:"typed_attrs"`
+
+	// GroupAttrDefs와 UnitAttrDefs는 이 쇼에서만 쓰는 그룹과 유닛의 커스텀 속성 정의이다.
+	// 사이트에 같은 이름의 정의가 있다면 이 정의를 따른다.
+	GroupAttrDefs []string `db:"group_attr_defs"`
+	UnitAttrDefs  []string `db:"unit_attr_defs"`
 }
 
 var showDBKey string = strings.Join(dbKeys(&Show{}), ", ")
@@ -82,6 +101,36 @@ func verifyShow(db *sql.DB, s *Show) error {
 	sort.Slice(s.Tags, func(i, j int) bool {
 		return strings.Compare(s.Tags[i], s.Tags[j]) <= 0
 	})
+	for _, defs := range [][]string{s.GroupAttrDefs, s.UnitAttrDefs} {
+		err = verifyAttrDefs(defs)
+		if err != nil {
+			return err
+		}
+	}
+	si, err := GetSite(db)
+	if err != nil {
+		return err
+	}
+	// 사이트의 상태 정의가 바뀌기 전에 저장된 쇼는 더 이상 정의되지 않은
+	// 상태를 가질 수 있다. 상태를 바꾸지 않는다면 다른 수정을 막지 않는다.
+	oldStatus, stored, err := storedStatus(db, "shows", "show=$1", s.Show)
+	if err != nil {
+		return err
+	}
+	if !stored || s.Status != oldStatus {
+		err = verifyShowStatus(si, ShowStatus(s.Status))
+		if err != nil {
+			return err
+		}
+	}
+	old, err := storedAttrs(db, "shows", "show=$1", s.Show)
+	if err != nil {
+		return err
+	}
+	s.TypedAttrs, err = verifyAttrs(db, attrDefs(si, nil, AttrKindShow), &s.Attrs, old)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -94,6 +143,7 @@ func AddShow(db *sql.DB, s *Show) error {
 	stmts := []dbStatement{
 		dbStmt(fmt.Sprintf("INSERT INTO shows (%s) VALUES (%s)", showDBKey, showDBIdx), dbVals(s)...),
 	}
+	stmts = append(stmts, showIndexStmts(s)...)
 	return dbExec(db, stmts)
 }
 
@@ -107,11 +157,12 @@ func UpdateShow(db *sql.DB, s *Show) error {
 	if err != nil {
 		return err
 	}
-	stmt := fmt.Sprintf("UPDATE shows SET (%s) = (%s) WHERE show='%s'", showDBKey, showDBIdx, s.Show)
-	if _, err := db.Exec(stmt, dbVals(s)...); err != nil {
-		return err
+	stmts := []dbStatement{
+		dbStmt(fmt.Sprintf("UPDATE shows SET (%s) = (%s) WHERE show='%s'", showDBKey, showDBIdx, s.Show), dbVals(s)...),
 	}
-	return nil
+	stmts = append(stmts, showIndexStmts(s)...)
+	defer invalidateShowCache(s.Show)
+	return dbExec(db, stmts)
 }
 
 // GetShow는 db에서 하나의 쇼를 부른다.
@@ -120,6 +171,10 @@ func GetShow(db *sql.DB, show string) (*Show, error) {
 	if show == "" {
 		return nil, BadRequest("show not specified")
 	}
+	v, gen, ok := cache.get(showCacheKey(show))
+	if ok {
+		return v.(*Show), nil
+	}
 	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM shows WHERE show=$1", showDBKey), show)
 	s := &Show{}
 	err := dbQueryRow(db, stmt, func(row *sql.Row) error {
@@ -131,6 +186,7 @@ func GetShow(db *sql.DB, show string) (*Show, error) {
 		}
 		return nil, err
 	}
+	cache.put(showCacheKey(show), s, gen)
 	return s, nil
 }
 
@@ -155,18 +211,33 @@ func AllShows(db *sql.DB) ([]*Show, error) {
 }
 
 // DeleteShow는 해당 쇼와 그 하위의 모든 데이터를 db에서 지운다.
+// 사이트의 라이브러리 쇼는 지울 수 없다.
 // 만일 처리 중간에 에러가 나면 아무 데이터도 지우지 않고 에러를 반환한다.
 func DeleteShow(db *sql.DB, show string) error {
 	_, err := GetShow(db, show)
 	if err != nil {
 		return err
 	}
+	si, err := GetSite(db)
+	if err != nil {
+		return err
+	}
+	if si.LibraryShow == show {
+		return BadRequest("could not delete the library show: %s", show)
+	}
 	stmts := []dbStatement{
 		dbStmt("DELETE FROM shows WHERE show=$1", show),
 		dbStmt("DELETE FROM groups WHERE show=$1", show),
 		dbStmt("DELETE FROM units WHERE show=$1", show),
 		dbStmt("DELETE FROM tasks WHERE show=$1", show),
 		dbStmt("DELETE FROM versions WHERE show=$1", show),
+		dbStmt("DELETE FROM cut_revisions WHERE show=$1", show),
+		dbStmt("DELETE FROM saved_searches WHERE show=$1", show),
+		dbStmt("DELETE FROM search_subscriptions WHERE show=$1", show),
+		dbStmt("DELETE FROM search_docs WHERE show=$1", show),
+		dbStmt("DELETE FROM search_index WHERE show=$1", show),
+		dbStmt("DELETE FROM asset_refs WHERE show=$1", show),
 	}
+	defer invalidateShowCache(show)
 	return dbExec(db, stmts)
 }
@@ -11,6 +11,8 @@ const (
 	ShowHold           = ShowStatus("hold")
 )
 
+// AllShowStatus는 사이트에서 따로 정의하지 않아도 쇼가 가질 수 있는 기본 상태이다.
+// 사이트에 새로 정의된 상태도 쇼의 상태로 쓸 수 있다. Site.AvailableShowStatuses를 참고한다.
 var AllShowStatus = []ShowStatus{
 	ShowWaiting,
 	ShowPreProduction,
@@ -20,51 +22,22 @@ var AllShowStatus = []ShowStatus{
 	ShowHold,
 }
 
-// verifyShowStatus는 해당 태스크 상태가 유효하지 않다면 에러를 반환한다.
-func verifyShowStatus(ss ShowStatus) error {
-	for _, s := range AllShowStatus {
+// legacyShowStatuses는 쇼 상태가 검사되지 않던 때에 쓰이던 상태와 그에 해당하는 현재 상태이다.
+// db에 남아있거나 외부 도구에서 여전히 쓰일 수 있으므로 받아들인다.
+var legacyShowStatuses = map[ShowStatus]ShowStatus{
+	"waiting": ShowWaiting,
+}
+
+// verifyShowStatus는 해당 쇼 상태가 사이트에서 쓸 수 없는 상태라면 에러를 반환한다.
+// 예전에 쓰이던 상태(legacyShowStatuses)는 받아들인다.
+func verifyShowStatus(site *Site, ss ShowStatus) error {
+	if _, ok := legacyShowStatuses[ss]; ok {
+		return nil
+	}
+	for _, s := range site.AvailableShowStatuses() {
 		if ss == s {
 			return nil
 		}
 	}
 	return BadRequest("invalid show status: '%s'", ss)
 }
-
-// UIString은 UI안에서 사용하는 현지화된 문자열이다.
-// 할일: 한국어 외의 문자열 지원
-func (s ShowStatus) UIString() string {
-	switch s {
-	case ShowWaiting:
-		return "대기"
-	case ShowPreProduction:
-		return "프리 프로덕션"
-	case ShowProduction:
-		return "프로덕션"
-	case ShowPostProduction:
-		return "포스트 프로덕션"
-	case ShowDone:
-		return "완료"
-	case ShowHold:
-		return "홀드"
-	}
-	return ""
-}
-
-// UIColor는 UI안에서 사용하는 색상이다.
-func (s ShowStatus) UIColor() string {
-	switch s {
-	case ShowWaiting:
-		return ""
-	case ShowPreProduction:
-		return "yellow"
-	case ShowProduction:
-		return "yellow"
-	case ShowPostProduction:
-		return "green"
-	case ShowDone:
-		return "blue"
-	case ShowHold:
-		return "gray"
-	}
-	return ""
-}
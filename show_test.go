@@ -1,6 +1,7 @@
 package roi
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -70,4 +71,21 @@ func TestShow(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not update project: %s", err)
 	}
+	// 정의되지 않은 상태로 저장된 쇼도 상태를 바꾸지 않는다면 수정할 수 있어야 한다.
+	_, err = db.Exec("UPDATE shows SET status=$1 WHERE show=$2", "bidding", testShow.Show)
+	if err != nil {
+		t.Fatalf("could not set legacy status: %s", err)
+	}
+	legacy := *testShow
+	legacy.Status = "bidding"
+	legacy.Supervisor = "김성환2"
+	err = UpdateShow(db, &legacy)
+	if err != nil {
+		t.Fatalf("could not update project with unchanged legacy status: %s", err)
+	}
+	legacy.Status = "unknown"
+	err = UpdateShow(db, &legacy)
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error for undefined show status, got %v", err)
+	}
 }
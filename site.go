@@ -18,9 +18,26 @@ var CreateTableIfNotExistsSitesStmt = `CREATE TABLE IF NOT EXISTS sites (
 	default_asset_tasks STRING[] NOT NULL,
 	leads STRING[] NOT NULL,
 	notes STRING NOT NULL,
-	attrs STRING NOT NULL
+	attrs STRING NOT NULL,
+	workflow STRING NOT NULL DEFAULT '',
+	statuses STRING[] NOT NULL DEFAULT ARRAY[],
+	show_statuses STRING[] NOT NULL DEFAULT ARRAY[],
+	show_attr_defs STRING[] NOT NULL DEFAULT ARRAY[],
+	group_attr_defs STRING[] NOT NULL DEFAULT ARRAY[],
+	unit_attr_defs STRING[] NOT NULL DEFAULT ARRAY[],
+	library_show STRING NOT NULL DEFAULT ''
 )`
 
+var AlterTableSitesStmts = []string{
+	"ALTER TABLE sites ADD COLUMN IF NOT EXISTS workflow STRING NOT NULL DEFAULT ''",
+	"ALTER TABLE sites ADD COLUMN IF NOT EXISTS statuses STRING[] NOT NULL DEFAULT ARRAY[]",
+	"ALTER TABLE sites ADD COLUMN IF NOT EXISTS show_statuses STRING[] NOT NULL DEFAULT ARRAY[]",
+	"ALTER TABLE sites ADD COLUMN IF NOT EXISTS show_attr_defs STRING[] NOT NULL DEFAULT ARRAY[]",
+	"ALTER TABLE sites ADD COLUMN IF NOT EXISTS group_attr_defs STRING[] NOT NULL DEFAULT ARRAY[]",
+	"ALTER TABLE sites ADD COLUMN IF NOT EXISTS unit_attr_defs STRING[] NOT NULL DEFAULT ARRAY[]",
+	"ALTER TABLE sites ADD COLUMN IF NOT EXISTS library_show STRING NOT NULL DEFAULT ''",
+}
+
 // Site는 현재 스튜디오를 뜻한다.
 type Site struct {
 	// 현재로서는 빈 이름의 하나의 사이트만 존재한다.
@@ -43,6 +60,28 @@ type Site struct {
 
 	// Attrs는 커스텀 속성으로 db에는 여러줄의 문자열로 저장된다. 각 줄은 키: 값의 쌍이다.
 	Attrs DBStringMap `db:"attrs"`
+
+	// Workflow는 태스크 상태와 그 전환 규칙을 정의하는 문자열이다. Workflow 타입을 참고한다.
+	// 비어 있으면 DefaultWorkflow를 사용한다.
+	Workflow string `db:"workflow"`
+
+	// Statuses와 ShowStatuses는 "상태: 레이블, 색상, 분류" 형식의 상태 정의이다.
+	// 유닛 및 태스크 상태, 쇼 상태의 UI 표시는 여기서 정의된다. StatusDef를 참고한다.
+	// 정의되지 않은 기본 상태는 DefaultStatusDefs, DefaultShowStatusDefs를 따른다.
+	Statuses     []string `db:"statuses"`
+	ShowStatuses []string `db:"show_statuses"`
+
+	// ShowAttrDefs, GroupAttrDefs, UnitAttrDefs는 쇼, 그룹, 유닛의 커스텀 속성 정의이다.
+	// 각 정의는 "이름: 타입[, required][, default=기본값]" 형식이다. AttrDef를 참고한다.
+	// 그룹과 유닛의 속성은 쇼에서 더 정의할 수 있다.
+	ShowAttrDefs  []string `db:"show_attr_defs"`
+	GroupAttrDefs []string `db:"group_attr_defs"`
+	UnitAttrDefs  []string `db:"unit_attr_defs"`
+
+	// LibraryShow는 스튜디오 전체에서 공유하는 애셋을 담는 라이브러리 쇼이다.
+	// 다른 쇼의 유닛은 이 쇼의 애셋을 쇼/그룹/유닛 형식의 아이디로 참조할 수 있다.
+	// 비어 있으면 라이브러리를 사용하지 않는다.
+	LibraryShow string `db:"library_show"`
 }
 
 var siteDBKey string = strings.Join(dbKeys(&Site{}), ", ")
@@ -97,6 +136,32 @@ func verifySite(db *sql.DB, s *Site) error {
 			return fmt.Errorf("invalid site: task %q not specified but used as default asset task", task)
 		}
 	}
+	s.Workflow = strings.TrimSpace(s.Workflow)
+	_, err := ParseWorkflow(s.Workflow)
+	if err != nil {
+		return err
+	}
+	err = verifyStatusDefs(s.Statuses, false)
+	if err != nil {
+		return fmt.Errorf("invalid site: statuses: %w", err)
+	}
+	err = verifyStatusDefs(s.ShowStatuses, true)
+	if err != nil {
+		return fmt.Errorf("invalid site: show statuses: %w", err)
+	}
+	for _, defs := range [][]string{s.ShowAttrDefs, s.GroupAttrDefs, s.UnitAttrDefs} {
+		err = verifyAttrDefs(defs)
+		if err != nil {
+			return fmt.Errorf("invalid site: %w", err)
+		}
+	}
+	s.LibraryShow = strings.TrimSpace(s.LibraryShow)
+	if s.LibraryShow != "" {
+		_, err = GetShow(db, s.LibraryShow)
+		if err != nil {
+			return fmt.Errorf("invalid site: library show: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -110,6 +175,7 @@ func AddSite(db *sql.DB) error {
 	stmts := []dbStatement{
 		dbStmt(fmt.Sprintf("INSERT INTO sites (%s) VALUES (%s)", siteDBKey, siteDBIdx), dbVals(DefaultSite)...),
 	}
+	defer invalidateSiteCache()
 	return dbExec(db, stmts)
 }
 
@@ -130,9 +196,33 @@ func UpdateSite(db *sql.DB, s *Site) error {
 			return fmt.Errorf("could not delete site shot task: %w", err)
 		}
 	}
+	oldW, err := ParseWorkflow(oldS.Workflow)
+	if err != nil {
+		return err
+	}
+	newW, err := ParseWorkflow(s.Workflow)
+	if err != nil {
+		return err
+	}
+	for _, st := range oldW.Statuses() {
+		if newW.State(st) != nil {
+			continue
+		}
+		err := SiteMustNotHaveTaskStatus(db, st)
+		if err != nil {
+			return fmt.Errorf("could not delete workflow state: %w", err)
+		}
+	}
+	if oldS.LibraryShow != "" && oldS.LibraryShow != s.LibraryShow {
+		err := libraryMustNotBeReferenced(db, oldS.LibraryShow)
+		if err != nil {
+			return fmt.Errorf("could not change library show: %w", err)
+		}
+	}
 	stmts := []dbStatement{
 		dbStmt(fmt.Sprintf("UPDATE sites SET (%s) = (%s)", siteDBKey, siteDBIdx), dbVals(s)...),
 	}
+	defer invalidateSiteCache()
 	return dbExec(db, stmts)
 }
 
@@ -152,9 +242,29 @@ func SiteMustNotHaveTask(db *sql.DB, task string) error {
 	return nil
 }
 
+// SiteMustNotHaveTaskStatus는 사이트 내에 해당 상태의 태스크가 하나라도 있으면 에러를 반환한다.
+func SiteMustNotHaveTaskStatus(db *sql.DB, status Status) error {
+	t := &Task{}
+	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM tasks WHERE status=$1 LIMIT 1", taskDBKey), status)
+	err := dbQueryRow(db, stmt, func(row *sql.Row) error {
+		return scan(row, t)
+	})
+	if err == nil {
+		return BadRequest("task %q has status %q (and there's possibly more)", t.ID(), status)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	return nil
+}
+
 // GetSite는 db에서 사이트 정보를 가지고 온다.
 // 사이트 정보가 존재하지 않으면 nil과 NotFound 에러를 반환한다.
 func GetSite(db *sql.DB) (*Site, error) {
+	v, gen, ok := cache.get(siteCacheKey())
+	if ok {
+		return v.(*Site), nil
+	}
 	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM sites LIMIT 1", siteDBKey))
 	s := &Site{}
 	err := dbQueryRow(db, stmt, func(row *sql.Row) error {
@@ -166,6 +276,7 @@ func GetSite(db *sql.DB) (*Site, error) {
 		}
 		return nil, err
 	}
+	cache.put(siteCacheKey(), s, gen)
 	return s, err
 }
 
@@ -173,5 +284,6 @@ func DeleteSite(db *sql.DB) error {
 	stmts := []dbStatement{
 		dbStmt("DELETE FROM sites"),
 	}
+	defer invalidateSiteCache()
 	return dbExec(db, stmts)
 }
@@ -15,3 +15,13 @@ func subtractStringSlice(from []string, to []string) []string {
 	}
 	return result
 }
+
+// hasString은 문자열 슬라이스에 해당 문자열이 있는지를 반환한다.
+func hasString(ss []string, s string) bool {
+	for _, el := range ss {
+		if el == s {
+			return true
+		}
+	}
+	return false
+}
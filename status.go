@@ -1,5 +1,7 @@
 package roi
 
+import "regexp"
+
 // Status는 유닛 및 태스크의 상태이다.
 type Status string
 
@@ -24,44 +26,14 @@ var AllStatus = []Status{
 	StatusDone,
 }
 
-// UIStirng은 UI에서 각 상태를 뜻할 문자열을 의미한다.
-func (s Status) UIString() string {
-	switch s {
-	case StatusOmit:
-		return "오밋"
-	case StatusHold:
-		return "홀드"
-	case StatusInProgress:
-		return "진행"
-	case StatusNeedReview:
-		return "리뷰대기"
-	case StatusRetake:
-		return "리테이크"
-	case StatusApproved:
-		return "승인"
-	case StatusDone:
-		return "완료"
-	}
-	return ""
-}
+// reStatusName은 가능한 상태명을 정의하는 정규식이다.
+var reStatusName = regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`)
 
-// UIColor는 UI에서 각 상태를 뜻할 색상을 의미한다.
-func (s Status) UIColor() string {
-	switch s {
-	case StatusOmit:
-		return "black"
-	case StatusHold:
-		return "grey"
-	case StatusInProgress:
-		return "green"
-	case StatusNeedReview:
-		return "magenta"
-	case StatusRetake:
-		return "crimson"
-	case StatusApproved:
-		return "aquamarine"
-	case StatusDone:
-		return "blue"
+// verifyStatusName은 받아들인 상태명이 유효하지 않다면 에러를 반환한다.
+// 사이트 워크플로우에서 새 상태를 정의할 수 있으므로 AllStatus에 없는 상태도 허용한다.
+func verifyStatusName(s Status) error {
+	if !reStatusName.MatchString(string(s)) {
+		return BadRequest("invalid status name: '%s'", s)
 	}
-	return ""
+	return nil
 }
@@ -0,0 +1,297 @@
+package roi
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// 상태 분류는 상태의 의미를 나타낸다.
+// 사이트에서 상태를 새로 정의하더라도 분류를 통해 그 상태가 작업중인지, 끝났는지 알 수 있다.
+const (
+	StatusCategoryActive  = "active"
+	StatusCategoryWaiting = "waiting"
+	StatusCategoryDone    = "done"
+	StatusCategoryOmitted = "omitted"
+)
+
+var AllStatusCategory = []string{
+	StatusCategoryActive,
+	StatusCategoryWaiting,
+	StatusCategoryDone,
+	StatusCategoryOmitted,
+}
+
+// StatusDef는 사이트에 정의된 상태 하나의 레이블, 색상, 분류이다.
+// 사이트에는 "상태: 레이블, 색상, 분류" 형식의 문자열로 저장된다.
+type StatusDef struct {
+	Status   string `json:"status"`
+	Label    string `json:"label"`
+	Color    string `json:"color"`
+	Category string `json:"category"`
+}
+
+// DefaultStatusDefs는 유닛과 태스크 상태의 기본 정의이다.
+// 사이트에 정의되지 않은 상태는 이 정의를 따른다.
+var DefaultStatusDefs = []string{
+	"omit: 오밋, black, omitted",
+	"hold: 홀드, grey, waiting",
+	"in-progress: 진행, green, active",
+	"need-review: 리뷰대기, magenta, waiting",
+	"retake: 리테이크, crimson, active",
+	"approved: 승인, aquamarine, done",
+	"done: 완료, blue, done",
+}
+
+// DefaultShowStatusDefs는 쇼 상태의 기본 정의이다.
+// 대기 상태는 빈 문자열이다.
+var DefaultShowStatusDefs = []string{
+	": 대기, grey, waiting",
+	"pre: 프리 프로덕션, yellow, active",
+	"prod: 프로덕션, yellow, active",
+	"post: 포스트 프로덕션, green, active",
+	"done: 완료, blue, done",
+	"hold: 홀드, grey, waiting",
+}
+
+// StatusColors는 상태 색상으로 쓸 수 있는 색상 이름이다.
+// UI(cmd/roi/static/roi.css)에 같은 이름의 css 변수로 정의되어 있어야 한다.
+var StatusColors = []string{
+	"red",
+	"orange",
+	"yellow",
+	"olive",
+	"green",
+	"teal",
+	"blue",
+	"violet",
+	"purple",
+	"pink",
+	"brown",
+	"grey",
+	"black",
+	"magenta",
+	"crimson",
+	"aquamarine",
+}
+
+// reStatusHexColor는 #rrggbb 형식의 상태 색상을 정의하는 정규식이다.
+var reStatusHexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// verifyStatusColor는 색상이 StatusColors의 색상 이름이나 #rrggbb 형식이 아니라면 에러를 반환한다.
+func verifyStatusColor(c string) error {
+	if hasString(StatusColors, c) || reStatusHexColor.MatchString(c) {
+		return nil
+	}
+	return BadRequest("invalid color: %s", c)
+}
+
+// ParseStatusDef는 "상태: 레이블, 색상, 분류" 형식의 문자열을 상태 정의로 해석한다.
+// 레이블에는 쉼표가 들어갈 수 있다. 형식이 맞지 않으면 BadRequest 에러를 반환한다.
+// 상태 이름은 검사하지 않는다.
+func ParseStatusDef(s string) (*StatusDef, error) {
+	kv := strings.SplitN(s, ":", 2)
+	if len(kv) != 2 {
+		return nil, BadRequest("invalid status definition: %q: want 'status: label, color, category'", s)
+	}
+	vs := strings.Split(kv[1], ",")
+	n := len(vs)
+	if n < 3 {
+		return nil, BadRequest("invalid status definition: %q: want 'status: label, color, category'", s)
+	}
+	d := &StatusDef{
+		Status:   strings.TrimSpace(kv[0]),
+		Label:    strings.TrimSpace(strings.Join(vs[:n-2], ",")),
+		Color:    strings.TrimSpace(vs[n-2]),
+		Category: strings.TrimSpace(vs[n-1]),
+	}
+	if d.Label == "" {
+		return nil, BadRequest("invalid status definition: %q: label not specified", s)
+	}
+	if verifyStatusColor(d.Color) != nil {
+		return nil, BadRequest("invalid status definition: %q: invalid color: %s", s, d.Color)
+	}
+	if !hasString(AllStatusCategory, d.Category) {
+		return nil, BadRequest("invalid status definition: %q: invalid category: %s", s, d.Category)
+	}
+	return d, nil
+}
+
+// String은 상태 정의를 사이트에 저장되는 형식의 문자열로 반환한다.
+func (d *StatusDef) String() string {
+	return d.Status + ": " + d.Label + ", " + d.Color + ", " + d.Category
+}
+
+// mergeStatusDefs는 기본 정의에 사이트의 정의를 덮어 쓴 상태 정의들을 반환한다.
+// 기본 정의에 없는 상태는 뒤에 사이트에 정의된 순서대로 붙는다.
+// 해석할 수 없는 정의는 무시한다. 사이트를 수정할 때 검사하기 때문이다.
+func mergeStatusDefs(defaults, defs []string) []*StatusDef {
+	merged := make([]*StatusDef, 0, len(defaults)+len(defs))
+	idx := make(map[string]int)
+	for _, ds := range [][]string{defaults, defs} {
+		for _, s := range ds {
+			d, err := ParseStatusDef(s)
+			if err != nil {
+				continue
+			}
+			if i, ok := idx[d.Status]; ok {
+				merged[i] = d
+				continue
+			}
+			idx[d.Status] = len(merged)
+			merged = append(merged, d)
+		}
+	}
+	return merged
+}
+
+// findStatusDef는 정의들 중 상태에 해당하는 정의를 찾는다.
+// 정의되지 않은 상태라면 상태 이름을 레이블로 하는 작업중 분류의 정의를 반환한다.
+func findStatusDef(defs []*StatusDef, status string) *StatusDef {
+	for _, d := range defs {
+		if d.Status == status {
+			return d
+		}
+	}
+	return &StatusDef{Status: status, Label: status, Color: "grey", Category: StatusCategoryActive}
+}
+
+// hasStatusDef는 정의들 중 해당 상태의 정의가 있는지를 반환한다.
+func hasStatusDef(defs []*StatusDef, status string) bool {
+	for _, d := range defs {
+		if d.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyStatusDefs는 사이트에 저장될 상태 정의들이 유효하지 않다면 에러를 반환한다.
+// allowEmpty가 참이면 빈 상태 이름을 허용한다. 쇼의 대기 상태가 빈 문자열이기 때문이다.
+func verifyStatusDefs(defs []string, allowEmpty bool) error {
+	has := make(map[string]bool)
+	for _, s := range defs {
+		d, err := ParseStatusDef(s)
+		if err != nil {
+			return err
+		}
+		if !(allowEmpty && d.Status == "") {
+			err = verifyStatusName(Status(d.Status))
+			if err != nil {
+				return err
+			}
+		}
+		if has[d.Status] {
+			return BadRequest("status defined twice: %q", d.Status)
+		}
+		has[d.Status] = true
+	}
+	return nil
+}
+
+// StatusDefs는 사이트의 유닛 및 태스크 상태 정의들을 반환한다.
+// 사이트에서 다시 정의하지 않은 기본 상태(DefaultStatusDefs)의 정의도 포함한다.
+func (s *Site) StatusDefs() []*StatusDef {
+	return mergeStatusDefs(DefaultStatusDefs, s.Statuses)
+}
+
+// ShowStatusDefs는 사이트의 쇼 상태 정의들을 반환한다.
+// 사이트에서 다시 정의하지 않은 기본 상태(DefaultShowStatusDefs)의 정의도 포함한다.
+func (s *Site) ShowStatusDefs() []*StatusDef {
+	return mergeStatusDefs(DefaultShowStatusDefs, s.ShowStatuses)
+}
+
+// newStatusKeys는 defs에 정의된 상태 중 defaults에 없는 상태들을 정의된 순서대로 반환한다.
+// 해석할 수 없는 정의는 무시한다.
+func newStatusKeys(defaults, defs []string) []string {
+	has := make(map[string]bool)
+	for _, s := range defaults {
+		d, err := ParseStatusDef(s)
+		if err != nil {
+			continue
+		}
+		has[d.Status] = true
+	}
+	keys := make([]string, 0)
+	for _, s := range defs {
+		d, err := ParseStatusDef(s)
+		if err != nil || has[d.Status] {
+			continue
+		}
+		has[d.Status] = true
+		keys = append(keys, d.Status)
+	}
+	return keys
+}
+
+// AvailableUnitStatuses는 유닛이 가질 수 있는 상태들을 반환한다.
+// 기본 유닛 상태(AllUnitStatus) 뒤에 사이트에 새로 정의된 상태들이 정의된 순서대로 붙는다.
+func (s *Site) AvailableUnitStatuses() []Status {
+	ss := append([]Status{}, AllUnitStatus...)
+	for _, k := range newStatusKeys(DefaultStatusDefs, s.Statuses) {
+		ss = append(ss, Status(k))
+	}
+	return ss
+}
+
+// AvailableShowStatuses는 쇼가 가질 수 있는 상태들을 반환한다.
+// 기본 쇼 상태(AllShowStatus) 뒤에 사이트에 새로 정의된 상태들이 정의된 순서대로 붙는다.
+func (s *Site) AvailableShowStatuses() []ShowStatus {
+	ss := append([]ShowStatus{}, AllShowStatus...)
+	for _, k := range newStatusKeys(DefaultShowStatusDefs, s.ShowStatuses) {
+		ss = append(ss, ShowStatus(k))
+	}
+	return ss
+}
+
+// IsOmitted는 유닛이나 태스크의 상태가 오밋 분류에 속하는지를 반환한다.
+func (s *Site) IsOmitted(st Status) bool {
+	return s.StatusDef(st).Category == StatusCategoryOmitted
+}
+
+// OmitStatus는 유닛을 작업에서 뺄 때 쓰는 상태를 반환한다.
+// 유닛이 가질 수 있는 상태 중 오밋 분류에 속하는 첫 상태이며, 없다면 StatusOmit이다.
+func (s *Site) OmitStatus() Status {
+	for _, st := range s.AvailableUnitStatuses() {
+		if s.IsOmitted(st) {
+			return st
+		}
+	}
+	return StatusOmit
+}
+
+// StatusDef는 유닛 또는 태스크 상태의 정의를 반환한다.
+// 정의되지 않은 상태라도 상태 이름을 레이블로 하는 정의를 반환한다.
+func (s *Site) StatusDef(st Status) *StatusDef {
+	return findStatusDef(s.StatusDefs(), string(st))
+}
+
+// ShowStatusDef는 쇼 상태의 정의를 반환한다.
+// 사이트에서 정의하지 않은 예전 상태는 그에 해당하는 현재 상태의 정의를 따른다.
+// 정의되지 않은 상태라도 상태 이름을 레이블로 하는 정의를 반환한다.
+func (s *Site) ShowStatusDef(st ShowStatus) *StatusDef {
+	defs := s.ShowStatusDefs()
+	if to, ok := legacyShowStatuses[st]; ok && !hasStatusDef(defs, string(st)) {
+		st = to
+	}
+	return findStatusDef(defs, string(st))
+}
+
+// storedStatus는 db에 저장되어 있는 항목의 상태를 가져온다.
+// 항목이 아직 저장되지 않았다면 ok로 false를 반환한다.
+//
+// 캐시된 항목은 핸들러에서 수정될 수 있으므로 항상 db에서 직접 읽는다.
+func storedStatus(db *sql.DB, table, where string, args ...interface{}) (status string, ok bool, err error) {
+	stmt := dbStmt("SELECT status FROM "+table+" WHERE "+where, args...)
+	err = dbQueryRow(db, stmt, func(row *sql.Row) error {
+		return row.Scan(&status)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return status, true, nil
+}
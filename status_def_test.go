@@ -0,0 +1,138 @@
+package roi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatusDef(t *testing.T) {
+	cases := []struct {
+		s       string
+		want    *StatusDef
+		wantErr bool
+	}{
+		{s: "done: 완료, blue, done", want: &StatusDef{Status: "done", Label: "완료", Color: "blue", Category: "done"}},
+		{s: "wfc:Waiting, for client ,#ff8000,waiting", want: &StatusDef{Status: "wfc", Label: "Waiting, for client", Color: "#ff8000", Category: "waiting"}},
+		{s: ": 대기, grey, waiting", want: &StatusDef{Status: "", Label: "대기", Color: "grey", Category: "waiting"}},
+		{s: "done 완료, blue, done", wantErr: true},
+		{s: "done: 완료, done", wantErr: true},
+		{s: "done: , blue, done", wantErr: true},
+		{s: "done: 완료, #ff80, done", wantErr: true},
+		{s: "done: 완료, blue, finished", wantErr: true},
+		{s: "done: 완료, bleu, done", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := ParseStatusDef(c.s)
+		if c.wantErr {
+			if !errors.As(err, &BadRequestError{}) {
+				t.Fatalf("%q: want bad request error, got %v", c.s, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", c.s, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%q: got %v, want %v", c.s, got, c.want)
+		}
+		back, err := ParseStatusDef(got.String())
+		if err != nil || !reflect.DeepEqual(back, got) {
+			t.Fatalf("%q: could not parse back %q: %v", c.s, got.String(), err)
+		}
+	}
+}
+
+func TestSiteStatusDefs(t *testing.T) {
+	s := &Site{
+		Statuses: []string{
+			"in-progress: WIP, teal, active",
+			"waiting-for-client: Client, #ff8000, waiting",
+		},
+	}
+	defs := s.StatusDefs()
+	if len(defs) != len(DefaultStatusDefs)+1 {
+		t.Fatalf("got %d definitions, want %d", len(defs), len(DefaultStatusDefs)+1)
+	}
+	// 사이트에서 다시 정의한 상태는 기본 상태의 순서를 유지한다.
+	if defs[2].Status != "in-progress" || defs[2].Label != "WIP" {
+		t.Fatalf("overridden definition: got %v", defs[2])
+	}
+	if d := defs[len(defs)-1]; d.Status != "waiting-for-client" {
+		t.Fatalf("new definition should be last: got %v", d)
+	}
+	// 기존 상태 키는 사이트 정의가 없어도 그대로 동작한다.
+	if d := s.StatusDef(StatusRetake); d.Label != "리테이크" || d.Category != StatusCategoryActive {
+		t.Fatalf("default definition: got %v", d)
+	}
+	if d := s.StatusDef("unknown"); d.Label != "unknown" {
+		t.Fatalf("undefined status: got %v", d)
+	}
+	if d := s.ShowStatusDef(ShowWaiting); d.Label != "대기" {
+		t.Fatalf("show waiting status: got %v", d)
+	}
+	// 예전 쇼 상태는 그에 해당하는 현재 상태의 정의를 따른다.
+	if d := s.ShowStatusDef("waiting"); d.Label != "대기" || d.Category != StatusCategoryWaiting {
+		t.Fatalf("legacy show waiting status: got %v", d)
+	}
+
+	err := verifyStatusDefs([]string{"a: A, red, active", "a: B, red, active"}, false)
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error for duplicated status, got %v", err)
+	}
+	err = verifyStatusDefs([]string{": A, red, active"}, false)
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error for empty status, got %v", err)
+	}
+	err = verifyStatusDefs(DefaultShowStatusDefs, true)
+	if err != nil {
+		t.Fatalf("default show statuses: %v", err)
+	}
+	err = verifyStatusDefs(DefaultStatusDefs, false)
+	if err != nil {
+		t.Fatalf("default statuses: %v", err)
+	}
+}
+
+func TestSiteAvailableStatuses(t *testing.T) {
+	s := &Site{
+		Statuses: []string{
+			"in-progress: WIP, teal, active",
+			"waiting-for-client: Client, #ff8000, waiting",
+			"cut: 편집에서 빠짐, black, omitted",
+		},
+		ShowStatuses: []string{
+			"bid: 비딩, orange, waiting",
+		},
+	}
+	want := append(append([]Status{}, AllUnitStatus...), "waiting-for-client", "cut")
+	if got := s.AvailableUnitStatuses(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unit statuses: got %v, want %v", got, want)
+	}
+	if err := verifyUnitStatus(s, "waiting-for-client"); err != nil {
+		t.Fatalf("site defined unit status: %v", err)
+	}
+	if err := verifyUnitStatus(s, "unknown"); !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error for undefined unit status, got %v", err)
+	}
+	if err := verifyShowStatus(s, "bid"); err != nil {
+		t.Fatalf("site defined show status: %v", err)
+	}
+	if err := verifyShowStatus(s, "waiting"); err != nil {
+		t.Fatalf("legacy show status: %v", err)
+	}
+	if err := verifyShowStatus(s, "unknown"); !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error for undefined show status, got %v", err)
+	}
+	if !s.IsOmitted("cut") || s.IsOmitted(StatusInProgress) {
+		t.Fatalf("omitted category not respected")
+	}
+	if got := s.OmitStatus(); got != StatusOmit {
+		t.Fatalf("omit status: got %s, want %s", got, StatusOmit)
+	}
+	// 기본 오밋 상태를 다른 분류로 바꾸면 사이트의 오밋 상태를 쓴다.
+	s.Statuses = append(s.Statuses, "omit: 보류, grey, waiting")
+	if got := s.OmitStatus(); got != "cut" {
+		t.Fatalf("omit status: got %s, want cut", got)
+	}
+}
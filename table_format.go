@@ -0,0 +1,200 @@
+package roi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// utf8BOM은 UTF-8 파일의 시작을 나타내는 바이트 순서 표식이다.
+// 엑셀은 이 표식이 없는 CSV 파일의 한글을 제대로 읽지 못한다.
+const utf8BOM = "\ufeff"
+
+// ParseDelimiter는 CSV 구분자 이름을 구분자로 변환한다.
+// 빈 문자열은 쉼표이며, tab, comma, semicolon, pipe 같은 이름이나 한 글자를 받아들인다.
+func ParseDelimiter(s string) (rune, error) {
+	switch s {
+	case "", "comma":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "semicolon":
+		return ';', nil
+	case "pipe":
+		return '|', nil
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	if n != len(s) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, BadRequest("invalid delimiter: %q", s)
+	}
+	return r, nil
+}
+
+// ReadCSVTable은 CSV 형식의 표를 읽는다. 파일 앞의 UTF-8 BOM은 무시한다.
+func ReadCSVTable(r io.Reader, delim rune) ([][]string, error) {
+	br := bufio.NewReader(r)
+	bom, err := br.Peek(len(utf8BOM))
+	if err == nil && string(bom) == utf8BOM {
+		br.Discard(len(utf8BOM))
+	}
+	cr := csv.NewReader(br)
+	cr.Comma = delim
+	// 줄마다 열의 갯수가 다를 수 있다.
+	cr.FieldsPerRecord = -1
+	table, err := cr.ReadAll()
+	if err != nil {
+		return nil, BadRequest("invalid csv: %v", err)
+	}
+	return table, nil
+}
+
+// WriteCSVTable은 표를 CSV 형식으로 쓴다.
+// bom이 참이면 엑셀에서 열 수 있도록 파일 앞에 UTF-8 BOM을 붙인다.
+func WriteCSVTable(w io.Writer, table [][]string, delim rune, bom bool) error {
+	if bom {
+		_, err := io.WriteString(w, utf8BOM)
+		if err != nil {
+			return err
+		}
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = delim
+	err := cw.WriteAll(table)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReadJSONTable은 JSON 형식의 유닛 목록을 표로 읽는다.
+// JSON은 열 이름을 키로 하는 오브젝트의 배열이다. 예) [{"show": "test", "unit": "CG0010", "comp.due": "2020-03-01"}]
+// 값은 문자열, 숫자, 불리언 또는 문자열 배열이며, 배열은 태그처럼 공백으로 이어붙인다.
+// 표의 열은 UnitTableColumns 순서로 시작해서 나머지 열 이름순으로 정렬된다.
+func ReadJSONTable(r io.Reader) ([][]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte(utf8BOM))
+	objs := make([]map[string]interface{}, 0)
+	err = json.Unmarshal(data, &objs)
+	if err != nil {
+		return nil, BadRequest("invalid json: %v", err)
+	}
+	hasCol := make(map[string]bool)
+	for _, o := range objs {
+		for k := range o {
+			hasCol[k] = true
+		}
+	}
+	title := make([]string, 0, len(hasCol))
+	for _, c := range UnitTableColumns {
+		if hasCol[c] {
+			title = append(title, c)
+			delete(hasCol, c)
+		}
+	}
+	others := make([]string, 0, len(hasCol))
+	for c := range hasCol {
+		others = append(others, c)
+	}
+	sort.Strings(others)
+	title = append(title, others...)
+	table := [][]string{title}
+	for i, o := range objs {
+		row := make([]string, len(title))
+		for j, c := range title {
+			v, err := jsonTableCell(o[c])
+			if err != nil {
+				return nil, BadRequest("invalid json: item %d: %s: %v", i, c, err)
+			}
+			row[j] = v
+		}
+		table = append(table, row)
+	}
+	return table, nil
+}
+
+// jsonTableCell은 JSON 값을 표의 셀 문자열로 변환한다.
+func jsonTableCell(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		vals := make([]string, 0, len(v))
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return "", fmt.Errorf("array should only have strings")
+			}
+			vals = append(vals, s)
+		}
+		return strings.Join(vals, " "), nil
+	}
+	return "", fmt.Errorf("unsupported value: %v", v)
+}
+
+// WriteJSONTable은 표를 열 이름을 키로 하는 오브젝트의 배열로 쓴다.
+// 빈 셀은 쓰지 않는다.
+func WriteJSONTable(w io.Writer, table [][]string) error {
+	objs := JSONTable(table)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(objs)
+}
+
+// JSONTable은 표를 열 이름을 키로 하는 맵의 슬라이스로 변환한다. 빈 셀은 포함하지 않는다.
+func JSONTable(table [][]string) []map[string]string {
+	objs := make([]map[string]string, 0)
+	if len(table) == 0 {
+		return objs
+	}
+	title := table[0]
+	for _, row := range table[1:] {
+		o := make(map[string]string)
+		for j, v := range row {
+			if j >= len(title) || title[j] == "" || v == "" {
+				continue
+			}
+			o[title[j]] = v
+		}
+		objs = append(objs, o)
+	}
+	return objs
+}
+
+// ReadTable은 format 형식(csv 또는 json)의 표를 읽는다. delim은 csv에서만 쓰인다.
+func ReadTable(r io.Reader, format string, delim rune) ([][]string, error) {
+	switch format {
+	case "csv":
+		return ReadCSVTable(r, delim)
+	case "json":
+		return ReadJSONTable(r)
+	}
+	return nil, BadRequest("unknown table format: %s", format)
+}
+
+// WriteTable은 표를 format 형식(csv 또는 json)으로 쓴다. delim과 bom은 csv에서만 쓰인다.
+func WriteTable(w io.Writer, table [][]string, format string, delim rune, bom bool) error {
+	switch format {
+	case "csv":
+		return WriteCSVTable(w, table, delim, bom)
+	case "json":
+		return WriteJSONTable(w, table)
+	}
+	return BadRequest("unknown table format: %s", format)
+}
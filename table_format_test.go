@@ -0,0 +1,86 @@
+package roi
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDelimiter(t *testing.T) {
+	cases := []struct {
+		s    string
+		want rune
+		ok   bool
+	}{
+		{s: "", want: ',', ok: true},
+		{s: "tab", want: '\t', ok: true},
+		{s: ";", want: ';', ok: true},
+		{s: "pipe", want: '|', ok: true},
+		{s: "ab", ok: false},
+		{s: `"`, ok: false},
+	}
+	for _, c := range cases {
+		got, err := ParseDelimiter(c.s)
+		if (err == nil) != c.ok || got != c.want {
+			t.Fatalf("%q: got (%q, %v), want (%q, ok %v)", c.s, got, err, c.want, c.ok)
+		}
+	}
+}
+
+func TestCSVTable(t *testing.T) {
+	table := [][]string{
+		{"show", "group", "unit", "description"},
+		{"test", "CG", "0010", "방에 우두커니; 혼자"},
+	}
+	var buf bytes.Buffer
+	err := WriteCSVTable(&buf, table, ';', true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), utf8BOM) {
+		t.Fatalf("bom not written")
+	}
+	got, err := ReadCSVTable(&buf, ';')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, table) {
+		t.Fatalf("got %v, want %v", got, table)
+	}
+}
+
+func TestJSONTable(t *testing.T) {
+	in := `[
+		{"unit": "0010", "show": "test", "group": "CG", "tags": ["로이", "창문"], "duration": 132, "comp.due": "2020-03-01"},
+		{"show": "test", "group": "CG", "unit": "0020"}
+	]`
+	want := [][]string{
+		{"show", "group", "unit", "tags", "comp.due", "duration"},
+		{"test", "CG", "0010", "로이 창문", "2020-03-01", "132"},
+		{"test", "CG", "0020", "", "", ""},
+	}
+	got, err := ReadJSONTable(strings.NewReader(utf8BOM + in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	var buf bytes.Buffer
+	err = WriteJSONTable(&buf, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ReadJSONTable(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, want) {
+		t.Fatalf("got %v, want %v", again, want)
+	}
+	_, err = ReadJSONTable(strings.NewReader(`[{"unit": {"a": 1}}]`))
+	if err == nil {
+		t.Fatalf("want error for object value")
+	}
+}
@@ -123,48 +123,48 @@ func verifyTask(db *sql.DB, t *Task) error {
 	if err != nil {
 		return err
 	}
-	err = verifyTaskStatus(t.Status)
+	_, w, err := siteWorkflow(db)
 	if err != nil {
 		return err
 	}
-	t.PublishVersion = strings.TrimSpace(t.PublishVersion)
-	if t.PublishVersion != "" {
-		_, err = GetVersion(db, t.Show, t.Group, t.Unit, t.Task, t.PublishVersion)
-		if err != nil {
-			return fmt.Errorf("publish version: %w", err)
-		}
+	if w.State(t.Status) == nil {
+		return BadRequest("invalid task status: '%s'", t.Status)
 	}
+	t.PublishVersion = strings.TrimSpace(t.PublishVersion)
 	t.ApprovedVersion = strings.TrimSpace(t.ApprovedVersion)
-	if t.ApprovedVersion != "" {
-		_, err = GetVersion(db, t.Show, t.Group, t.Unit, t.Task, t.ApprovedVersion)
-		if err != nil {
-			return fmt.Errorf("approved version: %w", err)
-		}
-	}
 	t.ReviewVersion = strings.TrimSpace(t.ReviewVersion)
-	if t.ReviewVersion != "" {
-		_, err = GetVersion(db, t.Show, t.Group, t.Unit, t.Task, t.ReviewVersion)
-		if err != nil {
-			return fmt.Errorf("review version: %w", err)
-		}
-	}
 	t.WorkingVersion = strings.TrimSpace(t.WorkingVersion)
-	if t.WorkingVersion != "" {
-		_, err = GetVersion(db, t.Show, t.Group, t.Unit, t.Task, t.WorkingVersion)
-		if err != nil {
-			return fmt.Errorf("working version: %w", err)
+	refs := []struct {
+		field string
+		ver   string
+	}{
+		{"publish version", t.PublishVersion},
+		{"approved version", t.ApprovedVersion},
+		{"review version", t.ReviewVersion},
+		{"working version", t.WorkingVersion},
+	}
+	// 태스크가 가리키는 버전들은 한번의 쿼리로 불러와 확인한다.
+	var has map[string]bool
+	for _, r := range refs {
+		if r.ver == "" {
+			continue
+		}
+		if has == nil {
+			vs, err := VersionsOfTasks(db, t.Show, []string{t.ID()})
+			if err != nil {
+				return err
+			}
+			has = make(map[string]bool)
+			for _, v := range vs[t.ID()] {
+				has[v.Version] = true
+			}
+		}
+		if !has[r.ver] {
+			return fmt.Errorf("%s: %w", r.field, NotFound("version not found: %s", JoinVersionID(t.Show, t.Group, t.Unit, t.Task, r.ver)))
 		}
 	}
-	if t.Status == StatusDone && t.PublishVersion == "" {
-		return BadRequest("cannot set task status to TaskDone: no publish version")
-	}
-	if t.Status == StatusApproved && t.ApprovedVersion == "" {
-		return BadRequest("cannot set task status to TaskApproved: no approved version")
-	}
-	if t.Status == StatusNeedReview && t.ReviewVersion == "" {
-		return BadRequest("cannot set task status to TaskNeedReview: no review versions")
-	}
-	return nil
+	// 상태가 요구하는 필드는 사이트 워크플로우에 정의되어 있다.
+	return w.verifyTask(t)
 }
 
 // AddTask는 db의 특정 쇼, 카테고리, 유닛에 태스크를 추가한다.
@@ -198,19 +198,64 @@ func addTaskStmts(db *sql.DB, t *Task) ([]dbStatement, error) {
 }
 
 // UpdateTask는 db의 특정 태스크를 업데이트 한다.
+// 워크플로우의 상태 전환은 검사하지 않으므로 관리 도구나 테스트처럼 믿을 수 있는 곳에서만 쓴다.
+// 사용자의 요청으로 태스크를 바꿀 때는 UpdateTaskAs를 사용한다.
 func UpdateTask(db *sql.DB, t *Task) error {
+	_, err := GetTask(db, t.Show, t.Group, t.Unit, t.Task)
+	if err != nil {
+		return err
+	}
+	stmts, err := updateTaskStmts(db, t)
+	if err != nil {
+		return err
+	}
+	return dbExec(db, stmts)
+}
+
+// UpdateTaskAs는 user로서 db의 특정 태스크를 업데이트 한다.
+// 상태가 바뀐다면 사이트 워크플로우에 그 전환이 정의되어 있고 user가 그 전환을 일으킬 수 있어야 한다.
+func UpdateTaskAs(db *sql.DB, t *Task, user string) error {
 	err := verifyTask(db, t)
 	if err != nil {
 		return err
 	}
-	_, err = GetTask(db, t.Show, t.Group, t.Unit, t.Task)
+	old, err := GetTask(db, t.Show, t.Group, t.Unit, t.Task)
+	if err != nil {
+		return err
+	}
+	err = verifyTaskTransition(db, old, t, user)
+	if err != nil {
+		return err
+	}
+	stmts, err := updateTaskStmts(db, t)
 	if err != nil {
 		return err
 	}
+	return dbExec(db, stmts)
+}
+
+// updateTaskStmts는 태스크를 수정하는 db 구문을 반환한다.
+// 태스크가 있는지는 검사하지 않는다.
+func updateTaskStmts(db *sql.DB, t *Task) ([]dbStatement, error) {
+	err := verifyTask(db, t)
+	if err != nil {
+		return nil, err
+	}
 	stmts := []dbStatement{
 		dbStmt(fmt.Sprintf("UPDATE tasks SET (%s) = (%s) WHERE show='%s' AND grp='%s' AND unit='%s' AND task='%s'", taskDBKey, taskDBIdx, t.Show, t.Group, t.Unit, t.Task), dbVals(t)...),
 	}
-	return dbExec(db, stmts)
+	return stmts, nil
+}
+
+// verifyTaskTransition은 태스크를 old에서 t로 바꿀 때 사이트 워크플로우에 없는 상태 전환이라면
+// BadRequest 에러를, user가 일으킬 수 없는 전환이라면 Auth 에러를 반환한다.
+// 담당자 등 user의 역할은 바뀌기 전의 태스크를 기준으로 판단한다.
+func verifyTaskTransition(db *sql.DB, old, t *Task, user string) error {
+	s, w, err := siteWorkflow(db)
+	if err != nil {
+		return err
+	}
+	return w.verifyTransition(s, old.Status, t.Status, old, user)
 }
 
 // GetTask는 db에서 하나의 태스크를 찾는다.
@@ -220,11 +265,7 @@ func GetTask(db *sql.DB, show, grp, unit, task string) (*Task, error) {
 	if err != nil {
 		return nil, err
 	}
-	// 부모가 있는지 검사
-	_, err = GetUnit(db, show, grp, unit)
-	if err != nil {
-		return nil, err
-	}
+	// 부모는 태스크를 찾지 못했을 때만 검사한다.
 	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM tasks WHERE show=$1 AND grp=$2 AND unit=$3 AND task=$4 LIMIT 1", taskDBKey), show, grp, unit, task)
 	t := &Task{}
 	err = dbQueryRow(db, stmt, func(row *sql.Row) error {
@@ -232,6 +273,10 @@ func GetTask(db *sql.DB, show, grp, unit, task string) (*Task, error) {
 	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			_, err = GetUnit(db, show, grp, unit)
+			if err != nil {
+				return nil, err
+			}
 			return nil, NotFound("task not found: %s", JoinTaskID(show, grp, unit, task))
 		}
 		return nil, err
@@ -305,6 +350,77 @@ func UnitTasks(db *sql.DB, show, grp, unit string) ([]*Task, error) {
 	return ts, nil
 }
 
+// TasksOfUnits는 한 쇼에 속한 여러 유닛의 태스크를 한번에 불러와 유닛 아이디별로 반환한다.
+// UnitTasks와 마찬가지로 유닛의 Tasks에 정의된 태스크만 그 순서대로 담긴다.
+// 유닛 수와 관계없이 두 번의 쿼리로 처리하며, db에 없는 유닛은 결과에서 빠진다.
+func TasksOfUnits(db *sql.DB, show string, unitIDs []string) (map[string][]*Task, error) {
+	err := verifyShowPrimaryKeys(show)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make(map[string][]*Task)
+	if len(unitIDs) == 0 {
+		return tasks, nil
+	}
+	args := &queryArgs{}
+	args.add(show)
+	keys := make([]string, 0, len(unitIDs))
+	for _, id := range unitIDs {
+		s, grp, unit, err := SplitUnitID(id)
+		if err != nil {
+			return nil, err
+		}
+		if s != show {
+			return nil, BadRequest("unit not in show %s: %s", show, id)
+		}
+		keys = append(keys, "("+args.add(grp)+", "+args.add(unit)+")")
+	}
+	where := "show=$1 AND (grp, unit) IN (" + strings.Join(keys, ", ") + ")"
+	// 유닛별로 보일 태스크와 그 순서를 정한다.
+	taskIdx := make(map[string]map[string]int)
+	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM units WHERE %s", unitDBKey, where), args.vals...)
+	err = dbQuery(db, stmt, func(rows *sql.Rows) error {
+		u := &Unit{}
+		err := scan(rows, u)
+		if err != nil {
+			return err
+		}
+		idx := make(map[string]int)
+		for i, task := range u.Tasks {
+			idx[task] = i
+		}
+		taskIdx[u.ID()] = idx
+		tasks[u.ID()] = make([]*Task, 0)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stmt = dbStmt(fmt.Sprintf("SELECT %s FROM tasks WHERE %s", taskDBKey, where), args.vals...)
+	err = dbQuery(db, stmt, func(rows *sql.Rows) error {
+		t := &Task{}
+		err := scan(rows, t)
+		if err != nil {
+			return err
+		}
+		id := t.UnitID()
+		if _, ok := taskIdx[id][t.Task]; ok {
+			tasks[id] = append(tasks[id], t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for id, ts := range tasks {
+		idx := taskIdx[id]
+		sort.Slice(ts, func(i, j int) bool {
+			return idx[ts[i].Task] < idx[ts[j].Task]
+		})
+	}
+	return tasks, nil
+}
+
 // UserTasks는 해당 유저의 모든 태스크를 db에서 검색해 반환한다.
 func UserTasks(db *sql.DB, user string) ([]*Task, error) {
 	// 샷의 tasks에 속하지 않은 태스크는 보이지 않는다.
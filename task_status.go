@@ -1,17 +1,9 @@
 package roi
 
+// AllTaskStatus는 기본 워크플로우(DefaultWorkflow)의 태스크 상태이다.
+// 사이트에서 사용하는 상태는 SiteWorkflow로 얻는다.
 var AllTaskStatus = []Status{
 	StatusHold,
 	StatusInProgress,
 	StatusDone,
 }
-
-// verifyTaskStatus는 받아들인 태스크 상태가 유효하지 않다면 에러를 반환한다.
-func verifyTaskStatus(ts Status) error {
-	for _, s := range AllTaskStatus {
-		if ts == s {
-			return nil
-		}
-	}
-	return BadRequest("invalid task status: '%s'", ts)
-}
@@ -1,6 +1,7 @@
 package roi
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -80,6 +81,17 @@ func TestTask(t *testing.T) {
 	if len(tasks) != 1 {
 		t.Fatalf("invalid number of unit tasks: want 1, got %d", len(tasks))
 	}
+	unitTasks, err := TasksOfUnits(db, testUnitA.Show, []string{testUnitA.ID()})
+	if err != nil {
+		t.Fatalf("could not get tasks of units: %s", err)
+	}
+	if len(unitTasks[testUnitA.ID()]) != 1 {
+		t.Fatalf("invalid number of unit tasks: want 1, got %d", len(unitTasks[testUnitA.ID()]))
+	}
+	_, err = GetTask(db, testTaskA.Show, testTaskA.Group, "nonexist", testTaskA.Task)
+	if !errors.As(err, &NotFoundError{}) {
+		t.Fatalf("want not found error for task of non existing unit, got: %v", err)
+	}
 	tasks, err = UserTasks(db, "kybin")
 	if err != nil {
 		t.Fatalf("could not get user tasks: %s", err)
@@ -0,0 +1,360 @@
+package roi
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 본문 검색은 쇼, 그룹, 유닛, 리뷰의 글에서 단어를 찾는다.
+//
+// 각 글은 search_docs 테이블에 그대로 저장되고, 글을 토큰으로 나눈 결과는
+// search_index 테이블에 저장된다. 글이 수정될 때 같은 트랜잭션에서 함께 갱신된다.
+//
+// 한글은 띄어쓰기 단위로 조사가 붙기 때문에 단어 전체 대신 두 글자씩 나누어 저장한다.
+// 예) "폭발이 작다" -> 폭발, 발이, 작다
+// 검색어도 같은 방식으로 나누므로 "폭발"로 "폭발이"를 찾을 수 있다.
+// 한글이 아닌 단어는 소문자로 바꾼 단어 전체가 토큰이다.
+
+// CreateTableIfNotExistsSearchDocsStmt는 DB에 search_docs 테이블을 생성하는 sql 구문이다.
+// 테이블은 타입보다 많은 정보를 담고 있을수도 있다.
+var CreateTableIfNotExistsSearchDocsStmt = `CREATE TABLE IF NOT EXISTS search_docs (
+	show STRING NOT NULL CHECK (length(show) > 0) CHECK (show NOT LIKE '% %'),
+	grp STRING NOT NULL,
+	unit STRING NOT NULL,
+	kind STRING NOT NULL CHECK (length(kind) > 0),
+	id STRING NOT NULL CHECK (length(id) > 0),
+	field STRING NOT NULL CHECK (length(field) > 0),
+	text STRING NOT NULL,
+	CONSTRAINT search_docs_pk PRIMARY KEY (show, kind, id, field),
+	INDEX search_docs_unit_idx (show, grp, unit)
+)`
+
+// CreateTableIfNotExistsSearchIndexStmt는 DB에 search_index 테이블을 생성하는 sql 구문이다.
+// 테이블은 타입보다 많은 정보를 담고 있을수도 있다.
+var CreateTableIfNotExistsSearchIndexStmt = `CREATE TABLE IF NOT EXISTS search_index (
+	show STRING NOT NULL CHECK (length(show) > 0) CHECK (show NOT LIKE '% %'),
+	grp STRING NOT NULL,
+	unit STRING NOT NULL,
+	kind STRING NOT NULL CHECK (length(kind) > 0),
+	id STRING NOT NULL CHECK (length(id) > 0),
+	token STRING NOT NULL CHECK (length(token) > 0),
+	CONSTRAINT search_index_pk PRIMARY KEY (show, token, kind, id),
+	INDEX search_index_unit_idx (show, grp, unit)
+)`
+
+// 본문 검색에서 찾을 수 있는 항목의 종류이다.
+const (
+	SearchKindShow   = "show"
+	SearchKindGroup  = "group"
+	SearchKindUnit   = "unit"
+	SearchKindReview = "review"
+)
+
+// searchKindOrder는 검색 결과를 보일 때 종류의 순서이다.
+var searchKindOrder = map[string]int{
+	SearchKindShow:   0,
+	SearchKindGroup:  1,
+	SearchKindUnit:   2,
+	SearchKindReview: 3,
+}
+
+// searchDoc은 본문 검색의 대상이 되는 글 하나이다.
+// 쇼나 그룹의 글이라면 Group이나 Unit은 빈 문자열이다.
+type searchDoc struct {
+	Show  string `db:"show"`
+	Group string `db:"grp"`
+	Unit  string `db:"unit"`
+	Kind  string `db:"kind"`
+	ID    string `db:"id"`
+	Field string `db:"field"`
+	Text  string `db:"text"`
+}
+
+var searchDocDBKey string = strings.Join(dbKeys(&searchDoc{}), ", ")
+var searchDocDBIdx string = strings.Join(dbIdxs(&searchDoc{}), ", ")
+var _ []interface{} = dbVals(&searchDoc{})
+
+// maxSearchHits는 한번의 본문 검색에서 반환하는 최대 항목 수이다.
+const maxSearchHits = 200
+
+// searchTokens는 글을 본문 검색의 토큰들로 나눈다. 같은 토큰은 한번만 포함된다.
+// 글자나 숫자가 아닌 문자는 단어를 나누며, 한글과 다른 글자 사이도 나눈다.
+func searchTokens(text string) []string {
+	toks := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(t string) {
+		if !seen[t] {
+			seen[t] = true
+			toks = append(toks, t)
+		}
+	}
+	word := make([]rune, 0)
+	hangul := false
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		if !hangul {
+			add(strings.ToLower(string(word)))
+		} else if len(word) == 1 {
+			add(string(word))
+		} else {
+			for i := 0; i+1 < len(word); i++ {
+				add(string(word[i : i+2]))
+			}
+		}
+		word = word[:0]
+	}
+	for _, r := range text {
+		h := unicode.Is(unicode.Hangul, r)
+		if !h && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if h != hangul {
+			flush()
+		}
+		hangul = h
+		word = append(word, r)
+	}
+	flush()
+	return toks
+}
+
+// indexDocsStmts는 한 항목의 글들과 그 토큰을 추가하는 dbStatement를 반환한다.
+// 기존의 글을 지우지 않으므로 필요하다면 먼저 deleteIndexStmts를 사용해야 한다.
+func indexDocsStmts(docs []*searchDoc) []dbStatement {
+	stmts := make([]dbStatement, 0)
+	vals := make([]interface{}, 0)
+	rows := make([]string, 0)
+	for _, d := range docs {
+		if strings.TrimSpace(d.Text) == "" {
+			continue
+		}
+		stmts = append(stmts, dbStmt(fmt.Sprintf("UPSERT INTO search_docs (%s) VALUES (%s)", searchDocDBKey, searchDocDBIdx), dbVals(d)...))
+		for _, t := range searchTokens(d.Text) {
+			n := len(vals)
+			rows = append(rows, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6))
+			vals = append(vals, d.Show, d.Group, d.Unit, d.Kind, d.ID, t)
+		}
+	}
+	if len(rows) != 0 {
+		// 같은 항목의 다른 글에 이미 있는 토큰일 수 있으므로 UPSERT를 사용한다.
+		stmts = append(stmts, dbStmt("UPSERT INTO search_index (show, grp, unit, kind, id, token) VALUES "+strings.Join(rows, ", "), vals...))
+	}
+	return stmts
+}
+
+// deleteIndexStmts는 한 항목의 글들과 그 토큰을 지우는 dbStatement를 반환한다.
+func deleteIndexStmts(show, kind, id string) []dbStatement {
+	return []dbStatement{
+		dbStmt("DELETE FROM search_docs WHERE show=$1 AND kind=$2 AND id=$3", show, kind, id),
+		dbStmt("DELETE FROM search_index WHERE show=$1 AND kind=$2 AND id=$3", show, kind, id),
+	}
+}
+
+// attrDocs는 커스텀 속성의 값들을 attr.<키> 필드의 글로 만든다.
+func attrDocs(base searchDoc, attrs DBStringMap) []*searchDoc {
+	docs := make([]*searchDoc, 0, len(attrs))
+	for k, v := range attrs {
+		d := base
+		d.Field = "attr." + k
+		d.Text = v
+		docs = append(docs, &d)
+	}
+	return docs
+}
+
+// showIndexStmts는 쇼의 글들을 다시 색인하는 dbStatement를 반환한다.
+func showIndexStmts(s *Show) []dbStatement {
+	base := searchDoc{Show: s.Show, Kind: SearchKindShow, ID: s.Show}
+	notes := base
+	notes.Field = "notes"
+	notes.Text = s.Notes
+	docs := append([]*searchDoc{&notes}, attrDocs(base, s.Attrs)...)
+	return append(deleteIndexStmts(s.Show, SearchKindShow, s.Show), indexDocsStmts(docs)...)
+}
+
+// groupIndexStmts는 그룹의 글들을 다시 색인하는 dbStatement를 반환한다.
+func groupIndexStmts(g *Group) []dbStatement {
+	base := searchDoc{Show: g.Show, Group: g.Group, Kind: SearchKindGroup, ID: g.ID()}
+	notes := base
+	notes.Field = "notes"
+	notes.Text = g.Notes
+	docs := append([]*searchDoc{&notes}, attrDocs(base, g.Attrs)...)
+	return append(deleteIndexStmts(g.Show, SearchKindGroup, g.ID()), indexDocsStmts(docs)...)
+}
+
+// unitIndexStmts는 유닛의 글들을 다시 색인하는 dbStatement를 반환한다.
+func unitIndexStmts(u *Unit) []dbStatement {
+	base := searchDoc{Show: u.Show, Group: u.Group, Unit: u.Unit, Kind: SearchKindUnit, ID: u.ID()}
+	desc := base
+	desc.Field = "description"
+	desc.Text = u.Description
+	cgDesc := base
+	cgDesc.Field = "cg_description"
+	cgDesc.Text = u.CGDescription
+	docs := append([]*searchDoc{&desc, &cgDesc}, attrDocs(base, u.Attrs)...)
+	return append(deleteIndexStmts(u.Show, SearchKindUnit, u.ID()), indexDocsStmts(docs)...)
+}
+
+// reviewIndexStmts는 리뷰 메시지를 색인하는 dbStatement를 반환한다.
+// 리뷰는 버전 단위로 찾으며, 한 버전의 리뷰들은 작성 시간을 필드로 구분한다.
+func reviewIndexStmts(r *Review) []dbStatement {
+	d := &searchDoc{
+		Show:  r.Show,
+		Group: r.Group,
+		Unit:  r.Unit,
+		Kind:  SearchKindReview,
+		ID:    r.Show + "/" + r.Group + "/" + r.Unit + "/" + r.Task + "/" + r.Version,
+		Field: r.Created.UTC().Format(time.RFC3339Nano),
+		Text:  r.Msg,
+	}
+	return indexDocsStmts([]*searchDoc{d})
+}
+
+// TextSearchHit은 본문 검색에서 찾은 항목 하나이다.
+type TextSearchHit struct {
+	Kind string
+	ID   string
+	// Fields는 항목의 글 중 검색어의 단어가 들어있는 글들이다.
+	Fields []*TextSearchField
+}
+
+// TextSearchField는 본문 검색에서 찾은 항목의 글 하나이다.
+type TextSearchField struct {
+	Field string
+	Text  string
+}
+
+// Link는 검색된 항목을 볼 수 있는 페이지의 주소이다.
+// 리뷰는 그 리뷰가 달린 버전의 페이지를 가리킨다.
+func (h *TextSearchHit) Link() string {
+	switch h.Kind {
+	case SearchKindShow:
+		return "/update-show?id=" + h.ID
+	case SearchKindGroup:
+		return "/update-group?id=" + h.ID
+	case SearchKindUnit:
+		return "/update-unit?id=" + h.ID
+	case SearchKindReview:
+		return "/update-version?id=" + h.ID
+	}
+	return ""
+}
+
+// SearchText는 쇼에서 검색어의 모든 단어를 포함하는 항목들을 찾는다.
+// 단어들은 항목의 여러 글에 나뉘어 있어도 된다.
+// 결과는 쇼, 그룹, 유닛, 리뷰 순으로, 같은 종류 안에서는 아이디 순으로 정렬된다.
+func SearchText(db *sql.DB, show, query string) ([]*TextSearchHit, error) {
+	toks := searchTokens(query)
+	if len(toks) == 0 {
+		return nil, BadRequest("need words to search")
+	}
+	vals := []interface{}{show}
+	ps := make([]string, 0, len(toks))
+	for _, t := range toks {
+		vals = append(vals, t)
+		ps = append(ps, fmt.Sprintf("$%d", len(vals)))
+	}
+	vals = append(vals, len(toks))
+	stmt := dbStmt(fmt.Sprintf(`SELECT %s FROM search_docs WHERE show=$1 AND (kind, id) IN (
+		SELECT kind, id FROM search_index WHERE show=$1 AND token IN (%s)
+		GROUP BY kind, id HAVING count(token) = $%d LIMIT %d
+	)`, searchDocDBKey, strings.Join(ps, ", "), len(vals), maxSearchHits), vals...)
+	want := make(map[string]bool)
+	for _, t := range toks {
+		want[t] = true
+	}
+	hits := make(map[string]*TextSearchHit)
+	err := dbQuery(db, stmt, func(rows *sql.Rows) error {
+		d := &searchDoc{}
+		err := scan(rows, d)
+		if err != nil {
+			return err
+		}
+		key := d.Kind + " " + d.ID
+		h := hits[key]
+		if h == nil {
+			h = &TextSearchHit{Kind: d.Kind, ID: d.ID}
+			hits[key] = h
+		}
+		for _, t := range searchTokens(d.Text) {
+			if want[t] {
+				h.Fields = append(h.Fields, &TextSearchField{Field: d.Field, Text: d.Text})
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	hs := make([]*TextSearchHit, 0, len(hits))
+	for _, h := range hits {
+		sort.Slice(h.Fields, func(i, j int) bool {
+			return h.Fields[i].Field < h.Fields[j].Field
+		})
+		hs = append(hs, h)
+	}
+	sort.Slice(hs, func(i, j int) bool {
+		if hs[i].Kind != hs[j].Kind {
+			return searchKindOrder[hs[i].Kind] < searchKindOrder[hs[j].Kind]
+		}
+		return hs[i].ID < hs[j].ID
+	})
+	return hs, nil
+}
+
+// RebuildSearchIndex는 쇼의 본문 검색 색인을 지우고 처음부터 다시 만든다.
+// 본문 검색이 추가되기 전에 만들어진 항목을 색인할 때 사용한다.
+func RebuildSearchIndex(db *sql.DB, show string) error {
+	s, err := GetShow(db, show)
+	if err != nil {
+		return err
+	}
+	stmts := []dbStatement{
+		dbStmt("DELETE FROM search_docs WHERE show=$1", show),
+		dbStmt("DELETE FROM search_index WHERE show=$1", show),
+	}
+	stmts = append(stmts, showIndexStmts(s)...)
+	err = dbExec(db, stmts)
+	if err != nil {
+		return err
+	}
+	grps, err := ShowGroups(db, show)
+	if err != nil {
+		return err
+	}
+	for _, g := range grps {
+		err = dbExec(db, groupIndexStmts(g))
+		if err != nil {
+			return err
+		}
+	}
+	units, err := searchUnits(db, show, &UnitQuery{})
+	if err != nil {
+		return err
+	}
+	for _, u := range units {
+		err = dbExec(db, unitIndexStmts(u))
+		if err != nil {
+			return err
+		}
+	}
+	reviews, err := ShowReviews(db, show)
+	if err != nil {
+		return err
+	}
+	for _, r := range reviews {
+		err = dbExec(db, reviewIndexStmts(r))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package roi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchTokens(t *testing.T) {
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{text: "", want: []string{}},
+		{text: "Explosion is TOO small", want: []string{"explosion", "is", "too", "small"}},
+		{text: "폭발이 너무 작아요", want: []string{"폭발", "발이", "너무", "작아", "아요"}},
+		{text: "펑 소리", want: []string{"펑", "소리"}},
+		{text: "CG_0010의 3D작업, 3d", want: []string{"cg", "0010", "의", "3d", "작업"}},
+	}
+	for _, c := range cases {
+		got := searchTokens(c.text)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%q: got %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+func TestSearchText(t *testing.T) {
+	db, err := testDB()
+	if err != nil {
+		t.Fatalf("could not connect to database: %v", err)
+	}
+	err = AddSite(db)
+	if err != nil {
+		t.Fatalf("could not add site: %s", err)
+	}
+	defer func() {
+		err := DeleteSite(db)
+		if err != nil {
+			t.Fatalf("could not delete site: %s", err)
+		}
+	}()
+	err = AddShow(db, testShow)
+	if err != nil {
+		t.Fatalf("could not add show: %s", err)
+	}
+	defer func() {
+		err = DeleteShow(db, testShow.ID())
+		if err != nil {
+			t.Fatalf("could not delete show: %s", err)
+		}
+	}()
+	err = AddGroup(db, testGroup)
+	if err != nil {
+		t.Fatalf("could not add group: %s", err)
+	}
+	defer func() {
+		err = DeleteGroup(db, testGroup.Show, testGroup.Group)
+		if err != nil {
+			t.Fatalf("could not delete group: %s", err)
+		}
+	}()
+	// 다른 테스트와 공유하는 유닛이므로 복사해서 쓴다.
+	u := *testUnitA
+	err = AddUnit(db, &u)
+	if err != nil {
+		t.Fatalf("could not add unit: %s", err)
+	}
+	defer func() {
+		err = DeleteUnit(db, u.Show, u.Group, u.Unit)
+		if err != nil {
+			t.Fatalf("could not delete unit: %s", err)
+		}
+	}()
+
+	hits, err := SearchText(db, testShow.Show, "조명판 사람")
+	if err != nil {
+		t.Fatalf("could not search text: %s", err)
+	}
+	if len(hits) != 1 || hits[0].Kind != SearchKindUnit || hits[0].ID != u.ID() {
+		t.Fatalf("got %v, want unit %s", hits, u.ID())
+	}
+	if len(hits[0].Fields) != 1 || hits[0].Fields[0].Field != "cg_description" {
+		t.Fatalf("got fields %v, want cg_description", hits[0].Fields)
+	}
+	// 설명이 바뀌면 색인도 바뀌어야 한다.
+	u.CGDescription = "폭발이 너무 작아요."
+	err = UpdateUnit(db, &u)
+	if err != nil {
+		t.Fatalf("could not update unit: %s", err)
+	}
+	hits, err = SearchText(db, testShow.Show, "조명판")
+	if err != nil {
+		t.Fatalf("could not search text: %s", err)
+	}
+	if len(hits) != 0 {
+		t.Fatalf("got %v after update, want none", hits)
+	}
+	hits, err = SearchText(db, testShow.Show, "폭발 작아")
+	if err != nil {
+		t.Fatalf("could not search text: %s", err)
+	}
+	if len(hits) != 1 || hits[0].ID != u.ID() {
+		t.Fatalf("got %v, want unit %s", hits, u.ID())
+	}
+	// 커스텀 속성 값도 찾는다.
+	hits, err = SearchText(db, testShow.Show, "00:00:05:12")
+	if err != nil {
+		t.Fatalf("could not search text: %s", err)
+	}
+	if len(hits) != 1 || hits[0].Fields[0].Field != "attr.timecode_out" {
+		t.Fatalf("got %v, want attr.timecode_out of %s", hits, u.ID())
+	}
+	err = RebuildSearchIndex(db, testShow.Show)
+	if err != nil {
+		t.Fatalf("could not rebuild search index: %s", err)
+	}
+	hits, err = SearchText(db, testShow.Show, "폭발")
+	if err != nil {
+		t.Fatalf("could not search text: %s", err)
+	}
+	if len(hits) != 1 {
+		t.Fatalf("got %d hits after rebuild, want 1", len(hits))
+	}
+	_, err = SearchText(db, testShow.Show, "  ...  ")
+	if err == nil {
+		t.Fatalf("want error when searching without words, got nil")
+	}
+}
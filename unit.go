@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -18,6 +19,10 @@ var CreateTableIfNotExistsUnitsStmt = `CREATE TABLE IF NOT EXISTS units (
 	unit STRING NOT NULL CHECK (length(unit) > 0) CHECK (unit NOT LIKE '% %'),
 	status STRING NOT NULL CHECK (length(status) > 0),
 	edit_order INT NOT NULL,
+	cut_in INT NOT NULL DEFAULT 0,
+	cut_out INT NOT NULL DEFAULT 0,
+	head_handle INT NOT NULL DEFAULT 0,
+	tail_handle INT NOT NULL DEFAULT 0,
 	description STRING NOT NULL,
 	cg_description STRING NOT NULL,
 	tags STRING[] NOT NULL,
@@ -27,10 +32,21 @@ var CreateTableIfNotExistsUnitsStmt = `CREATE TABLE IF NOT EXISTS units (
 	end_date TIMESTAMPTZ NOT NULL,
 	due_date TIMESTAMPTZ NOT NULL,
 	attrs STRING NOT NULL,
+	typed_attrs JSONB NOT NULL DEFAULT '{}',
 	UNIQUE(show, grp, unit),
 	CONSTRAINT units_pk PRIMARY KEY (show, grp, unit)
 )`
 
+// AlterTableUnitsStmts는 이전 버전에서 만들어진 units 테이블에
+// 새로 추가된 열을 더하는 sql 구문들이다. 여러번 실행해도 안전하다.
+var AlterTableUnitsStmts = []string{
+	"ALTER TABLE units ADD COLUMN IF NOT EXISTS cut_in INT NOT NULL DEFAULT 0",
+	"ALTER TABLE units ADD COLUMN IF NOT EXISTS cut_out INT NOT NULL DEFAULT 0",
+	"ALTER TABLE units ADD COLUMN IF NOT EXISTS head_handle INT NOT NULL DEFAULT 0",
+	"ALTER TABLE units ADD COLUMN IF NOT EXISTS tail_handle INT NOT NULL DEFAULT 0",
+	"ALTER TABLE units ADD COLUMN IF NOT EXISTS typed_attrs JSONB NOT NULL DEFAULT '{}'",
+}
+
 type Unit struct {
 	Show  string `db:"show"`
 	Group string `db:"grp"` // group이 sql 구문이기 때문에 줄여서 씀.
@@ -43,10 +59,18 @@ type Unit struct {
 	CGDescription string   `db:"cg_description"`
 	Tags          []string `db:"tags"`
 
+	// CutIn과 CutOut은 편집본에 쓰이는 구간의 첫 프레임과 마지막 프레임이다.
+	// 둘 다 0이면 프레임 구간이 정해지지 않은 것이다.
+	CutIn  int `db:"cut_in"`
+	CutOut int `db:"cut_out"`
+	// HeadHandle과 TailHandle은 컷 구간 앞뒤로 여유를 두고 작업할 프레임 수이다.
+	HeadHandle int `db:"head_handle"`
+	TailHandle int `db:"tail_handle"`
+
 	// Assets는 샷이 필요로 하는 애셋 이름 리스트이다.
-	// 현재는 애셋이 같은 쇼 안에 존재할 때만 처리가 가능하다.
+	// 같은 쇼의 애셋은 그룹/유닛, 사이트 라이브러리 쇼의 애셋은 쇼/그룹/유닛 형식이다.
 	// 여기에 등록된 애셋은 존재해야만 하며,
-	// 애셋이 삭제되기 전 우선 모든 샷의 애셋 태그에서 지워져야 한다.
+	// 애셋이 삭제되기 전 우선 모든 샷의 애셋 태그에서 지워져야 한다. AssetUsers를 참고한다.
 	Assets []string `db:"assets"`
 
 	// Tasks는 샷에 작업중인 어떤 태스크가 있는지를 나타낸다.
@@ -64,6 +88,9 @@ type Unit struct {
 
 	// Attrs는 커스텀 속성으로 db에는 여러줄의 문자열로 저장된다. 각 줄은 키: 값의 쌍이다.
 	Attrs DBStringMap `db:"attrs"`
+	// TypedAttrs는 커스텀 속성을 정의된 타입의 값으로 저장한 것으로, db에서 비교할 때 쓴다.
+	// 유닛을 저장할 때 Attrs로부터 다시 만들어진다.
+	TypedAttrs DBJSONMap `db:"typed_attrs"`
 }
 
 var unitDBKey string = strings.Join(dbKeys(&Unit{}), ", ")
@@ -75,6 +102,12 @@ func (s *Unit) ID() string {
 	return s.Show + "/" + s.Group + "/" + s.Unit
 }
 
+// Duration은 컷 인부터 컷 아웃까지, 두 프레임을 포함한 길이를 반환한다.
+// 프레임 구간이 정해지지 않았다면 0을 반환한다.
+func (s *Unit) Duration() int {
+	return cutDuration(s.CutIn, s.CutOut)
+}
+
 // SplitUnitID는 받아들인 샷 아이디를 쇼, 샷으로 분리해서 반환한다.
 // 만일 샷 아이디가 유효하지 않다면 에러를 반환한다.
 func SplitUnitID(id string) (string, string, string, error) {
@@ -152,15 +185,27 @@ func verifyUnit(db *sql.DB, s *Unit) error {
 	if err != nil {
 		return err
 	}
-	err = verifyUnitStatus(s.Status)
+	si, err := GetSite(db)
 	if err != nil {
 		return err
 	}
-	// 태스크에는 순서가 있으므로 사이트에 정의된 순서대로 재정렬한다.
-	si, err := GetSite(db)
+	// 사이트의 상태 정의가 바뀌기 전에 저장된 유닛은 더 이상 정의되지 않은
+	// 상태를 가질 수 있다. 상태를 바꾸지 않는다면 다른 수정을 막지 않는다.
+	oldStatus, stored, err := storedStatus(db, "units", "show=$1 AND grp=$2 AND unit=$3", s.Show, s.Group, s.Unit)
 	if err != nil {
 		return err
 	}
+	if !stored || s.Status != Status(oldStatus) {
+		err = verifyUnitStatus(si, s.Status)
+		if err != nil {
+			return err
+		}
+	}
+	err = verifyUnitFrames(s)
+	if err != nil {
+		return err
+	}
+	// 태스크에는 순서가 있으므로 사이트에 정의된 순서대로 재정렬한다.
 	hasTask := make(map[string]bool)
 	taskIdx := make(map[string]int)
 	for i, task := range si.Tasks {
@@ -175,109 +220,174 @@ func verifyUnit(db *sql.DB, s *Unit) error {
 	sort.Slice(s.Tasks, func(i, j int) bool {
 		return taskIdx[s.Tasks[i]] <= taskIdx[s.Tasks[j]]
 	})
-	sort.Slice(s.Assets, func(i, j int) bool {
-		return strings.Compare(s.Assets[i], s.Assets[j]) <= 0
-	})
-	for _, asset := range s.Assets {
-		a := strings.Split(asset, "/")
-		if len(a) != 2 {
-			return BadRequest("need asset name as group/unit")
+	for i, asset := range s.Assets {
+		show, grp, unit, err := resolveAssetRef(s.Show, si.LibraryShow, asset)
+		if err != nil {
+			return err
 		}
-		grp := a[0]
-		unit := a[1]
-		_, err := GetUnit(db, s.Show, grp, unit)
+		_, err = GetUnit(db, show, grp, unit)
 		if err != nil {
 			return err
 		}
+		s.Assets[i] = assetRef(s.Show, show, grp, unit)
 	}
+	sort.Slice(s.Assets, func(i, j int) bool {
+		return strings.Compare(s.Assets[i], s.Assets[j]) <= 0
+	})
 	sort.Slice(s.Tags, func(i, j int) bool {
 		return strings.Compare(s.Tags[i], s.Tags[j]) <= 0
 	})
-	if len(s.Tags) != 0 {
-		// 샷의 태그가 쇼에 이미 생성되어 있는 태그가 아니라면 쇼에 추가한다.
-		//
-		// 할일: 이 함수에서 쇼를 고치는 것이 맞는 방식이란 생각이 들지는 않는다.
-		// 나중에 각각의 속성을 독립적으로 수정할 수 있게 하게 되면 여기서 이동 시킨다.
-		sh, err := GetShow(db, s.Show)
-		if err != nil {
-			return err
-		}
-		showTag := make(map[string]bool)
-		for _, t := range sh.Tags {
+	sh, err := GetShow(db, s.Show)
+	if err != nil {
+		return err
+	}
+	old, err := storedAttrs(db, "units", "show=$1 AND grp=$2 AND unit=$3", s.Show, s.Group, s.Unit)
+	if err != nil {
+		return err
+	}
+	s.TypedAttrs, err = verifyAttrs(db, attrDefs(si, sh, AttrKindUnit), &s.Attrs, old)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// verifyUnitFrames는 유닛의 프레임 구간과 핸들이 유효하지 않다면 에러를 반환한다.
+func verifyUnitFrames(s *Unit) error {
+	if s.CutIn < 0 || s.CutOut < 0 {
+		return BadRequest("cut in/out should not be negative: %d-%d", s.CutIn, s.CutOut)
+	}
+	if s.CutOut < s.CutIn {
+		return BadRequest("cut out should not be smaller than cut in: %d-%d", s.CutIn, s.CutOut)
+	}
+	if s.HeadHandle < 0 || s.TailHandle < 0 {
+		return BadRequest("handles should not be negative: %d, %d", s.HeadHandle, s.TailHandle)
+	}
+	return nil
+}
+
+// addShowTagsStmts는 유닛의 태그 중 쇼에 아직 등록되지 않은 태그가 있다면
+// 쇼에 추가하는 dbStatement를 반환한다. 추가할 태그가 없다면 빈 슬라이스를 반환한다.
+//
+// 여러 유닛을 한 트랜잭션에서 수정할 때는 유닛들의 태그를 모아 한번만 호출해야 한다.
+// 그렇지 않으면 나중 구문이 앞선 구문에서 추가한 태그를 덮어쓴다.
+func addShowTagsStmts(db *sql.DB, show string, tags []string) ([]dbStatement, error) {
+	if len(tags) == 0 {
+		return []dbStatement{}, nil
+	}
+	sh, err := GetShow(db, show)
+	if err != nil {
+		return nil, err
+	}
+	showTag := make(map[string]bool)
+	for _, t := range sh.Tags {
+		showTag[t] = true
+	}
+	updateShowTag := false
+	for _, t := range tags {
+		if !showTag[t] {
 			showTag[t] = true
-		}
-		updateShowTag := false
-		for _, t := range s.Tags {
-			if !showTag[t] {
-				showTag[t] = true
-				updateShowTag = true
-			}
-		}
-		if updateShowTag {
-			tags := make([]string, 0, len(showTag))
-			for t := range showTag {
-				tags = append(tags, t)
-			}
-			sh.Tags = tags
-			err := UpdateShow(db, sh)
-			if err != nil {
-				return err
-			}
+			updateShowTag = true
 		}
 	}
-	return nil
+	if !updateShowTag {
+		return []dbStatement{}, nil
+	}
+	sh.Tags = make([]string, 0, len(showTag))
+	for t := range showTag {
+		sh.Tags = append(sh.Tags, t)
+	}
+	sort.Strings(sh.Tags)
+	stmts := []dbStatement{
+		dbStmt(fmt.Sprintf("UPDATE shows SET (%s) = (%s) WHERE show='%s'", showDBKey, showDBIdx, sh.Show), dbVals(sh)...),
+	}
+	return stmts, nil
 }
 
 // AddUnit은 db의 특정 프로젝트에 샷을 하나 추가한다.
 func AddUnit(db *sql.DB, s *Unit) error {
-	err := verifyUnit(db, s)
+	stmts, err := addUnitStmts(db, s)
 	if err != nil {
 		return err
 	}
+	st, err := addShowTagsStmts(db, s.Show, s.Tags)
+	if err != nil {
+		return err
+	}
+	if len(st) != 0 {
+		defer invalidateShowCache(s.Show)
+	}
+	stmts = append(stmts, st...)
+	return dbExec(db, stmts)
+}
+
+// newUnitTask는 유닛에 태스크가 새로 등록될 때 만들어지는 태스크를 반환한다.
+// 태스크의 상태는 사이트 워크플로우의 시작 상태이다.
+func newUnitTask(db *sql.DB, s *Unit, task string) (*Task, error) {
+	w, err := SiteWorkflow(db)
+	if err != nil {
+		return nil, err
+	}
+	t := &Task{
+		Show:    s.Show,
+		Group:   s.Group,
+		Unit:    s.Unit,
+		Task:    task,
+		Status:  w.Start,
+		DueDate: time.Time{},
+	}
+	return t, nil
+}
+
+// addUnitStmts는 유닛과 그 하위 태스크를 추가하는 dbStatement를 반환한다.
+// 유닛의 태그를 쇼에 추가하는 구문은 포함하지 않는다. addShowTagsStmts를 참고한다.
+func addUnitStmts(db *sql.DB, s *Unit) ([]dbStatement, error) {
+	err := verifyUnit(db, s)
+	if err != nil {
+		return nil, err
+	}
 	if len(s.Tasks) == 0 {
 		g, err := GetGroup(db, s.Show, s.Group)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		s.Tasks = g.DefaultTasks
 	}
 	// 부모가 있는지 검사
 	_, err = GetGroup(db, s.Show, s.Group)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// 이미 존재하는 유닛인지 검사
 	_, err = GetUnit(db, s.Show, s.Group, s.Unit)
 	if err == nil {
-		return BadRequest("unit already exist: %s", s.ID())
+		return nil, BadRequest("unit already exist: %s", s.ID())
 	}
 	if !errors.As(err, &NotFoundError{}) {
-		return err
+		return nil, err
 	}
 	stmts := []dbStatement{
 		dbStmt(fmt.Sprintf("INSERT INTO units (%s) VALUES (%s)", unitDBKey, unitDBIdx), dbVals(s)...),
 	}
+	stmts = append(stmts, unitIndexStmts(s)...)
+	stmts = append(stmts, assetRefStmts(s)...)
 	// 하위 태스크 생성
 	for _, task := range s.Tasks {
-		t := &Task{
-			Show:    s.Show,
-			Group:   s.Group,
-			Unit:    s.Unit,
-			Task:    task,
-			Status:  StatusInProgress,
-			DueDate: time.Time{},
+		t, err := newUnitTask(db, s, task)
+		if err != nil {
+			return nil, err
 		}
-		err := verifyTask(db, t)
+		err = verifyTask(db, t)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		st, err := addTaskStmts(db, t)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		stmts = append(stmts, st...)
 	}
-	return dbExec(db, stmts)
+	return stmts, nil
 }
 
 // GetUnit은 db에서 하나의 샷을 찾는다.
@@ -287,10 +397,7 @@ func GetUnit(db *sql.DB, show, grp, unit string) (*Unit, error) {
 	if err != nil {
 		return nil, err
 	}
-	_, err = GetGroup(db, show, grp)
-	if err != nil {
-		return nil, err
-	}
+	// 부모는 유닛을 찾지 못했을 때만 검사한다.
 	s := &Unit{}
 	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM units WHERE show=$1 AND grp=$2 AND unit=$3 LIMIT 1", unitDBKey), show, grp, unit)
 	err = dbQueryRow(db, stmt, func(row *sql.Row) error {
@@ -298,6 +405,10 @@ func GetUnit(db *sql.DB, show, grp, unit string) (*Unit, error) {
 	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			_, err = GetGroup(db, show, grp)
+			if err != nil {
+				return nil, err
+			}
 			return nil, NotFound("unit not found: %s", JoinUnitID(show, grp, unit))
 		}
 		return nil, err
@@ -306,190 +417,365 @@ func GetUnit(db *sql.DB, show, grp, unit string) (*Unit, error) {
 }
 
 // SearchUnits는 db의 특정 프로젝트에서 검색 조건에 맞는 샷 리스트를 반환한다.
-func SearchUnits(db *sql.DB, show string, grps, units []string, tag, status, task, assignee, task_status string, task_due_date time.Time) ([]*Unit, error) {
+// duration은 >100, <=48 처럼 비교 연산자와 프레임 수로 된 조건이며,
+// 연산자 없이 숫자만 있다면 길이가 같은 유닛을 찾는다.
+// 프레임 구간이 정해지지 않은 유닛은 duration 조건에 맞지 않는다.
+// task_due_date는 날짜 단위로 비교한다.
+func SearchUnits(db *sql.DB, show string, grps, units []string, tag, status, duration, task, assignee, task_status string, task_due_date time.Time) ([]*Unit, error) {
+	q := &UnitQuery{}
+	// add는 값이 비어있지 않은 필터를 검색어에 추가한다.
+	add := func(field, op string, vals ...string) {
+		vs := make([]string, 0, len(vals))
+		for _, v := range vals {
+			if v != "" {
+				vs = append(vs, v)
+			}
+		}
+		if len(vs) != 0 {
+			q.Terms = append(q.Terms, &QueryTerm{Field: field, Op: op, Values: vs})
+		}
+	}
+	add("group", ":", grps...)
+	add("unit", ":", units...)
+	add("tag", ":", tag)
+	add("status", ":", status)
+	if duration != "" {
+		op, n, err := parseIntCondition(duration)
+		if err != nil {
+			return nil, err
+		}
+		if op == "=" {
+			op = ":"
+		}
+		add("duration", op, strconv.Itoa(n))
+	}
+	add("task", ":", task)
+	add("assignee", ":", assignee)
+	add("task-status", ":", task_status)
+	if !task_due_date.IsZero() {
+		add("due", ":", task_due_date.Format("2006-01-02"))
+	}
+	return searchUnits(db, show, q)
+}
+
+// searchUnits는 db의 특정 프로젝트에서 검색어에 맞는 샷 리스트를
+// 그룹, 유닛 이름 순으로 정렬해 반환한다.
+func searchUnits(db *sql.DB, show string, q *UnitQuery) ([]*Unit, error) {
+	res, err := searchUnitsPage(db, show, q, UnitSearchOptions{}, false)
+	if err != nil {
+		return nil, err
+	}
+	return res.Units, nil
+}
+
+// UnitSearchOptions는 유닛 검색 결과의 정렬과 페이지를 정한다.
+type UnitSearchOptions struct {
+	// Sort는 정렬 기준으로 UnitSortKeys 중 하나이거나 task.<태스크>.status,
+	// task.<태스크>.assignee 형식이다. 비어있으면 이름 순으로 정렬한다.
+	Sort string
+	Desc bool
+	// Offset은 결과에서 건너뛸 유닛 수이다.
+	Offset int
+	// Limit은 반환할 최대 유닛 수이다. 0이면 모두 반환한다.
+	Limit int
+}
+
+// UnitSortKeys는 태스크와 상관없는 유닛 정렬 기준들이다.
+var UnitSortKeys = []string{"name", "edit-order", "due", "status"}
+
+// UnitSearchResult는 유닛 검색 결과의 한 페이지이다.
+type UnitSearchResult struct {
+	Units []*Unit
+	// Total은 페이지와 상관없이 검색어에 맞는 전체 유닛 수이다.
+	Total  int
+	Offset int
+	Limit  int
+}
+
+// ParseUnitSort는 - 로 시작하면 내림차순인 정렬 문자열을 정렬 기준과 내림차순 여부로 나눈다.
+// 예) -due, task.comp.status
+func ParseUnitSort(s string) (string, bool, error) {
+	desc := strings.HasPrefix(s, "-")
+	key := strings.TrimPrefix(s, "-")
+	_, _, err := unitSortExprs(key, &queryArgs{}, nil, nil)
+	if err != nil {
+		return "", false, err
+	}
+	return key, desc, nil
+}
+
+// statusOrderExpr은 상태들을 진행 순서대로 정렬하기 위한 sql 표현식이다.
+// 목록에 없는 상태는 뒤에 온다.
+func statusOrderExpr(col string, all []Status, args *queryArgs) string {
+	expr := "CASE " + col
+	for i, s := range all {
+		expr += fmt.Sprintf(" WHEN %s THEN %d", args.add(string(s)), i)
+	}
+	return expr + fmt.Sprintf(" ELSE %d END", len(all))
+}
+
+// unitSortExprs는 정렬 기준에 맞는 ORDER BY 표현식들을 반환한다.
+// 태스크로 정렬한다면 그 태스크를 JOIN하는 구문을 함께 반환한다.
+// 상태는 unitStatus, taskStatus의 순서대로 정렬되며, 이들은 사이트의 상태 정의와
+// 워크플로우에서 가지고 온다. 정렬 기준을 검사만 할 때는 nil이어도 된다.
+// 반환되는 표현식에 방향은 붙어있지 않다.
+func unitSortExprs(key string, args *queryArgs, unitStatus, taskStatus []Status) (string, []string, error) {
+	switch key {
+	case "", "name":
+		return "", []string{}, nil
+	case "edit-order":
+		return "", []string{"units.edit_order"}, nil
+	case "due":
+		return "", []string{"units.due_date"}, nil
+	case "status":
+		return "", []string{statusOrderExpr("units.status", unitStatus, args)}, nil
+	}
+	if strings.HasPrefix(key, "task.") {
+		i := strings.LastIndex(key, ".")
+		task := key[len("task."):i]
+		field := key[i+1:]
+		if i > len("task.") && verifyTaskName(task) == nil {
+			join := "LEFT JOIN tasks AS sort_task ON (sort_task.show=units.show AND sort_task.grp=units.grp AND sort_task.unit=units.unit AND sort_task.task=" + args.add(task) + ")"
+			switch field {
+			case "status":
+				return join, []string{statusOrderExpr("sort_task.status", taskStatus, args)}, nil
+			case "assignee":
+				return join, []string{"sort_task.assignee"}, nil
+			}
+		}
+	}
+	return "", nil, BadRequest("invalid unit sort: %s", key)
+}
+
+// SearchUnitsPage는 db의 특정 프로젝트에서 검색어에 맞는 유닛들을 정렬해 한 페이지를 반환한다.
+// 정렬 기준이 같은 유닛들은 그룹, 유닛 이름 순으로 정렬된다.
+func SearchUnitsPage(db *sql.DB, show, query string, opt UnitSearchOptions) (*UnitSearchResult, error) {
+	q, err := ParseUnitQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return searchUnitsPage(db, show, q, opt, true)
+}
+
+// searchUnitsPage는 검색어에 맞는 유닛들을 정렬해 한 페이지를 반환한다.
+// count가 참이면 전체 유닛 수를 따로 세어 Total에 넣고, 아니라면 반환하는 유닛 수를 넣는다.
+func searchUnitsPage(db *sql.DB, show string, q *UnitQuery, opt UnitSearchOptions, count bool) (*UnitSearchResult, error) {
+	if opt.Offset < 0 || opt.Limit < 0 {
+		return nil, BadRequest("negative offset or limit: %d, %d", opt.Offset, opt.Limit)
+	}
 	keys := ""
 	for i, k := range dbKeys(&Unit{}) {
 		if i != 0 {
 			keys += ", "
 		}
-		// 태스크에 있는 정보를 찾기 위해 JOIN 해야 할 경우가 있기 때문에
-		// units. 을 붙인다.
 		keys += "units." + k
 	}
-	where := make([]string, 0)
-	vals := make([]interface{}, 0)
-	i := 1 // 인덱스가 1부터 시작이다.
-	stmt := fmt.Sprintf("SELECT %s FROM units", keys)
-	where = append(where, fmt.Sprintf("units.show=$%d", i))
-	vals = append(vals, show)
-	i++
-	if len(grps) != 0 {
-		whereGroups := "units.grp IN ("
-		j := 0
-		for _, grp := range grps {
-			if grp == "" {
-				continue
-			}
-			if j != 0 {
-				whereGroups += ","
-			}
-			whereGroups += fmt.Sprintf("$%d", i)
-			vals = append(vals, grp)
-			i++
-			j++
+	where, vals := q.sqlWhere(show)
+	args := &queryArgs{vals: vals}
+	var unitStatus, taskStatus []Status
+	if opt.Sort == "status" || strings.HasSuffix(opt.Sort, ".status") {
+		// 상태는 사이트에 정의된 순서대로 정렬한다.
+		site, wf, err := siteWorkflow(db)
+		if err != nil {
+			return nil, err
 		}
-		whereGroups += ")"
-		where = append(where, whereGroups)
-	}
-	if len(units) != 0 {
-		j := 0
-		whereUnits := "("
-		for _, unit := range units {
-			if unit == "" {
-				continue
-			}
-			if j != 0 {
-				whereUnits += " OR "
-			}
-			tok := strings.SplitN(unit, "/", 2)
-			var grp string
-			if len(tok) == 2 {
-				grp = tok[0]
-				unit = tok[1]
-			}
-			if grp != "" {
-				whereUnits += fmt.Sprintf("(units.grp=$%d AND units.unit=$%d)", i, i+1)
-				vals = append(vals, grp, unit)
-				i += 2
-			} else {
-				whereUnits += fmt.Sprintf("units.unit=$%d", i)
-				vals = append(vals, unit)
-				i++
-			}
-			j++
+		for _, d := range site.StatusDefs() {
+			unitStatus = append(unitStatus, Status(d.Status))
 		}
-		whereUnits += ")"
-		where = append(where, whereUnits)
+		taskStatus = wf.Statuses()
 	}
-	if tag != "" {
-		where = append(where, fmt.Sprintf("$%d::string = ANY(units.tags)", i))
-		vals = append(vals, tag)
-		i++
+	join, exprs, err := unitSortExprs(opt.Sort, args, unitStatus, taskStatus)
+	if err != nil {
+		return nil, err
 	}
-	if status != "" {
-		where = append(where, fmt.Sprintf("units.status=$%d", i))
-		vals = append(vals, status)
-		i++
+	dir := ""
+	if opt.Desc {
+		dir = " DESC"
 	}
-	if task != "" {
-		where = append(where, fmt.Sprintf("$%d::string = ANY(units.tasks)", i))
-		vals = append(vals, task)
-		i++
+	order := make([]string, 0)
+	if opt.Sort == "due" {
+		// 마감일이 정해지지 않은 유닛은 방향과 상관없이 뒤에 둔다.
+		order = append(order, "(units.due_date = "+args.add(time.Time{})+")")
 	}
-	if assignee != "" || task_status != "" || !task_due_date.IsZero() {
-		stmt += " JOIN tasks ON (tasks.show = units.show AND tasks.unit = units.unit)"
+	if join != "" {
+		// 태스크가 없는 유닛은 방향과 상관없이 뒤에 둔다.
+		order = append(order, "(sort_task.task IS NULL)")
 	}
-	if assignee != "" {
-		where = append(where, fmt.Sprintf("tasks.assignee=$%d", i))
-		vals = append(vals, assignee)
-		i++
+	for _, e := range exprs {
+		order = append(order, e+dir)
 	}
-	if task_status != "" {
-		where = append(where, fmt.Sprintf("tasks.status=$%d", i))
-		vals = append(vals, task_status)
-		i++
+	if len(exprs) == 0 {
+		order = append(order, "units.grp"+dir, "units.unit"+dir)
+	} else {
+		order = append(order, "units.grp", "units.unit")
 	}
-	if !task_due_date.IsZero() {
-		where = append(where, fmt.Sprintf("tasks.due_date=$%d", i))
-		vals = append(vals, task_due_date)
-		i++
+	stmt := fmt.Sprintf("SELECT %s FROM units %s WHERE %s ORDER BY %s", keys, join, where, strings.Join(order, ", "))
+	if opt.Limit != 0 {
+		stmt += fmt.Sprintf(" LIMIT %d", opt.Limit)
 	}
-	wherestr := strings.Join(where, " AND ")
-	if wherestr != "" {
-		stmt += " WHERE " + wherestr
+	if opt.Offset != 0 {
+		stmt += fmt.Sprintf(" OFFSET %d", opt.Offset)
 	}
-	st := dbStmt(stmt, vals...)
+	st := dbStmt(stmt, args.vals...)
 	ss := make([]*Unit, 0)
-	hasUnit := make(map[string]bool)
-	err := dbQuery(db, st, func(rows *sql.Rows) error {
-		// 태스크 검색을 해 JOIN이 되면 샷이 중복으로 추가될 수 있다.
-		// DISTINCT를 이용해 문제를 해결하려고 했으나 DB가 꺼진다.
-		// 우선은 여기서 걸러낸다.
+	err = dbQuery(db, st, func(rows *sql.Rows) error {
 		s := &Unit{}
 		err := scan(rows, s)
 		if err != nil {
 			return err
 		}
-		if !hasUnit[s.Group+"/"+s.Unit] {
-			hasUnit[s.Group+"/"+s.Unit] = true
-			ss = append(ss, s)
-		}
+		ss = append(ss, s)
 		return nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("search units: %s: %w", st, err)
 	}
-	sort.Slice(ss, func(i int, j int) bool {
-		if ss[i].Group < ss[j].Group {
-			return true
-		} else if ss[i].Group > ss[j].Group {
-			return false
+	res := &UnitSearchResult{
+		Units:  ss,
+		Total:  len(ss),
+		Offset: opt.Offset,
+		Limit:  opt.Limit,
+	}
+	if count {
+		st := dbStmt(fmt.Sprintf("SELECT count(*) FROM units WHERE %s", where), vals...)
+		err = dbQueryRow(db, st, func(row *sql.Row) error {
+			return row.Scan(&res.Total)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("count units: %s: %w", st, err)
 		}
-		return ss[i].Unit <= ss[j].Unit
-	})
-	return ss, nil
+	}
+	return res, nil
+}
+
+// reIntCondition은 정수 비교 조건을 나타내는 정규식이다. 예) >100, <=48, =24, 24
+var reIntCondition = regexp.MustCompile(`^(>=|<=|>|<|=)?(\d+)$`)
+
+// parseIntCondition은 정수 비교 조건을 sql 비교 연산자와 정수로 나눈다.
+// 연산자가 없다면 = 를 반환한다.
+func parseIntCondition(cond string) (string, int, error) {
+	m := reIntCondition.FindStringSubmatch(cond)
+	if m == nil {
+		return "", 0, BadRequest("invalid integer condition: %s", cond)
+	}
+	op := m[1]
+	if op == "" {
+		op = "="
+	}
+	n, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, BadRequest("invalid integer condition: %s", cond)
+	}
+	return op, n, nil
+}
+
+// SearchUnitsQuery는 유닛 검색 페이지에서 사용하는 검색어로 샷을 검색한다.
+// 검색어의 문법은 unit_query.go를 참고한다.
+// 문법에 맞지 않는 검색어에 대해서는 QueryError를 감싼 BadRequestError를 반환한다.
+func SearchUnitsQuery(db *sql.DB, show, query string) ([]*Unit, error) {
+	q, err := ParseUnitQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return searchUnits(db, show, q)
 }
 
 // UpdateUnit은 db에서 해당 샷을 수정한다.
+// 컷 정보가 바뀌었다면 작성자와 이유 없이 변경 기록을 남긴다.
 func UpdateUnit(db *sql.DB, s *Unit) error {
-	err := verifyUnit(db, s)
+	return UpdateUnitWithReason(db, s, "", "")
+}
+
+// UpdateUnitWithReason은 db에서 해당 샷을 수정한다.
+// 컷 정보가 바뀌었다면 author와 reason을 변경 기록에 함께 남긴다.
+func UpdateUnitWithReason(db *sql.DB, s *Unit, author, reason string) error {
+	stmts, err := updateUnitStmts(db, s, author, reason)
 	if err != nil {
 		return err
 	}
-	_, err = GetUnit(db, s.Show, s.Group, s.Unit)
+	st, err := addShowTagsStmts(db, s.Show, s.Tags)
 	if err != nil {
 		return err
 	}
+	if len(st) != 0 {
+		defer invalidateShowCache(s.Show)
+	}
+	stmts = append(stmts, st...)
+	return dbExec(db, stmts)
+}
+
+// updateUnitStmts는 유닛을 수정하고 새로 등록된 태스크를 추가하는 dbStatement를 반환한다.
+// 컷 정보가 바뀌었다면 author와 reason으로 변경 기록을 추가하는 구문도 포함한다.
+// 유닛의 태그를 쇼에 추가하는 구문은 포함하지 않는다. addShowTagsStmts를 참고한다.
+func updateUnitStmts(db *sql.DB, s *Unit, author, reason string) ([]dbStatement, error) {
+	err := verifyUnit(db, s)
+	if err != nil {
+		return nil, err
+	}
+	return updateVerifiedUnitStmts(db, s, author, reason)
+}
+
+// updateVerifiedUnitStmts는 updateUnitStmts와 같지만 유닛을 검사하지 않는다.
+// 같은 트랜잭션에서 만들어질 애셋을 참조하도록 바꾸는 것처럼
+// 호출자가 미리 verifyUnit으로 검사한 유닛을 수정할 때 사용한다.
+func updateVerifiedUnitStmts(db *sql.DB, s *Unit, author, reason string) ([]dbStatement, error) {
+	old, err := GetUnit(db, s.Show, s.Group, s.Unit)
+	if err != nil {
+		return nil, err
+	}
 	stmts := []dbStatement{
 		dbStmt(fmt.Sprintf("UPDATE units SET (%s) = (%s) WHERE show='%s' AND grp='%s' AND unit='%s'", unitDBKey, unitDBIdx, s.Show, s.Group, s.Unit), dbVals(s)...),
 	}
+	stmts = append(stmts, addCutRevisionStmts(old, s, author, reason)...)
+	stmts = append(stmts, unitIndexStmts(s)...)
+	stmts = append(stmts, assetRefStmts(s)...)
 	// 샷에 등록된 태스크 중 기존에 없었던 태스크가 있다면 생성한다.
 	for _, task := range s.Tasks {
 		_, err := GetTask(db, s.Show, s.Group, s.Unit, task)
 		if err != nil {
 			if !errors.As(err, &NotFoundError{}) {
-				return fmt.Errorf("get task: %s", err)
+				return nil, fmt.Errorf("get task: %s", err)
 			} else {
-				t := &Task{
-					Show:    s.Show,
-					Group:   s.Group,
-					Unit:    s.Unit,
-					Task:    task,
-					Status:  StatusInProgress,
-					DueDate: time.Time{},
+				t, err := newUnitTask(db, s, task)
+				if err != nil {
+					return nil, err
 				}
 				st, err := addTaskStmts(db, t)
 				if err != nil {
-					return err
+					return nil, err
 				}
 				stmts = append(stmts, st...)
 			}
 		}
 	}
-	return dbExec(db, stmts)
+	return stmts, nil
 }
 
 // DeleteUnit은 해당 샷과 그 하위의 모든 데이터를 db에서 지운다.
+// 다른 유닛이 애셋으로 참조하고 있다면 지우지 않고 에러를 반환한다.
+// 참조를 함께 지우려면 DetachAndDeleteUnit을 사용한다.
 // 만일 처리 중간에 에러가 나면 아무 데이터도 지우지 않고 에러를 반환한다.
 func DeleteUnit(db *sql.DB, show, grp, unit string) error {
 	_, err := GetUnit(db, show, grp, unit)
 	if err != nil {
 		return err
 	}
-	stmts := []dbStatement{
+	err = unitMustNotBeReferenced(db, show, grp, unit)
+	if err != nil {
+		return err
+	}
+	return dbExec(db, deleteUnitStmts(show, grp, unit))
+}
+
+// deleteUnitStmts는 유닛과 그 하위의 모든 데이터를 지우는 dbStatement를 반환한다.
+func deleteUnitStmts(show, grp, unit string) []dbStatement {
+	return []dbStatement{
 		dbStmt("DELETE FROM units WHERE show=$1 AND grp=$2 AND unit=$3", show, grp, unit),
 		dbStmt("DELETE FROM tasks WHERE show=$1 AND grp=$2 AND unit=$3", show, grp, unit),
 		dbStmt("DELETE FROM versions WHERE show=$1 AND grp=$2 AND unit=$3", show, grp, unit),
+		dbStmt("DELETE FROM cut_revisions WHERE show=$1 AND grp=$2 AND unit=$3", show, grp, unit),
+		dbStmt("DELETE FROM search_docs WHERE show=$1 AND grp=$2 AND unit=$3", show, grp, unit),
+		dbStmt("DELETE FROM search_index WHERE show=$1 AND grp=$2 AND unit=$3", show, grp, unit),
+		dbStmt("DELETE FROM asset_refs WHERE show=$1 AND grp=$2 AND unit=$3", show, grp, unit),
 	}
-	return dbExec(db, stmts)
 }
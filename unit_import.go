@@ -0,0 +1,500 @@
+package roi
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UnitImport는 표로부터 유닛을 가져오는 계획이다.
+// PlanUnitImport로 만들어 내용을 확인한 후 ApplyUnitImport로 적용한다.
+type UnitImport struct {
+	Rows []*UnitImportRow
+}
+
+// UnitImportRow는 표의 한 줄이 어떤 유닛에 어떻게 적용될지를 나타낸다.
+type UnitImportRow struct {
+	// Line은 표에서의 줄 번호이다. 열 이름이 있는 첫 줄이 1이다.
+	Line int
+	// Show, Group, Unit은 표에 적힌 유닛 아이디 구성요소이다.
+	Show  string
+	Group string
+	Unit  string
+	// New는 db에 없는 새 유닛을 만드는지를 나타낸다.
+	New bool
+	// Changes는 기존 유닛에서 바뀌는 필드들이다. 새 유닛은 표에 적힌 모든 필드가 들어간다.
+	Changes []*FieldChange
+	// Err는 이 줄의 검증 에러이다. 에러가 있는 가져오기는 적용할 수 없다.
+	Err error
+
+	unit  *Unit
+	tasks []*Task
+}
+
+// FieldChange는 가져오기로 바뀌는 필드 하나를 나타낸다.
+// 커스텀 속성의 경우 Field는 속성 이름이며, Old가 빈 문자열이면 새로 추가되는 속성이다.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Invalid는 가져오기 중 에러가 있는 줄들을 반환한다.
+func (im *UnitImport) Invalid() []*UnitImportRow {
+	rows := make([]*UnitImportRow, 0)
+	for _, r := range im.Rows {
+		if r.Err != nil {
+			rows = append(rows, r)
+		}
+	}
+	return rows
+}
+
+// Added는 가져오기로 새로 생성될 유닛의 줄들을 반환한다.
+func (im *UnitImport) Added() []*UnitImportRow {
+	rows := make([]*UnitImportRow, 0)
+	for _, r := range im.Rows {
+		if r.Err == nil && r.New {
+			rows = append(rows, r)
+		}
+	}
+	return rows
+}
+
+// Changed는 가져오기로 수정될 기존 유닛의 줄들을 반환한다.
+func (im *UnitImport) Changed() []*UnitImportRow {
+	rows := make([]*UnitImportRow, 0)
+	for _, r := range im.Rows {
+		if r.Err == nil && !r.New && len(r.Changes) != 0 {
+			rows = append(rows, r)
+		}
+	}
+	return rows
+}
+
+// Unchanged는 가져오기로 바뀌는 것이 없는 줄의 수를 반환한다.
+func (im *UnitImport) Unchanged() int {
+	n := 0
+	for _, r := range im.Rows {
+		if r.Err == nil && !r.New && len(r.Changes) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// ErrorTable은 에러가 있는 줄들을 표로 반환한다. 첫 줄은 열 이름이다.
+// 가져오기에 실패한 줄들을 파일로 내려받아 고칠 때 사용한다.
+func (im *UnitImport) ErrorTable() [][]string {
+	table := [][]string{{"line", "show", "group", "unit", "error"}}
+	for _, r := range im.Invalid() {
+		table = append(table, []string{strconv.Itoa(r.Line), r.Show, r.Group, r.Unit, r.Err.Error()})
+	}
+	return table
+}
+
+// PlanUnitImport는 표를 읽어 유닛 가져오기 계획을 만든다. 이 함수는 db를 수정하지 않는다.
+// 표의 첫 줄은 열 이름이며 UnitTableColumns, 태스크 열, 커스텀 속성 열을 쓸 수 있다.
+// 태스크 열은 해당 태스크를 생성하거나 수정한다. SplitTaskColumn을 참고한다.
+// 커스텀 속성 열은 UnitTableAttrPrefix로 시작한다. 접두어가 없던 예전 표를 위해
+// 기본 열과 태스크 열이 아닌 나머지 열도 커스텀 속성 열로 취급한다.
+//
+// 새로 생기는 태스크는 사이트 워크플로우의 시작 상태를 가지며,
+// 태스크의 상태를 바꾸려면 user가 워크플로우의 그 전환을 일으킬 수 있어야 한다.
+//
+// 각 줄의 검증 에러는 해당 줄의 Err에 기록되고, 표 자체가 잘못되었거나
+// db에서 정보를 가지고 올 수 없을 때만 에러를 반환한다.
+func PlanUnitImport(db *sql.DB, table [][]string, user string) (*UnitImport, error) {
+	if len(table) == 0 {
+		return nil, BadRequest("empty table")
+	}
+	site, wf, err := siteWorkflow(db)
+	if err != nil {
+		return nil, err
+	}
+	title := make(map[int]string)
+	attrTitle := make(map[int]string)
+	taskTitle := make(map[int][2]string)
+	hasTitle := make(map[string]bool)
+	hasAttr := make(map[string]bool)
+	for j, cell := range table[0] {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		if hasTitle[cell] {
+			return nil, BadRequest("duplicated column: %s", cell)
+		}
+		hasTitle[cell] = true
+		if task, field, ok := SplitTaskColumn(site, cell); ok {
+			// 태스크 열은 유닛의 커스텀 속성이 아니다.
+			taskTitle[j] = [2]string{task, field}
+			continue
+		}
+		key, ok := SplitAttrColumn(cell)
+		if !ok {
+			if IsUnitTableColumn(cell) {
+				title[j] = cell
+				continue
+			}
+			key = cell
+		}
+		if key == "" {
+			return nil, BadRequest("invalid attribute column: %s", cell)
+		}
+		// lens와 attr.lens는 같은 속성을 가리킨다.
+		if hasAttr[key] {
+			return nil, BadRequest("duplicated attribute column: %s", cell)
+		}
+		hasAttr[key] = true
+		attrTitle[j] = key
+	}
+	for _, c := range []string{"show", "group", "unit"} {
+		if !hasTitle[c] {
+			return nil, BadRequest("need %q column", c)
+		}
+	}
+	im := &UnitImport{Rows: make([]*UnitImportRow, 0)}
+	seen := make(map[string]int)
+	for i, row := range table[1:] {
+		base := make(map[string]string)
+		for j := range title {
+			if j >= len(row) {
+				// 뒤쪽 셀이 비어있는 줄은 열 이름보다 짧을 수 있다.
+				continue
+			}
+			base[title[j]] = strings.TrimSpace(row[j])
+		}
+		attr := make(map[string]string)
+		for j, k := range attrTitle {
+			if j >= len(row) {
+				continue
+			}
+			attr[k] = strings.TrimSpace(row[j])
+		}
+		// taskCell은 태스크별 태스크 열의 값이다. 예) taskCell["comp"]["due"]
+		taskCell := make(map[string]map[string]string)
+		for j, tf := range taskTitle {
+			if j >= len(row) {
+				continue
+			}
+			v := strings.TrimSpace(row[j])
+			if v == "" {
+				continue
+			}
+			if taskCell[tf[0]] == nil {
+				taskCell[tf[0]] = make(map[string]string)
+			}
+			taskCell[tf[0]][tf[1]] = v
+		}
+		empty := len(taskCell) == 0
+		for _, m := range []map[string]string{base, attr} {
+			for _, v := range m {
+				if v != "" {
+					empty = false
+					break
+				}
+			}
+		}
+		if empty {
+			// 엑셀의 뒤쪽 줄들은 비어있더라도 표에 추가되는 경우가 있다.
+			continue
+		}
+		r := &UnitImportRow{
+			Line:  i + 2,
+			Show:  popCell(base, "show"),
+			Group: popCell(base, "group"),
+			Unit:  popCell(base, "unit"),
+		}
+		im.Rows = append(im.Rows, r)
+		id := JoinUnitID(r.Show, r.Group, r.Unit)
+		if line, ok := seen[id]; ok {
+			r.Err = BadRequest("unit %s already defined at line %d", id, line)
+			continue
+		}
+		seen[id] = r.Line
+		err := planUnitImportRow(db, site, wf, user, r, base, attr, taskCell)
+		if err != nil {
+			if !errors.As(err, &BadRequestError{}) && !errors.As(err, &NotFoundError{}) && !errors.As(err, &AuthError{}) {
+				return nil, err
+			}
+			r.Err = err
+		}
+	}
+	return im, nil
+}
+
+// popCell은 m에 해당 키가 있으면 지우고 그 값을 반환한다.
+func popCell(m map[string]string, key string) string {
+	v, ok := m[key]
+	if ok {
+		delete(m, key)
+	}
+	return v
+}
+
+// planUnitImportRow는 한 줄의 값들을 유닛과 태스크에 적용하고 바뀌는 필드들을 r에 기록한다.
+// base에는 기본 열 중 유닛 아이디를 제외한 열이, attr에는 속성 이름별 커스텀 속성 열이,
+// taskCell에는 비어있지 않은 태스크 열이 태스크별로 들어있다.
+func planUnitImportRow(db *sql.DB, site *Site, wf *Workflow, user string, r *UnitImportRow, base, attr map[string]string, taskCell map[string]map[string]string) error {
+	err := verifyUnitPrimaryKeys(r.Show, r.Group, r.Unit)
+	if err != nil {
+		return err
+	}
+	// 그룹 이름을 잘못 쓴 줄이 새 유닛으로 보이지 않도록 그룹을 먼저 확인한다.
+	g, err := GetGroup(db, r.Show, r.Group)
+	if err != nil {
+		return err
+	}
+	u, err := GetUnit(db, r.Show, r.Group, r.Unit)
+	if err != nil {
+		if !errors.As(err, &NotFoundError{}) {
+			return err
+		}
+		u = &Unit{
+			Show:   r.Show,
+			Group:  r.Group,
+			Unit:   r.Unit,
+			Status: StatusInProgress,
+		}
+		r.New = true
+		// 새 유닛에는 그룹의 기본 태스크가 생성된다.
+		// 태스크 열이 기본 태스크를 수정할 수 있도록 미리 채워둔다.
+		u.Tasks = append([]string{}, g.DefaultTasks...)
+	}
+	change := func(field, old, new string) {
+		if r.New || old != new {
+			r.Changes = append(r.Changes, &FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	// 빈 셀은 기존 값을 지우지 않는다.
+	if status := popCell(base, "status"); status != "" {
+		change("status", string(u.Status), status)
+		u.Status = Status(status)
+	}
+	if desc := popCell(base, "description"); desc != "" {
+		change("description", u.Description, desc)
+		u.Description = desc
+	}
+	if cg := popCell(base, "cg_description"); cg != "" {
+		change("cg_description", u.CGDescription, cg)
+		u.CGDescription = cg
+	}
+	frames := []struct {
+		col string
+		val *int
+	}{
+		{"cut_in", &u.CutIn},
+		{"cut_out", &u.CutOut},
+		{"head_handle", &u.HeadHandle},
+		{"tail_handle", &u.TailHandle},
+	}
+	for _, f := range frames {
+		v := popCell(base, f.col)
+		if v == "" {
+			continue
+		}
+		n, err := parseTableInt(v)
+		if err != nil {
+			return BadRequest("invalid %s: %s", f.col, v)
+		}
+		change(f.col, tableInt(*f.val), tableInt(n))
+		*f.val = n
+	}
+	if tags := strings.Fields(popCell(base, "tags")); len(tags) != 0 {
+		// 태그는 덮어쓰지 않고 추가한다.
+		old := strings.Join(u.Tags, " ")
+		has := make(map[string]bool)
+		for _, t := range u.Tags {
+			has[t] = true
+		}
+		for _, t := range tags {
+			if !has[t] {
+				has[t] = true
+				u.Tags = append(u.Tags, t)
+			}
+		}
+		sort.Strings(u.Tags)
+		change("tags", old, strings.Join(u.Tags, " "))
+	}
+	if u.Attrs == nil {
+		u.Attrs = make(DBStringMap)
+	}
+	keys := make([]string, 0, len(attr))
+	for k := range attr {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := attr[k]
+		if v == "" {
+			// 빈 속성은 저장할 수 없을 뿐더러
+			// 내보낸 파일에서 해당 유닛에 없던 속성이 빈 값으로 들어온다.
+			continue
+		}
+		if strings.Contains(k, ": ") || strings.Contains(k, "\n") {
+			return BadRequest("invalid attribute name: %q", k)
+		}
+		if strings.Contains(v, "\n") {
+			return BadRequest("attribute %q should be a single line", k)
+		}
+		change(UnitTableAttrPrefix+k, u.Attrs[k], v)
+		u.Attrs[k] = v
+	}
+	// 태스크 열은 사이트의 태스크 순서대로 처리한다.
+	for _, task := range site.Tasks {
+		cell, ok := taskCell[task]
+		if !ok {
+			continue
+		}
+		t, err := planTaskImport(db, site, wf, user, r, u, task, cell, change)
+		if err != nil {
+			return err
+		}
+		r.tasks = append(r.tasks, t)
+	}
+	err = verifyUnit(db, u)
+	if err != nil {
+		return err
+	}
+	r.unit = u
+	return nil
+}
+
+// planTaskImport는 한 유닛의 태스크 열 값들을 태스크에 적용한다.
+// 유닛에 해당 태스크가 없다면 워크플로우의 시작 상태로 새로 만들어 유닛의 태스크로 등록한다.
+// 상태가 바뀐다면 user가 워크플로우의 그 전환을 일으킬 수 있어야 한다.
+func planTaskImport(db *sql.DB, site *Site, wf *Workflow, user string, r *UnitImportRow, u *Unit, task string, cell map[string]string, change func(field, old, new string)) (*Task, error) {
+	var t *Task
+	if !r.New {
+		var err error
+		t, err = GetTask(db, u.Show, u.Group, u.Unit, task)
+		if err != nil && !errors.As(err, &NotFoundError{}) {
+			return nil, err
+		}
+	}
+	if t == nil {
+		t = &Task{
+			Show:   u.Show,
+			Group:  u.Group,
+			Unit:   u.Unit,
+			Task:   task,
+			Status: wf.Start,
+		}
+	}
+	// 역할은 바뀌기 전의 태스크를 기준으로 판단한다.
+	old := *t
+	hasTask := false
+	for _, ut := range u.Tasks {
+		if ut == task {
+			hasTask = true
+			break
+		}
+	}
+	if !hasTask {
+		// 숨겨진 태스크도 다시 보이게 된다.
+		old := strings.Join(u.Tasks, " ")
+		u.Tasks = append(u.Tasks, task)
+		change("tasks", old, strings.Join(u.Tasks, " "))
+	}
+	if status, ok := cell["status"]; ok {
+		change(task+".status", string(t.Status), status)
+		t.Status = Status(status)
+	}
+	if assignee, ok := cell["assignee"]; ok {
+		change(task+".assignee", t.Assignee, assignee)
+		t.Assignee = assignee
+	}
+	if due, ok := cell["due"]; ok {
+		d, err := parseTableDate(due)
+		if err != nil {
+			return nil, BadRequest("invalid %s.due: %s", task, due)
+		}
+		change(task+".due", tableDate(t.DueDate), tableDate(d))
+		t.DueDate = d
+	}
+	err := wf.verifyTransition(site, old.Status, t.Status, &old, user)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", task, err)
+	}
+	err = verifyTask(db, t)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", task, err)
+	}
+	return t, nil
+}
+
+// ApplyUnitImport는 가져오기 계획을 하나의 트랜잭션으로 db에 적용한다.
+// 에러가 있는 줄이 하나라도 있으면 아무것도 적용하지 않고 에러를 반환한다.
+// 적용 도중 에러가 나도 db는 바뀌지 않는다.
+// 유닛의 컷 정보가 바뀌면 author를 작성자로 변경 기록을 남긴다.
+// author가 있다면 가져오기를 되돌릴 수 있도록 작업 기록(ChangeSet)을 함께 남긴다.
+func ApplyUnitImport(db *sql.DB, im *UnitImport, author string) error {
+	if im == nil {
+		return fmt.Errorf("nil unit import")
+	}
+	if invalid := im.Invalid(); len(invalid) != 0 {
+		return BadRequest("import has %d invalid rows: line %d: %v", len(invalid), invalid[0].Line, invalid[0].Err)
+	}
+	stmts := make([]dbStatement, 0)
+	cs := newChangeSetRecorder(author, "import-units")
+	showTags := make(map[string][]string)
+	shows := make([]string, 0)
+	rows := 0
+	for _, r := range im.Rows {
+		if !r.New && len(r.Changes) == 0 {
+			continue
+		}
+		var st []dbStatement
+		var err error
+		if r.New {
+			st, err = addUnitStmts(db, r.unit)
+		} else {
+			st, err = updateUnitStmts(db, r.unit, author, "unit import")
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", r.Line, err)
+		}
+		err = cs.addUnit(db, r.unit, r.New)
+		if err != nil {
+			return err
+		}
+		stmts = append(stmts, st...)
+		// 유닛 구문이 필요한 태스크를 먼저 생성하므로 태스크는 수정만 하면 된다.
+		for _, t := range r.tasks {
+			st, err := updateTaskStmts(db, t)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", r.Line, err)
+			}
+			err = cs.updateTask(db, t)
+			if err != nil {
+				return err
+			}
+			stmts = append(stmts, st...)
+		}
+		rows++
+		if _, ok := showTags[r.Show]; !ok {
+			shows = append(shows, r.Show)
+		}
+		showTags[r.Show] = append(showTags[r.Show], r.unit.Tags...)
+	}
+	// 쇼의 태그는 쇼마다 한번에 업데이트해야 앞의 구문을 덮어쓰지 않는다.
+	for _, show := range shows {
+		st, err := addShowTagsStmts(db, show, showTags[show])
+		if err != nil {
+			return err
+		}
+		if len(st) != 0 {
+			defer invalidateShowCache(show)
+		}
+		stmts = append(stmts, st...)
+	}
+	if len(stmts) == 0 {
+		return nil
+	}
+	stmts = append(stmts, cs.stmts(fmt.Sprintf("%d rows", rows))...)
+	return dbExec(db, stmts)
+}
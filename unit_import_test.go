@@ -0,0 +1,215 @@
+package roi
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnitImport(t *testing.T) {
+	db, err := testDB()
+	if err != nil {
+		t.Fatalf("could not connect to database: %v", err)
+	}
+	err = AddSite(db)
+	if err != nil {
+		t.Fatalf("could not add site: %s", err)
+	}
+	defer func() {
+		err := DeleteSite(db)
+		if err != nil {
+			t.Fatalf("could not delete site: %s", err)
+		}
+	}()
+	err = AddShow(db, testShow)
+	if err != nil {
+		t.Fatalf("could not add show: %s", err)
+	}
+	defer func() {
+		err = DeleteShow(db, testShow.ID())
+		if err != nil {
+			t.Fatalf("could not delete show: %s", err)
+		}
+	}()
+	err = AddGroup(db, testGroup)
+	if err != nil {
+		t.Fatalf("could not add group: %s", err)
+	}
+	defer func() {
+		err = DeleteGroup(db, testGroup.Show, testGroup.Group)
+		if err != nil {
+			t.Fatalf("could not delete group: %s", err)
+		}
+	}()
+	err = AddUnit(db, testUnitA)
+	if err != nil {
+		t.Fatalf("could not add unit: %s", err)
+	}
+	defer func() {
+		err = DeleteUnit(db, testUnitA.Show, testUnitA.Group, testUnitA.Unit)
+		if err != nil {
+			t.Fatalf("could not delete unit: %s", err)
+		}
+	}()
+
+	show := testShow.Show
+	grp := testGroup.Group
+	table := [][]string{
+		{"show", "group", "unit", "status", "description", "tags", "fx.assignee", "fx.due", "lens"},
+		{show, grp, "0010", "", "바뀐 설명", "", "kybin", "2020-03-01", ""},
+		{show, grp, "0040", "hold", "새 샷", "새태그", "", "", "35mm"},
+		{show, grp, "0050", "unknown", "", "", "", "", ""},
+		{show, grp, "0040", "", "", "", "", "", ""},
+		{show, grp, "0010", "", "", "", "", "", ""},
+		{show, grp, "0060", "", "", "", "", "2020-13-01", ""},
+		{"", "", "", "", "", "", "", "", ""},
+	}
+	im, err := PlanUnitImport(db, table, "admin")
+	if err != nil {
+		t.Fatalf("could not plan import: %s", err)
+	}
+	if len(im.Rows) != 6 {
+		t.Fatalf("rows: got %d, want 6", len(im.Rows))
+	}
+	invalid := im.Invalid()
+	if len(invalid) != 4 || invalid[0].Line != 4 || invalid[1].Line != 5 || invalid[2].Line != 6 || invalid[3].Line != 7 {
+		t.Fatalf("invalid rows: got %v", invalid)
+	}
+	err = ApplyUnitImport(db, im, "")
+	if err == nil {
+		t.Fatalf("import with invalid rows should not be applied")
+	}
+	// 누가 가져오는지 모른다면 태스크 상태를 바꿀 수 없다.
+	im, err = PlanUnitImport(db, [][]string{{"show", "group", "unit", "fx.status"}, {show, grp, "0010", "hold"}}, "")
+	if err != nil {
+		t.Fatalf("could not plan import: %s", err)
+	}
+	if invalid := im.Invalid(); len(invalid) != 1 || !errors.As(invalid[0].Err, &AuthError{}) {
+		t.Fatalf("want auth error for status change without user, got %v", invalid)
+	}
+	_, err = GetUnit(db, show, grp, "0040")
+	if err == nil {
+		t.Fatalf("unit added from invalid import")
+	}
+
+	im, err = PlanUnitImport(db, table[:3], "admin")
+	if err != nil {
+		t.Fatalf("could not plan import: %s", err)
+	}
+	if added := im.Added(); len(added) != 1 || added[0].Unit != "0040" {
+		t.Fatalf("added rows: got %v", added)
+	}
+	changed := im.Changed()
+	if len(changed) != 1 || len(changed[0].Changes) != 3 || changed[0].Changes[0].Field != "description" {
+		t.Fatalf("changed rows: got %v", changed)
+	}
+	err = ApplyUnitImport(db, im, "")
+	if err != nil {
+		t.Fatalf("could not apply import: %s", err)
+	}
+	defer func() {
+		err = DeleteUnit(db, show, grp, "0040")
+		if err != nil {
+			t.Fatalf("could not delete unit: %s", err)
+		}
+	}()
+	a, err := GetUnit(db, show, grp, "0010")
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	if a.Description != "바뀐 설명" {
+		t.Fatalf("description: got %q", a.Description)
+	}
+	if _, ok := a.Attrs["fx.assignee"]; ok {
+		t.Fatalf("task column imported as attribute")
+	}
+	fx, err := GetTask(db, show, grp, "0010", "fx")
+	if err != nil {
+		t.Fatalf("could not get task: %s", err)
+	}
+	if fx.Assignee != "kybin" || tableDate(fx.DueDate) != "2020-03-01" {
+		t.Fatalf("imported task: got %v", fx)
+	}
+	if _, ok := a.Attrs["lens"]; ok {
+		t.Fatalf("empty cell imported as attribute")
+	}
+	u, err := GetUnit(db, show, grp, "0040")
+	if err != nil {
+		t.Fatalf("could not get added unit: %s", err)
+	}
+	if u.Status != StatusHold || u.Attrs["lens"] != "35mm" {
+		t.Fatalf("added unit: got %v", u)
+	}
+	sh, err := GetShow(db, show)
+	if err != nil {
+		t.Fatalf("could not get show: %s", err)
+	}
+	hasTag := false
+	for _, tag := range sh.Tags {
+		if tag == "새태그" {
+			hasTag = true
+		}
+	}
+	if !hasTag {
+		t.Fatalf("show tags not updated: %v", sh.Tags)
+	}
+
+	// 기본 열이나 태스크 열과 이름이 같은 속성도 내보낸 표를 그대로 가져올 수 있어야 한다.
+	c := &Unit{
+		Show:   show,
+		Group:  grp,
+		Unit:   "0070",
+		Status: StatusInProgress,
+		Tasks:  []string{"fx"},
+		Attrs: DBStringMap{
+			"status":    "attr status",
+			"fx.status": "attr task status",
+		},
+	}
+	err = AddUnit(db, c)
+	if err != nil {
+		t.Fatalf("could not add unit: %s", err)
+	}
+	defer func() {
+		err = DeleteUnit(db, show, grp, "0070")
+		if err != nil {
+			t.Fatalf("could not delete unit: %s", err)
+		}
+	}()
+	exported, err := UnitTable(db, []*Unit{c})
+	if err != nil {
+		t.Fatalf("could not export units: %s", err)
+	}
+	hasCol := make(map[string]bool)
+	for _, col := range exported[0] {
+		hasCol[col] = true
+	}
+	if !hasCol["attr.status"] || !hasCol["attr.fx.status"] || !hasCol["fx.status"] {
+		t.Fatalf("exported columns: got %v", exported[0])
+	}
+	im, err = PlanUnitImport(db, exported, "admin")
+	if err != nil {
+		t.Fatalf("could not plan import of exported table: %s", err)
+	}
+	if len(im.Invalid()) != 0 || len(im.Added()) != 0 || len(im.Changed()) != 0 {
+		t.Fatalf("exported table should import without changes: %v", im.Rows)
+	}
+	im, err = PlanUnitImport(db, [][]string{{"show", "group", "unit", "attr.status"}, {show, grp, "0070", "changed"}}, "admin")
+	if err != nil {
+		t.Fatalf("could not plan import: %s", err)
+	}
+	err = ApplyUnitImport(db, im, "")
+	if err != nil {
+		t.Fatalf("could not apply import: %s", err)
+	}
+	c, err = GetUnit(db, show, grp, "0070")
+	if err != nil {
+		t.Fatalf("could not get unit: %s", err)
+	}
+	if c.Status != StatusInProgress || c.Attrs["status"] != "changed" {
+		t.Fatalf("attr column imported as unit status: got %v", c)
+	}
+	_, err = PlanUnitImport(db, [][]string{{"show", "group", "unit", "lens", "attr.lens"}}, "admin")
+	if !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error for duplicated attribute column, got %v", err)
+	}
+}
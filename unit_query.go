@@ -0,0 +1,521 @@
+package roi
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 유닛 검색어의 문법은 다음과 같다.
+//
+// 검색어는 공백으로 나뉜 항목들이며 모든 항목을 만족하는 유닛을 찾는다.
+// 공백이 들어간 값은 큰 따옴표로 감싼다.
+//
+//	항목   := [-] (필터 | 이름)
+//	필터   := 필드 연산자 값[,값...]
+//	연산자 := : | = | != | < | <= | > | >=
+//	이름   := 그룹/ | 유닛 | 그룹/유닛
+//
+// 항목 앞의 - 는 조건을 뒤집는다. 쉼표로 나열한 값은 그 중 하나만 맞으면 된다.
+// 이름 항목들은 그룹은 그룹끼리, 유닛은 유닛끼리 그 중 하나만 맞으면 된다.
+//
+// 필드는 다음과 같다.
+//
+//	group, unit, tag, status, task  유닛의 그룹, 이름, 태그, 상태, 태스크
+//	assignee, task-status, due       유닛의 태스크 중 하나의 담당자, 상태, 마감일
+//	duration                         유닛의 컷 길이(프레임)
+//	attr.<키>                        유닛의 커스텀 속성, 값이 * 이면 속성이 있기만 하면 된다.
+//
+// 크기 비교(<, <=, >, >=)는 due와 duration에만 쓸 수 있다.
+// task 필터가 있으면 태스크 필드들은 그 태스크들에서만 찾는다.
+//
+// 예) CG/ status:in-progress,hold -tag:hero due<2026-11-01 task:comp assignee:kybin attr.camera:A
+
+// QueryError는 유닛 검색어의 문법 에러이다.
+type QueryError struct {
+	// Pos는 검색어에서 에러가 난 항목이 시작하는 위치(바이트)이다.
+	Pos  int
+	Term string
+	Msg  string
+}
+
+func (e QueryError) Error() string {
+	return fmt.Sprintf("query: %s: %s", e.Term, e.Msg)
+}
+
+// UnitQuery는 해석된 유닛 검색어이다.
+type UnitQuery struct {
+	Terms []*QueryTerm
+}
+
+// QueryTerm은 검색어의 항목 하나이다.
+// 이름 항목은 Field가 group 또는 unit인 필터로 바뀐다.
+type QueryTerm struct {
+	Pos    int
+	Negate bool
+	Field  string
+	// Op는 : 또는 크기 비교 연산자이다. : 는 값 중 하나와 같음을 뜻한다.
+	Op     string
+	Values []string
+}
+
+// queryFieldOps는 검색어의 필드마다 쓸 수 있는 연산자이다.
+// attr. 로 시작하는 커스텀 속성 필드는 queryAttrOps를 쓸 수 있다.
+var queryFieldOps = map[string][]string{
+	"group":       {":"},
+	"unit":        {":"},
+	"tag":         {":"},
+	"status":      {":"},
+	"task":        {":"},
+	"assignee":    {":"},
+	"task-status": {":"},
+	"due":         {":", "<", "<=", ">", ">="},
+	"duration":    {":", "<", "<=", ">", ">="},
+}
+
+// queryAttrOps는 커스텀 속성 필드에 쓸 수 있는 연산자이다.
+// 비교 연산자는 타입이 정의된 속성 값(typed_attrs)을 비교한다.
+var queryAttrOps = []string{":", "<", "<=", ">", ">="}
+
+// queryTaskFields는 유닛이 아니라 유닛의 태스크에서 찾는 필드이다.
+var queryTaskFields = map[string]bool{
+	"assignee":    true,
+	"task-status": true,
+	"due":         true,
+}
+
+// queryOps는 필드 뒤에 올 수 있는 연산자이다. 긴 연산자를 먼저 검사해야 한다.
+var queryOps = []string{">=", "<=", "!=", ":", "=", "<", ">"}
+
+// queryDateLayouts는 검색어에서 받아들이는 날짜 형식이다.
+var queryDateLayouts = []string{"2006-01-02", "2006-01-02T15:04:05"}
+
+// ParseUnitQuery는 유닛 검색어를 해석한다.
+// 문법에 맞지 않는 항목이 있다면 QueryError를 감싼 BadRequestError를 반환한다.
+func ParseUnitQuery(query string) (*UnitQuery, error) {
+	toks, err := splitQuery(query)
+	if err != nil {
+		return nil, BadRequest("%w", err)
+	}
+	q := &UnitQuery{Terms: make([]*QueryTerm, 0, len(toks))}
+	for _, tok := range toks {
+		t, msg := parseQueryTerm(tok.pos, tok.text)
+		if msg != "" {
+			return nil, BadRequest("%w", QueryError{Pos: tok.pos, Term: tok.text, Msg: msg})
+		}
+		q.Terms = append(q.Terms, t)
+	}
+	return q, nil
+}
+
+type queryToken struct {
+	pos  int
+	text string
+}
+
+// splitQuery는 검색어를 공백으로 나눈다. 큰 따옴표 안의 공백으로는 나누지 않는다.
+func splitQuery(query string) ([]queryToken, error) {
+	toks := make([]queryToken, 0)
+	start := -1
+	quoted := false
+	for i, r := range query {
+		if quoted {
+			if r == '"' {
+				quoted = false
+			}
+			continue
+		}
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				toks = append(toks, queryToken{pos: start, text: query[start:i]})
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		if r == '"' {
+			quoted = true
+		}
+	}
+	if quoted {
+		return nil, QueryError{Pos: start, Term: query[start:], Msg: "unclosed quote"}
+	}
+	if start >= 0 {
+		toks = append(toks, queryToken{pos: start, text: query[start:]})
+	}
+	return toks, nil
+}
+
+// parseQueryTerm은 검색어 항목 하나를 해석한다. 문법에 맞지 않으면 그 이유를 반환한다.
+func parseQueryTerm(pos int, s string) (*QueryTerm, string) {
+	t := &QueryTerm{Pos: pos}
+	if len(s) > 1 && s[0] == '-' {
+		t.Negate = true
+		s = s[1:]
+	}
+	i := strings.IndexAny(s, `:=<>!"`)
+	if i < 0 {
+		return parseQueryName(t, s)
+	}
+	if s[i] == '"' {
+		return nil, "quote is only allowed in a value"
+	}
+	t.Field = s[:i]
+	rest := s[i:]
+	for _, op := range queryOps {
+		if strings.HasPrefix(rest, op) {
+			t.Op = op
+			rest = rest[len(op):]
+			break
+		}
+	}
+	if t.Op == "" {
+		return nil, "unknown operator"
+	}
+	if t.Op == ":" {
+		// duration:>100 처럼 : 뒤에 비교 연산자를 쓸 수도 있다.
+		for _, op := range []string{">=", "<=", "=", "<", ">"} {
+			if strings.HasPrefix(rest, op) {
+				t.Op = op
+				rest = rest[len(op):]
+				break
+			}
+		}
+	}
+	if t.Op == "!=" {
+		t.Negate = !t.Negate
+		t.Op = ":"
+	}
+	if t.Op == "=" {
+		t.Op = ":"
+	}
+	if t.Field == "" {
+		return nil, "need a field name before the operator"
+	}
+	vals, msg := splitQueryValues(rest)
+	if msg != "" {
+		return nil, msg
+	}
+	t.Values = vals
+	msg = verifyQueryTerm(t)
+	if msg != "" {
+		return nil, msg
+	}
+	return t, ""
+}
+
+// parseQueryName은 필드가 없는 이름 항목을 group 또는 unit 필터로 해석한다.
+func parseQueryName(t *QueryTerm, s string) (*QueryTerm, string) {
+	t.Op = ":"
+	if strings.HasSuffix(s, "/") {
+		t.Field = "group"
+		t.Values = []string{strings.TrimSuffix(s, "/")}
+	} else {
+		t.Field = "unit"
+		t.Values = []string{s}
+	}
+	msg := verifyQueryTerm(t)
+	if msg != "" {
+		return nil, msg
+	}
+	return t, ""
+}
+
+// splitQueryValues는 쉼표로 나열된 값들을 나누고 큰 따옴표를 벗겨낸다.
+func splitQueryValues(s string) ([]string, string) {
+	vals := make([]string, 0)
+	val := ""
+	quoted := false
+	hasVal := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			hasVal = true
+		case r == ',' && !quoted:
+			if !hasVal {
+				return nil, "empty value"
+			}
+			vals = append(vals, val)
+			val = ""
+			hasVal = false
+		default:
+			val += string(r)
+			hasVal = true
+		}
+	}
+	if !hasVal {
+		if len(vals) == 0 {
+			return nil, "need a value after the operator"
+		}
+		return nil, "empty value"
+	}
+	vals = append(vals, val)
+	for _, v := range vals {
+		if v == "" {
+			return nil, "empty value"
+		}
+	}
+	return vals, ""
+}
+
+// verifyQueryTerm은 항목의 필드와 연산자, 값이 유효한지 검사한다. 유효하지 않으면 그 이유를 반환한다.
+func verifyQueryTerm(t *QueryTerm) string {
+	ops, ok := queryFieldOps[t.Field]
+	if strings.HasPrefix(t.Field, "attr.") {
+		if t.Field == "attr." {
+			return "need an attribute name after attr."
+		}
+		ops, ok = queryAttrOps, true
+	}
+	if !ok {
+		return fmt.Sprintf("unknown field %q", t.Field)
+	}
+	hasOp := false
+	for _, op := range ops {
+		if op == t.Op {
+			hasOp = true
+			break
+		}
+	}
+	if !hasOp {
+		return fmt.Sprintf("%s cannot be used with %s", t.Op, t.Field)
+	}
+	if t.Op != ":" && len(t.Values) != 1 {
+		return fmt.Sprintf("%s needs exactly one value", t.Op)
+	}
+	for _, v := range t.Values {
+		var err error
+		switch t.Field {
+		case "group":
+			err = verifyGroupName(v)
+		case "unit":
+			if i := strings.Index(v, "/"); i >= 0 {
+				err = verifyGroupName(v[:i])
+				if err == nil {
+					err = verifyUnitName(v[i+1:])
+				}
+			} else {
+				err = verifyUnitName(v)
+			}
+		case "status":
+			// 사이트에 정의된 상태는 검색할 때 알 수 없으므로 이름만 검사한다.
+			err = verifyStatusName(Status(v))
+		case "task":
+			err = verifyTaskName(v)
+		case "task-status":
+			err = verifyStatusName(Status(v))
+		case "due":
+			_, err = parseQueryDate(v)
+		case "duration":
+			var n int
+			n, err = strconv.Atoi(v)
+			if err == nil && n < 0 {
+				err = fmt.Errorf("negative duration: %d", n)
+			}
+		}
+		if err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// parseQueryDate는 검색어의 날짜를 현재 지역의 시간으로 변환한다.
+func parseQueryDate(s string) (time.Time, error) {
+	var err error
+	for _, layout := range queryDateLayouts {
+		var t time.Time
+		t, err = time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", s)
+}
+
+// queryArgs는 sql 구문의 인자를 모으며 각 인자의 자리 표시자를 반환한다.
+type queryArgs struct {
+	vals []interface{}
+}
+
+func (a *queryArgs) add(v interface{}) string {
+	a.vals = append(a.vals, v)
+	return fmt.Sprintf("$%d", len(a.vals))
+}
+
+// list는 여러 인자를 추가하고 쉼표로 나열된 자리 표시자를 반환한다.
+func (a *queryArgs) list(vals []string) string {
+	ps := make([]string, 0, len(vals))
+	for _, v := range vals {
+		ps = append(ps, a.add(v))
+	}
+	return strings.Join(ps, ", ")
+}
+
+// sqlWhere는 검색어를 units 테이블에 대한 sql 조건과 그 인자로 변환한다.
+// 모든 값은 인자로 전달되므로 검색어가 sql 구문에 직접 들어가지 않는다.
+func (q *UnitQuery) sqlWhere(show string) (string, []interface{}) {
+	args := &queryArgs{}
+	where := []string{"units.show=" + args.add(show)}
+	// 이름 항목은 그룹끼리, 유닛끼리 모아서 그 중 하나만 맞으면 된다.
+	names := map[string]*QueryTerm{
+		"group": {Field: "group", Op: ":"},
+		"unit":  {Field: "unit", Op: ":"},
+	}
+	tasks := make([]string, 0)
+	taskConds := make([]string, 0)
+	for _, t := range q.Terms {
+		if !t.Negate && (t.Field == "group" || t.Field == "unit") {
+			names[t.Field].Values = append(names[t.Field].Values, t.Values...)
+			continue
+		}
+		if !t.Negate && t.Field == "task" {
+			tasks = append(tasks, t.Values...)
+		}
+	}
+	for _, field := range []string{"group", "unit"} {
+		if t := names[field]; len(t.Values) != 0 {
+			where = append(where, unitQueryCond(t, args))
+		}
+	}
+	for _, t := range q.Terms {
+		if !t.Negate && (t.Field == "group" || t.Field == "unit") {
+			continue
+		}
+		if queryTaskFields[t.Field] {
+			cond := taskQueryCond(t, args)
+			if t.Negate {
+				where = append(where, "NOT "+taskExistsCond(cond, tasks, args))
+			} else {
+				taskConds = append(taskConds, cond)
+			}
+			continue
+		}
+		cond := unitQueryCond(t, args)
+		if t.Negate {
+			cond = "NOT " + cond
+		}
+		where = append(where, cond)
+	}
+	if len(taskConds) != 0 {
+		// 태스크 조건들은 같은 태스크 하나가 모두 만족해야 한다.
+		where = append(where, taskExistsCond(strings.Join(taskConds, " AND "), tasks, args))
+	}
+	return strings.Join(where, " AND "), args.vals
+}
+
+// taskExistsCond는 유닛의 보이는 태스크 중 cond를 만족하는 태스크가 있는지 검사하는 조건이다.
+// tasks가 비어있지 않다면 그 태스크들에서만 찾는다.
+func taskExistsCond(cond string, tasks []string, args *queryArgs) string {
+	s := "EXISTS (SELECT 1 FROM tasks WHERE tasks.show=units.show AND tasks.grp=units.grp AND tasks.unit=units.unit AND tasks.task = ANY(units.tasks)"
+	if len(tasks) != 0 {
+		s += " AND tasks.task IN (" + args.list(tasks) + ")"
+	}
+	return s + " AND " + cond + ")"
+}
+
+// unitQueryCond는 유닛 필드에 대한 항목을 sql 조건으로 변환한다. Negate는 고려하지 않는다.
+func unitQueryCond(t *QueryTerm, args *queryArgs) string {
+	conds := make([]string, 0, len(t.Values))
+	switch {
+	case t.Field == "group":
+		return "units.grp IN (" + args.list(t.Values) + ")"
+	case t.Field == "status":
+		return "units.status IN (" + args.list(t.Values) + ")"
+	case t.Field == "unit":
+		for _, v := range t.Values {
+			if i := strings.Index(v, "/"); i >= 0 {
+				conds = append(conds, fmt.Sprintf("(units.grp=%s AND units.unit=%s)", args.add(v[:i]), args.add(v[i+1:])))
+			} else {
+				conds = append(conds, "units.unit="+args.add(v))
+			}
+		}
+	case t.Field == "tag":
+		for _, v := range t.Values {
+			conds = append(conds, args.add(v)+"::string = ANY(units.tags)")
+		}
+	case t.Field == "task":
+		for _, v := range t.Values {
+			conds = append(conds, args.add(v)+"::string = ANY(units.tasks)")
+		}
+	case t.Field == "duration":
+		dur := "(units.cut_out - units.cut_in + 1)"
+		cmp := ""
+		if t.Op == ":" {
+			cmp = dur + " IN (" + intArgs(t.Values, args) + ")"
+		} else {
+			cmp = dur + " " + t.Op + " " + intArgs(t.Values, args)
+		}
+		return "(NOT (units.cut_in=0 AND units.cut_out=0) AND " + cmp + ")"
+	case strings.HasPrefix(t.Field, "attr.") && t.Op != ":":
+		// 숫자로 해석되는 값은 숫자 속성과, 그 외에는 문자열 속성과 비교한다.
+		// 문자열 비교는 yyyy-mm-dd 형식인 날짜 속성의 비교에도 쓸 수 있다.
+		key := args.add(strings.TrimPrefix(t.Field, "attr.")) + "::STRING"
+		v := t.Values[0]
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return "(CASE WHEN jsonb_typeof(units.typed_attrs->" + key + ") = 'number' THEN (units.typed_attrs->>" + key + ")::FLOAT8 END) " + t.Op + " " + args.add(v) + "::FLOAT8"
+		}
+		return "(CASE WHEN jsonb_typeof(units.typed_attrs->" + key + ") = 'string' THEN units.typed_attrs->>" + key + " END) " + t.Op + " " + args.add(v)
+	case strings.HasPrefix(t.Field, "attr."):
+		// 커스텀 속성은 db에 "키: 값" 줄들로 저장되어 있다.
+		key := regexp.QuoteMeta(strings.TrimPrefix(t.Field, "attr."))
+		re := "(^|\n)" + key + ": "
+		if len(t.Values) != 1 || t.Values[0] != "*" {
+			vals := make([]string, 0, len(t.Values))
+			for _, v := range t.Values {
+				vals = append(vals, regexp.QuoteMeta(v))
+			}
+			re += "(" + strings.Join(vals, "|") + ")(\n|$)"
+		}
+		return "units.attrs ~ " + args.add(re)
+	}
+	return "(" + strings.Join(conds, " OR ") + ")"
+}
+
+// taskQueryCond는 태스크 필드에 대한 항목을 tasks 테이블의 sql 조건으로 변환한다. Negate는 고려하지 않는다.
+func taskQueryCond(t *QueryTerm, args *queryArgs) string {
+	switch t.Field {
+	case "assignee":
+		return "tasks.assignee IN (" + args.list(t.Values) + ")"
+	case "task-status":
+		return "tasks.status IN (" + args.list(t.Values) + ")"
+	case "due":
+		// 날짜는 하루 단위로 비교하며 마감일이 정해지지 않은 태스크는 맞지 않는다.
+		if t.Op == ":" {
+			conds := make([]string, 0, len(t.Values))
+			for _, v := range t.Values {
+				d, _ := parseQueryDate(v)
+				conds = append(conds, fmt.Sprintf("(tasks.due_date >= %s AND tasks.due_date < %s)", args.add(d), args.add(d.AddDate(0, 0, 1))))
+			}
+			return "(" + strings.Join(conds, " OR ") + ")"
+		}
+		d, _ := parseQueryDate(t.Values[0])
+		switch t.Op {
+		case "<":
+			return fmt.Sprintf("(tasks.due_date > %s AND tasks.due_date < %s)", args.add(time.Time{}), args.add(d))
+		case "<=":
+			return fmt.Sprintf("(tasks.due_date > %s AND tasks.due_date < %s)", args.add(time.Time{}), args.add(d.AddDate(0, 0, 1)))
+		case ">":
+			return "tasks.due_date >= " + args.add(d.AddDate(0, 0, 1))
+		case ">=":
+			return "tasks.due_date >= " + args.add(d)
+		}
+	}
+	return "false"
+}
+
+// intArgs는 정수 문자열 값들을 정수 인자로 추가하고 자리 표시자들을 반환한다.
+func intArgs(vals []string, args *queryArgs) string {
+	ps := make([]string, 0, len(vals))
+	for _, v := range vals {
+		n, _ := strconv.Atoi(v)
+		ps = append(ps, args.add(n))
+	}
+	return strings.Join(ps, ", ")
+}
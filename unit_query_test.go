@@ -0,0 +1,168 @@
+package roi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseUnitQuery(t *testing.T) {
+	cases := []struct {
+		query string
+		want  []*QueryTerm
+	}{
+		{
+			query: "",
+			want:  []*QueryTerm{},
+		},
+		{
+			query: "CG/ CG0010 CG/0020",
+			want: []*QueryTerm{
+				{Pos: 0, Field: "group", Op: ":", Values: []string{"CG"}},
+				{Pos: 4, Field: "unit", Op: ":", Values: []string{"CG0010"}},
+				{Pos: 11, Field: "unit", Op: ":", Values: []string{"CG/0020"}},
+			},
+		},
+		{
+			query: "status:in-progress,hold -tag:hero due<2026-11-01",
+			want: []*QueryTerm{
+				{Pos: 0, Field: "status", Op: ":", Values: []string{"in-progress", "hold"}},
+				{Pos: 24, Negate: true, Field: "tag", Op: ":", Values: []string{"hero"}},
+				{Pos: 34, Field: "due", Op: "<", Values: []string{"2026-11-01"}},
+			},
+		},
+		{
+			query: "duration:>=100 duration<=48 task=comp assignee!=kybin -task-status!=done",
+			want: []*QueryTerm{
+				{Pos: 0, Field: "duration", Op: ">=", Values: []string{"100"}},
+				{Pos: 15, Field: "duration", Op: "<=", Values: []string{"48"}},
+				{Pos: 28, Field: "task", Op: ":", Values: []string{"comp"}},
+				{Pos: 38, Negate: true, Field: "assignee", Op: ":", Values: []string{"kybin"}},
+				{Pos: 54, Field: "task-status", Op: ":", Values: []string{"done"}},
+			},
+		},
+		{
+			query: `attr.camera:A,"B cam" tag:"로이 창문"`,
+			want: []*QueryTerm{
+				{Pos: 0, Field: "attr.camera", Op: ":", Values: []string{"A", "B cam"}},
+				{Pos: 22, Field: "tag", Op: ":", Values: []string{"로이 창문"}},
+			},
+		},
+	}
+	for _, c := range cases {
+		q, err := ParseUnitQuery(c.query)
+		if err != nil {
+			t.Fatalf("%q: %v", c.query, err)
+		}
+		if !reflect.DeepEqual(q.Terms, c.want) {
+			t.Fatalf("%q: got %v, want %v", c.query, q.Terms, c.want)
+		}
+	}
+}
+
+func TestParseUnitQueryError(t *testing.T) {
+	cases := []struct {
+		query   string
+		wantPos int
+		wantErr string
+	}{
+		{query: "tag:", wantPos: 0, wantErr: "need a value after the operator"},
+		{query: "CG/ foo:bar", wantPos: 4, wantErr: `unknown field "foo"`},
+		{query: "CG/ tag:로이 tag<hero", wantPos: 15, wantErr: "< cannot be used with tag"},
+		{query: "status:hold,,done", wantPos: 0, wantErr: "empty value"},
+		{query: "duration>a", wantPos: 0},
+		{query: "due:2026-13-01", wantPos: 0},
+		{query: "status:Unknown", wantPos: 0},
+		{query: `tag:"로이`, wantPos: 0, wantErr: "unclosed quote"},
+		{query: "duration<10,20", wantPos: 0, wantErr: "< needs exactly one value"},
+		{query: ":hold", wantPos: 0, wantErr: "need a field name before the operator"},
+	}
+	for _, c := range cases {
+		_, err := ParseUnitQuery(c.query)
+		if err == nil {
+			t.Fatalf("%q: want error, got nil", c.query)
+		}
+		if !errors.As(err, &BadRequestError{}) {
+			t.Fatalf("%q: want BadRequestError, got %v", c.query, err)
+		}
+		e := QueryError{}
+		if !errors.As(err, &e) {
+			t.Fatalf("%q: want QueryError, got %v", c.query, err)
+		}
+		if e.Pos != c.wantPos {
+			t.Fatalf("%q: got error position %d, want %d", c.query, e.Pos, c.wantPos)
+		}
+		if c.wantErr != "" && e.Msg != c.wantErr {
+			t.Fatalf("%q: got error %q, want %q", c.query, e.Msg, c.wantErr)
+		}
+	}
+}
+
+func TestUnitQuerySQLWhere(t *testing.T) {
+	q, err := ParseUnitQuery("CG/ -tag:hero task:comp assignee:kybin,kim attr.camera:A.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	where, vals := q.sqlWhere("test")
+	want := "units.show=$1 AND units.grp IN ($2) AND NOT ($3::string = ANY(units.tags)) AND ($4::string = ANY(units.tasks)) AND units.attrs ~ $7 AND " +
+		"EXISTS (SELECT 1 FROM tasks WHERE tasks.show=units.show AND tasks.grp=units.grp AND tasks.unit=units.unit AND tasks.task = ANY(units.tasks) AND tasks.task IN ($8) AND tasks.assignee IN ($5, $6))"
+	if where != want {
+		t.Fatalf("got %s, want %s", where, want)
+	}
+	wantVals := []interface{}{"test", "CG", "hero", "comp", "kybin", "kim", "(^|\n)camera: (A\\.1)(\n|$)", "comp"}
+	if !reflect.DeepEqual(vals, wantVals) {
+		t.Fatalf("got %q, want %q", vals, wantVals)
+	}
+}
+
+func TestUnitQueryAttrCompare(t *testing.T) {
+	q, err := ParseUnitQuery("attr.fps>=24 attr.delivery<2020-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	where, vals := q.sqlWhere("test")
+	want := "units.show=$1 AND " +
+		"(CASE WHEN jsonb_typeof(units.typed_attrs->$2::STRING) = 'number' THEN (units.typed_attrs->>$2::STRING)::FLOAT8 END) >= $3::FLOAT8 AND " +
+		"(CASE WHEN jsonb_typeof(units.typed_attrs->$4::STRING) = 'string' THEN units.typed_attrs->>$4::STRING END) < $5"
+	if where != want {
+		t.Fatalf("got %s, want %s", where, want)
+	}
+	wantVals := []interface{}{"test", "fps", "24", "delivery", "2020-01-01"}
+	if !reflect.DeepEqual(vals, wantVals) {
+		t.Fatalf("got %q, want %q", vals, wantVals)
+	}
+}
+
+func TestParseUnitSort(t *testing.T) {
+	cases := []struct {
+		sort     string
+		wantKey  string
+		wantDesc bool
+		wantErr  bool
+	}{
+		{sort: "", wantKey: ""},
+		{sort: "-name", wantKey: "name", wantDesc: true},
+		{sort: "edit-order", wantKey: "edit-order"},
+		{sort: "-due", wantKey: "due", wantDesc: true},
+		{sort: "task.comp.status", wantKey: "task.comp.status"},
+		{sort: "-task.comp.assignee", wantKey: "task.comp.assignee", wantDesc: true},
+		{sort: "task.comp.due", wantErr: true},
+		{sort: "task..status", wantErr: true},
+		{sort: "unknown", wantErr: true},
+	}
+	for _, c := range cases {
+		key, desc, err := ParseUnitSort(c.sort)
+		if c.wantErr {
+			if err == nil {
+				t.Fatalf("%q: want error, got nil", c.sort)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", c.sort, err)
+		}
+		if key != c.wantKey || desc != c.wantDesc {
+			t.Fatalf("%q: got (%q, %v), want (%q, %v)", c.sort, key, desc, c.wantKey, c.wantDesc)
+		}
+	}
+}
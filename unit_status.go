@@ -1,5 +1,7 @@
 package roi
 
+// AllUnitStatus는 사이트에서 따로 정의하지 않아도 유닛이 가질 수 있는 기본 상태이다.
+// 사이트에 새로 정의된 상태도 유닛의 상태로 쓸 수 있다. Site.AvailableUnitStatuses를 참고한다.
 var AllUnitStatus = []Status{
 	StatusOmit,
 	StatusHold,
@@ -7,9 +9,9 @@ var AllUnitStatus = []Status{
 	StatusDone,
 }
 
-// verifyUnitStatus는 받아들인 샷의 상태가 유효하지 않다면 에러를 반환한다.
-func verifyUnitStatus(ss Status) error {
-	for _, s := range AllUnitStatus {
+// verifyUnitStatus는 받아들인 샷의 상태가 사이트에서 쓸 수 없는 상태라면 에러를 반환한다.
+func verifyUnitStatus(site *Site, ss Status) error {
+	for _, s := range site.AvailableUnitStatuses() {
 		if ss == s {
 			return nil
 		}
@@ -0,0 +1,230 @@
+package roi
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnitTableColumns는 유닛 표의 기본 열 이름이다.
+// 엑셀 등으로 유닛을 내보내거나 가져올 때 이 이름을 사용한다.
+// 커스텀 속성 열은 UnitTableAttrPrefix로 시작한다.
+var UnitTableColumns = []string{
+	"show",
+	"group",
+	"unit",
+	"status",
+	"description",
+	"cg_description",
+	"tags",
+	"cut_in",
+	"cut_out",
+	"head_handle",
+	"tail_handle",
+}
+
+// TaskTableFields는 태스크 열에 쓰이는 태스크 필드 이름이다.
+// 태스크 열은 태스크명.필드 형식이다. 예) comp.status, comp.assignee, comp.due
+var TaskTableFields = []string{
+	"status",
+	"assignee",
+	"due",
+}
+
+// UnitTableAttrPrefix는 유닛 표에서 커스텀 속성 열 이름 앞에 붙는 접두어이다. 예) attr.lens
+// 속성 이름이 기본 열이나 태스크 열과 같더라도 구분할 수 있게 한다.
+// 검색어에서 속성을 나타낼 때와 같은 형식이다.
+const UnitTableAttrPrefix = "attr."
+
+// TableDateLayout은 유닛 표에서 날짜를 나타낼 때 쓰는 형식이다.
+const TableDateLayout = "2006-01-02"
+
+// IsUnitTableColumn은 해당 열 이름이 유닛 표의 기본 열인지 검사한다.
+func IsUnitTableColumn(col string) bool {
+	for _, c := range UnitTableColumns {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitAttrColumn은 커스텀 속성 열 이름에서 속성 이름을 반환한다.
+// UnitTableAttrPrefix로 시작하지 않는다면 ok로 false를 반환한다.
+func SplitAttrColumn(col string) (key string, ok bool) {
+	if !strings.HasPrefix(col, UnitTableAttrPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(col, UnitTableAttrPrefix), true
+}
+
+// SplitTaskColumn은 태스크 열 이름을 태스크와 필드로 나눈다.
+// 태스크가 사이트에 정의되어 있지 않거나 필드가 TaskTableFields에 없다면
+// 태스크 열이 아니므로 ok로 false를 반환한다.
+func SplitTaskColumn(site *Site, col string) (task, field string, ok bool) {
+	i := strings.LastIndex(col, ".")
+	if i < 0 {
+		return "", "", false
+	}
+	task = col[:i]
+	field = col[i+1:]
+	hasTask := false
+	for _, t := range site.Tasks {
+		if t == task {
+			hasTask = true
+			break
+		}
+	}
+	if !hasTask {
+		return "", "", false
+	}
+	for _, f := range TaskTableFields {
+		if f == field {
+			return task, field, true
+		}
+	}
+	return "", "", false
+}
+
+// UnitTable은 유닛들과 그 태스크 정보를 표로 만들어 반환한다.
+// 첫 줄은 열 이름이며 기본 열, 커스텀 속성 열, 태스크 열 순서이다.
+// 커스텀 속성 열의 이름은 UnitTableAttrPrefix로 시작한다.
+// 커스텀 속성 열은 이름순으로, 태스크 열은 사이트의 태스크 순서로 정렬된다.
+// 반환된 표는 다시 가져오기에 그대로 쓸 수 있다.
+func UnitTable(db *sql.DB, units []*Unit) ([][]string, error) {
+	site, err := GetSite(db)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make([]string, 0)
+	hasAttr := make(map[string]bool)
+	hasTask := make(map[string]bool)
+	// 태스크는 쇼별로 한번에 불러온다.
+	showUnits := make(map[string][]string)
+	for _, u := range units {
+		showUnits[u.Show] = append(showUnits[u.Show], u.ID())
+	}
+	allTasks := make(map[string][]*Task)
+	for show, ids := range showUnits {
+		tm, err := TasksOfUnits(db, show, ids)
+		if err != nil {
+			return nil, err
+		}
+		for id, ts := range tm {
+			allTasks[id] = ts
+		}
+	}
+	unitTasks := make([]map[string]*Task, len(units))
+	for i, u := range units {
+		for k := range u.Attrs {
+			if !hasAttr[k] {
+				hasAttr[k] = true
+				attrs = append(attrs, k)
+			}
+		}
+		tasks := make(map[string]*Task)
+		for _, t := range allTasks[u.ID()] {
+			tasks[t.Task] = t
+			hasTask[t.Task] = true
+		}
+		unitTasks[i] = tasks
+	}
+	sort.Strings(attrs)
+	tasks := make([]string, 0)
+	for _, t := range site.Tasks {
+		if hasTask[t] {
+			tasks = append(tasks, t)
+		}
+	}
+
+	title := make([]string, 0)
+	title = append(title, UnitTableColumns...)
+	for _, a := range attrs {
+		title = append(title, UnitTableAttrPrefix+a)
+	}
+	for _, t := range tasks {
+		for _, f := range TaskTableFields {
+			title = append(title, t+"."+f)
+		}
+	}
+	table := [][]string{title}
+	for i, u := range units {
+		row := []string{
+			u.Show,
+			u.Group,
+			u.Unit,
+			string(u.Status),
+			u.Description,
+			u.CGDescription,
+			strings.Join(u.Tags, " "),
+			tableInt(u.CutIn),
+			tableInt(u.CutOut),
+			tableInt(u.HeadHandle),
+			tableInt(u.TailHandle),
+		}
+		for _, a := range attrs {
+			row = append(row, u.Attrs[a])
+		}
+		for _, task := range tasks {
+			t := unitTasks[i][task]
+			if t == nil {
+				for range TaskTableFields {
+					row = append(row, "")
+				}
+				continue
+			}
+			row = append(row, string(t.Status), t.Assignee, tableDate(t.DueDate))
+		}
+		table = append(table, row)
+	}
+	return table, nil
+}
+
+// tableDate는 표에 들어갈 날짜 문자열을 반환한다. 날짜가 정해지지 않았다면 빈 문자열이다.
+func tableDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(TableDateLayout)
+}
+
+// tableInt는 표에 들어갈 프레임 숫자 문자열을 반환한다. 정해지지 않은 값인 0은 빈 문자열이다.
+func tableInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// parseTableInt는 표의 숫자 문자열을 정수로 변환한다.
+// 엑셀이 정수를 실수 형식으로 저장하는 경우가 있어 소수점 아래가 0인 실수도 받아들인다.
+func parseTableInt(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err == nil {
+		return n, nil
+	}
+	f, ferr := strconv.ParseFloat(s, 64)
+	if ferr != nil || f != float64(int(f)) {
+		return 0, err
+	}
+	return int(f), nil
+}
+
+// excelEpoch는 엑셀이 날짜를 숫자로 저장할 때 기준이 되는 날이다.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.Local)
+
+// parseTableDate는 표의 날짜 문자열을 시간으로 변환한다.
+// TableDateLayout 형식 외에도 엑셀이 날짜 셀을 저장하는 일련번호 형식을 받아들인다.
+func parseTableDate(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(TableDateLayout, s, time.Local)
+	if err == nil {
+		return t, nil
+	}
+	days, nerr := strconv.ParseFloat(s, 64)
+	if nerr != nil || days < 1 {
+		return time.Time{}, err
+	}
+	return excelEpoch.AddDate(0, 0, int(days)), nil
+}
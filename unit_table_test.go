@@ -0,0 +1,70 @@
+package roi
+
+import "testing"
+
+func TestSplitTaskColumn(t *testing.T) {
+	site := &Site{Tasks: []string{"comp", "fx_fire"}}
+	cases := []struct {
+		col   string
+		task  string
+		field string
+		ok    bool
+	}{
+		{col: "comp.status", task: "comp", field: "status", ok: true},
+		{col: "comp.assignee", task: "comp", field: "assignee", ok: true},
+		{col: "fx_fire.due", task: "fx_fire", field: "due", ok: true},
+		{col: "comp.note", ok: false},
+		{col: "lit.status", ok: false},
+		{col: "status", ok: false},
+		{col: "frame.in", ok: false},
+	}
+	for _, c := range cases {
+		task, field, ok := SplitTaskColumn(site, c.col)
+		if task != c.task || field != c.field || ok != c.ok {
+			t.Fatalf("%s: got (%q, %q, %v), want (%q, %q, %v)", c.col, task, field, ok, c.task, c.field, c.ok)
+		}
+	}
+}
+
+func TestParseTableDate(t *testing.T) {
+	cases := []struct {
+		s    string
+		want string
+		ok   bool
+	}{
+		{s: "2020-03-01", want: "2020-03-01", ok: true},
+		{s: "43891", want: "2020-03-01", ok: true},
+		{s: "2020-13-01", ok: false},
+		{s: "0", ok: false},
+		{s: "내일", ok: false},
+	}
+	for _, c := range cases {
+		d, err := parseTableDate(c.s)
+		if (err == nil) != c.ok {
+			t.Fatalf("%s: got error %v, want ok %v", c.s, err, c.ok)
+		}
+		if c.ok && tableDate(d) != c.want {
+			t.Fatalf("%s: got %s, want %s", c.s, tableDate(d), c.want)
+		}
+	}
+}
+
+func TestSplitAttrColumn(t *testing.T) {
+	cases := []struct {
+		col string
+		key string
+		ok  bool
+	}{
+		{col: "attr.lens", key: "lens", ok: true},
+		{col: "attr.status", key: "status", ok: true},
+		{col: "attr.comp.status", key: "comp.status", ok: true},
+		{col: "lens", ok: false},
+		{col: "status", ok: false},
+	}
+	for _, c := range cases {
+		key, ok := SplitAttrColumn(c.col)
+		if key != c.key || ok != c.ok {
+			t.Fatalf("%s: got (%q, %v), want (%q, %v)", c.col, key, ok, c.key, c.ok)
+		}
+	}
+}
@@ -15,6 +15,10 @@ var testUnitA = &Unit{
 	Description:   "방에 우두커니 혼자 않아 있는 로이.",
 	CGDescription: "조명판 들고 있는 사람이 촬영되었으니 지워주세요.",
 	Tags:          []string{"로이", "리무브"},
+	CutIn:         1001,
+	CutOut:        1132,
+	HeadHandle:    8,
+	TailHandle:    8,
 	Assets:        []string{},
 	// 사이트에 이 샷 태스크가 존재해야만 에러가 나지 않는다.
 	Tasks: []string{"fx"},
@@ -34,6 +38,8 @@ var testUnitB = &Unit{
 	Description:   "고개를 돌려 창문 밖을 바라본다.",
 	CGDescription: "전반적인 느낌을 어둡게 바꿔주세요.",
 	Tags:          []string{"로이", "창문"},
+	CutIn:         1001,
+	CutOut:        1015,
 	Assets:        []string{},
 	Tasks:         []string{"lit"},
 	Attrs: DBStringMap{
@@ -118,7 +124,7 @@ func TestUnit(t *testing.T) {
 		}
 	}
 
-	got, err := SearchUnits(db, testShow.Show, []string{}, []string{}, "", "", "", "", "", time.Time{})
+	got, err := SearchUnits(db, testShow.Show, []string{}, []string{}, "", "", "", "", "", "", time.Time{})
 	if err != nil {
 		t.Fatalf("could not search units from units table: %s", err)
 	}
@@ -129,7 +135,7 @@ func TestUnit(t *testing.T) {
 		t.Fatalf("got: %v, want: %v", got, want)
 	}
 
-	got, err = SearchUnits(db, testShow.Show, []string{"CG"}, []string{"0010"}, "", "", "", "", "", time.Time{})
+	got, err = SearchUnits(db, testShow.Show, []string{"CG"}, []string{"0010"}, "", "", "", "", "", "", time.Time{})
 	if err != nil {
 		t.Fatalf("could not search units from units table: %s", err)
 	}
@@ -137,7 +143,7 @@ func TestUnit(t *testing.T) {
 	if !reflect.DeepEqual(got, want) {
 		t.Fatalf("got: %v, want: %v", got, want)
 	}
-	got, err = SearchUnits(db, testShow.Show, []string{}, []string{}, "로이", "", "", "", "", time.Time{})
+	got, err = SearchUnits(db, testShow.Show, []string{}, []string{}, "로이", "", "", "", "", "", time.Time{})
 	if err != nil {
 		t.Fatalf("could not search units from units table: %s", err)
 	}
@@ -145,6 +151,61 @@ func TestUnit(t *testing.T) {
 	if !reflect.DeepEqual(got, want) {
 		t.Fatalf("got: %v, want: %v", got, want)
 	}
+	got, err = SearchUnitsQuery(db, testShow.Show, "duration>100")
+	if err != nil {
+		t.Fatalf("could not search units from units table: %s", err)
+	}
+	if !reflect.DeepEqual(got, []*Unit{testUnitA}) {
+		t.Fatalf("duration>100: got: %v, want: %v", got, []*Unit{testUnitA})
+	}
+	// 프레임 구간이 없는 testUnitC는 길이 조건에 맞지 않는다.
+	got, err = SearchUnitsQuery(db, testShow.Show, "duration:<=15")
+	if err != nil {
+		t.Fatalf("could not search units from units table: %s", err)
+	}
+	if !reflect.DeepEqual(got, []*Unit{testUnitB}) {
+		t.Fatalf("duration:<=15: got: %v, want: %v", got, []*Unit{testUnitB})
+	}
+	queries := []struct {
+		q    string
+		want []*Unit
+	}{
+		{q: "status:in-progress,hold -tag:창문", want: []*Unit{testUnitA}},
+		{q: "CG/ -tag:리무브 duration>10", want: []*Unit{testUnitB}},
+		{q: "attr.duration:15,36", want: []*Unit{testUnitB, testUnitC}},
+		{q: "-attr.timecode_in:*", want: []*Unit{}},
+		{q: "0010 CG/0030", want: []*Unit{testUnitA, testUnitC}},
+		{q: "task:lit,comp -status=hold", want: []*Unit{}},
+	}
+	for _, c := range queries {
+		got, err = SearchUnitsQuery(db, testShow.Show, c.q)
+		if err != nil {
+			t.Fatalf("%s: could not search units from units table: %s", c.q, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%s: got: %v, want: %v", c.q, got, c.want)
+		}
+	}
+	pages := []struct {
+		opt       UnitSearchOptions
+		want      []*Unit
+		wantTotal int
+	}{
+		{opt: UnitSearchOptions{Limit: 2}, want: []*Unit{testUnitA, testUnitB}, wantTotal: 3},
+		{opt: UnitSearchOptions{Offset: 2, Limit: 2}, want: []*Unit{testUnitC}, wantTotal: 3},
+		{opt: UnitSearchOptions{Sort: "edit-order", Desc: true, Limit: 2}, want: []*Unit{testUnitC, testUnitB}, wantTotal: 3},
+		{opt: UnitSearchOptions{Sort: "status"}, want: []*Unit{testUnitB, testUnitC, testUnitA}, wantTotal: 3},
+		{opt: UnitSearchOptions{Sort: "task.fx.status"}, want: []*Unit{testUnitA, testUnitB, testUnitC}, wantTotal: 3},
+	}
+	for _, c := range pages {
+		res, err := SearchUnitsPage(db, testShow.Show, "", c.opt)
+		if err != nil {
+			t.Fatalf("%v: could not search units page: %s", c.opt, err)
+		}
+		if !reflect.DeepEqual(res.Units, c.want) || res.Total != c.wantTotal {
+			t.Fatalf("%v: got: %v (total %d), want: %v (total %d)", c.opt, res.Units, res.Total, c.want, c.wantTotal)
+		}
+	}
 
 	for _, s := range want {
 		err = UpdateUnit(db, s)
@@ -157,3 +218,62 @@ func TestUnit(t *testing.T) {
 		}
 	}
 }
+
+func TestUnitDuration(t *testing.T) {
+	cases := []struct {
+		unit    *Unit
+		want    int
+		wantErr bool
+	}{
+		{unit: &Unit{}, want: 0},
+		{unit: &Unit{CutIn: 1001, CutOut: 1132}, want: 132},
+		{unit: &Unit{CutIn: 1001, CutOut: 1001}, want: 1},
+		{unit: &Unit{CutIn: 0, CutOut: 23}, want: 24},
+		{unit: &Unit{CutIn: 1001}, want: -1000, wantErr: true},
+		{unit: &Unit{CutIn: -1, CutOut: 10}, want: 12, wantErr: true},
+		{unit: &Unit{CutIn: 1001, CutOut: 1010, HeadHandle: -1}, want: 10, wantErr: true},
+	}
+	for _, c := range cases {
+		got := c.unit.Duration()
+		if got != c.want {
+			t.Fatalf("%d-%d: got duration %d, want %d", c.unit.CutIn, c.unit.CutOut, got, c.want)
+		}
+		err := verifyUnitFrames(c.unit)
+		if (err != nil) != c.wantErr {
+			t.Fatalf("%d-%d: got error %v, want error: %v", c.unit.CutIn, c.unit.CutOut, err, c.wantErr)
+		}
+	}
+}
+
+func TestParseIntCondition(t *testing.T) {
+	cases := []struct {
+		cond    string
+		op      string
+		n       int
+		wantErr bool
+	}{
+		{cond: ">100", op: ">", n: 100},
+		{cond: "<=48", op: "<=", n: 48},
+		{cond: "24", op: "=", n: 24},
+		{cond: "=24", op: "=", n: 24},
+		{cond: "!=24", wantErr: true},
+		{cond: ">", wantErr: true},
+		{cond: "> 100", wantErr: true},
+		{cond: "1; DROP TABLE units", wantErr: true},
+	}
+	for _, c := range cases {
+		op, n, err := parseIntCondition(c.cond)
+		if c.wantErr {
+			if err == nil {
+				t.Fatalf("%q: want error, got none", c.cond)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", c.cond, err)
+		}
+		if op != c.op || n != c.n {
+			t.Fatalf("%q: got %s %d, want %s %d", c.cond, op, n, c.op, c.n)
+		}
+	}
+}
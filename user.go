@@ -57,9 +57,18 @@ var CreateTableIfNotExistsUsersStmt = `CREATE TABLE IF NOT EXISTS users (
 	entry_date STRING NOT NULL,
 	hashed_password STRING NOT NULL,
 	current_show STRING NOT NULL,
+	pinned_searches STRING[] NOT NULL DEFAULT ARRAY[],
+	language STRING NOT NULL DEFAULT '',
 	CONSTRAINT users_pk PRIMARY KEY (id)
 )`
 
+// AlterTableUsersStmts는 이전 버전에서 만들어진 users 테이블에
+// 새로 추가된 열을 추가하는 sql 구문들이다. 여러번 실행해도 안전하다.
+var AlterTableUsersStmts = []string{
+	"ALTER TABLE users ADD COLUMN IF NOT EXISTS pinned_searches STRING[] NOT NULL DEFAULT ARRAY[]",
+	"ALTER TABLE users ADD COLUMN IF NOT EXISTS language STRING NOT NULL DEFAULT ''",
+}
+
 // AddUser는 db에 한 명의 사용자를 추가한다.
 func AddUser(db *sql.DB, id, pw string) error {
 	if id == "" {
@@ -159,8 +168,16 @@ func UpdateUser(db *sql.DB, id string, u *User) error {
 
 type UserConfig struct {
 	CurrentShow string `db:"current_show"`
+	// PinnedSearches는 메뉴에 고정한 저장된 검색들의 아이디이다.
+	PinnedSearches []string `db:"pinned_searches"`
+	// Language는 사용자가 UI에서 사용할 언어이다. AllLanguages 중 하나이며,
+	// 빈 문자열이면 브라우저의 언어 설정을 따른다.
+	Language string `db:"language"`
 }
 
+// AllLanguages는 로이 UI가 지원하는 언어이다.
+var AllLanguages = []string{"ko", "en"}
+
 var userConfigDBKey string = strings.Join(dbKeys(&UserConfig{}), ", ")
 var userConfigDBIdx string = strings.Join(dbIdxs(&UserConfig{}), ", ")
 var _ []interface{} = dbVals(&UserConfig{})
@@ -184,6 +201,27 @@ func GetUserConfig(db *sql.DB, id string) (*UserConfig, error) {
 	return u, nil
 }
 
+// GetUserAndConfig는 유저와 그 설정 값들을 한번의 쿼리로 받아온다.
+// 매 요청마다 세션 유저와 그 설정을 함께 불러올 때 사용한다.
+func GetUserAndConfig(db *sql.DB, id string) (*User, *UserConfig, error) {
+	if id == "" {
+		return nil, nil, errors.New("need id")
+	}
+	stmt := dbStmt(fmt.Sprintf("SELECT %s, %s FROM users WHERE id=$1", userDBKey, userConfigDBKey), id)
+	u := &User{}
+	cfg := &UserConfig{}
+	err := dbQueryRow(db, stmt, func(row *sql.Row) error {
+		return row.Scan(append(dbAddrs(u), dbAddrs(cfg)...)...)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, NotFound("user not found: %s", id)
+		}
+		return nil, nil, err
+	}
+	return u, cfg, nil
+}
+
 // UpdateUserConfig는 유저의 설정 값들을 업데이트 한다.
 func UpdateUserConfig(db *sql.DB, id string, u *UserConfig) error {
 	if id == "" {
@@ -192,6 +230,9 @@ func UpdateUserConfig(db *sql.DB, id string, u *UserConfig) error {
 	if u == nil {
 		return BadRequest("user config shold not nil")
 	}
+	if u.Language != "" && !hasString(AllLanguages, u.Language) {
+		return BadRequest("unsupported language: %s", u.Language)
+	}
 	stmts := []dbStatement{
 		dbStmt(fmt.Sprintf("UPDATE users SET (%s) = (%s) WHERE id='%s'", userConfigDBKey, userConfigDBIdx, id), dbVals(u)...),
 	}
@@ -220,6 +261,10 @@ func DeleteUser(db *sql.DB, id string) error {
 	}
 	stmts := []dbStatement{
 		dbStmt(fmt.Sprintf("DELETE FROM users WHERE id='%s'", id)),
+		dbStmt("DELETE FROM search_subscriptions WHERE user_id=$1", id),
+		dbStmt("DELETE FROM notifications WHERE user_id=$1", id),
+		dbStmt("DELETE FROM change_sets WHERE author=$1", id),
+		dbStmt("DELETE FROM change_set_items WHERE author=$1", id),
 	}
 	return dbExec(db, stmts)
 }
@@ -35,8 +35,8 @@ type Version struct {
 	Version string `db:"version"` // 버전명
 
 	Owner       string   `db:"owner"`        // 버전 소유자
-	OutputFiles []string `db:"output_files"` // 결과물 경로
-	Images      []string `db:"images"`       // 결과물을 확인할 수 있는 이미지
+	OutputFiles []string `db:"output_files"` // 결과물 경로, 시퀀스는 묶여서 저장된다.
+	Images      []string `db:"images"`       // 결과물을 확인할 수 있는 이미지, 시퀀스는 묶여서 저장된다.
 	Mov         string   `db:"mov"`          // 결과물을 영상으로 볼 수 있는 경로
 	WorkFile    string   `db:"work_file"`    // 이 결과물을 만든 작업 파일
 }
@@ -60,6 +60,16 @@ func (v *Version) TaskID() string {
 	return v.Show + "/" + v.Group + "/" + v.Unit + "/" + v.Task
 }
 
+// OutputSequences는 버전의 결과물을 시퀀스로 반환한다.
+func (v *Version) OutputSequences() []*Sequence {
+	return parseSequences(v.OutputFiles)
+}
+
+// ImageSequences는 버전의 이미지를 시퀀스로 반환한다.
+func (v *Version) ImageSequences() []*Sequence {
+	return parseSequences(v.Images)
+}
+
 // reVersionName은 가능한 버전명을 정의하는 정규식이다.
 var reVersionName = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
 
@@ -128,6 +138,9 @@ func verifyVersion(db *sql.DB, v *Version) error {
 	if err != nil {
 		return err
 	}
+	// 같은 시퀀스에 속한 파일들은 하나의 시퀀스로 묶는다.
+	v.OutputFiles = collapseSequenceStrings(v.OutputFiles)
+	v.Images = collapseSequenceStrings(v.Images)
 	return nil
 }
 
@@ -172,10 +185,7 @@ func GetVersion(db *sql.DB, show, grp, unit, task, ver string) (*Version, error)
 	if err != nil {
 		return nil, err
 	}
-	_, err = GetTask(db, show, grp, unit, task)
-	if err != nil {
-		return nil, err
-	}
+	// 부모는 버전을 찾지 못했을 때만 검사한다.
 	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM versions WHERE show=$1 AND grp=$2 AND unit=$3 AND task=$4 AND version=$5 LIMIT 1", versionDBKey), show, grp, unit, task, ver)
 	v := &Version{}
 	err = dbQueryRow(db, stmt, func(row *sql.Row) error {
@@ -183,6 +193,10 @@ func GetVersion(db *sql.DB, show, grp, unit, task, ver string) (*Version, error)
 	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			_, err = GetTask(db, show, grp, unit, task)
+			if err != nil {
+				return nil, err
+			}
 			return nil, NotFound("version not found: %s", JoinVersionID(show, grp, unit, task, ver))
 		}
 		return nil, err
@@ -196,10 +210,6 @@ func TaskVersions(db *sql.DB, show, grp, unit, task string) ([]*Version, error)
 	if err != nil {
 		return nil, err
 	}
-	_, err = GetTask(db, show, grp, unit, task)
-	if err != nil {
-		return nil, err
-	}
 	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM versions WHERE show=$1 AND grp=$2 AND unit=$3 AND task=$4", versionDBKey), show, grp, unit, task)
 	versions := make([]*Version, 0)
 	err = dbQuery(db, stmt, func(rows *sql.Rows) error {
@@ -214,12 +224,65 @@ func TaskVersions(db *sql.DB, show, grp, unit, task string) ([]*Version, error)
 	if err != nil {
 		return nil, err
 	}
+	if len(versions) == 0 {
+		// 버전이 없을 때만 태스크가 있는지 검사한다.
+		_, err = GetTask(db, show, grp, unit, task)
+		if err != nil {
+			return nil, err
+		}
+	}
 	sort.Slice(versions, func(i, j int) bool {
 		return strings.Compare(versions[i].Version, versions[j].Version) < 0
 	})
 	return versions, nil
 }
 
+// VersionsOfTasks는 한 쇼에 속한 여러 태스크의 버전을 한번의 쿼리로 불러와
+// 태스크 아이디별로 반환한다. 각 태스크의 버전은 이름 순으로 정렬된다.
+// 버전이 없는 태스크는 결과에서 빠진다.
+func VersionsOfTasks(db *sql.DB, show string, taskIDs []string) (map[string][]*Version, error) {
+	err := verifyShowPrimaryKeys(show)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string][]*Version)
+	if len(taskIDs) == 0 {
+		return versions, nil
+	}
+	args := &queryArgs{}
+	args.add(show)
+	keys := make([]string, 0, len(taskIDs))
+	for _, id := range taskIDs {
+		s, grp, unit, task, err := SplitTaskID(id)
+		if err != nil {
+			return nil, err
+		}
+		if s != show {
+			return nil, BadRequest("task not in show %s: %s", show, id)
+		}
+		keys = append(keys, "("+args.add(grp)+", "+args.add(unit)+", "+args.add(task)+")")
+	}
+	stmt := dbStmt(fmt.Sprintf("SELECT %s FROM versions WHERE show=$1 AND (grp, unit, task) IN (%s)", versionDBKey, strings.Join(keys, ", ")), args.vals...)
+	err = dbQuery(db, stmt, func(rows *sql.Rows) error {
+		v := &Version{}
+		err := scan(rows, v)
+		if err != nil {
+			return err
+		}
+		versions[v.TaskID()] = append(versions[v.TaskID()], v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, vs := range versions {
+		sort.Slice(vs, func(i, j int) bool {
+			return strings.Compare(vs[i].Version, vs[j].Version) < 0
+		})
+	}
+	return versions, nil
+}
+
 // DeleteVersion은 해당 버전과 그 하위의 모든 데이터를 db에서 지운다.
 // 만일 처리 중간에 에러가 나면 아무 데이터도 지우지 않고 에러를 반환한다.
 func DeleteVersion(db *sql.DB, show, grp, unit, task, ver string) error {
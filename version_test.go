@@ -103,6 +103,13 @@ func TestVersion(t *testing.T) {
 	if len(taskVersions) != 1 {
 		t.Fatalf("task should have 1 version at this time.")
 	}
+	versions, err := VersionsOfTasks(db, testTaskA.Show, []string{testTaskA.ID()})
+	if err != nil {
+		t.Fatalf("could not get versions of tasks: %v", err)
+	}
+	if !reflect.DeepEqual(versions[testTaskA.ID()], []*Version{testVersionA}) {
+		t.Fatalf("versions of tasks: got %v, want %v", versions[testTaskA.ID()], []*Version{testVersionA})
+	}
 	err = UpdateVersion(db, testVersionA)
 	if err != nil {
 		t.Fatalf("could not update version: %v", err)
@@ -0,0 +1,335 @@
+package roi
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DefaultWorkflow는 사이트에 워크플로우가 정의되지 않았을 때 사용하는 워크플로우이다.
+var DefaultWorkflow = `state hold
+state in-progress
+state done require publish_version
+start in-progress
+review retake approved
+* -> *
+`
+
+// workflowFields는 워크플로우 상태에서 요구할 수 있는 태스크 필드이다.
+var workflowFields = []string{
+	"assignee",
+	"due_date",
+	"working_version",
+	"review_version",
+	"approved_version",
+	"publish_version",
+}
+
+// workflowRoles는 워크플로우 전환을 일으킬 수 있는 사람을 나타내는 역할이다.
+// 그 외에 user:<아이디> 형식으로 특정 사용자를 지정할 수 있다.
+var workflowRoles = []string{
+	"assignee",
+	"leads",
+	"vfx_supervisors",
+	"vfx_producers",
+	"cg_supervisors",
+	"project_managers",
+}
+
+// Workflow는 태스크가 가질 수 있는 상태와 상태 사이의 전환 규칙이다.
+// 사이트의 Workflow 필드에 여러줄의 문자열로 저장되며 각 줄은 다음 중 하나이다.
+// # 뒤의 내용은 주석으로 무시한다.
+//
+//	state <상태> [require <필드>...]
+//	start <상태>
+//	review <상태>...
+//	<상태|*> -> <상태|*>[,<상태>...] [by <사람>...]
+//
+// state는 태스크의 상태를 순서대로 정의한다. require 뒤의 태스크 필드가 모두 채워져 있어야
+// 태스크가 그 상태가 될 수 있다.
+// start는 새로 생기는 태스크의 상태이며 정의하지 않으면 처음 정의된 상태이다.
+// review는 리뷰시 고를 수 있는 상태이다. 리뷰한 상태가 state로도 정의되어 있다면
+// 태스크의 상태도 그 상태로 바뀐다.
+// 화살표는 허용되는 상태 전환이며 *는 모든 상태를 뜻한다. by 뒤에 그 전환을 일으킬 수 있는
+// 사람을 역할(workflowRoles) 또는 user:<아이디>로 적고, 적지 않으면 누구나 전환할 수 있다.
+type Workflow struct {
+	States      []*WorkflowState
+	Start       Status
+	Review      []Status
+	Transitions []*WorkflowTransition
+}
+
+// WorkflowState는 워크플로우에 정의된 하나의 태스크 상태이다.
+type WorkflowState struct {
+	Status  Status
+	Require []string
+}
+
+// WorkflowTransition은 워크플로우에서 허용되는 하나의 상태 전환이다.
+type WorkflowTransition struct {
+	From Status
+	To   Status
+	By   []string
+}
+
+// ParseWorkflow는 문자열로 정의된 워크플로우를 해석해 반환한다.
+// 빈 문자열이라면 DefaultWorkflow를 해석해 반환한다.
+// 정의가 잘못되었다면 BadRequest 에러를 반환한다.
+func ParseWorkflow(s string) (*Workflow, error) {
+	if strings.TrimSpace(s) == "" {
+		s = DefaultWorkflow
+	}
+	w := &Workflow{
+		States:      make([]*WorkflowState, 0),
+		Review:      make([]Status, 0),
+		Transitions: make([]*WorkflowTransition, 0),
+	}
+	trs := make([]*WorkflowTransition, 0)
+	for i, ln := range strings.Split(s, "\n") {
+		if n := strings.Index(ln, "#"); n != -1 {
+			ln = ln[:n]
+		}
+		f := strings.Fields(ln)
+		if len(f) == 0 {
+			continue
+		}
+		bad := func(msg string, vals ...interface{}) error {
+			return BadRequest("invalid workflow: line %d: %s", i+1, fmt.Sprintf(msg, vals...))
+		}
+		switch f[0] {
+		case "state":
+			if len(f) < 2 {
+				return nil, bad("state not specified")
+			}
+			st := &WorkflowState{Status: Status(f[1]), Require: make([]string, 0)}
+			err := verifyStatusName(st.Status)
+			if err != nil {
+				return nil, bad("%v", err)
+			}
+			if w.State(st.Status) != nil {
+				return nil, bad("state defined twice: %s", st.Status)
+			}
+			if len(f) > 2 {
+				if f[2] != "require" {
+					return nil, bad("want require, got %s", f[2])
+				}
+				for _, fld := range f[3:] {
+					if !hasString(workflowFields, fld) {
+						return nil, bad("invalid task field: %s", fld)
+					}
+					st.Require = append(st.Require, fld)
+				}
+			}
+			w.States = append(w.States, st)
+		case "start":
+			if len(f) != 2 {
+				return nil, bad("start needs exactly one state")
+			}
+			w.Start = Status(f[1])
+		case "review":
+			for _, r := range f[1:] {
+				err := verifyStatusName(Status(r))
+				if err != nil {
+					return nil, bad("%v", err)
+				}
+				w.Review = append(w.Review, Status(r))
+			}
+		default:
+			// 전환은 상태가 모두 정의된 후에 검사한다.
+			if len(f) < 3 || f[1] != "->" {
+				return nil, bad("unknown definition: %s", strings.TrimSpace(ln))
+			}
+			by := make([]string, 0)
+			if len(f) > 3 {
+				if f[3] != "by" || len(f) == 4 {
+					return nil, bad("want by and who can change the state after transition")
+				}
+				for _, b := range f[4:] {
+					if !hasString(workflowRoles, b) && !(strings.HasPrefix(b, "user:") && len(b) > len("user:")) {
+						return nil, bad("invalid role: %s", b)
+					}
+					by = append(by, b)
+				}
+			}
+			for _, to := range strings.Split(f[2], ",") {
+				if to == "" {
+					continue
+				}
+				trs = append(trs, &WorkflowTransition{From: Status(f[0]), To: Status(to), By: by})
+			}
+		}
+	}
+	if len(w.States) == 0 {
+		return nil, BadRequest("invalid workflow: no state defined")
+	}
+	if w.Start == "" {
+		w.Start = w.States[0].Status
+	}
+	if w.State(w.Start) == nil {
+		return nil, BadRequest("invalid workflow: start state not defined: %s", w.Start)
+	}
+	for _, tr := range trs {
+		for _, st := range []Status{tr.From, tr.To} {
+			if st != "*" && w.State(st) == nil {
+				return nil, BadRequest("invalid workflow: transition state not defined: %s", st)
+			}
+		}
+		w.Transitions = append(w.Transitions, tr)
+	}
+	return w, nil
+}
+
+// SiteWorkflow는 db에 저장된 사이트의 워크플로우를 반환한다.
+func SiteWorkflow(db *sql.DB) (*Workflow, error) {
+	_, w, err := siteWorkflow(db)
+	return w, err
+}
+
+// siteWorkflow는 db의 사이트와 그 워크플로우를 반환한다.
+func siteWorkflow(db *sql.DB) (*Site, *Workflow, error) {
+	s, err := GetSite(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	w, err := ParseWorkflow(s.Workflow)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, w, nil
+}
+
+// State는 워크플로우에 정의된 상태를 반환한다. 정의되지 않은 상태라면 nil을 반환한다.
+func (w *Workflow) State(s Status) *WorkflowState {
+	for _, st := range w.States {
+		if st.Status == s {
+			return st
+		}
+	}
+	return nil
+}
+
+// Statuses는 워크플로우에 정의된 상태들을 정의된 순서대로 반환한다.
+func (w *Workflow) Statuses() []Status {
+	ss := make([]Status, 0, len(w.States))
+	for _, st := range w.States {
+		ss = append(ss, st.Status)
+	}
+	return ss
+}
+
+// NextStatuses는 user가 태스크를 바꿀 수 있는 상태들을 현재 상태를 포함해 정의된 순서대로 반환한다.
+func (w *Workflow) NextStatuses(site *Site, t *Task, user string) []Status {
+	ss := make([]Status, 0)
+	for _, st := range w.States {
+		if w.verifyTransition(site, t.Status, st.Status, t, user) == nil {
+			ss = append(ss, st.Status)
+		}
+	}
+	return ss
+}
+
+// verifyTask는 태스크의 상태가 워크플로우에 정의되지 않았거나
+// 그 상태가 요구하는 필드가 비어 있다면 BadRequest 에러를 반환한다.
+func (w *Workflow) verifyTask(t *Task) error {
+	st := w.State(t.Status)
+	if st == nil {
+		return BadRequest("invalid task status: '%s'", t.Status)
+	}
+	for _, fld := range st.Require {
+		if !taskFieldSet(t, fld) {
+			return BadRequest("cannot set task status to %s: no %s", t.Status, fld)
+		}
+	}
+	return nil
+}
+
+// verifyReviewStatus는 리뷰시 고를 수 없는 상태라면 BadRequest 에러를 반환한다.
+func (w *Workflow) verifyReviewStatus(s Status) error {
+	for _, r := range w.Review {
+		if r == s {
+			return nil
+		}
+	}
+	return BadRequest("invalid review status: '%s'", s)
+}
+
+// verifyTransition은 태스크 t의 상태를 from에서 to로 바꾸는 전환이 워크플로우에 없다면
+// BadRequest 에러를, user가 그 전환을 일으킬 수 없다면 Auth 에러를 반환한다.
+// 상태가 바뀌지 않는다면 검사하지 않는다. 누가 바꾸는지 모르는 전환은 허용하지 않으므로
+// user가 빈 문자열이면 Auth 에러를 반환한다.
+func (w *Workflow) verifyTransition(site *Site, from, to Status, t *Task, user string) error {
+	if from == to {
+		return nil
+	}
+	if user == "" {
+		return Auth("user not specified: cannot change status of task %s from %s to %s", t.ID(), from, to)
+	}
+	found := false
+	for _, tr := range w.Transitions {
+		if (tr.From != "*" && tr.From != from) || (tr.To != "*" && tr.To != to) {
+			continue
+		}
+		if len(tr.By) == 0 {
+			return nil
+		}
+		found = true
+		for _, b := range tr.By {
+			if hasRole(site, t, b, user) {
+				return nil
+			}
+		}
+	}
+	if found {
+		return Auth("%s cannot change status of task %s from %s to %s", user, t.ID(), from, to)
+	}
+	return BadRequest("cannot change status of task %s from %s to %s", t.ID(), from, to)
+}
+
+// taskFieldSet은 태스크의 해당 필드가 채워져 있는지를 반환한다.
+func taskFieldSet(t *Task, field string) bool {
+	switch field {
+	case "assignee":
+		return t.Assignee != ""
+	case "due_date":
+		return !t.DueDate.IsZero()
+	case "working_version":
+		return t.WorkingVersion != ""
+	case "review_version":
+		return t.ReviewVersion != ""
+	case "approved_version":
+		return t.ApprovedVersion != ""
+	case "publish_version":
+		return t.PublishVersion != ""
+	}
+	return false
+}
+
+// hasRole은 user가 태스크 t에 대해 워크플로우 역할 role을 가지고 있는지를 반환한다.
+func hasRole(site *Site, t *Task, role, user string) bool {
+	switch role {
+	case "assignee":
+		return t.Assignee == user
+	case "leads":
+		for _, l := range site.Leads {
+			kv := strings.SplitN(l, ":", 2)
+			if len(kv) != 2 || strings.TrimSpace(kv[0]) != t.Task {
+				continue
+			}
+			for _, u := range strings.Split(kv[1], ",") {
+				if strings.TrimSpace(u) == user {
+					return true
+				}
+			}
+		}
+		return false
+	case "vfx_supervisors":
+		return hasString(site.VFXSupervisors, user)
+	case "vfx_producers":
+		return hasString(site.VFXProducers, user)
+	case "cg_supervisors":
+		return hasString(site.CGSupervisors, user)
+	case "project_managers":
+		return hasString(site.ProjectManagers, user)
+	}
+	return role == "user:"+user
+}
@@ -0,0 +1,138 @@
+package roi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var testWorkflow = `# 클라이언트 리뷰가 있는 워크플로우
+state ready-to-start
+state in-progress require assignee
+state pending-internal-review require review_version
+state waiting-for-client require review_version
+state retake
+state done require publish_version
+start ready-to-start
+review retake approved waiting-for-client
+ready-to-start -> in-progress
+in-progress -> pending-internal-review by assignee leads
+pending-internal-review -> waiting-for-client,retake by cg_supervisors user:kybin
+waiting-for-client -> done,retake by vfx_supervisors
+retake -> in-progress
+* -> ready-to-start by project_managers
+`
+
+func TestParseWorkflow(t *testing.T) {
+	w, err := ParseWorkflow("")
+	if err != nil {
+		t.Fatalf("could not parse default workflow: %v", err)
+	}
+	if !reflect.DeepEqual(w.Statuses(), AllTaskStatus) {
+		t.Fatalf("default workflow statuses: got %v, want %v", w.Statuses(), AllTaskStatus)
+	}
+	if !reflect.DeepEqual(w.Review, AllReviewStatus) {
+		t.Fatalf("default workflow review statuses: got %v, want %v", w.Review, AllReviewStatus)
+	}
+	if w.Start != StatusInProgress {
+		t.Fatalf("default workflow start: got %v, want %v", w.Start, StatusInProgress)
+	}
+
+	w, err = ParseWorkflow(testWorkflow)
+	if err != nil {
+		t.Fatalf("could not parse workflow: %v", err)
+	}
+	if len(w.States) != 6 || w.Start != "ready-to-start" {
+		t.Fatalf("unexpected workflow: %v states, start %v", len(w.States), w.Start)
+	}
+	want := []*WorkflowTransition{
+		{From: "pending-internal-review", To: "waiting-for-client", By: []string{"cg_supervisors", "user:kybin"}},
+		{From: "pending-internal-review", To: "retake", By: []string{"cg_supervisors", "user:kybin"}},
+	}
+	if !reflect.DeepEqual(w.Transitions[2:4], want) {
+		t.Fatalf("transitions: got %v, want %v", w.Transitions[2:4], want)
+	}
+
+	bads := []string{
+		"hold -> done",
+		"state hold\nstate hold",
+		"state hold require nothing",
+		"state Hold",
+		"state hold\nstart done",
+		"state hold\nhold -> done",
+		"state hold\nhold -> hold by everyone",
+		"state hold\nhold done",
+	}
+	for _, b := range bads {
+		_, err := ParseWorkflow(b)
+		if !errors.As(err, &BadRequestError{}) {
+			t.Fatalf("%q: want bad request error, got %v", b, err)
+		}
+	}
+}
+
+func TestWorkflowTransition(t *testing.T) {
+	w, err := ParseWorkflow(testWorkflow)
+	if err != nil {
+		t.Fatalf("could not parse workflow: %v", err)
+	}
+	site := &Site{
+		VFXSupervisors:  []string{"sup"},
+		CGSupervisors:   []string{"cgsup"},
+		ProjectManagers: []string{"pm"},
+		Leads:           []string{"comp: lead1, lead2"},
+	}
+	task := &Task{Show: "TEST", Group: "CG", Unit: "0010", Task: "comp", Status: "in-progress", Assignee: "artist"}
+	cases := []struct {
+		to      Status
+		user    string
+		wantErr error
+	}{
+		{to: "in-progress", user: "anyone"},
+		{to: "in-progress", user: ""},
+		{to: "pending-internal-review", user: "", wantErr: AuthError{}},
+		{to: "pending-internal-review", user: "artist"},
+		{to: "pending-internal-review", user: "lead2"},
+		{to: "pending-internal-review", user: "sup", wantErr: AuthError{}},
+		{to: "ready-to-start", user: "pm"},
+		{to: "ready-to-start", user: "artist", wantErr: AuthError{}},
+		{to: "done", user: "sup", wantErr: BadRequestError{}},
+	}
+	for _, c := range cases {
+		err := w.verifyTransition(site, task.Status, c.to, task, c.user)
+		if c.wantErr == nil {
+			if err != nil {
+				t.Fatalf("%s -> %s by %q: %v", task.Status, c.to, c.user, err)
+			}
+			continue
+		}
+		target := reflect.New(reflect.TypeOf(c.wantErr)).Interface()
+		if !errors.As(err, target) {
+			t.Fatalf("%s -> %s by %q: want %T, got %v", task.Status, c.to, c.user, c.wantErr, err)
+		}
+	}
+	got := w.NextStatuses(site, task, "artist")
+	want := []Status{"in-progress", "pending-internal-review"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("next statuses: got %v, want %v", got, want)
+	}
+}
+
+func TestWorkflowVerifyTask(t *testing.T) {
+	w, err := ParseWorkflow(testWorkflow)
+	if err != nil {
+		t.Fatalf("could not parse workflow: %v", err)
+	}
+	task := &Task{Status: "in-progress"}
+	if err := w.verifyTask(task); !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error for task without assignee, got %v", err)
+	}
+	task.Assignee = "artist"
+	if err := w.verifyTask(task); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	task.Status = StatusHold
+	if err := w.verifyTask(task); !errors.As(err, &BadRequestError{}) {
+		t.Fatalf("want bad request error for status not in workflow, got %v", err)
+	}
+}